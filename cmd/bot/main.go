@@ -2,20 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 
 	"github.com/d3nd3/dota-report-timestamps/pkg/dota2gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
 )
 
-var (
-	client *dota2gc.Client
-	mu     sync.Mutex
-)
+// service owns the GC client; the handlers expose it over HTTP for
+// botclient.
+var service = dota2gc.NewService()
 
 type InitRequest struct {
 	Username string `json:"username"`
@@ -24,11 +25,20 @@ type InitRequest struct {
 
 type SubmitCodeRequest struct {
 	Code string `json:"code"`
+	// Username picks a pool account; empty means the primary login.
+	Username string `json:"username,omitempty"`
+}
+
+type DisconnectRequest struct {
+	// ForgetSession also deletes the saved session of Username.
+	ForgetSession bool   `json:"forgetSession"`
+	Username      string `json:"username"`
 }
 
 type StatusResponse struct {
-	Status       int    `json:"status"`
-	ErrorMessage string `json:"errorMessage,omitempty"`
+	Status       int                     `json:"status"`
+	ErrorMessage string                  `json:"errorMessage,omitempty"`
+	Accounts     []dota2gc.AccountStatus `json:"accounts,omitempty"`
 }
 
 type ReplayInfoResponse struct {
@@ -43,11 +53,42 @@ func main() {
 		port = "8082"
 	}
 
+	// STEAM_SHARED_SECRET is the mobile authenticator shared_secret of the
+	// account logged in through /init; with it Steam Guard codes are
+	// generated instead of asked for.
+	if v := os.Getenv("STEAM_SHARED_SECRET"); v != "" {
+		if err := service.SetSharedSecret(v); err != nil {
+			log.Printf("Ignoring STEAM_SHARED_SECRET: %v", err)
+		}
+	}
+
+	// BOT_ACCOUNTS is a comma separated list of user:pass[:shared_secret]
+	// logins that share the GC request load with the account logged in
+	// through /init.
+	if v := os.Getenv("BOT_ACCOUNTS"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			parts := strings.SplitN(entry, ":", 3)
+			if len(parts) < 2 || parts[0] == "" {
+				log.Printf("Ignoring invalid BOT_ACCOUNTS entry (want user:pass[:shared_secret])")
+				continue
+			}
+			user, pass, secret := parts[0], parts[1], ""
+			if len(parts) == 3 {
+				secret = parts[2]
+			}
+			if err := service.AddAccount(user, pass, secret); err != nil {
+				log.Printf("Failed to add pool account %s: %v", user, err)
+			}
+		}
+	}
+
 	http.HandleFunc("/init", handleInit)
 	http.HandleFunc("/submit-code", handleSubmitCode)
 	http.HandleFunc("/disconnect", handleDisconnect)
 	http.HandleFunc("/status", handleStatus)
+	http.HandleFunc("/events", handleEvents)
 	http.HandleFunc("/replay-info", handleReplayInfo)
+	http.HandleFunc("/match-details", handleMatchDetails)
 	http.HandleFunc("/player-match-history", handlePlayerMatchHistory)
 	http.HandleFunc("/fatal-search", handleFatalSearch)
 	http.HandleFunc("/conduct-scorecard", handleConductScorecard)
@@ -68,27 +109,7 @@ func handleInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-
-	// Close existing client if any, and wait for cleanup
-	if client != nil {
-		log.Printf("Closing existing client before reinitializing...")
-		client.Close()
-		client = nil
-		// Give a moment for cleanup to complete
-		time.Sleep(500 * time.Millisecond)
-	}
-
-	log.Printf("Initializing bot for user %s", req.Username)
-	client = dota2gc.NewClient(req.Username, req.Password)
-
-	mu.Unlock() // Release lock before potentially blocking Connect()
-
-	if err := client.Connect(); err != nil {
-		log.Printf("Failed to connect: %v", err)
-		mu.Lock()
-		client = nil
-		mu.Unlock()
+	if err := service.Init(req.Username, req.Password); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -108,16 +129,16 @@ func handleSubmitCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	if client == nil {
-		http.Error(w, "Client not initialized", http.StatusBadRequest)
-		return
-	}
-
 	log.Printf("Submitting code: %s", req.Code)
-	if err := client.SubmitCode(req.Code); err != nil {
+	submit := service.SubmitCode
+	if req.Username != "" {
+		submit = func(code string) error { return service.SubmitCodeFor(req.Username, code) }
+	}
+	if err := submit(req.Code); err != nil {
+		if errors.Is(err, dota2gc.ErrNotInitialized) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		log.Printf("Failed to submit code: %v", err)
 		http.Error(w, "Failed to submit code: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -131,10 +152,29 @@ func handleDisconnect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	var req DisconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ForgetSession {
+		if req.Username == "" {
+			http.Error(w, "username required to forget a session", http.StatusBadRequest)
+			return
+		}
+		if err := service.ForgetSession(req.Username); err != nil {
+			log.Printf("Failed to forget session: %v", err)
+			http.Error(w, "Failed to forget session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Disconnected and session forgotten",
+		})
+		return
+	}
 
-	if client == nil {
+	if !service.LoggedIn() {
 		// Already disconnected
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -145,8 +185,7 @@ func handleDisconnect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Disconnecting Steam client...")
-	client.Close()
-	client = nil
+	service.Disconnect()
 	log.Printf("Steam client disconnected successfully")
 
 	w.WriteHeader(http.StatusOK)
@@ -157,22 +196,20 @@ func handleDisconnect(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleStatus(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	status := 0 // Disconnected
-	var errorMessage string
-	if client != nil {
-		status = int(client.GetStatus())
-		errorMessage = client.GetLastErrorMessage()
-	}
+	status, errorMessage := service.GetStatusWithError()
 
 	json.NewEncoder(w).Encode(StatusResponse{
-		Status:       status,
+		Status:       int(status),
 		ErrorMessage: errorMessage,
+		Accounts:     service.Accounts(),
 	})
 }
 
+// handleEvents streams status changes, so clients need not poll /status.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	gc.ServeEvents(w, r, service.Subscribe)
+}
+
 func handleReplayInfo(w http.ResponseWriter, r *http.Request) {
 	matchIDStr := r.URL.Query().Get("match_id")
 	matchID, err := strconv.ParseUint(matchIDStr, 10, 64)
@@ -181,28 +218,35 @@ func handleReplayInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	c := client
-	mu.Unlock() // Don't hold lock during network call
-
-	if c == nil {
-		json.NewEncoder(w).Encode(ReplayInfoResponse{Error: "Client not initialized"})
-		return
-	}
-
-	// Wait for readiness if needed? The client.GetReplayInfo checks status.
-	cluster, salt, err := c.GetReplayInfo(matchID)
+	cluster, salt, err := requestService(r).GetReplayInfo(r.Context(), matchID)
 	resp := ReplayInfoResponse{
 		Cluster: cluster,
 		Salt:    salt,
 	}
 	if err != nil {
 		resp.Error = err.Error()
+		gc.SetErrorHeader(w, err)
 	}
 
 	json.NewEncoder(w).Encode(resp)
 }
 
+func handleMatchDetails(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.ParseUint(r.URL.Query().Get("match_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid match_id", http.StatusBadRequest)
+		return
+	}
+
+	details, err := requestService(r).GetMatchDetails(r.Context(), matchID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(details)
+}
+
 type PlayerMatchHistoryRequest struct {
 	SteamID64      int64  `json:"steamId64"`
 	Limit          int    `json:"limit"`
@@ -222,24 +266,9 @@ func handlePlayerMatchHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	c := client
-	mu.Unlock()
-
-	if c == nil {
-		http.Error(w, "Client not initialized", http.StatusBadRequest)
-		return
-	}
-
-	var matches []dota2gc.Match
-	var err error
-	if req.StartAtMatchID > 0 {
-		matches, err = c.GetPlayerMatchHistoryPaginated(req.SteamID64, req.Limit, req.TurboOnly, req.StartAtMatchID)
-	} else {
-		matches, err = c.GetPlayerMatchHistory(req.SteamID64, req.Limit, req.TurboOnly)
-	}
+	matches, err := requestService(r).GetPlayerMatchHistoryPaginated(r.Context(), req.SteamID64, req.Limit, req.TurboOnly, req.StartAtMatchID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -264,18 +293,9 @@ func handleFatalSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	c := client
-	mu.Unlock()
-
-	if c == nil {
-		http.Error(w, "Client not initialized", http.StatusBadRequest)
-		return
-	}
-
-	matches, err := c.FindFatalGames(req.SteamID64, req.MaxDepth, req.GamesPerFatal)
+	matches, err := requestService(r).FindFatalGames(r.Context(), req.SteamID64, req.MaxDepth, req.GamesPerFatal)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -288,20 +308,34 @@ func handleConductScorecard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mu.Lock()
-	c := client
-	mu.Unlock()
-
-	if c == nil {
-		http.Error(w, "Client not initialized", http.StatusBadRequest)
-		return
-	}
-
-	scorecard, err := c.GetPlayerConductScorecard()
+	scorecard, err := requestService(r).GetPlayerConductScorecard(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(scorecard)
 }
+
+// requestService returns the service at the priority the caller asked for.
+func requestService(r *http.Request) gc.Service {
+	return service.WithPriority(gc.PriorityFromRequest(r))
+}
+
+// writeServiceError answers 400 before Init and tags GC failures with their
+// error code, so botclient can rebuild the typed error.
+func writeServiceError(w http.ResponseWriter, err error) {
+	gc.SetErrorHeader(w, err)
+	switch {
+	case errors.Is(err, dota2gc.ErrNotInitialized):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, gc.ErrRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, gc.ErrNotReady):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, gc.ErrTimeout):
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,453 @@
+// Command fakegc imitates the GC bot (cmd/bot) from a fixture file, so fatal
+// search, report card validation and replay downloads can run without a
+// Steam account.
+//
+// It listens on the bot's port by default, so the server needs no changes:
+//
+//	go run ./cmd/fakegc &
+//	STEAM_USER=fake STEAM_PASS=fake ./server
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// Fixture is the content of gc.json.
+type Fixture struct {
+	Login     Login                `json:"login"`
+	Players   []Player             `json:"players"`
+	Replays   []Replay             `json:"replays"`
+	Details   []gc.MatchDetails    `json:"details"`
+	Scorecard *gc.ConductScorecard `json:"scorecard"`
+	// Failures make an endpoint ("replay-info", "match-details",
+	// "player-match-history", "fatal-search", "conduct-scorecard") fail.
+	Failures map[string]*Failure `json:"failures"`
+}
+
+// Login scripts the connection.
+type Login struct {
+	// StartReady starts the service logged in, without /init.
+	StartReady bool `json:"startReady"`
+	// ConnectDelaySeconds is how long /init and /submit-code stay in
+	// StatusConnecting.
+	ConnectDelaySeconds int `json:"connectDelaySeconds"`
+	// SteamGuardCode, when set, makes /init ask for this code.
+	SteamGuardCode string `json:"steamGuardCode"`
+	// RateLimited makes every login end in StatusRateLimited.
+	RateLimited bool `json:"rateLimited"`
+}
+
+// Player is the scripted match history of one account.
+type Player struct {
+	SteamID64 int64      `json:"steamId64"`
+	Matches   []gc.Match `json:"matches"`
+}
+
+// Replay is the answer to /replay-info for one match.
+type Replay struct {
+	MatchID int64  `json:"matchId"`
+	Cluster uint32 `json:"cluster"`
+	Salt    uint64 `json:"salt"`
+	Error   string `json:"error"`
+}
+
+// Failure makes an endpoint answer with Error after DelaySeconds. Times
+// limits it to the first calls (0 = every call). Code is the typed error
+// reported to botclient ("timeout", "not_ready", "rate_limited"); a failure
+// with neither Error nor Code is a timeout.
+type Failure struct {
+	Error        string `json:"error"`
+	Code         string `json:"code"`
+	DelaySeconds int    `json:"delaySeconds"`
+	Times        int    `json:"times"`
+
+	calls int
+}
+
+// fakeGC is the scripted connection state.
+type fakeGC struct {
+	mu           sync.Mutex
+	fx           Fixture
+	status       gc.ConnectionStatus
+	errorMessage string
+	events       gc.Broadcaster
+}
+
+var fake = &fakeGC{}
+
+// errNotInitialized matches the bot's answer before /init.
+var errNotInitialized = fmt.Errorf("%w: client not initialized", gc.ErrNotReady)
+
+func main() {
+	port := os.Getenv("FAKE_GC_PORT")
+	if port == "" {
+		port = "8082"
+	}
+	dir := os.Getenv("FAKE_GC_FIXTURES")
+	if dir == "" {
+		dir = "./cmd/fakegc/fixtures"
+	}
+	if err := fake.load(filepath.Join(dir, "gc.json")); err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	log.Printf("Fake GC serving %d player histories from %s on port %s", len(fake.fx.Players), dir, port)
+	log.Fatal(http.ListenAndServe(":"+port, newMux()))
+}
+
+// newMux serves the bot's endpoints from fake.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/init", handleInit)
+	mux.HandleFunc("/submit-code", handleSubmitCode)
+	mux.HandleFunc("/disconnect", handleDisconnect)
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/events", handleEvents)
+	mux.HandleFunc("/replay-info", handleReplayInfo)
+	mux.HandleFunc("/match-details", handleMatchDetails)
+	mux.HandleFunc("/player-match-history", handlePlayerMatchHistory)
+	mux.HandleFunc("/fatal-search", handleFatalSearch)
+	mux.HandleFunc("/conduct-scorecard", handleConductScorecard)
+	return mux
+}
+
+func (f *fakeGC) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &f.fx); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, p := range f.fx.Players {
+		// The GC lists the newest match first.
+		sort.Slice(p.Matches, func(a, b int) bool { return p.Matches[a].ID > p.Matches[b].ID })
+	}
+	if f.fx.Login.StartReady {
+		f.status = gc.StatusGCReady
+	}
+	f.events.Publish(gc.NewStatusEvent(f.status, ""))
+	return nil
+}
+
+func (f *fakeGC) setStatus(s gc.ConnectionStatus, errorMessage string) {
+	f.mu.Lock()
+	f.status = s
+	f.errorMessage = errorMessage
+	f.mu.Unlock()
+	f.events.Publish(gc.NewStatusEvent(s, errorMessage))
+}
+
+// connect moves through StatusConnecting to the scripted end state.
+func (f *fakeGC) connect(askForCode bool) {
+	f.setStatus(gc.StatusConnecting, "")
+	time.Sleep(time.Duration(f.fx.Login.ConnectDelaySeconds) * time.Second)
+	switch {
+	case f.fx.Login.RateLimited:
+		f.setStatus(gc.StatusRateLimited, "Rate limited by Steam (RateLimitExceeded). Wait before trying again.")
+	case askForCode && f.fx.Login.SteamGuardCode != "":
+		f.setStatus(gc.StatusNeedGuardCode, "")
+	default:
+		f.setStatus(gc.StatusGCReady, "")
+	}
+}
+
+// ready returns an error unless requests can be made, like the real bot.
+func (f *fakeGC) ready() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status == gc.StatusDisconnected {
+		return errNotInitialized
+	}
+	if f.status == gc.StatusRateLimited {
+		return gc.ErrRateLimited
+	}
+	if f.status != gc.StatusGCReady && f.status != gc.StatusConnected {
+		return fmt.Errorf("%w (status %d)", gc.ErrNotReady, f.status)
+	}
+	return nil
+}
+
+// codeErrors are the typed errors a failure without a message answers with.
+var codeErrors = map[string]error{
+	"timeout":      gc.ErrTimeout,
+	"not_ready":    gc.ErrNotReady,
+	"rate_limited": gc.ErrRateLimited,
+}
+
+// fail applies the scripted failure of an endpoint, if any.
+func (f *fakeGC) fail(endpoint string) error {
+	f.mu.Lock()
+	fl, ok := f.fx.Failures[endpoint]
+	if !ok || (fl.Times > 0 && fl.calls >= fl.Times) {
+		f.mu.Unlock()
+		return nil
+	}
+	fl.calls++
+	delay := time.Duration(fl.DelaySeconds) * time.Second
+	msg, code := fl.Error, fl.Code
+	f.mu.Unlock()
+
+	time.Sleep(delay)
+	if msg == "" && code == "" {
+		msg, code = "context deadline exceeded", "timeout"
+	}
+	if err, ok := codeErrors[code]; ok && msg == "" {
+		return err
+	}
+	if msg == "" {
+		msg = code
+	}
+	return gc.ErrorFromCode(code, msg)
+}
+
+// check returns the error a request to endpoint answers with. A request
+// made before the service is ready fails without using up the endpoint's
+// scripted failure.
+func (f *fakeGC) check(endpoint string) error {
+	if err := f.ready(); err != nil {
+		return err
+	}
+	return f.fail(endpoint)
+}
+
+// history returns a page of a player's matches older than startAtMatchID.
+func (f *fakeGC) history(steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) []gc.Match {
+	if limit <= 0 || limit > 20 {
+		limit = 20
+	}
+	matches := []gc.Match{}
+	for _, p := range f.fx.Players {
+		if p.SteamID64 != steamID64 {
+			continue
+		}
+		for _, m := range p.Matches {
+			if startAtMatchID > 0 && uint64(m.ID) >= startAtMatchID {
+				continue
+			}
+			if turboOnly && m.GameMode != gc.GameModeTurbo {
+				continue
+			}
+			matches = append(matches, m)
+			if len(matches) == limit {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func handleInit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	log.Printf("Fake login")
+	go fake.connect(true)
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleSubmitCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code != fake.fx.Login.SteamGuardCode {
+		fake.setStatus(gc.StatusNeedGuardCode, "Invalid Steam Guard code. Please try again.")
+		http.Error(w, "Failed to submit code: invalid code", http.StatusInternalServerError)
+		return
+	}
+	go fake.connect(false)
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// forgetSession needs no handling: the fake keeps no sessions.
+	fake.setStatus(gc.StatusDisconnected, "")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Disconnected successfully",
+	})
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       int(fake.status),
+		"errorMessage": fake.errorMessage,
+	})
+}
+
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	gc.ServeEvents(w, r, fake.events.Subscribe)
+}
+
+func handleReplayInfo(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("match_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid match_id", http.StatusBadRequest)
+		return
+	}
+	resp := Replay{MatchID: matchID}
+	if err := fake.check("replay-info"); err != nil {
+		resp.Error = err.Error()
+		gc.SetErrorHeader(w, err)
+	} else {
+		resp.Error = fmt.Sprintf("GC returned error result: %d", 2)
+		for _, rp := range fake.fx.Replays {
+			if rp.MatchID == matchID {
+				resp = rp
+				break
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cluster": resp.Cluster,
+		"salt":    resp.Salt,
+		"error":   resp.Error,
+	})
+}
+
+func handleMatchDetails(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("match_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid match_id", http.StatusBadRequest)
+		return
+	}
+	if err := fake.check("match-details"); err != nil {
+		writeError(w, fmt.Errorf("failed to request match details: %w", err))
+		return
+	}
+	for _, d := range fake.fx.Details {
+		if d.MatchID == matchID {
+			json.NewEncoder(w).Encode(d)
+			return
+		}
+	}
+	writeError(w, fmt.Errorf("GC returned error result: %d", 2))
+}
+
+func handlePlayerMatchHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		SteamID64      int64  `json:"steamId64"`
+		Limit          int    `json:"limit"`
+		TurboOnly      bool   `json:"turboOnly"`
+		StartAtMatchID uint64 `json:"startAtMatchId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := fake.check("player-match-history"); err != nil {
+		writeError(w, fmt.Errorf("failed to get player match history: %w", err))
+		return
+	}
+	json.NewEncoder(w).Encode(fake.history(req.SteamID64, req.Limit, req.TurboOnly, req.StartAtMatchID))
+}
+
+func handleFatalSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		SteamID64     int64 `json:"steamId64"`
+		MaxDepth      int   `json:"maxDepth"`
+		GamesPerFatal int   `json:"gamesPerFatal"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MaxDepth < 1 {
+		writeError(w, fmt.Errorf("maxDepth must be at least 1"))
+		return
+	}
+	if err := fake.check("fatal-search"); err != nil {
+		writeError(w, fmt.Errorf("failed to get match history: %w", err))
+		return
+	}
+
+	// Page through the history the way dota2gc does.
+	var all []gc.Match
+	var startAt uint64
+	for {
+		page := fake.history(req.SteamID64, 20, false, startAt)
+		all = append(all, page...)
+		if len(page) < 20 {
+			break
+		}
+		startAt = uint64(page[len(page)-1].ID)
+	}
+	matches, err := gc.FindFatalMatches(all, req.MaxDepth, req.GamesPerFatal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(matches)
+}
+
+func handleConductScorecard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := fake.check("conduct-scorecard"); err != nil {
+		writeError(w, fmt.Errorf("failed to request conduct scorecard: %w", err))
+		return
+	}
+	if fake.fx.Scorecard == nil {
+		writeError(w, fmt.Errorf("failed to request conduct scorecard: no scorecard"))
+		return
+	}
+	json.NewEncoder(w).Encode(fake.fx.Scorecard)
+}
+
+// writeError answers like the bot: 400 before /init, otherwise a JSON
+// error tagged with its error code.
+func writeError(w http.ResponseWriter, err error) {
+	gc.SetErrorHeader(w, err)
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errNotInitialized):
+		http.Error(w, errNotInitialized.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, gc.ErrRateLimited):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, gc.ErrNotReady):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, gc.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
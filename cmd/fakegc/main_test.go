@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/botclient"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+const fixturePlayer = 76561198000000001
+
+// startFake serves the checked-in fixtures and returns a bot client for
+// them.
+func startFake(t *testing.T) *botclient.Client {
+	t.Helper()
+	fake = &fakeGC{}
+	if err := fake.load("fixtures/gc.json"); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newMux())
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return botclient.NewClient(u.Port())
+}
+
+func TestNotReadyKeepsScriptedFailure(t *testing.T) {
+	client := startFake(t)
+	fake.fx.Failures = map[string]*Failure{"replay-info": {Code: "timeout", Times: 1}}
+
+	if _, _, err := client.GetReplayInfo(context.Background(), 8000000110); !errors.Is(err, gc.ErrNotReady) {
+		t.Fatalf("before login: %v, want ErrNotReady", err)
+	}
+	fake.setStatus(gc.StatusGCReady, "")
+	if _, _, err := client.GetReplayInfo(context.Background(), 8000000110); !errors.Is(err, gc.ErrTimeout) {
+		t.Fatalf("first call after login: %v, want the scripted ErrTimeout", err)
+	}
+	cluster, salt, err := client.GetReplayInfo(context.Background(), 8000000110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cluster != 236 || salt != 1234567890 {
+		t.Fatalf("got cluster %d salt %d, want 236 1234567890", cluster, salt)
+	}
+}
+
+func TestFatalSearch(t *testing.T) {
+	client := startFake(t)
+	fake.setStatus(gc.StatusGCReady, "")
+
+	got, err := client.FindFatalGames(context.Background(), fixturePlayer, 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gc.FatalMatchInfo{
+		{FatalMatchID: 8000000107, SingleDraftMatchID: 8000000108, SingleDraftDate: 1760080000, AdditionalMatchIDs: []int64{8000000109, 8000000110}},
+		{FatalMatchID: 8000000104, SingleDraftMatchID: 8000000105, SingleDraftDate: 1760000000, AdditionalMatchIDs: []int64{8000000107, 8000000109}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestHistoryPages(t *testing.T) {
+	client := startFake(t)
+	fake.setStatus(gc.StatusGCReady, "")
+
+	page, err := client.GetPlayerMatchHistoryPaginated(context.Background(), fixturePlayer, 3, false, 8000000108)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, m := range page {
+		ids = append(ids, m.ID)
+	}
+	if want := []int64{8000000107, 8000000106, 8000000105}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("page older than 8000000108 = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,327 @@
+// Command fakeproviders imitates OpenDota, the Steam WebAPI, Stratz and the
+// replay CDN from fixture files, so the download pipeline can run offline.
+//
+// Point the server at it with:
+//
+//	OPENDOTA_BASE_URL=http://localhost:8090/opendota/api
+//	STEAM_API_BASE_URL=http://localhost:8090/steam
+//	STRATZ_GRAPHQL_URL=http://localhost:8090/stratz/graphql
+//	HERO_ICON_BASE_URL=http://localhost:8090/heroes
+//	REPLAY_CDN_HOSTS=http://localhost:8090/cdn/replay{cluster}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FixtureMatch describes one match known to the fake providers.
+type FixtureMatch struct {
+	MatchID   int64  `json:"matchId"`
+	Cluster   uint32 `json:"cluster"`
+	Salt      uint64 `json:"salt"`
+	StartTime int64  `json:"startTime"`
+	LobbyType int    `json:"lobbyType"`
+	GameMode  int    `json:"gameMode"`
+	// Replay is the .dem.bz2 file served by the CDN, relative to the
+	// fixture directory. A missing file is served as 404 (expired).
+	Replay string `json:"replay"`
+	// Parsed is whether OpenDota has parsed the match. When false, the
+	// first parse request marks it parsed after ParseDelaySeconds.
+	Parsed            bool `json:"parsed"`
+	ParseDelaySeconds int  `json:"parseDelaySeconds"`
+
+	// Failure modes: a non-zero status is returned instead of the normal
+	// answer by that provider.
+	OpenDotaStatus int `json:"openDotaStatus"`
+	SteamStatus    int `json:"steamStatus"`
+	StratzStatus   int `json:"stratzStatus"`
+	CDNStatus      int `json:"cdnStatus"`
+	// StratzMissing returns the match without cluster/salt.
+	StratzMissing bool `json:"stratzMissing"`
+}
+
+type fixtures struct {
+	mu       sync.Mutex
+	dir      string
+	matches  map[int64]*FixtureMatch
+	parsedAt map[int64]time.Time
+}
+
+var fx = &fixtures{
+	matches:  make(map[int64]*FixtureMatch),
+	parsedAt: make(map[int64]time.Time),
+}
+
+func main() {
+	port := os.Getenv("FAKE_PROVIDERS_PORT")
+	if port == "" {
+		port = "8090"
+	}
+	dir := os.Getenv("FAKE_PROVIDERS_FIXTURES")
+	if dir == "" {
+		dir = "./cmd/fakeproviders/fixtures"
+	}
+	if err := fx.load(dir); err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	log.Printf("Fake providers serving %d fixture matches from %s on port %s", len(fx.matches), dir, port)
+	log.Fatal(http.ListenAndServe(":"+port, newMux(dir)))
+}
+
+// newMux routes the fake provider APIs. dir is the fixture directory.
+func newMux(dir string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/opendota/api/matches/", handleOpenDotaMatch)
+	mux.HandleFunc("/opendota/api/request/", handleOpenDotaRequest)
+	mux.HandleFunc("/steam/IDOTA2Match_570/GetMatchDetails/v1/", handleSteamMatchDetails)
+	mux.HandleFunc("/steam/IDOTA2Match_570/GetMatchHistory/v1/", handleSteamMatchHistory)
+	mux.HandleFunc("/stratz/graphql", handleStratz)
+	mux.HandleFunc("/cdn/", handleCDN)
+	mux.Handle("/heroes/", http.StripPrefix("/heroes/", http.FileServer(http.Dir(filepath.Join(dir, "heroes")))))
+	return mux
+}
+
+// load reads matches.json from dir.
+func (f *fixtures) load(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "matches.json"))
+	if err != nil {
+		return err
+	}
+	var list []*FixtureMatch
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse matches.json: %w", err)
+	}
+	f.dir = dir
+	for _, m := range list {
+		f.matches[m.MatchID] = m
+	}
+	return nil
+}
+
+// get returns a copy of the fixture for matchID, with Parsed updated once a
+// requested parse has finished.
+func (f *fixtures) get(matchID int64) (FixtureMatch, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.matches[matchID]
+	if !ok {
+		return FixtureMatch{}, false
+	}
+	if at, requested := f.parsedAt[matchID]; requested && !m.Parsed && time.Now().After(at) {
+		m.Parsed = true
+	}
+	return *m, true
+}
+
+// requestParse schedules the match to become parsed.
+func (f *fixtures) requestParse(matchID int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.matches[matchID]
+	if !ok {
+		return false
+	}
+	if _, requested := f.parsedAt[matchID]; !requested {
+		f.parsedAt[matchID] = time.Now().Add(time.Duration(m.ParseDelaySeconds) * time.Second)
+	}
+	return true
+}
+
+// matchIDFromPath returns the number after prefix in the request path.
+func matchIDFromPath(r *http.Request, prefix string) (int64, error) {
+	return strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), 10, 64)
+}
+
+// replayURL is the URL OpenDota reports for a match, on the fake CDN.
+func replayURL(r *http.Request, m FixtureMatch) string {
+	return fmt.Sprintf("http://%s/cdn/replay%d/570/%d_%d.dem.bz2", r.Host, m.Cluster, m.MatchID, m.Salt)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func handleOpenDotaMatch(w http.ResponseWriter, r *http.Request) {
+	matchID, err := matchIDFromPath(r, "/opendota/api/matches/")
+	if err != nil {
+		http.Error(w, `{"error":"invalid match id"}`, http.StatusBadRequest)
+		return
+	}
+	m, ok := fx.get(matchID)
+	if !ok {
+		http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	if m.OpenDotaStatus != 0 {
+		http.Error(w, "fake OpenDota failure", m.OpenDotaStatus)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"match_id":   m.MatchID,
+		"start_time": m.StartTime,
+		"lobby_type": m.LobbyType,
+		"game_mode":  m.GameMode,
+		"cluster":    m.Cluster,
+		"od_data":    map[string]interface{}{"has_parsed": m.Parsed},
+	}
+	if m.Parsed {
+		resp["replay_salt"] = m.Salt
+		resp["replay_url"] = replayURL(r, m)
+	}
+	writeJSON(w, resp)
+}
+
+// handleOpenDotaRequest answers POST /request/<matchID> (request a parse)
+// and GET /request/<jobID> (parse job status). Job IDs equal match IDs.
+func handleOpenDotaRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := matchIDFromPath(r, "/opendota/api/request/")
+	if err != nil {
+		http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	m, ok := fx.get(id)
+	if !ok {
+		http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	if m.OpenDotaStatus != 0 {
+		http.Error(w, "fake OpenDota failure", m.OpenDotaStatus)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		fx.requestParse(id)
+		log.Printf("OpenDota: parse requested for match %d", id)
+		writeJSON(w, map[string]interface{}{"job": map[string]interface{}{"jobId": id}})
+		return
+	}
+	if m.Parsed {
+		writeJSON(w, nil)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"jobId": id, "type": "parse"})
+}
+
+func handleSteamMatchDetails(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("key") == "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("match_id"), 10, 64)
+	if err != nil {
+		writeJSON(w, map[string]interface{}{"result": map[string]interface{}{"error": "Practice matches are not available via GetMatchDetails"}})
+		return
+	}
+	m, ok := fx.get(matchID)
+	if !ok {
+		writeJSON(w, map[string]interface{}{"result": map[string]interface{}{"error": "Match ID not found"}})
+		return
+	}
+	if m.SteamStatus != 0 {
+		http.Error(w, "fake Steam WebAPI failure", m.SteamStatus)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"result": map[string]interface{}{
+		"match_id":    m.MatchID,
+		"cluster":     m.Cluster,
+		"replay_salt": m.Salt,
+		"start_time":  m.StartTime,
+		"lobby_type":  m.LobbyType,
+		"game_mode":   m.GameMode,
+	}})
+}
+
+// handleSteamMatchHistory returns every fixture match, newest first.
+func handleSteamMatchHistory(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("key") == "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	fx.mu.Lock()
+	var matches []map[string]interface{}
+	for _, m := range fx.matches {
+		matches = append(matches, map[string]interface{}{
+			"match_id":   m.MatchID,
+			"start_time": m.StartTime,
+			"lobby_type": m.LobbyType,
+		})
+	}
+	fx.mu.Unlock()
+	writeJSON(w, map[string]interface{}{"result": map[string]interface{}{
+		"status":      1,
+		"num_results": len(matches),
+		"matches":     matches,
+	}})
+}
+
+// handleStratz answers the GetReplayInfo GraphQL query.
+func handleStratz(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Variables struct {
+			MatchID int64 `json:"matchId"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m, ok := fx.get(req.Variables.MatchID)
+	if !ok {
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"match": nil}})
+		return
+	}
+	if m.StratzStatus != 0 {
+		http.Error(w, "fake Stratz failure", m.StratzStatus)
+		return
+	}
+	match := map[string]interface{}{"clusterId": m.Cluster, "replaySalt": m.Salt}
+	if m.StratzMissing {
+		match = map[string]interface{}{"clusterId": nil, "replaySalt": nil}
+	}
+	writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"match": match}})
+}
+
+// handleCDN serves /cdn/replay<cluster>/570/<matchID>_<salt>.dem.bz2 with
+// Range support.
+func handleCDN(w http.ResponseWriter, r *http.Request) {
+	var cluster uint32
+	var matchID int64
+	var salt uint64
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/cdn/"), "replay%d/570/%d_%d.dem.bz2", &cluster, &matchID, &salt); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	m, ok := fx.get(matchID)
+	if !ok || m.Cluster != cluster || m.Salt != salt {
+		http.NotFound(w, r)
+		return
+	}
+	if m.CDNStatus != 0 {
+		http.Error(w, "fake CDN failure", m.CDNStatus)
+		return
+	}
+	f, err := os.Open(filepath.Join(fx.dir, m.Replay))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	log.Printf("CDN: serving replay of match %d (Range: %q)", matchID, r.Header.Get("Range"))
+	http.ServeContent(w, r, filepath.Base(m.Replay), time.Unix(m.StartTime, 0), f)
+}
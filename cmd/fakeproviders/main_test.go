@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/downloader"
+	"github.com/d3nd3/dota-report-timestamps/pkg/opendota"
+)
+
+// startFake serves the checked-in fixtures and points the downloader at
+// them, resolving through OpenDota only.
+func startFake(t *testing.T) *httptest.Server {
+	t.Helper()
+	fx = &fixtures{matches: make(map[int64]*FixtureMatch), parsedAt: make(map[int64]time.Time)}
+	if err := fx.load("fixtures"); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newMux("fixtures"))
+	t.Cleanup(srv.Close)
+
+	downloader.SetEndpoints(downloader.Endpoints{
+		OpenDota:    opendota.NewClient(srv.URL+"/opendota/api", "", nil),
+		ReplayHosts: []string{srv.URL + "/cdn/replay{cluster}"},
+	})
+	t.Cleanup(func() { downloader.SetEndpoints(downloader.Endpoints{}) })
+	if err := downloader.SetProviderOrder([]string{"opendota"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { downloader.SetProviderOrder(nil) })
+	return srv
+}
+
+func TestFixtureReplaysExist(t *testing.T) {
+	startFake(t)
+	for id, m := range fx.matches {
+		_, err := os.Stat(filepath.Join("fixtures", m.Replay))
+		if m.CDNStatus == 0 && err != nil {
+			t.Errorf("match %d: %v", id, err)
+		}
+	}
+}
+
+func TestCDNServesRanges(t *testing.T) {
+	srv := startFake(t)
+	m := fx.matches[8000000001]
+	url := fmt.Sprintf("%s/cdn/replay%d/570/%d_%d.dem.bz2", srv.URL, m.Cluster, m.MatchID, m.Salt)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=100-")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	whole, _ := os.ReadFile(filepath.Join("fixtures", m.Replay))
+	if resp.StatusCode != http.StatusPartialContent || string(body) != string(whole[100:]) {
+		t.Fatalf("Range request: status %d, %d bytes; want 206 and %d bytes", resp.StatusCode, len(body), len(whole)-100)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/cdn/replay%d/570/%d_1.dem.bz2", srv.URL, m.Cluster, m.MatchID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("wrong salt: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestDownloadFromFakeProviders(t *testing.T) {
+	startFake(t)
+	dir := t.TempDir()
+
+	if err := downloader.DownloadReplay(8000000001, dir, "", "", nil); err != nil {
+		t.Fatalf("parsed match: %v", err)
+	}
+	dem, err := os.ReadFile(filepath.Join(dir, "8000000001.dem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dem[:8]) != "PBDEMS2\x00" {
+		t.Fatalf("downloaded replay starts with %q, want the demo header", dem[:8])
+	}
+
+	// Not parsed yet: OpenDota is asked to parse it.
+	if err := downloader.DownloadReplay(8000000002, dir, "", "", nil); !downloader.IsQueued(err) {
+		t.Errorf("unparsed match: %v, want it queued", err)
+	}
+	// Gone from the CDN.
+	if err := downloader.DownloadReplay(8000000003, dir, "", "", nil); !errors.Is(err, downloader.ErrExpired) {
+		t.Errorf("expired match: %v, want ErrExpired", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Command import adds replays from a directory to a profile, the same way
+// the server's /api/import does.
+//
+//	go run ./cmd/import -from /mnt/old/replays -profile main -fatal
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/importer"
+)
+
+func main() {
+	homeDir, _ := os.UserHomeDir()
+	defaultReplayDir := filepath.Join(homeDir, ".steam/debian-installation/steamapps/common/dota 2 beta/game/dota/replays/")
+
+	replayDir := flag.String("replay-dir", defaultReplayDir, "the server's replay directory (profiles live under it)")
+	from := flag.String("from", "", "directory to import from (default: -replay-dir, the Dota client's replay folder)")
+	profile := flag.String("profile", "", "profile to import into (default: no profile)")
+	fatal := flag.Bool("fatal", false, "file replays under fatal/<date> like fatal game downloads")
+	gamesPerFatal := flag.Int("games", 2, "with -fatal, ranked games after each Single Draft to file with it (max 15)")
+	copyFiles := flag.Bool("copy", false, "copy files instead of hardlinking them")
+	recursive := flag.Bool("r", false, "also import from subdirectories")
+	verbose := flag.Bool("v", false, "list every file, not only imports and errors")
+	flag.Parse()
+
+	if *from == "" {
+		*from = *replayDir
+	}
+	mode := importer.ModeLink
+	if *copyFiles {
+		mode = importer.ModeCopy
+	}
+
+	summary, err := importer.Import(importer.Options{
+		SourceDir:     *from,
+		ProfileDir:    profileDir(*replayDir, *profile),
+		Fatal:         *fatal,
+		GamesPerFatal: *gamesPerFatal,
+		Mode:          mode,
+		Recursive:     *recursive,
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, r := range summary.Results {
+		switch r.Action {
+		case importer.ActionLinked, importer.ActionCopied:
+			fmt.Printf("%-9s %s -> %s\n", r.Action, r.Source, r.Dest)
+		case importer.ActionFailed, importer.ActionConflict:
+			fmt.Printf("%-9s %s: %s\n", r.Action, r.Source, r.Error)
+		default:
+			if *verbose {
+				fmt.Printf("%-9s %s (%s)\n", r.Action, r.Source, r.Dest)
+			}
+		}
+	}
+	fmt.Printf("Imported %d, %d duplicate, %d conflict, %d failed\n", summary.Imported, summary.Duplicates, summary.Conflicts, summary.Failed)
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// profileDir mirrors the server's getProfileReplayDir.
+func profileDir(replayDir, profile string) string {
+	profile = strings.TrimSpace(profile)
+	if profile == "" {
+		return replayDir
+	}
+	profile = strings.ReplaceAll(profile, "/", "_")
+	profile = strings.ReplaceAll(profile, "\\", "_")
+	profile = strings.ReplaceAll(profile, "..", "_")
+	return filepath.Join(replayDir, profile)
+}
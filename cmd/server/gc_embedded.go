@@ -0,0 +1,31 @@
+//go:build embeddedgc
+
+// Building with -tags embeddedgc links the GC client into the server so it
+// can run without the bot process (GC_MODE=embedded). go-dota2 and manta
+// register clashing protobuf files, so such a binary must be started with
+// GOLANG_PROTOBUF_REGISTRATION_CONFLICT=warn (run.sh sets it) or built with
+// -ldflags "-X google.golang.org/protobuf/reflect/protoregistry.conflictPolicy=warn".
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/dota2gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+func init() {
+	newEmbeddedGC = func() gc.Service {
+		s := dota2gc.NewService()
+		// Same as the bot: generate Steam Guard codes from the
+		// authenticator's shared_secret.
+		if v := os.Getenv("STEAM_SHARED_SECRET"); v != "" {
+			if err := s.SetSharedSecret(v); err != nil {
+				log.Printf("Ignoring STEAM_SHARED_SECRET: %v", err)
+			}
+		}
+		return s
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,8 +18,13 @@ import (
 	"sync"
 	"time"
 
-	"github.com/d3nd3/dota-report-timestamps/pkg/botclient"
+	"github.com/d3nd3/dota-report-timestamps/pkg/conduct"
 	"github.com/d3nd3/dota-report-timestamps/pkg/downloader"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/heroes"
+	"github.com/d3nd3/dota-report-timestamps/pkg/history"
+	"github.com/d3nd3/dota-report-timestamps/pkg/importer"
+	"github.com/d3nd3/dota-report-timestamps/pkg/opendota"
 	"github.com/d3nd3/dota-report-timestamps/pkg/parser"
 	"github.com/d3nd3/dota-report-timestamps/pkg/steamapi"
 	// "github.com/d3nd3/dota-report-timestamps/pkg/stratz" // DEPRECATED: Stratz API no longer used
@@ -55,13 +63,40 @@ func convertSteamID(input uint64, to64 bool) uint64 {
 
 func handleConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		json.NewEncoder(w).Encode(config)
+		configMu.RLock()
+		defer configMu.RUnlock()
+		json.NewEncoder(w).Encode(redactedConfig())
 	} else if r.Method == http.MethodPost {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// parserTraceMatchId is read apart from the other fields: 0, "" or
+		// null turn tracing off, which Config cannot tell from a missing
+		// field.
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		traceRaw, traceSet := fields["parserTraceMatchId"]
+		var traceMatchID int64
+		if traceSet {
+			if traceMatchID, err = parseTraceMatchID(traceRaw); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			delete(fields, "parserTraceMatchId")
+			body, _ = json.Marshal(fields)
+		}
 		var newConfig Config
-		if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
+		if err := json.Unmarshal(body, &newConfig); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		configMu.Lock()
+		defer configMu.Unlock()
 		if newConfig.ReplayDir != "" {
 			config.ReplayDir = newConfig.ReplayDir
 		}
@@ -81,10 +116,107 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 			config.SteamPass = strings.TrimSpace(newConfig.SteamPass)
 			log.Printf("Steam Password updated (length: %d)", len(config.SteamPass))
 		}
-		json.NewEncoder(w).Encode(config)
+		if newConfig.ParserLogLevel != "" {
+			config.ParserLogLevel = strings.TrimSpace(newConfig.ParserLogLevel)
+			setParserLogLevel(config.ParserLogLevel)
+			log.Printf("Parser log level updated: %s", parserLogger.GetLevel())
+		}
+		if traceSet {
+			config.ParserTraceMatchID = traceMatchID
+			if traceMatchID == 0 {
+				log.Printf("Parser trace turned off")
+			} else {
+				log.Printf("Parser trace match ID updated: %d", config.ParserTraceMatchID)
+			}
+		}
+		if newConfig.OpenDotaAPIKey != "" {
+			config.OpenDotaAPIKey = strings.TrimSpace(newConfig.OpenDotaAPIKey)
+			applyEndpointConfig()
+			log.Printf("OpenDota API key updated (length: %d)", len(config.OpenDotaAPIKey))
+		}
+		if newConfig.OpenDotaBaseURL != "" || newConfig.SteamAPIBaseURL != "" || newConfig.StratzGraphQLURL != "" || newConfig.ReplayCDNHosts != nil {
+			if newConfig.OpenDotaBaseURL != "" {
+				config.OpenDotaBaseURL = strings.TrimSpace(newConfig.OpenDotaBaseURL)
+			}
+			if newConfig.SteamAPIBaseURL != "" {
+				config.SteamAPIBaseURL = strings.TrimSpace(newConfig.SteamAPIBaseURL)
+			}
+			if newConfig.StratzGraphQLURL != "" {
+				config.StratzGraphQLURL = strings.TrimSpace(newConfig.StratzGraphQLURL)
+			}
+			if newConfig.ReplayCDNHosts != nil {
+				config.ReplayCDNHosts = newConfig.ReplayCDNHosts
+			}
+			applyEndpointConfig()
+			log.Printf("Provider endpoints updated")
+		}
+		if newConfig.MaxConcurrentDownloads != 0 || newConfig.DownloadBytesPerSec != 0 {
+			if newConfig.MaxConcurrentDownloads != 0 {
+				config.MaxConcurrentDownloads = newConfig.MaxConcurrentDownloads
+			}
+			if newConfig.DownloadBytesPerSec != 0 {
+				// A negative value removes the limit.
+				config.DownloadBytesPerSec = max(newConfig.DownloadBytesPerSec, 0)
+			}
+			downloader.SetDownloadLimits(config.MaxConcurrentDownloads, config.DownloadBytesPerSec)
+			log.Printf("Download limits updated: %d concurrent, %d bytes/s", config.MaxConcurrentDownloads, config.DownloadBytesPerSec)
+		}
+		if newConfig.WatchProfiles != nil {
+			config.WatchProfiles = newConfig.WatchProfiles
+			downloader.PollWatchedNow()
+			log.Printf("Watched profiles updated: %d profile(s)", len(config.WatchProfiles))
+		}
+		if newConfig.WatchIntervalMinutes != 0 {
+			config.WatchIntervalMinutes = newConfig.WatchIntervalMinutes
+			downloader.SetWatchInterval(time.Duration(config.WatchIntervalMinutes) * time.Minute)
+			log.Printf("Watch interval updated: %d minutes", config.WatchIntervalMinutes)
+		}
+		if newConfig.HeroIconBaseURL != "" {
+			config.HeroIconBaseURL = strings.TrimSpace(newConfig.HeroIconBaseURL)
+			log.Printf("Hero icon base URL updated: %s", config.HeroIconBaseURL)
+		}
+		if newConfig.ProviderOrder != nil || newConfig.ProviderFailureThreshold != 0 || newConfig.ProviderCooldownMinutes != 0 {
+			if newConfig.ProviderOrder != nil {
+				config.ProviderOrder = newConfig.ProviderOrder
+			}
+			if newConfig.ProviderFailureThreshold != 0 {
+				config.ProviderFailureThreshold = newConfig.ProviderFailureThreshold
+			}
+			if newConfig.ProviderCooldownMinutes != 0 {
+				config.ProviderCooldownMinutes = newConfig.ProviderCooldownMinutes
+			}
+			applyProviderConfig()
+			log.Printf("Replay providers updated: %v", downloader.ProviderOrder())
+		}
+		json.NewEncoder(w).Encode(redactedConfig())
 	}
 }
 
+// parseTraceMatchID reads the parserTraceMatchId config field, a match ID
+// as a number or string. 0, "" and null mean no trace.
+func parseTraceMatchID(raw json.RawMessage) (int64, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0, fmt.Errorf("invalid parserTraceMatchId: %w", err)
+	}
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int64(v), nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return 0, nil
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid parserTraceMatchId %q", v)
+		}
+		return id, nil
+	}
+	return 0, fmt.Errorf("invalid parserTraceMatchId %s", raw)
+}
+
 type SteamLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -103,16 +235,19 @@ func handleSteamLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	configMu.Lock()
 	if req.Username != "" {
 		config.SteamUser = req.Username
 	}
 	if req.Password != "" {
 		config.SteamPass = req.Password
 	}
+	user, pass := config.SteamUser, config.SteamPass
+	configMu.Unlock()
 
-	// Always try to ensure bot is initialized if we have creds
-	if config.SteamUser == "" || config.SteamPass == "" {
-		http.Error(w, "Username and Password required", http.StatusBadRequest)
+	// The password may be left out once the bot has saved a session
+	if user == "" {
+		http.Error(w, "Username required", http.StatusBadRequest)
 		return
 	}
 
@@ -143,7 +278,7 @@ func handleSteamLogin(w http.ResponseWriter, r *http.Request) {
 		// Allow resetting StatusConnecting to handle stuck connections
 		// StatusNeedGuardCode is NOT included here - we allow re-init to start fresh connection
 		currentStatus := gcClient.GetStatus()
-		if currentStatus == botclient.StatusConnected || currentStatus == botclient.StatusGCReady {
+		if currentStatus == gc.StatusConnected || currentStatus == gc.StatusGCReady {
 			log.Printf("Steam client already in state %d, returning current status", currentStatus)
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"success": true,
@@ -153,12 +288,12 @@ func handleSteamLogin(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		
-		if currentStatus == botclient.StatusConnecting {
+		if currentStatus == gc.StatusConnecting {
 			log.Printf("Steam client in StatusConnecting, allowing reset to handle stuck connection")
 		}
 
 		// Init if disconnected, StatusNeedGuardCode, or StatusConnecting (allows reset)
-		if err := gcClient.Init(config.SteamUser, config.SteamPass); err != nil {
+		if err := gcClient.Init(user, pass); err != nil {
 			log.Printf("Failed to initialize Steam client: %v", err)
 			http.Error(w, "Failed to connect: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -167,12 +302,10 @@ func handleSteamLogin(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	// Poll status a few times to get the most up-to-date state
-	status := gcClient.GetStatus()
-	for i := 0; i < 3 && (status == botclient.StatusDisconnected || status == botclient.StatusConnecting); i++ {
-		time.Sleep(200 * time.Millisecond)
-		status = gcClient.GetStatus()
-	}
+	// Wait briefly for the login to move past Disconnected/Connecting
+	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Millisecond)
+	status, _ := gc.WaitFor(ctx, gcClient, gc.StatusNeedGuardCode, gc.StatusConnected, gc.StatusGCReady, gc.StatusRateLimited)
+	cancel()
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -180,12 +313,22 @@ func handleSteamLogin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+type SteamDisconnectRequest struct {
+	ForgetSession bool `json:"forgetSession"`
+}
+
 func handleSteamDisconnect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	var req SteamDisconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if gcClient == nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -194,6 +337,24 @@ func handleSteamDisconnect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	configMu.RLock()
+	steamUser := config.SteamUser
+	configMu.RUnlock()
+
+	if req.ForgetSession && steamUser != "" {
+		log.Printf("Disconnecting Steam client and forgetting the saved session...")
+		if err := gcClient.ForgetSession(steamUser); err != nil {
+			log.Printf("Failed to forget session: %v", err)
+			http.Error(w, "Failed to forget session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Disconnected and session forgotten",
+		})
+		return
+	}
+
 	log.Printf("Disconnecting Steam client...")
 	if err := gcClient.Disconnect(); err != nil {
 		log.Printf("Failed to disconnect: %v", err)
@@ -209,33 +370,28 @@ func handleSteamDisconnect(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSteamStatus(w http.ResponseWriter, r *http.Request) {
-	status := botclient.StatusDisconnected
+	status := gc.StatusDisconnected
 	var errorMessage string
 	if gcClient != nil {
 		status, errorMessage = gcClient.GetStatusWithError()
 	}
 
-	statusText := "Disconnected"
-	switch status {
-	case botclient.StatusConnecting:
-		statusText = "Connecting"
-	case botclient.StatusNeedGuardCode:
-		statusText = "Need Steam Guard Code"
-	case botclient.StatusConnected:
-		statusText = "Connected to Steam"
-	case botclient.StatusGCReady:
-		statusText = "Dota 2 GC Ready"
-	case botclient.StatusRateLimited:
-		statusText = "Rate Limited (Wait 24h)"
-	}
-
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":      int(status),
-		"statusText":  statusText,
+		"statusText":  gc.StatusText(status),
 		"errorMessage": errorMessage,
 	})
 }
 
+// handleSteamEvents relays GC status changes to the browser as they happen.
+func handleSteamEvents(w http.ResponseWriter, r *http.Request) {
+	if gcClient == nil {
+		http.Error(w, "GC client not available", http.StatusInternalServerError)
+		return
+	}
+	gc.ServeEvents(w, r, gcClient.Subscribe)
+}
+
 func handleConductScorecard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -248,27 +404,122 @@ func handleConductScorecard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	status := gcClient.GetStatus()
-	if status != botclient.StatusGCReady && status != botclient.StatusConnected {
+	if status != gc.StatusGCReady && status != gc.StatusConnected {
 		http.Error(w, "GC not ready. Please connect to Steam first.", http.StatusBadRequest)
 		return
 	}
 
-	scorecard, err := gcClient.GetPlayerConductScorecard()
+	scorecard, err := gcClient.GetPlayerConductScorecard(r.Context())
 	if err != nil {
 		log.Printf("Error fetching conduct scorecard: %v", err)
 		http.Error(w, fmt.Sprintf("Error fetching conduct scorecard: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if _, isNew := conductHistory.Record(*scorecard); isNew {
+		log.Printf("Recorded new conduct scorecard of account %d: match %d, raw behavior score %d", scorecard.AccountID, scorecard.MatchID, scorecard.RawBehaviorScore)
+	}
+
 	json.NewEncoder(w).Encode(scorecard)
 }
 
+// conductPoint is one scorecard in the /api/conduct/history time series.
+type conductPoint struct {
+	conduct.Entry
+	// ScoreChange is the raw behavior score change since the previous
+	// stored scorecard (or the GC's old score for the first one).
+	ScoreChange int64 `json:"score_change"`
+	// MatchIDs are the matches of the report period, newest first, when
+	// they are in the stored match history.
+	MatchIDs []int64 `json:"match_ids,omitempty"`
+}
+
+func handleConductHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	accountID := conductHistory.LatestAccount()
+	if v := r.URL.Query().Get("accountId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid accountId", http.StatusBadRequest)
+			return
+		}
+		accountID = uint32(convertSteamID(id, false))
+	}
+	// since (unix seconds) asks whether a scorecard arrived after it;
+	// without it, whether the last fetch brought a new one. That flag is
+	// gone after the next fetch whether or not a client saw it, so clients
+	// that must not miss a scorecard pass since.
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = time.Unix(n, 0)
+	}
+
+	entries, lastFetched := conductHistory.History(accountID)
+	matches := matchHistory.Matches(int64(convertSteamID(uint64(accountID), true)))
+	points := make([]conductPoint, 0, len(entries))
+	newScorecard := false
+	var arrived time.Time
+	for i, e := range entries {
+		p := conductPoint{Entry: e}
+		if i > 0 {
+			p.ScoreChange = int64(e.RawBehaviorScore) - int64(entries[i-1].RawBehaviorScore)
+		} else if e.OldRawBehaviorScore > 0 {
+			p.ScoreChange = int64(e.RawBehaviorScore) - int64(e.OldRawBehaviorScore)
+		}
+		for j, m := range matches {
+			if m.ID != int64(e.MatchID) {
+				continue
+			}
+			for _, m2 := range matches[j:] {
+				if len(p.MatchIDs) >= int(e.MatchesInReport) {
+					break
+				}
+				p.MatchIDs = append(p.MatchIDs, m2.ID)
+			}
+			break
+		}
+		if e.FirstSeen.After(arrived) {
+			arrived = e.FirstSeen
+		}
+		if !since.IsZero() && e.FirstSeen.After(since) {
+			newScorecard = true
+		}
+		points = append(points, p)
+	}
+	if since.IsZero() {
+		newScorecard = len(entries) > 0 && arrived.Equal(lastFetched)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accountId":    accountID,
+		"scorecards":   points,
+		"newScorecard": newScorecard,
+		"lastArrived":  arrived,
+		"lastFetched":  lastFetched,
+	})
+}
+
 type ValidateReportCardRequest struct {
 	MatchID   uint64 `json:"matchId"`
 	SteamID64 int64  `json:"steamId64,omitempty"`
 	AccountID uint32 `json:"accountId,omitempty"`
 }
 
+// isReportCardGame reports whether m counts towards a report card: ranked
+// matches other than Single Draft and Turbo.
+func isReportCardGame(m gc.Match) bool {
+	return m.LobbyType == gc.LobbyTypeRanked && m.GameMode != gc.GameModeSingleDraft && m.GameMode != gc.GameModeTurbo
+}
+
 func handleValidateReportCard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -291,12 +542,6 @@ func handleValidateReportCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := gcClient.GetStatus()
-	if status != botclient.StatusGCReady && status != botclient.StatusConnected {
-		http.Error(w, "GC not ready. Please connect to Steam first.", http.StatusBadRequest)
-		return
-	}
-
 	var steamID64 int64
 	if req.SteamID64 > 0 {
 		steamID64 = req.SteamID64
@@ -339,78 +584,38 @@ func handleValidateReportCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	const (
-		LobbyTypeRanked     = 7
-		GameModeSingleDraft = 4
-		GameModeTurbo       = 23
-		targetGames         = 15
-	)
-
-	var rankedMatches []int64
-	batchSize := 50
-	startAtMatchID := uint64(0)
-	batchesFetched := 0
-	maxBatches := 5
-	foundStartMatch := false
-
-	for len(rankedMatches) < targetGames && batchesFetched < maxBatches {
-		if batchesFetched > 0 {
-			time.Sleep(500 * time.Millisecond)
-		}
-
-		log.Printf("[ValidateReportCard] Fetching batch %d: startAtMatchID=%d, looking for match %d and older games", batchesFetched+1, startAtMatchID, req.MatchID)
-		matches, err := gcClient.GetPlayerMatchHistoryPaginated(steamID64, batchSize, false, startAtMatchID)
-		if err != nil {
-			log.Printf("[ValidateReportCard] Error fetching match history: %v", err)
-			http.Error(w, fmt.Sprintf("Error fetching match history: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		if len(matches) == 0 {
-			log.Printf("[ValidateReportCard] No more matches available")
-			break
-		}
+	const targetGames = 15
 
+	// collect returns the start match, if ranked, and the ranked games
+	// played before it, up to targetGames.
+	collect := func(matches []gc.Match) (ranked []int64, found bool) {
 		for i, m := range matches {
-			if m.ID == int64(req.MatchID) {
-				foundStartMatch = true
-				if m.LobbyType == LobbyTypeRanked && m.GameMode != GameModeSingleDraft && m.GameMode != GameModeTurbo {
-					rankedMatches = append(rankedMatches, int64(m.ID))
-					log.Printf("[ValidateReportCard] Added start match %d (ranked, non-singledraft, non-turbo)", m.ID)
+			if m.ID != int64(req.MatchID) {
+				continue
+			}
+			for _, m2 := range matches[i:] {
+				if len(ranked) >= targetGames {
+					break
 				}
-
-				for j := i + 1; j < len(matches) && len(rankedMatches) < targetGames; j++ {
-					m2 := matches[j]
-					if m2.LobbyType == LobbyTypeRanked && m2.GameMode != GameModeSingleDraft && m2.GameMode != GameModeTurbo {
-						rankedMatches = append(rankedMatches, int64(m2.ID))
-						log.Printf("[ValidateReportCard] Added match %d (ranked, non-singledraft, non-turbo)", m2.ID)
-					}
+				if isReportCardGame(m2) {
+					ranked = append(ranked, m2.ID)
 				}
-				break
 			}
+			return ranked, true
 		}
+		return nil, false
+	}
 
-		if foundStartMatch {
-			if len(rankedMatches) >= targetGames {
-				break
-			}
-			if len(matches) < batchSize {
-				log.Printf("[ValidateReportCard] No more matches available (batch size: %d)", len(matches))
-				break
-			}
-			startAtMatchID = uint64(matches[len(matches)-1].ID)
-			batchesFetched++
-			continue
-		}
-
-		if len(matches) < batchSize {
-			log.Printf("[ValidateReportCard] No more matches available (batch size: %d), start match not found", len(matches))
-			break
-		}
-
-		startAtMatchID = uint64(matches[len(matches)-1].ID)
-		batchesFetched++
+	matches, err := loadMatchHistory(w, r, steamID64, reportCardHistoryPages, func(ms []gc.Match) bool {
+		ranked, found := collect(ms)
+		return found && len(ranked) >= targetGames
+	})
+	if err != nil {
+		log.Printf("[ValidateReportCard] Error fetching match history: %v", err)
+		writeHistoryError(w, err)
+		return
 	}
+	rankedMatches, foundStartMatch := collect(matches)
 
 	if !foundStartMatch {
 		log.Printf("[ValidateReportCard] Start match %d not found in match history", req.MatchID)
@@ -449,9 +654,9 @@ func handleValidateReportCard(w http.ResponseWriter, r *http.Request) {
 		}
 
 		log.Printf("[ValidateReportCard] Downloading match %d", matchID)
-		if err := downloader.DownloadReplay(matchID, reportCardsDir, config.StratzAPIToken, config.SteamAPIKey, gcClient); err != nil {
+		if err := downloader.DownloadReplayWithPriority(matchID, reportCardsDir, config.StratzAPIToken, config.SteamAPIKey, gcClient, downloader.PriorityBulk); err != nil {
 			lock.Unlock()
-			if strings.Contains(err.Error(), "queued for parsing") {
+			if downloader.IsQueued(err) {
 				log.Printf("[ValidateReportCard] Match %d queued for parsing", matchID)
 				errors = append(errors, fmt.Sprintf("Match %d queued for parsing", matchID))
 			} else {
@@ -504,12 +709,6 @@ func handleValidateReportCardCurrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := gcClient.GetStatus()
-	if status != botclient.StatusGCReady && status != botclient.StatusConnected {
-		http.Error(w, "GC not ready. Please connect to Steam first.", http.StatusBadRequest)
-		return
-	}
-
 	var steamID64 int64
 	if req.SteamID64 > 0 {
 		steamID64 = req.SteamID64
@@ -553,63 +752,39 @@ func handleValidateReportCardCurrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	const (
-		LobbyTypeRanked     = 7
-		GameModeSingleDraft = 4
-		GameModeTurbo       = 23
-		maxGames            = 15
-	)
-
-	var rankedMatches []int64
-	batchSize := 50
-	startAtMatchID := uint64(0)
-	batchesFetched := 0
-	maxBatches := 3
-	foundStartMatch := false
-
-	for len(rankedMatches) < maxGames && batchesFetched < maxBatches {
-		if batchesFetched > 0 {
-			time.Sleep(500 * time.Millisecond)
-		}
-
-		log.Printf("[ValidateReportCardCurrent] Fetching batch %d: startAtMatchID=%d, looking for games after match %d", batchesFetched+1, startAtMatchID, req.MatchID)
-		matches, err := gcClient.GetPlayerMatchHistoryPaginated(steamID64, batchSize, false, startAtMatchID)
-		if err != nil {
-			log.Printf("[ValidateReportCardCurrent] Error fetching match history: %v", err)
-			http.Error(w, fmt.Sprintf("Error fetching match history: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		if len(matches) == 0 {
-			log.Printf("[ValidateReportCardCurrent] No more matches available")
-			break
-		}
+	const maxGames = 15
 
-		for i, m := range matches {
+	// Newer games are in the history once it is synced; only the start
+	// match may need a backfill.
+	matches, err := loadMatchHistory(w, r, steamID64, reportCardHistoryPages, func(ms []gc.Match) bool {
+		for _, m := range ms {
 			if m.ID == int64(req.MatchID) {
-				foundStartMatch = true
-				for j := i - 1; j >= 0 && len(rankedMatches) < maxGames; j-- {
-					m2 := matches[j]
-					if m2.LobbyType == LobbyTypeRanked && m2.GameMode != GameModeSingleDraft && m2.GameMode != GameModeTurbo {
-						rankedMatches = append(rankedMatches, int64(m2.ID))
-						log.Printf("[ValidateReportCardCurrent] Added match %d (ranked, non-singledraft, non-turbo)", m2.ID)
-					}
-				}
-				break
+				return true
 			}
 		}
+		return false
+	})
+	if err != nil {
+		log.Printf("[ValidateReportCardCurrent] Error fetching match history: %v", err)
+		writeHistoryError(w, err)
+		return
+	}
 
-		if foundStartMatch {
-			break
+	var rankedMatches []int64
+	foundStartMatch := false
+	for i, m := range matches {
+		if m.ID != int64(req.MatchID) {
+			continue
 		}
-
-		if len(matches) < batchSize {
-			log.Printf("[ValidateReportCardCurrent] No more matches available (batch size: %d)", len(matches))
-			break
+		foundStartMatch = true
+		for j := i - 1; j >= 0 && len(rankedMatches) < maxGames; j-- {
+			m2 := matches[j]
+			if isReportCardGame(m2) {
+				rankedMatches = append(rankedMatches, int64(m2.ID))
+				log.Printf("[ValidateReportCardCurrent] Added match %d (ranked, non-singledraft, non-turbo)", m2.ID)
+			}
 		}
-
-		startAtMatchID = uint64(matches[len(matches)-1].ID)
-		batchesFetched++
+		break
 	}
 
 	if !foundStartMatch {
@@ -671,9 +846,9 @@ func handleValidateReportCardCurrent(w http.ResponseWriter, r *http.Request) {
 		}
 
 		log.Printf("[ValidateReportCardCurrent] Downloading match %d", matchID)
-		if err := downloader.DownloadReplay(matchID, reportCardsCurrentDir, config.StratzAPIToken, config.SteamAPIKey, gcClient); err != nil {
+		if err := downloader.DownloadReplayWithPriority(matchID, reportCardsCurrentDir, config.StratzAPIToken, config.SteamAPIKey, gcClient, downloader.PriorityBulk); err != nil {
 			lock.Unlock()
-			if strings.Contains(err.Error(), "queued for parsing") {
+			if downloader.IsQueued(err) {
 				log.Printf("[ValidateReportCardCurrent] Match %d queued for parsing", matchID)
 				errors = append(errors, fmt.Sprintf("Match %d queued for parsing", matchID))
 			} else {
@@ -1031,7 +1206,7 @@ func handlePlayerInfo(w http.ResponseWriter, r *http.Request) {
 			if _, err := os.Stat(fatalPath); err == nil {
 				filePath = fatalPath
 			} else {
-				http.Error(w, fmt.Sprintf("Replay file not found: %s", req.FilePath), http.StatusNotFound)
+				writePlayerInfoFromDetails(r.Context(), w, matchID, fmt.Sprintf("Replay file not found: %s", req.FilePath))
 				return
 			}
 		}
@@ -1075,7 +1250,7 @@ func handlePlayerInfo(w http.ResponseWriter, r *http.Request) {
 			}
 			
 			if !found {
-				http.Error(w, fmt.Sprintf("Replay file not found: %s.dem", req.MatchID), http.StatusNotFound)
+				writePlayerInfoFromDetails(r.Context(), w, matchID, fmt.Sprintf("Replay file not found: %s.dem", req.MatchID))
 				return
 			}
 		}
@@ -1098,6 +1273,77 @@ func handlePlayerInfo(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(players)
 }
 
+// writePlayerInfoFromDetails answers a player info request for a match
+// whose replay is gone with the players of its GC match details, so its
+// reporters can still be labeled. Without details it answers 404 with
+// notFound.
+func writePlayerInfoFromDetails(ctx context.Context, w http.ResponseWriter, matchID int64, notFound string) {
+	d, err := downloader.FetchMatchDetails(ctx, matchID, gcClient)
+	if err != nil {
+		log.Printf("No match details for %d: %v", matchID, err)
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+
+	players := make([]parser.PlayerResource, 0, len(d.Players))
+	for _, p := range d.Players {
+		pr := parser.PlayerResource{
+			Slot:   p.Slot,
+			Team:   p.Team,
+			Name:   p.Name,
+			HeroID: p.HeroID,
+		}
+		// Anonymous players have account ID 4294967295.
+		if p.AccountID != 0 && p.AccountID != ^uint32(0) {
+			pr.SteamID = convertSteamID(uint64(p.AccountID), true)
+		}
+		if h, ok := heroes.ByID(p.HeroID); ok {
+			pr.Hero = h.Name
+			pr.HeroName = h.LocalizedName
+		}
+		players = append(players, pr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(players)
+}
+
+// gcErrorStatus maps a typed GC error to the HTTP status reporting it.
+func gcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gc.ErrNotReady):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, gc.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, gc.ErrTimeout):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// handleMatchDetails returns the GC match details of a match (players,
+// heroes, result), from the cache when possible.
+func handleMatchDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("matchId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid matchId", http.StatusBadRequest)
+		return
+	}
+
+	d, err := downloader.FetchMatchDetails(r.Context(), matchID, gcClient)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching match details: %v", err), gcErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
 func handleHeroIcon(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1123,25 +1369,27 @@ func handleHeroIcon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	heroIdMapping := map[string]string{
-		"zeus": "zuus",
-	}
-	if mappedId, ok := heroIdMapping[heroId]; ok {
-		heroId = mappedId
+	// Accept a numeric hero ID or any of the hero's names (internal,
+	// class or localized) and resolve it to the CDN image name.
+	var hero heroes.Hero
+	var found bool
+	if id, err := strconv.Atoi(heroId); err == nil {
+		hero, found = heroes.ByID(id)
+	} else {
+		hero, found = heroes.ByName(heroId)
 	}
-
-	heroesWithoutIcon := map[string]bool{
-		"primal_beast": true,
-		"ringmaster":   true,
-		"marci":        true,
-		"muerta":       true,
+	if !found {
+		log.Printf("Unknown hero in icon request: %s", heroId)
+		http.Error(w, "Unknown hero", http.StatusNotFound)
+		return
 	}
+	heroId = hero.Name
 
 	var iconUrl string
-	if heroId == "kez" || heroesWithoutIcon[heroId] {
-		iconUrl = fmt.Sprintf("https://cdn.cloudflare.steamstatic.com/apps/dota2/images/heroes/%s_full.png", heroId)
+	if hero.HasIcon {
+		iconUrl = heroImageURL(heroId, "icon")
 	} else {
-		iconUrl = fmt.Sprintf("https://cdn.cloudflare.steamstatic.com/apps/dota2/images/heroes/%s_icon.png", heroId)
+		iconUrl = heroImageURL(heroId, "full")
 	}
 
 	client := &http.Client{
@@ -1156,9 +1404,9 @@ func handleHeroIcon(w http.ResponseWriter, r *http.Request) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		if !heroesWithoutIcon[heroId] && heroId != "kez" {
+		if hero.HasIcon {
 			log.Printf("Hero icon %s returned status %d, trying full image", iconUrl, resp.StatusCode)
-			fullUrl := fmt.Sprintf("https://cdn.cloudflare.steamstatic.com/apps/dota2/images/heroes/%s_full.png", heroId)
+			fullUrl := heroImageURL(heroId, "full")
 			resp2, err2 := client.Get(fullUrl)
 			if err2 != nil {
 				log.Printf("Failed to fetch hero full image %s: %v", fullUrl, err2)
@@ -1183,6 +1431,64 @@ func handleHeroIcon(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, resp.Body)
 }
 
+// heroImageURL returns the CDN URL of a hero image; kind is "icon" or
+// "full".
+func heroImageURL(name string, kind string) string {
+	base := config.HeroIconBaseURL
+	if base == "" {
+		base = defaultHeroIconBaseURL
+	}
+	return fmt.Sprintf("%s/%s_%s.png", strings.TrimRight(base, "/"), name, kind)
+}
+
+// handleHeroes returns the hero identity table so the frontend does not
+// need its own copy.
+func handleHeroes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(heroes.All())
+}
+
+// parserOptions builds the parser options for a match. When the match is the
+// configured trace match, cursor samples are written to a file in the config
+// directory; the returned func closes it.
+func parserOptions(matchID int64, reportedSlot int, reportedSteamID uint64) (parser.Options, func()) {
+	opts := parser.Options{
+		MatchID:         matchID,
+		ReportedSlot:    reportedSlot,
+		ReportedSteamID: reportedSteamID,
+		Logger:          parserLogger,
+	}
+	if config.ParserTraceMatchID == 0 || config.ParserTraceMatchID != matchID {
+		return opts, func() {}
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	traceDir := filepath.Join(homeDir, ".dota-report-timestamps")
+	if err := os.MkdirAll(traceDir, 0755); err != nil {
+		log.Printf("Could not create trace directory %s: %v", traceDir, err)
+		return opts, func() {}
+	}
+	tracePath := filepath.Join(traceDir, fmt.Sprintf("trace_%d.tsv", matchID))
+	f, err := os.Create(tracePath)
+	if err != nil {
+		log.Printf("Could not create trace file %s: %v", tracePath, err)
+		return opts, func() {}
+	}
+	log.Printf("Tracing cursor samples for match %d to %s", matchID, tracePath)
+	bw := bufio.NewWriter(f)
+	opts.Trace = bw
+	return opts, func() {
+		bw.Flush()
+		f.Close()
+	}
+}
+
 type ParseRequest struct {
 	MatchID         string `json:"matchId"`
 	FilePath        string `json:"filePath"`
@@ -1245,7 +1551,10 @@ func handleParse(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	result, err := parser.ParseReplay(matchID, file, req.ReportedSlot, reportedSteamID)
+	opts, closeTrace := parserOptions(matchID, req.ReportedSlot, reportedSteamID)
+	defer closeTrace()
+
+	result, err := parser.Run(r.Context(), file, opts, nil)
 	if err != nil {
 		http.Error(w, "Error parsing replay: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -1254,6 +1563,65 @@ func handleParse(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// How many pages of older matches a request backfills in the foreground
+// before it answers from what is stored; the rest is backfilled in the
+// background.
+const (
+	historyPages           = 5
+	fatalHistoryPages      = 30
+	reportCardHistoryPages = 13
+)
+
+// Headers of responses built from a stored match history that is not
+// current: historyStaleHeader says why it could not be synced, and
+// historyIncompleteHeader why it may not reach back far enough.
+const (
+	historyStaleHeader      = "X-History-Stale"
+	historyIncompleteHeader = "X-History-Incomplete"
+)
+
+// loadMatchHistory syncs the stored history of a player, backfills it until
+// enough accepts it, and returns it newest first. When the GC cannot be
+// reached, the stored history is used as long as there is one. Either way
+// the history headers on w report what it lacks.
+func loadMatchHistory(w http.ResponseWriter, r *http.Request, steamID64 int64, maxPages int, enough func([]gc.Match) bool) ([]gc.Match, error) {
+	if _, err := matchHistory.Sync(r.Context(), steamID64, gcClient); err != nil {
+		stored := matchHistory.Matches(steamID64)
+		if len(stored) == 0 {
+			return nil, err
+		}
+		log.Printf("Using %d stored matches of %d: %v", len(stored), steamID64, err)
+		w.Header().Set(historyStaleHeader, err.Error())
+		if !enough(stored) {
+			w.Header().Set(historyIncompleteHeader, history.ErrIncomplete.Error())
+		}
+		return stored, nil
+	}
+	if err := matchHistory.Ensure(r.Context(), steamID64, gcClient, maxPages, enough); err != nil {
+		log.Printf("Match history of %d not deep enough yet: %v", steamID64, err)
+		w.Header().Set(historyIncompleteHeader, err.Error())
+	}
+	return matchHistory.Matches(steamID64), nil
+}
+
+// writeHistoryError reports a failed match history sync.
+func writeHistoryError(w http.ResponseWriter, err error) {
+	http.Error(w, fmt.Sprintf("Error fetching match history: %v", err), gcErrorStatus(err))
+}
+
+func handleHistoryStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	steamID, err := strconv.ParseInt(r.URL.Query().Get("steamId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid steamId", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(matchHistory.Status(steamID))
+}
+
 func handleHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1288,21 +1656,50 @@ func handleHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := gcClient.GetStatus()
-	if status != botclient.StatusGCReady && status != botclient.StatusConnected {
-		http.Error(w, "GC not ready. Please connect to Steam first.", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Using GC for steamID: %d, limit: %d, turboOnly: %v", steamID, limit, turboOnly)
-	matches, err := gcClient.GetPlayerMatchHistory(steamID, limit, turboOnly)
+	wanted := func(m gc.Match) bool { return !turboOnly || m.GameMode == gc.GameModeTurbo }
+	stored, err := loadMatchHistory(w, r, steamID, historyPages, func(ms []gc.Match) bool {
+		n := 0
+		for _, m := range ms {
+			if wanted(m) {
+				n++
+			}
+		}
+		return n >= limit
+	})
 	if err != nil {
 		log.Printf("Error fetching matches from GC: %v", err)
-		http.Error(w, fmt.Sprintf("Error fetching matches: %v", err), http.StatusInternalServerError)
+		writeHistoryError(w, err)
 		return
 	}
+	var matches []gc.Match
+	for _, m := range stored {
+		if len(matches) >= limit {
+			break
+		}
+		if wanted(m) {
+			matches = append(matches, m)
+		}
+	}
 
-	json.NewEncoder(w).Encode(matches)
+	type historyMatch struct {
+		gc.Match
+		Expiry  downloader.ReplayExpiry `json:"expiry"`
+		Details *gc.MatchDetails        `json:"details,omitempty"`
+	}
+	ids := make([]int64, 0, len(matches))
+	result := make([]historyMatch, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+		downloader.RecordStartTime(m.ID, time.Unix(int64(m.StartTime), 0))
+		hm := historyMatch{Match: m, Expiry: downloader.Expiry(m.ID)}
+		if d, ok := downloader.CachedMatchDetails(m.ID); ok {
+			hm.Details = &d
+		}
+		result = append(result, hm)
+	}
+	go downloader.PrefillLocators(steamID, ids, gcClient)
+
+	json.NewEncoder(w).Encode(result)
 }
 
 type FatalSearchRequest struct {
@@ -1341,14 +1738,6 @@ func handleFatalSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status := gcClient.GetStatus()
-	log.Printf("[FATAL_SEARCH] GC client status: %d", status)
-	if status != botclient.StatusGCReady && status != botclient.StatusConnected {
-		log.Printf("[FATAL_SEARCH] ERROR: GC not ready (status=%d, need %d or %d)", status, botclient.StatusGCReady, botclient.StatusConnected)
-		http.Error(w, "GC not ready. Please connect to Steam first.", http.StatusBadRequest)
-		return
-	}
-
 	if req.MaxDepth < 1 {
 		log.Printf("[FATAL_SEARCH] ERROR: Invalid maxDepth: %d", req.MaxDepth)
 		http.Error(w, "maxDepth must be at least 1", http.StatusBadRequest)
@@ -1356,17 +1745,36 @@ func handleFatalSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[FATAL_SEARCH] Starting fatal search: steamID=%d, maxDepth=%d, gamesPerFatal=%d, profile=%s", steamID, req.MaxDepth, req.GamesPerFatal, req.ProfileName)
-	matches, err := gcClient.FindFatalGames(steamID, req.MaxDepth, req.GamesPerFatal)
+	matches, err := loadMatchHistory(w, r, steamID, fatalHistoryPages, func(ms []gc.Match) bool {
+		found, err := gc.FindFatalMatches(ms, req.MaxDepth, req.GamesPerFatal)
+		return err != nil || len(found) >= req.MaxDepth
+	})
+	if err != nil {
+		log.Printf("[FATAL_SEARCH] ERROR fetching match history: %v", err)
+		writeHistoryError(w, err)
+		return
+	}
+	fatals, err := gc.FindFatalMatches(matches, req.MaxDepth, req.GamesPerFatal)
 	if err != nil {
 		log.Printf("[FATAL_SEARCH] ERROR finding fatal games: %v", err)
 		http.Error(w, fmt.Sprintf("Error finding fatal games: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[FATAL_SEARCH] Success: found %d fatal matches", len(matches))
+	log.Printf("[FATAL_SEARCH] Success: found %d fatal matches", len(fatals))
+	var ids []int64
+	for _, m := range fatals {
+		ids = append(ids, m.FatalMatchID, m.SingleDraftMatchID)
+		ids = append(ids, m.AdditionalMatchIDs...)
+		if m.SingleDraftDate != 0 {
+			downloader.RecordStartTime(m.SingleDraftMatchID, time.Unix(int64(m.SingleDraftDate), 0))
+		}
+	}
+	go downloader.PrefillLocators(steamID, ids, gcClient)
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"matches": matches,
-		"count":   len(matches),
+		"matches": fatals,
+		"count":   len(fatals),
 	})
 
 	// DEPRECATED: Stratz/OpenDota code paths removed - Steam GC is now the only active method
@@ -1405,6 +1813,16 @@ type DownloadRequest struct {
 	GamesPerFatal      int     `json:"gamesPerFatal,omitempty"` // Number of games to download per fatal (default: 2)
 	SteamID            int64   `json:"steamId,omitempty"`        // Steam ID needed to fetch match history for additional games
 	AdditionalMatchIDs []int64 `json:"additionalMatchIds,omitempty"` // List of additional match IDs to download (pre-calculated)
+	// Priority is "bulk" for downloads started as part of a batch; they wait
+	// behind downloads the user started individually.
+	Priority string `json:"priority,omitempty"`
+}
+
+func (req DownloadRequest) priority() downloader.Priority {
+	if req.Priority == "bulk" {
+		return downloader.PriorityBulk
+	}
+	return downloader.PriorityInteractive
 }
 
 func handleDownload(w http.ResponseWriter, r *http.Request) {
@@ -1472,7 +1890,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Using provided additional ranked games list: %v", additionalMatchIDs)
 			if len(additionalMatchIDs) < neededRankedGames && req.SteamID > 0 && gcClient != nil {
 				log.Printf("Provided list has %d games but need %d, fetching additional games", len(additionalMatchIDs), neededRankedGames)
-				moreGames := fetchAdditionalGames(req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames-len(additionalMatchIDs), gcClient)
+				moreGames := fetchAdditionalGames(r.Context(), req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames-len(additionalMatchIDs), gcClient)
 				for _, gameID := range moreGames {
 					found := false
 					for _, existingID := range additionalMatchIDs {
@@ -1489,7 +1907,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 			}
 		} else if neededRankedGames > 0 && req.SteamID > 0 && gcClient != nil {
 			log.Printf("Fetching ranked games before singledraft: gamesPerFatal=%d, need %d ranked games before singledraft", gamesPerFatal, neededRankedGames)
-			additionalMatchIDs = fetchAdditionalGames(req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames, gcClient)
+			additionalMatchIDs = fetchAdditionalGames(r.Context(), req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames, gcClient)
 			if len(additionalMatchIDs) == 0 && neededRankedGames > 0 {
 				// If we need additional games but couldn't fetch them, we can't verify they exist
 				// So we should proceed with download attempt
@@ -1590,17 +2008,17 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Fatal replay %d was downloaded by another request, reusing it", req.MatchID)
 				lock.Unlock()
 			} else {
-				if err := downloader.DownloadReplay(req.MatchID, tempDir, config.StratzAPIToken, config.SteamAPIKey, gcClient); err != nil {
+				if err := downloader.DownloadReplayWithPriority(req.MatchID, tempDir, config.StratzAPIToken, config.SteamAPIKey, gcClient, req.priority()); err != nil {
 					lock.Unlock()
-					if !strings.Contains(err.Error(), "queued for parsing") {
+					if !downloader.IsQueued(err) {
 						log.Printf("Error downloading fatal replay for match %d: %v", req.MatchID, err)
 						w.Header().Set("Content-Type", "application/json")
 						w.WriteHeader(http.StatusInternalServerError)
 						errorMsg := fmt.Sprintf("Error downloading fatal replay: %v", err)
 						// Check for expired replay (404) first
-						if strings.Contains(err.Error(), "replay has likely expired") || strings.Contains(err.Error(), "replay not found (404)") {
+						if errors.Is(err, downloader.ErrExpired) {
 							errorMsg = "Replay has expired (7-14 day limit). The replay is no longer available on Valve's servers."
-						} else if strings.Contains(err.Error(), "failed to download replay after trying all URLs") {
+						} else if errors.Is(err, downloader.ErrDownloadFailed) {
 							errorMsg = "Failed to download replay: All download URLs failed. The replay may be unavailable or the servers are down."
 						}
 						json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1620,7 +2038,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		// If we need additional ranked games and we haven't fetched them yet (or fetch failed earlier), try again
 		if neededRankedGames > 0 && req.SteamID > 0 && gcClient != nil && len(additionalMatchIDs) == 0 {
 			log.Printf("Fetching ranked games before singledraft for download: gamesPerFatal=%d, need %d ranked games", gamesPerFatal, neededRankedGames)
-			additionalMatchIDs = fetchAdditionalGames(req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames, gcClient)
+			additionalMatchIDs = fetchAdditionalGames(r.Context(), req.SteamID, req.SingleDraftMatchID, req.MatchID, neededRankedGames, gcClient)
 			if len(additionalMatchIDs) == 0 {
 				log.Printf("Warning: Could not fetch additional ranked games, will only download fatal match (1 game instead of %d)", gamesPerFatal)
 			} else {
@@ -1679,9 +2097,9 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 				lock.Unlock()
 			} else {
 				log.Printf("Downloading additional ranked game %d (before singledraft)", additionalMatchID)
-				if err := downloader.DownloadReplay(additionalMatchID, tempDir, config.StratzAPIToken, config.SteamAPIKey, gcClient); err != nil {
+				if err := downloader.DownloadReplayWithPriority(additionalMatchID, tempDir, config.StratzAPIToken, config.SteamAPIKey, gcClient, req.priority()); err != nil {
 					lock.Unlock()
-					if !strings.Contains(err.Error(), "queued for parsing") {
+					if !downloader.IsQueued(err) {
 						log.Printf("Error downloading additional ranked game %d: %v (continuing with other games)", additionalMatchID, err)
 					}
 				} else {
@@ -1813,9 +2231,9 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := downloader.DownloadReplay(req.MatchID, replayDir, config.StratzAPIToken, config.SteamAPIKey, gcClient); err != nil {
+	if err := downloader.DownloadReplayWithPriority(req.MatchID, replayDir, config.StratzAPIToken, config.SteamAPIKey, gcClient, req.priority()); err != nil {
 		// Check if match was queued for parsing (background processing)
-		if strings.Contains(err.Error(), "queued for parsing") {
+		if downloader.IsQueued(err) {
 			log.Printf("Match %d queued for parsing, will be processed in background", req.MatchID)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusAccepted)
@@ -1855,7 +2273,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 }
 
 // fetchAdditionalGamesGC fetches match history using the GC client as a fallback
-func fetchAdditionalGamesGC(steamID int64, singleDraftMatchID int64, fatalMatchID int64, count int, gcClient *botclient.Client) []int64 {
+func fetchAdditionalGamesGC(ctx context.Context, steamID int64, singleDraftMatchID int64, fatalMatchID int64, count int, gcClient gc.Service) []int64 {
 	if gcClient == nil {
 		return []int64{}
 	}
@@ -1863,61 +2281,20 @@ func fetchAdditionalGamesGC(steamID int64, singleDraftMatchID int64, fatalMatchI
 	limit := 20
 
 	log.Printf("Fetching match history (GC) starting from singledraft match %d (limit=%d)", singleDraftMatchID, limit)
-	
-	// Ensure GC is ready before attempting fetch
-	status := gcClient.GetStatus()
-	if status != botclient.StatusGCReady {
-		log.Printf("GC not ready (status: %d), refreshing connection...", status)
-		time.Sleep(2 * time.Second)
-		status = gcClient.GetStatus()
-		if status != botclient.StatusGCReady {
-			log.Printf("GC still not ready after refresh, proceeding anyway")
-		}
-	}
-	
-	matches, err := gcClient.GetPlayerMatchHistoryPaginated(steamID, limit, false, uint64(singleDraftMatchID))
+
+	// The GC queue waits for the session to be ready and times requests
+	// out, so failures fall through to the next starting point.
+	matches, err := gcClient.GetPlayerMatchHistoryPaginated(ctx, steamID, limit, false, uint64(singleDraftMatchID))
 	if err != nil {
 		log.Printf("Error fetching match history from singledraft match %d via GC: %v", singleDraftMatchID, err)
-		if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "context deadline exceeded") {
-			log.Printf("GC timeout detected, refreshing connection and retrying...")
-			time.Sleep(3 * time.Second)
-			status = gcClient.GetStatus()
-			if status != botclient.StatusGCReady {
-				log.Printf("GC not ready after timeout, waiting for ready state...")
-				for i := 0; i < 15; i++ {
-					time.Sleep(1 * time.Second)
-					status = gcClient.GetStatus()
-					if status == botclient.StatusGCReady {
-						log.Printf("GC is now ready, retrying...")
-						break
-					}
-				}
-			}
-		}
 		// Fallback: try starting from fatal match ID
 		log.Printf("Retrying GC from fatal match %d", fatalMatchID)
-		matches, err = gcClient.GetPlayerMatchHistoryPaginated(steamID, limit, false, uint64(fatalMatchID))
+		matches, err = gcClient.GetPlayerMatchHistoryPaginated(ctx, steamID, limit, false, uint64(fatalMatchID))
 		if err != nil {
 			log.Printf("Error fetching match history from fatal match %d via GC: %v", fatalMatchID, err)
-			if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "context deadline exceeded") {
-				log.Printf("GC timeout detected again, refreshing connection and retrying...")
-				time.Sleep(3 * time.Second)
-				status = gcClient.GetStatus()
-				if status != botclient.StatusGCReady {
-					log.Printf("GC not ready after timeout, waiting for ready state...")
-					for i := 0; i < 15; i++ {
-						time.Sleep(1 * time.Second)
-						status = gcClient.GetStatus()
-						if status == botclient.StatusGCReady {
-							log.Printf("GC is now ready, retrying...")
-							break
-						}
-					}
-				}
-			}
 			// Fallback: try starting from 0 (newest)
 			log.Printf("Retrying GC from start (0)")
-			matches, err = gcClient.GetPlayerMatchHistoryPaginated(steamID, limit, false, 0)
+			matches, err = gcClient.GetPlayerMatchHistoryPaginated(ctx, steamID, limit, false, 0)
 			if err != nil {
 				log.Printf("Error fetching match history from start via GC: %v", err)
 				return []int64{}
@@ -1940,7 +2317,7 @@ func fetchAdditionalGamesGC(steamID int64, singleDraftMatchID int64, fatalMatchI
 	}
 
 	if singleDraftIndex == -1 {
-		log.Printf("Could not find singledraft match %d in GC history. Searching linearly...", singleDraftMatchID, len(matches))
+		log.Printf("Could not find singledraft match %d in GC history (%d matches). Searching linearly...", singleDraftMatchID, len(matches))
 		// If we started from 0, maybe we can just process the list if we find the fatal match?
 		// Or just return whatever ranked matches we found?
 		// Let's try to find the fatal match at least
@@ -2000,7 +2377,7 @@ func fetchAdditionalGamesGC(steamID int64, singleDraftMatchID int64, fatalMatchI
 
 // fetchAdditionalGames fetches match history and finds additional games before the singledraft match
 // uses Steam Web API first, then GC fallback
-func fetchAdditionalGames(steamID int64, singleDraftMatchID int64, fatalMatchID int64, count int, gcClient *botclient.Client) []int64 {
+func fetchAdditionalGames(ctx context.Context, steamID int64, singleDraftMatchID int64, fatalMatchID int64, count int, gcClient gc.Service) []int64 {
 	if count <= 0 {
 		return []int64{}
 	}
@@ -2030,7 +2407,7 @@ func fetchAdditionalGames(steamID int64, singleDraftMatchID int64, fatalMatchID
 			
 			// Fallback to GC if Web API fails
 			log.Printf("Web API failed, attempting fallback to GC...")
-			return fetchAdditionalGamesGC(steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
+			return fetchAdditionalGamesGC(ctx, steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
 		}
 	}
 
@@ -2038,7 +2415,7 @@ func fetchAdditionalGames(steamID int64, singleDraftMatchID int64, fatalMatchID
 		log.Printf("No matches returned from history fetch")
 		// Fallback to GC if Web API returns no matches (unexpected if user has matches)
 		log.Printf("Web API returned no matches, attempting fallback to GC...")
-		return fetchAdditionalGamesGC(steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
+		return fetchAdditionalGamesGC(ctx, steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
 	}
 
 	// Find the singledraft match in the history
@@ -2054,7 +2431,7 @@ func fetchAdditionalGames(steamID int64, singleDraftMatchID int64, fatalMatchID
 		log.Printf("Could not find singledraft match %d in history (fetched %d matches). The singledraft may be too far back.", singleDraftMatchID, len(matches))
 		// Fallback to GC if index not found in Web API (maybe private profile partial result?)
 		log.Printf("Web API did not return singledraft match, attempting fallback to GC...")
-		return fetchAdditionalGamesGC(steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
+		return fetchAdditionalGamesGC(ctx, steamID, singleDraftMatchID, fatalMatchID, count, gcClient)
 	}
 
 	// Get ranked matches before the singledraft (history is ordered newest first)
@@ -2108,6 +2485,7 @@ func handleProgress(w http.ResponseWriter, r *http.Request) {
 	// Timeout after 5 minutes (just in case)
 	timeout := time.After(5 * time.Minute)
 
+	lastPosition := 0
 	for {
 		select {
 		case <-r.Context().Done():
@@ -2115,6 +2493,13 @@ func handleProgress(w http.ResponseWriter, r *http.Request) {
 		case <-timeout:
 			return
 		case <-ticker.C:
+			// While waiting for a download slot, report the queue
+			// position as a separate "queue" event (0 once started).
+			if position := downloader.QueuePosition(matchID); position != lastPosition {
+				fmt.Fprintf(w, "event: queue\ndata: %d\n\n", position)
+				lastPosition = position
+			}
+
 			progress := downloader.GetProgress(matchID)
 
 			// Send event
@@ -2129,3 +2514,238 @@ func handleProgress(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// handleJobs lists the persistent download jobs.
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type jobWithExpiry struct {
+		downloader.Job
+		Expiry downloader.ReplayExpiry `json:"expiry"`
+	}
+	list := downloader.ListJobs()
+	result := make([]jobWithExpiry, 0, len(list))
+	for _, j := range list {
+		result = append(result, jobWithExpiry{Job: j, Expiry: downloader.ExpiryFor(j.StartTime)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": result,
+	})
+}
+
+type ImportRequest struct {
+	// SourceDir defaults to the configured replay directory, which is the
+	// Dota client's own replay folder unless changed.
+	SourceDir   string `json:"sourceDir"`
+	ProfileName string `json:"profileName"`
+	Fatal       bool   `json:"fatal"`
+	// GamesPerFatal is the number of ranked games after each Single Draft
+	// filed with it, as in a fatal download.
+	GamesPerFatal int    `json:"gamesPerFatal"`
+	Mode          string `json:"mode"` // "link" (default) or "copy"
+	Recursive     bool   `json:"recursive"`
+}
+
+// importMatchDetails returns the cached GC details of a match, with its
+// start time taken from the match history if the details lack one.
+func importMatchDetails(matchID int64) (gc.MatchDetails, bool) {
+	d, ok := downloader.CachedMatchDetails(matchID)
+	if d.StartTime == 0 {
+		if start := downloader.Expiry(matchID).StartTime; !start.IsZero() {
+			d.MatchID = matchID
+			d.StartTime = uint32(start.Unix())
+			ok = true
+		}
+	}
+	return d, ok
+}
+
+// handleImport adds replays from a directory on this machine to a profile.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	configMu.RLock()
+	if req.SourceDir == "" {
+		req.SourceDir = config.ReplayDir
+	}
+	profileDir := getProfileReplayDir(req.ProfileName)
+	configMu.RUnlock()
+
+	summary, err := importer.Import(importer.Options{
+		SourceDir:     req.SourceDir,
+		ProfileDir:    profileDir,
+		Fatal:         req.Fatal,
+		GamesPerFatal: req.GamesPerFatal,
+		Mode:          importer.Mode(req.Mode),
+		Recursive:     req.Recursive,
+		MatchDetails:  importMatchDetails,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleWatcher reports the profiles archived in the background and the
+// outcome of their last poll. POST polls them now.
+func handleWatcher(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		downloader.PollWatchedNow()
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"profiles":        config.WatchProfiles,
+		"intervalMinutes": config.WatchIntervalMinutes,
+		"status":          downloader.WatcherStatus(),
+	})
+}
+
+// handleProviders reports the replay URL providers and their health.
+func handleProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"order":          downloader.ProviderOrder(),
+		"providers":      downloader.Providers(),
+		"openDotaBudget": openDotaBudget.Status(),
+	})
+}
+
+// handleOpenDotaMatch returns OpenDota's parsed data for a match (players,
+// chat, objectives) to cross-check our own replay parse.
+func handleOpenDotaMatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	matchID, err := strconv.ParseInt(r.URL.Query().Get("matchId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid matchId", http.StatusBadRequest)
+		return
+	}
+
+	client := opendota.NewClient(config.OpenDotaBaseURL, config.OpenDotaAPIKey, openDotaBudget)
+	match, err := client.GetMatch(matchID)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, opendota.ErrNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, opendota.ErrBudgetExhausted) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, fmt.Sprintf("Error fetching match from OpenDota: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matchId":    match.MatchID,
+		"parsed":     match.Parsed(),
+		"players":    match.Players,
+		"chat":       match.Chat,
+		"objectives": match.Objectives,
+	})
+}
+
+// handleLocators exports the replay locator cache (GET) or imports locators
+// shared by someone else (POST with {"locators": [...]}).
+func handleLocators(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("download") == "true" {
+			w.Header().Set("Content-Disposition", `attachment; filename="replay-locators.json"`)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"locators": downloader.ExportLocators(),
+		})
+	case http.MethodPost:
+		var req struct {
+			Locators []downloader.Locator `json:"locators"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		added := downloader.ImportLocators(req.Locators)
+		log.Printf("Imported %d of %d replay locators", added, len(req.Locators))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"imported": added,
+			"total":    len(req.Locators),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type JobActionRequest struct {
+	MatchID int64 `json:"matchId"`
+	// ReplayDir names the job when the match has jobs in several replay
+	// directories.
+	ReplayDir string `json:"replayDir,omitempty"`
+}
+
+// handleJobAction returns a handler that applies action (retry or cancel)
+// to the job named in the request body.
+func handleJobAction(action func(matchID int64, replayDir string) (downloader.Job, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req JobActionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.MatchID == 0 {
+			http.Error(w, "Missing matchId", http.StatusBadRequest)
+			return
+		}
+
+		job, err := action(req.MatchID, req.ReplayDir)
+		if err != nil {
+			if errors.Is(err, downloader.ErrNoJob) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusConflict)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"job":     job,
+		})
+	}
+}
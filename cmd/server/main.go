@@ -1,28 +1,91 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/d3nd3/dota-report-timestamps/pkg/botclient"
+	"github.com/d3nd3/dota-report-timestamps/pkg/conduct"
+	"github.com/d3nd3/dota-report-timestamps/pkg/downloader"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/history"
+	"github.com/d3nd3/dota-report-timestamps/pkg/opendota"
+	"github.com/d3nd3/dota-report-timestamps/pkg/steamapi"
+	"github.com/d3nd3/dota-report-timestamps/pkg/stratz"
+	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
 	ReplayDir      string `json:"replayDir"`
 	StratzAPIToken string `json:"stratzApiToken"`
 	SteamAPIKey    string `json:"steamApiKey"`
+	// OpenDotaAPIKey raises the OpenDota rate limits (optional).
+	OpenDotaAPIKey string `json:"openDotaApiKey"`
 	SteamUser      string `json:"steamUser"`
 	SteamPass      string `json:"steamPass"`
+	// ParserLogLevel is the logrus level for replay parsing ("warning" by default).
+	ParserLogLevel string `json:"parserLogLevel"`
+	// ParserTraceMatchID selects one match whose cursor samples are written
+	// to ~/.dota-report-timestamps/trace_<matchID>.tsv when it is parsed.
+	ParserTraceMatchID int64 `json:"parserTraceMatchId"`
+	// ProviderOrder lists the replay URL providers to try, in order
+	// ("gc", "steam", "stratz", "opendota"). Empty uses the default order.
+	ProviderOrder []string `json:"providerOrder"`
+	// ProviderFailureThreshold consecutive failures take a provider out of
+	// the chain for ProviderCooldownMinutes.
+	ProviderFailureThreshold int `json:"providerFailureThreshold"`
+	ProviderCooldownMinutes  int `json:"providerCooldownMinutes"`
+	// External service URLs. Empty values use the public services; point
+	// them at cmd/fakeproviders to run offline. ReplayCDNHosts entries
+	// contain "{cluster}", e.g. "http://replay{cluster}.valve.net".
+	OpenDotaBaseURL  string   `json:"openDotaBaseUrl"`
+	SteamAPIBaseURL  string   `json:"steamApiBaseUrl"`
+	StratzGraphQLURL string   `json:"stratzGraphqlUrl"`
+	HeroIconBaseURL  string   `json:"heroIconBaseUrl"`
+	ReplayCDNHosts   []string `json:"replayCdnHosts"`
+	// MaxConcurrentDownloads caps parallel replay transfers (default 2) and
+	// DownloadBytesPerSec their combined bandwidth (0 = unlimited).
+	MaxConcurrentDownloads int   `json:"maxConcurrentDownloads"`
+	DownloadBytesPerSec    int64 `json:"downloadBytesPerSec"`
+	// GCMode is "remote" (default) to use the bot process on BotPort, or
+	// "embedded" to run the GC client inside the server, which needs a
+	// server built with -tags embeddedgc. Read at startup only.
+	GCMode  string `json:"gcMode"`
+	BotPort string `json:"botPort"`
+	// WatchProfiles are polled through the GC every WatchIntervalMinutes
+	// (default 30) and their new ranked and Single Draft matches downloaded
+	// before the replays expire. Empty disables the watcher.
+	WatchProfiles        []WatchProfile `json:"watchProfiles"`
+	WatchIntervalMinutes int            `json:"watchIntervalMinutes"`
 }
 
+// WatchProfile is a profile whose new matches are archived automatically.
+type WatchProfile struct {
+	ProfileName string `json:"profileName"`
+	SteamID     string `json:"steamId"`
+}
+
+const defaultHeroIconBaseURL = "https://cdn.cloudflare.steamstatic.com/apps/dota2/images/heroes"
+
 var config Config
-var gcClient *botclient.Client
+var parserLogger = logrus.New()
+var gcClient gc.Service
+var matchHistory = history.NewStore(history.DefaultDir())
+var conductHistory *conduct.Store
+var openDotaBudget = opendota.NewBudget(opendota.Limits(""))
+
+// configMu guards config between handleConfig and the background goroutines
+// reading it, such as the watcher.
+var configMu sync.RWMutex
+
 var downloadLocks sync.Map // Map[int64]*sync.Mutex to prevent concurrent downloads of the same match
 var handlerLocks sync.Map // Map[int64]*sync.Mutex to prevent concurrent handler execution for the same match
 
@@ -38,6 +101,117 @@ func noCacheJS(next http.Handler) http.Handler {
 	})
 }
 
+// setParserLogLevel applies a logrus level name to the parser logger,
+// falling back to warnings only so normal runs stay quiet.
+func setParserLogLevel(name string) {
+	level := logrus.WarnLevel
+	if name != "" {
+		parsed, err := logrus.ParseLevel(name)
+		if err != nil {
+			log.Printf("Invalid parser log level %q, using %s: %v", name, level, err)
+		} else {
+			level = parsed
+		}
+	}
+	parserLogger.SetLevel(level)
+}
+
+// applyProviderConfig passes the replay provider settings to the downloader.
+func applyProviderConfig() {
+	if err := downloader.SetProviderOrder(config.ProviderOrder); err != nil {
+		log.Printf("Invalid replay provider order %v, using default: %v", config.ProviderOrder, err)
+		config.ProviderOrder = nil
+		downloader.SetProviderOrder(nil)
+	}
+	downloader.SetProviderPolicy(config.ProviderFailureThreshold, time.Duration(config.ProviderCooldownMinutes)*time.Minute)
+}
+
+// redactedConfig returns config without the password and API keys, for
+// the config API. The caller holds configMu.
+func redactedConfig() Config {
+	c := config
+	c.SteamPass = ""
+	c.SteamAPIKey = ""
+	c.StratzAPIToken = ""
+	c.OpenDotaAPIKey = ""
+	return c
+}
+
+// forgetPasswordOnceSaved drops the Steam password as soon as the login has
+// saved a session, which later logins use instead.
+func forgetPasswordOnceSaved() {
+	events, _ := gcClient.Subscribe()
+	for ev := range events {
+		if !ev.SessionSaved {
+			continue
+		}
+		configMu.Lock()
+		if config.SteamPass != "" {
+			config.SteamPass = ""
+			log.Printf("Steam session saved, forgetting the password")
+		}
+		configMu.Unlock()
+	}
+}
+
+// watchTargets resolves the watched profiles to their replay directories,
+// skipping entries with an invalid Steam ID.
+func watchTargets() []downloader.WatchTarget {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	var targets []downloader.WatchTarget
+	for _, p := range config.WatchProfiles {
+		steamID, err := strconv.ParseInt(strings.TrimSpace(p.SteamID), 10, 64)
+		if err != nil {
+			log.Printf("Not watching profile %q: invalid Steam ID %q", p.ProfileName, p.SteamID)
+			continue
+		}
+		targets = append(targets, downloader.WatchTarget{
+			Name:      p.ProfileName,
+			SteamID:   steamID,
+			ReplayDir: getProfileReplayDir(p.ProfileName),
+		})
+	}
+	return targets
+}
+
+// newEmbeddedGC runs the GC client in-process. It is set by gc_embedded.go
+// in builds with the embeddedgc tag.
+var newEmbeddedGC func() gc.Service
+
+// newGCService returns the GC client selected by config.GCMode, falling
+// back to the bot process when the embedded client is unavailable.
+func newGCService() gc.Service {
+	switch config.GCMode {
+	case "", "remote":
+	case "embedded":
+		if newEmbeddedGC != nil {
+			log.Printf("Running the Dota 2 GC client in-process")
+			return newEmbeddedGC()
+		}
+		log.Printf("GC_MODE=embedded needs a server built with -tags embeddedgc, using the bot process instead")
+		config.GCMode = "remote"
+	default:
+		log.Printf("Unknown GC mode %q, using the bot process", config.GCMode)
+		config.GCMode = "remote"
+	}
+	if config.BotPort == "" {
+		config.BotPort = "8082"
+	}
+	return botclient.NewClient(config.BotPort)
+}
+
+// applyEndpointConfig points the provider clients at the configured URLs.
+func applyEndpointConfig() {
+	openDotaBudget.SetLimits(opendota.Limits(config.OpenDotaAPIKey))
+	downloader.SetEndpoints(downloader.Endpoints{
+		OpenDota:    opendota.NewClient(config.OpenDotaBaseURL, config.OpenDotaAPIKey, openDotaBudget),
+		ReplayHosts: config.ReplayCDNHosts,
+	})
+	steamapi.SetBaseURL(config.SteamAPIBaseURL)
+	stratz.SetEndpoint(config.StratzGraphQLURL)
+}
+
 func main() {
 	// Default config
 	homeDir, _ := os.UserHomeDir()
@@ -46,24 +220,121 @@ func main() {
 	config.SteamAPIKey = os.Getenv("STEAM_API_KEY")
 	config.SteamUser = os.Getenv("STEAM_USER")
 	config.SteamPass = os.Getenv("STEAM_PASS")
+	config.ParserLogLevel = os.Getenv("PARSER_LOG_LEVEL")
+	if v := os.Getenv("PARSER_TRACE_MATCH_ID"); v != "" {
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+			config.ParserTraceMatchID = id
+		} else {
+			log.Printf("Ignoring invalid PARSER_TRACE_MATCH_ID %q: %v", v, err)
+		}
+	}
+	setParserLogLevel(config.ParserLogLevel)
+	if v := os.Getenv("REPLAY_PROVIDERS"); v != "" {
+		config.ProviderOrder = strings.Split(v, ",")
+	}
+	applyProviderConfig()
+	config.OpenDotaAPIKey = os.Getenv("OPENDOTA_API_KEY")
+	perMinute, perDay := opendota.Limits(config.OpenDotaAPIKey)
+	if b, err := opendota.LoadBudget(opendota.DefaultBudgetPath(), perMinute, perDay); err != nil {
+		log.Printf("Failed to load OpenDota call budget, starting a new one: %v", err)
+	} else {
+		openDotaBudget = b
+	}
+	config.OpenDotaBaseURL = os.Getenv("OPENDOTA_BASE_URL")
+	config.SteamAPIBaseURL = os.Getenv("STEAM_API_BASE_URL")
+	config.StratzGraphQLURL = os.Getenv("STRATZ_GRAPHQL_URL")
+	config.HeroIconBaseURL = os.Getenv("HERO_ICON_BASE_URL")
+	if v := os.Getenv("REPLAY_CDN_HOSTS"); v != "" {
+		config.ReplayCDNHosts = strings.Split(v, ",")
+	}
+	applyEndpointConfig()
+	if v := os.Getenv("MAX_CONCURRENT_DOWNLOADS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.MaxConcurrentDownloads = n
+		} else {
+			log.Printf("Ignoring invalid MAX_CONCURRENT_DOWNLOADS %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("DOWNLOAD_BYTES_PER_SEC"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			config.DownloadBytesPerSec = n
+		} else {
+			log.Printf("Ignoring invalid DOWNLOAD_BYTES_PER_SEC %q: %v", v, err)
+		}
+	}
+	downloader.SetDownloadLimits(config.MaxConcurrentDownloads, config.DownloadBytesPerSec)
 
-	// Initialize Bot Client
-	gcClient = botclient.NewClient("8082")
+	// Initialize the GC client
+	config.GCMode = os.Getenv("GC_MODE")
+	config.BotPort = os.Getenv("BOT_PORT")
+	gcClient = newGCService()
 
-	// If credentials are provided via env, try to init the bot
-	if config.SteamUser != "" && config.SteamPass != "" {
+	// Resume download jobs left over from the previous run
+	if err := downloader.StartJobs(downloader.DefaultJobsPath(), func() downloader.Sources {
+		return downloader.Sources{
+			StratzToken: config.StratzAPIToken,
+			SteamAPIKey: config.SteamAPIKey,
+			GCClient:    gcClient,
+		}
+	}); err != nil {
+		log.Printf("Failed to load download jobs: %v", err)
+	}
+	if err := downloader.LoadLocators(downloader.DefaultLocatorsPath()); err != nil {
+		log.Printf("Failed to load replay locator cache: %v", err)
+	}
+	if err := downloader.LoadMatchDetails(downloader.DefaultMatchDetailsPath()); err != nil {
+		log.Printf("Failed to load match details cache: %v", err)
+	}
+	scorecards, err := conduct.Load(conduct.DefaultPath())
+	if err != nil {
+		log.Printf("Failed to load conduct scorecard history: %v", err)
+	}
+	conductHistory = scorecards
+
+	// WATCH_PROFILES is a comma separated list of profileName:steamID64.
+	if v := os.Getenv("WATCH_PROFILES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			name, steamID, ok := strings.Cut(entry, ":")
+			if !ok {
+				log.Printf("Ignoring invalid WATCH_PROFILES entry %q (want profileName:steamID64)", entry)
+				continue
+			}
+			config.WatchProfiles = append(config.WatchProfiles, WatchProfile{ProfileName: name, SteamID: steamID})
+		}
+	}
+	if v := os.Getenv("WATCH_INTERVAL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.WatchIntervalMinutes = n
+		} else {
+			log.Printf("Ignoring invalid WATCH_INTERVAL_MINUTES %q: %v", v, err)
+		}
+	}
+	downloader.SetWatchInterval(time.Duration(config.WatchIntervalMinutes) * time.Minute)
+	downloader.StartWatcher(watchTargets, gcClient)
+	go forgetPasswordOnceSaved()
+
+	// If credentials are provided via env, try to init the bot. Without
+	// STEAM_PASS this only works once the bot has saved a session.
+	if config.SteamUser != "" {
 		log.Printf("Initializing Dota 2 GC Bot for user: %s", config.SteamUser)
 		// Run in background as bot process might take a moment to start up
 		go func() {
-			// Wait for bot process to be ready (run.sh starts it)
-			for i := 0; i < 10; i++ {
-				if err := gcClient.Init(config.SteamUser, config.SteamPass); err == nil {
-					log.Println("Dota 2 GC Bot initialized successfully via env vars")
-					return
-				}
-				time.Sleep(1 * time.Second)
+			// Wait for bot process to be ready (run.sh starts it): its
+			// event stream delivers the current status once it is up.
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, err := gc.WaitFor(ctx, gcClient); err != nil {
+				log.Println("Failed to auto-initialize bot (bot process might not be ready)")
+				return
+			}
+			configMu.RLock()
+			user, pass := config.SteamUser, config.SteamPass
+			configMu.RUnlock()
+			if err := gcClient.Init(user, pass); err != nil {
+				log.Printf("Failed to auto-initialize bot: %v", err)
+				return
 			}
-			log.Println("Failed to auto-initialize bot (bot process might not be ready)")
+			log.Println("Dota 2 GC Bot initialized successfully via env vars")
 		}()
 	}
 
@@ -78,17 +349,30 @@ func main() {
 	http.HandleFunc("/api/player-info", handlePlayerInfo)
 	http.HandleFunc("/api/parse", handleParse)
 	http.HandleFunc("/api/history", handleHistory)
+	http.HandleFunc("/api/history/status", handleHistoryStatus)
 	http.HandleFunc("/api/download", handleDownload)
 	http.HandleFunc("/api/progress", handleProgress)
+	http.HandleFunc("/api/jobs", handleJobs)
+	http.HandleFunc("/api/watcher", handleWatcher)
+	http.HandleFunc("/api/jobs/retry", handleJobAction(downloader.RetryJob))
+	http.HandleFunc("/api/jobs/cancel", handleJobAction(downloader.CancelJob))
+	http.HandleFunc("/api/providers", handleProviders)
+	http.HandleFunc("/api/locators", handleLocators)
+	http.HandleFunc("/api/match-details", handleMatchDetails)
+	http.HandleFunc("/api/opendota/match", handleOpenDotaMatch)
 	http.HandleFunc("/api/delete", handleDelete)
+	http.HandleFunc("/api/import", handleImport)
 	http.HandleFunc("/api/hero-icon/", handleHeroIcon)
+	http.HandleFunc("/api/heroes", handleHeroes)
 	http.HandleFunc("/api/fatal-search", handleFatalSearch)
 	
 	// Steam GC endpoints
 	http.HandleFunc("/api/steam/login", handleSteamLogin)
 	http.HandleFunc("/api/steam/disconnect", handleSteamDisconnect)
 	http.HandleFunc("/api/steam/status", handleSteamStatus)
+	http.HandleFunc("/api/steam/events", handleSteamEvents)
 	http.HandleFunc("/api/steam/conduct-scorecard", handleConductScorecard)
+	http.HandleFunc("/api/conduct/history", handleConductHistory)
 	http.HandleFunc("/api/steam/validate-report-card", handleValidateReportCard)
 	http.HandleFunc("/api/steam/validate-report-card-current", handleValidateReportCardCurrent)
 
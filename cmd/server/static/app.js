@@ -1,131 +1,43 @@
-const DOTA_HEROES = [
-    { id: 'antimage', name: 'Anti-Mage', localized_name: 'Anti-Mage' },
-    { id: 'axe', name: 'Axe', localized_name: 'Axe' },
-    { id: 'bane', name: 'Bane', localized_name: 'Bane' },
-    { id: 'bloodseeker', name: 'Bloodseeker', localized_name: 'Bloodseeker' },
-    { id: 'crystal_maiden', name: 'Crystal Maiden', localized_name: 'Crystal Maiden' },
-    { id: 'drow_ranger', name: 'Drow Ranger', localized_name: 'Drow Ranger' },
-    { id: 'earthshaker', name: 'Earthshaker', localized_name: 'Earthshaker' },
-    { id: 'juggernaut', name: 'Juggernaut', localized_name: 'Juggernaut' },
-    { id: 'mirana', name: 'Mirana', localized_name: 'Mirana' },
-    { id: 'morphling', name: 'Morphling', localized_name: 'Morphling' },
-    { id: 'nevermore', name: 'Shadow Fiend', localized_name: 'Shadow Fiend' },
-    { id: 'phantom_lancer', name: 'Phantom Lancer', localized_name: 'Phantom Lancer' },
-    { id: 'puck', name: 'Puck', localized_name: 'Puck' },
-    { id: 'pudge', name: 'Pudge', localized_name: 'Pudge' },
-    { id: 'razor', name: 'Razor', localized_name: 'Razor' },
-    { id: 'sand_king', name: 'Sand King', localized_name: 'Sand King' },
-    { id: 'storm_spirit', name: 'Storm Spirit', localized_name: 'Storm Spirit' },
-    { id: 'sven', name: 'Sven', localized_name: 'Sven' },
-    { id: 'tiny', name: 'Tiny', localized_name: 'Tiny' },
-    { id: 'vengefulspirit', name: 'Vengeful Spirit', localized_name: 'Vengeful Spirit' },
-    { id: 'windrunner', name: 'Windrunner', localized_name: 'Windrunner' },
-    { id: 'zeus', name: 'Zeus', localized_name: 'Zeus' },
-    { id: 'kunkka', name: 'Kunkka', localized_name: 'Kunkka' },
-    { id: 'kez', name: 'Kez', localized_name: 'Kez' },
-    { id: 'lina', name: 'Lina', localized_name: 'Lina' },
-    { id: 'lion', name: 'Lion', localized_name: 'Lion' },
-    { id: 'shadow_shaman', name: 'Shadow Shaman', localized_name: 'Shadow Shaman' },
-    { id: 'slardar', name: 'Slardar', localized_name: 'Slardar' },
-    { id: 'tidehunter', name: 'Tidehunter', localized_name: 'Tidehunter' },
-    { id: 'witch_doctor', name: 'Witch Doctor', localized_name: 'Witch Doctor' },
-    { id: 'lich', name: 'Lich', localized_name: 'Lich' },
-    { id: 'riki', name: 'Riki', localized_name: 'Riki' },
-    { id: 'enigma', name: 'Enigma', localized_name: 'Enigma' },
-    { id: 'tinker', name: 'Tinker', localized_name: 'Tinker' },
-    { id: 'sniper', name: 'Sniper', localized_name: 'Sniper' },
-    { id: 'necrolyte', name: 'Necrophos', localized_name: 'Necrophos' },
-    { id: 'warlock', name: 'Warlock', localized_name: 'Warlock' },
-    { id: 'beastmaster', name: 'Beastmaster', localized_name: 'Beastmaster' },
-    { id: 'queenofpain', name: 'Queen of Pain', localized_name: 'Queen of Pain' },
-    { id: 'venomancer', name: 'Venomancer', localized_name: 'Venomancer' },
-    { id: 'faceless_void', name: 'Faceless Void', localized_name: 'Faceless Void' },
-    { id: 'skeleton_king', name: 'Wraith King', localized_name: 'Wraith King' },
-    { id: 'death_prophet', name: 'Death Prophet', localized_name: 'Death Prophet' },
-    { id: 'phantom_assassin', name: 'Phantom Assassin', localized_name: 'Phantom Assassin' },
-    { id: 'pugna', name: 'Pugna', localized_name: 'Pugna' },
-    { id: 'templar_assassin', name: 'Templar Assassin', localized_name: 'Templar Assassin' },
-    { id: 'viper', name: 'Viper', localized_name: 'Viper' },
-    { id: 'luna', name: 'Luna', localized_name: 'Luna' },
-    { id: 'dragon_knight', name: 'Dragon Knight', localized_name: 'Dragon Knight' },
-    { id: 'dazzle', name: 'Dazzle', localized_name: 'Dazzle' },
-    { id: 'rattletrap', name: 'Clockwerk', localized_name: 'Clockwerk' },
-    { id: 'leshrac', name: 'Leshrac', localized_name: 'Leshrac' },
-    { id: 'furion', name: 'Nature\'s Prophet', localized_name: 'Nature\'s Prophet' },
-    { id: 'life_stealer', name: 'Lifestealer', localized_name: 'Lifestealer' },
-    { id: 'dark_seer', name: 'Dark Seer', localized_name: 'Dark Seer' },
-    { id: 'clinkz', name: 'Clinkz', localized_name: 'Clinkz' },
-    { id: 'omniknight', name: 'Omniknight', localized_name: 'Omniknight' },
-    { id: 'enchantress', name: 'Enchantress', localized_name: 'Enchantress' },
-    { id: 'huskar', name: 'Huskar', localized_name: 'Huskar' },
-    { id: 'night_stalker', name: 'Night Stalker', localized_name: 'Night Stalker' },
-    { id: 'broodmother', name: 'Broodmother', localized_name: 'Broodmother' },
-    { id: 'bounty_hunter', name: 'Bounty Hunter', localized_name: 'Bounty Hunter' },
-    { id: 'weaver', name: 'Weaver', localized_name: 'Weaver' },
-    { id: 'jakiro', name: 'Jakiro', localized_name: 'Jakiro' },
-    { id: 'batrider', name: 'Batrider', localized_name: 'Batrider' },
-    { id: 'chen', name: 'Chen', localized_name: 'Chen' },
-    { id: 'spectre', name: 'Spectre', localized_name: 'Spectre' },
-    { id: 'ancient_apparition', name: 'Ancient Apparition', localized_name: 'Ancient Apparition' },
-    { id: 'doom_bringer', name: 'Doom', localized_name: 'Doom' },
-    { id: 'ursa', name: 'Ursa', localized_name: 'Ursa' },
-    { id: 'spirit_breaker', name: 'Spirit Breaker', localized_name: 'Spirit Breaker' },
-    { id: 'gyrocopter', name: 'Gyrocopter', localized_name: 'Gyrocopter' },
-    { id: 'alchemist', name: 'Alchemist', localized_name: 'Alchemist' },
-    { id: 'invoker', name: 'Invoker', localized_name: 'Invoker' },
-    { id: 'silencer', name: 'Silencer', localized_name: 'Silencer' },
-    { id: 'obsidian_destroyer', name: 'Outworld Devourer', localized_name: 'Outworld Devourer' },
-    { id: 'lycan', name: 'Lycan', localized_name: 'Lycan' },
-    { id: 'brewmaster', name: 'Brewmaster', localized_name: 'Brewmaster' },
-    { id: 'shadow_demon', name: 'Shadow Demon', localized_name: 'Shadow Demon' },
-    { id: 'lone_druid', name: 'Lone Druid', localized_name: 'Lone Druid' },
-    { id: 'chaos_knight', name: 'Chaos Knight', localized_name: 'Chaos Knight' },
-    { id: 'meepo', name: 'Meepo', localized_name: 'Meepo' },
-    { id: 'treant', name: 'Treant Protector', localized_name: 'Treant Protector' },
-    { id: 'ogre_magi', name: 'Ogre Magi', localized_name: 'Ogre Magi' },
-    { id: 'undying', name: 'Undying', localized_name: 'Undying' },
-    { id: 'rubick', name: 'Rubick', localized_name: 'Rubick' },
-    { id: 'disruptor', name: 'Disruptor', localized_name: 'Disruptor' },
-    { id: 'nyx_assassin', name: 'Nyx Assassin', localized_name: 'Nyx Assassin' },
-    { id: 'naga_siren', name: 'Naga Siren', localized_name: 'Naga Siren' },
-    { id: 'keeper_of_the_light', name: 'Keeper of the Light', localized_name: 'Keeper of the Light' },
-    { id: 'wisp', name: 'Io', localized_name: 'Io' },
-    { id: 'visage', name: 'Visage', localized_name: 'Visage' },
-    { id: 'slark', name: 'Slark', localized_name: 'Slark' },
-    { id: 'medusa', name: 'Medusa', localized_name: 'Medusa' },
-    { id: 'troll_warlord', name: 'Troll Warlord', localized_name: 'Troll Warlord' },
-    { id: 'centaur', name: 'Centaur Warrunner', localized_name: 'Centaur Warrunner' },
-    { id: 'magnataur', name: 'Magnus', localized_name: 'Magnus' },
-    { id: 'shredder', name: 'Timbersaw', localized_name: 'Timbersaw' },
-    { id: 'bristleback', name: 'Bristleback', localized_name: 'Bristleback' },
-    { id: 'tusk', name: 'Tusk', localized_name: 'Tusk' },
-    { id: 'skywrath_mage', name: 'Skywrath Mage', localized_name: 'Skywrath Mage' },
-    { id: 'abaddon', name: 'Abaddon', localized_name: 'Abaddon' },
-    { id: 'elder_titan', name: 'Elder Titan', localized_name: 'Elder Titan' },
-    { id: 'legion_commander', name: 'Legion Commander', localized_name: 'Legion Commander' },
-    { id: 'techies', name: 'Techies', localized_name: 'Techies' },
-    { id: 'ember_spirit', name: 'Ember Spirit', localized_name: 'Ember Spirit' },
-    { id: 'earth_spirit', name: 'Earth Spirit', localized_name: 'Earth Spirit' },
-    { id: 'abyssal_underlord', name: 'Underlord', localized_name: 'Underlord' },
-    { id: 'terrorblade', name: 'Terrorblade', localized_name: 'Terrorblade' },
-    { id: 'phoenix', name: 'Phoenix', localized_name: 'Phoenix' },
-    { id: 'oracle', name: 'Oracle', localized_name: 'Oracle' },
-    { id: 'winter_wyvern', name: 'Winter Wyvern', localized_name: 'Winter Wyvern' },
-    { id: 'arc_warden', name: 'Arc Warden', localized_name: 'Arc Warden' },
-    { id: 'monkey_king', name: 'Monkey King', localized_name: 'Monkey King' },
-    { id: 'dark_willow', name: 'Dark Willow', localized_name: 'Dark Willow' },
-    { id: 'pangolier', name: 'Pangolier', localized_name: 'Pangolier' },
-    { id: 'grimstroke', name: 'Grimstroke', localized_name: 'Grimstroke' },
-    { id: 'hoodwink', name: 'Hoodwink', localized_name: 'Hoodwink' },
-    { id: 'void_spirit', name: 'Void Spirit', localized_name: 'Void Spirit' },
-    { id: 'snapfire', name: 'Snapfire', localized_name: 'Snapfire' },
-    { id: 'mars', name: 'Mars', localized_name: 'Mars' },
-    { id: 'dawnbreaker', name: 'Dawnbreaker', localized_name: 'Dawnbreaker' },
-    { id: 'marci', name: 'Marci', localized_name: 'Marci' },
-    { id: 'primal_beast', name: 'Primal Beast', localized_name: 'Primal Beast' },
-    { id: 'muerta', name: 'Muerta', localized_name: 'Muerta' },
-    { id: 'ringmaster', name: 'Ringmaster', localized_name: 'Ringmaster' }
-];
+// Hero identity table, loaded from /api/heroes (generated from pkg/heroes/heroes.csv).
+const heroesByName = new Map();
+const heroesById = new Map();
+
+function normalizeHeroKey(name) {
+    return String(name)
+        .replace(/^CDOTA_Unit_Hero_/, '')
+        .toLowerCase()
+        .replace(/^npc_dota_hero_/, '')
+        .replace(/[^a-z0-9]/g, '');
+}
+
+const heroesLoaded = fetch('/api/heroes')
+    .then(res => res.ok ? res.json() : [])
+    .then(list => {
+        for (const hero of list) {
+            heroesById.set(hero.id, hero);
+            for (const key of [hero.name, hero.className, hero.localizedName]) {
+                const normalized = normalizeHeroKey(key);
+                if (!heroesByName.has(normalized)) {
+                    heroesByName.set(normalized, hero);
+                }
+            }
+        }
+    })
+    .catch(err => console.warn('Failed to load hero table:', err));
+
+function findHero(heroName) {
+    if (!heroName) return null;
+    return heroesByName.get(normalizeHeroKey(heroName)) || null;
+}
+
+function findHeroById(id) {
+    return heroesById.get(id) || null;
+}
+
+function getHeroDisplayName(heroName) {
+    const hero = findHero(heroName);
+    return hero ? hero.localizedName : (heroName || '');
+}
 
     function convertSteamIDTo64(steamID) {
         if (!steamID) return null;
@@ -175,35 +87,9 @@ const DOTA_HEROES = [
 
     function getHeroIconUrl(heroName) {
     if (!heroName) return null;
-    
-    const normalizeHeroName = (name) => {
-        return name
-            .replace(/([a-z])([A-Z])/g, '$1_$2')
-            .toLowerCase()
-            .replace(/\s+/g, '_');
-    };
-    
-    const heroNameVariations = {
-        'zuus': 'zeus',
-        'zeus': 'zuus'
-    };
-    
-    let heroNameNormalized = normalizeHeroName(heroName);
-    if (heroNameVariations[heroNameNormalized]) {
-        heroNameNormalized = heroNameVariations[heroNameNormalized];
-    }
-    
-    const hero = DOTA_HEROES.find(h => 
-        h.id === heroNameNormalized || 
-        normalizeHeroName(h.name) === heroNameNormalized ||
-        normalizeHeroName(h.localized_name) === heroNameNormalized
-    );
-    if (!hero) {
-        console.warn(`Hero not found: ${heroName} (normalized: ${heroNameNormalized})`);
-        return null;
-    }
-    const heroId = hero.id;
-    return `/api/hero-icon/${heroId}`;
+    // The server resolves any hero name (internal, class or localized) to its icon.
+    const hero = findHero(heroName);
+    return `/api/hero-icon/${encodeURIComponent(hero ? hero.name : heroName)}`;
 }
 
 // Retry utility with exponential backoff
@@ -332,8 +218,11 @@ document.addEventListener('DOMContentLoaded', () => {
                 option.value = player.Slot;
                 const teamName = player.Team === 2 ? 'Radiant' : player.Team === 3 ? 'Dire' : 'Unknown';
                 const playerName = player.Name || '(Empty)';
-                const heroName = player.Hero || '';
+                const heroName = player.HeroName || getHeroDisplayName(player.Hero);
                 let displayText = `Slot ${player.Slot} [${teamName}] - ${playerName}`;
+                if (player.IsBot) {
+                    displayText += ' [Bot]';
+                }
                 if (heroName) {
                     displayText += ` (${heroName})`;
                 }
@@ -511,6 +400,14 @@ document.addEventListener('DOMContentLoaded', () => {
 
     // Steam Logic
     let steamPollingInterval;
+    // True while /api/steam/events is pushing status changes; polling is
+    // only the fallback for when the stream is down.
+    let steamEventsOpen = false;
+
+    function schedulePoll(ms) {
+        if (steamPollingInterval) clearInterval(steamPollingInterval);
+        steamPollingInterval = steamEventsOpen ? null : setInterval(pollSteamStatus, ms);
+    }
     let connectingTimeoutId = null;
     let submittingTimeoutId = null;
 
@@ -559,8 +456,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 steamDisconnectBtn.style.display = 'none';
                 lastSteamStatus = 1;
             }
-            if (steamPollingInterval) clearInterval(steamPollingInterval);
-            steamPollingInterval = setInterval(pollSteamStatus, 2000);
+            schedulePoll(2000);
             
             connectingTimeoutId = setTimeout(() => {
                 if (steamLoginBtn.disabled && steamLoginBtn.textContent === 'Connecting...') {
@@ -590,8 +486,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 steamDisconnectBtn.style.display = 'none';
                 lastSteamStatus = 2;
             }
-            if (steamPollingInterval) clearInterval(steamPollingInterval);
-            steamPollingInterval = setInterval(pollSteamStatus, 2000);
+            schedulePoll(2000);
         } else if (status === 3 || status === 4) {
              if (lastSteamStatus !== status) {
                  steamStatusText.style.color = '#4caf50';
@@ -604,8 +499,7 @@ document.addEventListener('DOMContentLoaded', () => {
                      fetchConductScorecard();
                  }
              }
-             if (steamPollingInterval) clearInterval(steamPollingInterval);
-             steamPollingInterval = setInterval(pollSteamStatus, 10000);
+             schedulePoll(10000);
         } else if (status === 5) { // Rate Limited
              if (lastSteamStatus !== 5) {
                  steamStatusText.style.color = '#f44336';
@@ -616,8 +510,7 @@ document.addEventListener('DOMContentLoaded', () => {
                  steamDisconnectBtn.style.display = 'inline-block';
                  lastSteamStatus = 5;
              }
-             if (steamPollingInterval) clearInterval(steamPollingInterval);
-             steamPollingInterval = setInterval(pollSteamStatus, 60000);
+             schedulePoll(60000);
         } else {
             if (lastSteamStatus !== status) {
                 steamStatusText.style.color = '';
@@ -629,8 +522,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 document.getElementById('conduct-scorecard-section').classList.add('hidden');
                 lastSteamStatus = status;
             }
-            if (steamPollingInterval) clearInterval(steamPollingInterval);
-            steamPollingInterval = setInterval(pollSteamStatus, 2000);
+            schedulePoll(2000);
         }
     }
 
@@ -803,6 +695,8 @@ document.addEventListener('DOMContentLoaded', () => {
             `;
         }
         
+        html += '<div id="conduct-history" class="conduct-history-card"></div>';
+        
         html += `
             <div class="conduct-theories-card">
                 <h3>Why counts might not match analysis:</h3>
@@ -827,6 +721,10 @@ document.addEventListener('DOMContentLoaded', () => {
             });
         }
         
+        if (data.account_id) {
+            fetchConductHistory(data.account_id);
+        }
+        
         const validateCurrentBtn = document.getElementById('validate-report-card-current-btn');
         if (validateCurrentBtn && !validateCurrentBtn.hasAttribute('data-listener-attached')) {
             validateCurrentBtn.setAttribute('data-listener-attached', 'true');
@@ -838,6 +736,58 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    // fetchConductHistory asks for the scorecards that arrived since the
+    // last one shown, so a new scorecard stays flagged until it was seen.
+    function fetchConductHistory(accountId) {
+        const seenKey = `conductSeen-${accountId}`;
+        const seen = localStorage.getItem(seenKey);
+        fetch(`/api/conduct/history?accountId=${accountId}${seen ? `&since=${seen}` : ''}`)
+            .then(res => {
+                if (!res.ok) {
+                    throw new Error(`HTTP ${res.status}: ${res.statusText}`);
+                }
+                return res.json();
+            })
+            .then(data => {
+                displayConductHistory(data);
+                const arrived = Date.parse(data.lastArrived);
+                if (arrived > 0) {
+                    localStorage.setItem(seenKey, String(Math.ceil(arrived / 1000)));
+                }
+            })
+            .catch(err => console.error('Error fetching conduct history:', err));
+    }
+
+    function displayConductHistory(data) {
+        const container = document.getElementById('conduct-history');
+        if (!container) return;
+        const scorecards = data.scorecards || [];
+        if (scorecards.length === 0) {
+            container.innerHTML = '';
+            return;
+        }
+        
+        let html = `<h3>Scorecard History${data.newScorecard ? ' <span class="conduct-new-badge">New scorecard</span>' : ''}</h3>`;
+        html += '<table class="conduct-history-table"><thead><tr><th>Date</th><th>Match ID</th><th>Score</th><th>Change</th><th>Reports</th><th>Commends</th></tr></thead><tbody>';
+        scorecards.slice().reverse().forEach(sc => {
+            const change = sc.score_change || 0;
+            const changeClass = change < 0 ? 'negative' : (change > 0 ? 'positive' : '');
+            const date = sc.date ? new Date(sc.date * 1000).toLocaleDateString() : 'N/A';
+            html += `
+                <tr>
+                    <td>${date}</td>
+                    <td class="conduct-match-id" title="${(sc.match_ids || []).join(', ')}">${sc.match_id || 'N/A'}</td>
+                    <td>${(sc.raw_behavior_score || 0).toLocaleString()}</td>
+                    <td class="conduct-history-change ${changeClass}">${change > 0 ? '+' : ''}${change.toLocaleString()}</td>
+                    <td>${sc.reports_count || 0}</td>
+                    <td>${sc.commend_count || 0}</td>
+                </tr>
+            `;
+        });
+        html += '</tbody></table>';
+        container.innerHTML = html;
+    }
+
     let validateReportCardInProgress = false;
 
     function validateReportCard(matchId, accountId, isCurrent) {
@@ -934,9 +884,25 @@ document.addEventListener('DOMContentLoaded', () => {
             });
     }
 
-    // Start polling on load
+    // Follow status changes as they are pushed, polling while the stream is down
     pollSteamStatus();
-    steamPollingInterval = setInterval(pollSteamStatus, 2000);
+    schedulePoll(2000);
+    if (window.EventSource) {
+        const steamEvents = new EventSource('/api/steam/events');
+        steamEvents.onopen = () => {
+            steamEventsOpen = true;
+            schedulePoll(0);
+        };
+        steamEvents.addEventListener('status', e => {
+            const data = JSON.parse(e.data);
+            updateSteamUI(data.status, data.statusText, data.reason);
+        });
+        steamEvents.onerror = () => {
+            // EventSource reconnects by itself; poll until it does
+            steamEventsOpen = false;
+            schedulePoll(2000);
+        };
+    }
 
     if (steamLoginBtn) {
         steamLoginBtn.addEventListener('click', () => {
@@ -945,8 +911,9 @@ document.addEventListener('DOMContentLoaded', () => {
         const isSubmittingCode = steamLoginBtn.textContent === 'Submit Code';
         const code = isSubmittingCode ? steamCodeInput.value.trim() : '';
 
-        if (!username || !password) {
-            alert('Please enter both username and password');
+        // The password can be left empty once the bot has saved a session
+        if (!username) {
+            alert('Please enter your username');
             return;
         }
 
@@ -1034,6 +1001,7 @@ document.addEventListener('DOMContentLoaded', () => {
             if (!confirm('Are you sure you want to disconnect from Steam?')) {
             return;
         }
+        const forgetSession = confirm('Also forget the saved session? The next login will need your password again.');
 
         const originalText = steamDisconnectBtn.textContent;
         steamDisconnectBtn.disabled = true;
@@ -1041,7 +1009,8 @@ document.addEventListener('DOMContentLoaded', () => {
 
         fetchWithRetry('/api/steam/disconnect', {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' }
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ forgetSession })
         }, 2, 1000)
         .then(async res => {
             if (!res.ok) {
@@ -1070,12 +1039,12 @@ document.addEventListener('DOMContentLoaded', () => {
     function loadFromStorage() {
         const savedReplayDir = localStorage.getItem('replayDir');
         const savedSteamUser = localStorage.getItem('steamUser');
-        const savedSteamPass = localStorage.getItem('steamPass');
         const savedSteamId = localStorage.getItem('steamId');
         
         if (savedReplayDir) replayDirInput.value = savedReplayDir;
         if (savedSteamUser) steamUserInput.value = savedSteamUser;
-        if (savedSteamPass) steamPassInput.value = savedSteamPass;
+        // The password is not kept in the browser; older versions did.
+        localStorage.removeItem('steamPass');
         if (savedSteamId) steamIdInput.value = savedSteamId;
     }
 
@@ -1083,7 +1052,6 @@ document.addEventListener('DOMContentLoaded', () => {
     function saveToStorage() {
         if (replayDirInput.value) localStorage.setItem('replayDir', replayDirInput.value);
         if (steamUserInput.value) localStorage.setItem('steamUser', steamUserInput.value);
-        if (steamPassInput.value) localStorage.setItem('steamPass', steamPassInput.value);
         if (steamIdInput.value) localStorage.setItem('steamId', steamIdInput.value);
     }
 
@@ -1100,11 +1068,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 steamUserInput.value = config.steamUser;
                 localStorage.setItem('steamUser', config.steamUser);
             }
-            
-            if (config.steamPass && config.steamPass.trim() !== '') {
-                steamPassInput.value = config.steamPass;
-                localStorage.setItem('steamPass', config.steamPass);
-            }
+
             browseDirectory(currentPath);
         })
         .catch(() => {
@@ -1119,8 +1083,7 @@ document.addEventListener('DOMContentLoaded', () => {
         
         if (replayDir) localStorage.setItem('replayDir', replayDir);
         if (steamUser) localStorage.setItem('steamUser', steamUser);
-        if (steamPass) localStorage.setItem('steamPass', steamPass);
-        
+
         clearTimeout(saveTokenTimeout);
         saveTokenTimeout = setTimeout(() => {
             const payload = {};
@@ -1180,10 +1143,6 @@ document.addEventListener('DOMContentLoaded', () => {
                     localStorage.setItem('steamUser', config.steamUser);
                     steamUserInput.value = config.steamUser;
                 }
-                if (config.steamPass) {
-                    localStorage.setItem('steamPass', config.steamPass);
-                    steamPassInput.value = config.steamPass;
-                }
                 alert('Configuration saved!');
                 browseDirectory(currentPath);
             })
@@ -2380,7 +2339,7 @@ document.addEventListener('DOMContentLoaded', () => {
             if (hoveredIcon) {
                 const timestamp = hoveredIcon.report.Time;
                 const heroName = hoveredIcon.report.TargetHero || hoveredIcon.report.Hero;
-                tooltip.textContent = `${heroName || 'Unknown'}: ${timestamp}`;
+                tooltip.textContent = `${getHeroDisplayName(heroName) || 'Unknown'}: ${timestamp}`;
                 tooltip.style.visibility = 'hidden';
                 tooltip.classList.remove('hidden');
                 
@@ -59,6 +59,19 @@ async function waitForSteamConnection(maxWait = 30000, checkInterval = 1000) {
     return false;
 }
 
+// showHistoryNotice warns in el when the server answered from a stored match
+// history it could not sync, or one that may not reach back far enough.
+function showHistoryNotice(el, res) {
+    if (!el) return;
+    const stale = res && res.headers.get('X-History-Stale');
+    const incomplete = res && res.headers.get('X-History-Incomplete');
+    const notes = [];
+    if (stale) notes.push(`Showing stored matches, the GC could not be reached (${stale}).`);
+    if (incomplete) notes.push(`The match history is still being synced, older matches may be missing (${incomplete}).`);
+    el.textContent = notes.join(' ');
+    el.classList.toggle('hidden', notes.length === 0);
+}
+
 // History Logic
 document.addEventListener('DOMContentLoaded', () => {
     const historySteamIdInput = document.getElementById('history-steam-id');
@@ -66,6 +79,7 @@ document.addEventListener('DOMContentLoaded', () => {
     const historyTurboOnlyCheckbox = document.getElementById('history-turbo-only');
     const fetchHistoryBtn = document.getElementById('fetch-history');
     const historyResults = document.getElementById('history-results');
+    const historyNotice = document.getElementById('history-notice');
 
     function getSelectedProfileName() {
         const profileSelect = document.getElementById('profile-select');
@@ -144,10 +158,14 @@ document.addEventListener('DOMContentLoaded', () => {
         }
         
         historyResults.innerHTML = '<p class="loading">Fetching match history...</p>';
+        showHistoryNotice(historyNotice, null);
         
         const turboParam = turboOnly ? '&turboOnly=true' : '';
         fetchWithRetry(`/api/history?steamId=${steamId}&limit=${limit}${turboParam}`, {}, 3, 1000)
-            .then(res => res.json())
+            .then(res => {
+                showHistoryNotice(historyNotice, res);
+                return res.json();
+            })
             .then(matches => {
                 renderHistory(matches);
                 // Check existence after rendering, then auto-download
@@ -160,6 +178,94 @@ document.addEventListener('DOMContentLoaded', () => {
             });
     });
 
+    // accountIdOf turns a SteamID64 (or a 32-bit account ID) into the
+    // account ID the GC uses in match details.
+    function accountIdOf(steamId) {
+        try {
+            const id = BigInt(String(steamId).trim());
+            const base = BigInt('76561197960265728');
+            return Number(id >= base ? id - base : id);
+        } catch (e) {
+            return null;
+        }
+    }
+
+    // detailsRow shows the heroes and the result of a match from its GC
+    // match details, with the player's own hero highlighted.
+    function detailsRow(m, accountId) {
+        const d = m.details;
+        if (!d || !d.players) {
+            return `<div class="match-details" id="details-${m.id}"></div>`;
+        }
+        const me = d.players.find(p => p.accountId === accountId);
+        let result = '';
+        if (me && d.winner) {
+            const won = (d.winner === 'radiant') === (me.team === 2);
+            result = `<span class="match-result ${won ? 'won' : 'lost'}">${won ? 'Won' : 'Lost'}</span>`;
+        } else if (d.winner) {
+            result = `<span class="match-result">${d.winner === 'radiant' ? 'Radiant' : 'Dire'} Victory</span>`;
+        }
+        const duration = d.duration ? `<span class="match-duration">${Math.floor(d.duration / 60)}:${String(d.duration % 60).padStart(2, '0')}</span>` : '';
+        const team = teamNumber => d.players
+            .filter(p => p.team === teamNumber)
+            .sort((a, b) => a.slot - b.slot)
+            .map(p => {
+                const hero = findHeroById(p.heroId);
+                const name = hero ? hero.localizedName : `Hero ${p.heroId}`;
+                const title = `${name}${p.name ? ' - ' + p.name : ''} (${p.kills}/${p.deaths}/${p.assists})`;
+                const cls = me && p === me ? 'details-hero me' : 'details-hero';
+                return hero
+                    ? `<img class="${cls}" src="/api/hero-icon/${encodeURIComponent(hero.name)}" alt="${name}" title="${title}">`
+                    : `<span class="${cls}" title="${title}">?</span>`;
+            }).join('');
+        return `
+            <div class="match-details" id="details-${m.id}">
+                ${result}${duration}
+                <span class="details-team radiant">${team(2)}</span>
+                <span class="details-vs">vs</span>
+                <span class="details-team dire">${team(3)}</span>
+            </div>`;
+    }
+
+    // fillMatchDetails loads the details missing from the history one match
+    // at a time; the server caches them, so each is only fetched once.
+    async function fillMatchDetails(matches, accountId) {
+        await heroesLoaded;
+        for (const m of matches) {
+            if (m.details) continue;
+            try {
+                const res = await fetch(`/api/match-details?matchId=${m.id}`);
+                if (!res.ok) {
+                    // Stop while the GC is unavailable; other errors only
+                    // concern this match.
+                    if (res.status === 503 || res.status === 429) return;
+                    continue;
+                }
+                m.details = await res.json();
+            } catch (err) {
+                console.warn(`Failed to load details of match ${m.id}:`, err);
+                return;
+            }
+            const row = document.getElementById(`details-${m.id}`);
+            if (row) row.outerHTML = detailsRow(m, accountId);
+        }
+    }
+
+    // expiryLabel describes how long the match's replay should stay on
+    // Valve's CDN, using the server's estimate from the match start time.
+    function expiryLabel(m) {
+        const e = m.expiry;
+        if (!e || e.availability === 'unknown') return '';
+        if (e.availability === 'likely_expired') {
+            return '<span class="expiry-label" style="margin-left: 8px; color: #ef4444; font-size: 0.85em;">Replay likely expired</span>';
+        }
+        if (e.availability === 'expiring') {
+            return '<span class="expiry-label" style="margin-left: 8px; color: #f59e0b; font-size: 0.85em;">Replay may expire any time</span>';
+        }
+        const days = Math.max(0, Math.floor((new Date(e.expiresAt) - Date.now()) / 86400000));
+        return `<span class="expiry-label" style="margin-left: 8px; color: #94a3b8; font-size: 0.85em;">Replay available for ${days}+ days</span>`;
+    }
+
     function renderHistory(matches) {
         if (!matches || matches.length === 0) {
             historyResults.innerHTML = '<p>No matches found.</p>';
@@ -167,6 +273,7 @@ document.addEventListener('DOMContentLoaded', () => {
         }
         
         const profileName = getSelectedProfileName();
+        const accountId = accountIdOf(historySteamIdInput.value);
         const url = '/api/replays?t=' + Date.now() + (profileName ? '&profile=' + encodeURIComponent(profileName) : '');
         fetchWithRetry(url, {}, 2, 1000)
             .then(res => res.json())
@@ -179,7 +286,9 @@ document.addEventListener('DOMContentLoaded', () => {
                         <li class="history-item" id="history-match-${m.id}">
                             <div class="match-row">
                                 <span class="match-id">Match ${m.id}</span>
+                                ${expiryLabel(m)}
                             </div>
+                            ${detailsRow(m, accountId)}
                     <div class="match-actions" style="margin-top: 8px; display: flex; justify-content: space-between; align-items: center;">
                         <span class="status-pill status-pending" id="status-${m.id}" style="visibility: hidden; min-width: 90px; text-align: center;">Checking...</span>
                         <button class="small-btn download-btn" data-match="${m.id}" style="min-width: 130px;">Download Replay</button>
@@ -198,6 +307,7 @@ document.addEventListener('DOMContentLoaded', () => {
                     downloadReplay(matchId, e.target);
                 });
             });
+            fillMatchDetails(matches, accountId);
         })
         .catch(err => {
             console.error('Error fetching replays:', err);
@@ -206,7 +316,9 @@ document.addEventListener('DOMContentLoaded', () => {
                     <li class="history-item" id="history-match-${m.id}">
                         <div class="match-row">
                             <span class="match-id">Match ${m.id}</span>
+                            ${expiryLabel(m)}
                         </div>
+                        ${detailsRow(m, accountId)}
                         <div class="match-actions" style="margin-top: 8px; display: flex; justify-content: space-between; align-items: center;">
                             <span class="status-pill status-pending" id="status-${m.id}" style="visibility: hidden; min-width: 90px; text-align: center;">Checking...</span>
                             <button class="small-btn download-btn" data-match="${m.id}" style="min-width: 130px;">Download Replay</button>
@@ -224,6 +336,7 @@ document.addEventListener('DOMContentLoaded', () => {
                     downloadReplay(matchId, e.target);
                 });
             });
+            fillMatchDetails(matches, accountId);
         });
     }
     
@@ -388,6 +501,12 @@ document.addEventListener('DOMContentLoaded', () => {
                     }
                 }
             };
+            eventSource.addEventListener('queue', (event) => {
+                const position = parseInt(event.data);
+                if (status) {
+                    status.textContent = position > 0 ? `Queued (#${position})` : 'Downloading...';
+                }
+            });
             
             eventSource.onerror = () => {
                 eventSource.close();
@@ -490,6 +609,7 @@ document.addEventListener('DOMContentLoaded', () => {
     const fatalMaxDepthInput = document.getElementById('fatal-max-depth');
     const findFatalGamesBtn = document.getElementById('find-fatal-games');
     const fatalResults = document.getElementById('fatal-results');
+    const fatalNotice = document.getElementById('fatal-notice');
 
     // Auto-fill Steam ID from selected profile (handled in app.js profile select change handler)
     // Initial update on page load
@@ -575,6 +695,7 @@ document.addEventListener('DOMContentLoaded', () => {
             if (fatalResults) {
                 fatalResults.innerHTML = '<p class="loading">Searching for fatal games...</p>';
             }
+            showHistoryNotice(fatalNotice, null);
 
             fetchWithRetry('/api/fatal-search', {
                 method: 'POST',
@@ -591,6 +712,7 @@ document.addEventListener('DOMContentLoaded', () => {
                     const text = await res.text();
                     throw new Error(text || 'Failed to search fatal games');
                 }
+                showHistoryNotice(fatalNotice, res);
                 return res.json();
             })
             .then(data => {
@@ -657,7 +779,7 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
-    function downloadFatalReplay(matchData, profileName, btn) {
+    function downloadFatalReplay(matchData, profileName, btn, priority) {
         const matchId = matchData.matchId;
         const progressDiv = document.getElementById(`progress-${matchId}`);
         const progressText = progressDiv ? progressDiv.querySelector('.progress-text') : null;
@@ -693,9 +815,29 @@ document.addEventListener('DOMContentLoaded', () => {
             payload.additionalMatchIds = matchData.additionalMatchIds;
         }
 
+        if (priority) {
+            payload.priority = priority;
+        }
+
+        // Show the queue position while the server waits for a download slot
+        let queuePosition = 0;
+        const queueSource = new EventSource(`/api/progress?matchId=${matchId}`);
+        queueSource.addEventListener('queue', (event) => {
+            queuePosition = parseInt(event.data) || 0;
+            if (queuePosition > 0 && progressText) {
+                progressText.textContent = `Queued (#${queuePosition})...`;
+            }
+        });
+        queueSource.onerror = () => {
+            queueSource.close();
+        };
+
         const startTime = Date.now();
         let progressInterval;
         const updateProgress = () => {
+            if (queuePosition > 0) {
+                return;
+            }
             if (progressText) {
                 const elapsed = Math.floor((Date.now() - startTime) / 1000);
                 if (elapsed < 5) {
@@ -716,6 +858,7 @@ document.addEventListener('DOMContentLoaded', () => {
         }, 3, 2000)
         .then(async res => {
             if (progressInterval) clearInterval(progressInterval);
+            queueSource.close();
             let data;
             const contentType = res.headers.get('content-type');
             if (contentType && contentType.includes('application/json')) {
@@ -778,6 +921,7 @@ document.addEventListener('DOMContentLoaded', () => {
         })
         .catch(err => {
             clearInterval(progressInterval);
+            queueSource.close();
             if (progressText) {
                 progressText.textContent = '✗ Failed: ' + err.message;
                 progressText.style.color = '#ef4444';
@@ -814,7 +958,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 const steamId = fatalSteamIdInput ? fatalSteamIdInput.value.trim() : '';
                 const matchData = { matchId, singleDraftId, singleDraftDate, gamesPerFatal, steamId, additionalMatchIds };
                 
-                downloadFatalReplay(matchData, profileName, null)
+                downloadFatalReplay(matchData, profileName, null, 'bulk')
                 .then(() => {
                     completed++;
                     if (downloadAllBtn) {
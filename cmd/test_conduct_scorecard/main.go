@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -75,7 +76,7 @@ func main() {
 
 func runConductScorecardTest(client *dota2gc.Client) {
 	fmt.Println("\nFetching conduct scorecard...")
-	scorecard, err := client.GetPlayerConductScorecard()
+	scorecard, err := client.GetPlayerConductScorecard(context.Background())
 	if err != nil {
 		log.Fatalf("Error fetching conduct scorecard: %v", err)
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -103,7 +104,7 @@ func runMatchHistoryTest(client *dota2gc.Client, reader *bufio.Reader) {
 		}
 
 		fmt.Printf("Fetching history for %d...\n", steamID)
-		matches, err := client.GetPlayerMatchHistory(steamID, 10, false)
+		matches, err := client.GetPlayerMatchHistory(context.Background(), steamID, 10, false)
 		if err != nil {
 			log.Printf("Error fetching history: %v", err)
 			continue
@@ -129,7 +130,7 @@ func testPagination(client *dota2gc.Client, reader *bufio.Reader) {
 	}
 
 	fmt.Println("Fetching first page (limit 5)...")
-	matches1, err := client.GetPlayerMatchHistory(steamID, 5, false)
+	matches1, err := client.GetPlayerMatchHistory(context.Background(), steamID, 5, false)
 	if err != nil {
 		log.Printf("Error fetching page 1: %v", err)
 		return
@@ -147,7 +148,7 @@ func testPagination(client *dota2gc.Client, reader *bufio.Reader) {
 	}
 
 	fmt.Printf("\nFetching second page (startAtMatchID=%d)...\n", lastMatchID)
-	matches2, err := client.GetPlayerMatchHistoryPaginated(steamID, 5, false, uint64(lastMatchID))
+	matches2, err := client.GetPlayerMatchHistoryPaginated(context.Background(), steamID, 5, false, uint64(lastMatchID))
 	if err != nil {
 		log.Printf("Error fetching page 2: %v", err)
 		return
@@ -1,35 +1,91 @@
+// Package botclient talks to the GC bot process (cmd/bot) over HTTP.
 package botclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-)
-
-type ConnectionStatus int
+	"strings"
 
-const (
-	StatusDisconnected ConnectionStatus = iota
-	StatusConnecting
-	StatusNeedGuardCode
-	StatusConnected
-	StatusGCReady
-	StatusRateLimited
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
 )
 
+// Client is a gc.Service backed by the bot process.
 type Client struct {
 	baseURL string
+	// priority is sent with GC requests, see WithPriority.
+	priority gc.Priority
+
+	stream *eventStream
 }
 
+var _ gc.Service = (*Client)(nil)
+
 func NewClient(port string) *Client {
 	if port == "" {
 		port = "8082"
 	}
 	return &Client{
 		baseURL: "http://localhost:" + port,
+		stream:  &eventStream{},
+	}
+}
+
+// WithPriority returns a view of c whose GC requests ask the bot for prio.
+func (c *Client) WithPriority(prio gc.Priority) gc.Service {
+	v := *c
+	v.priority = prio
+	return &v
+}
+
+// get and post send GC requests with c's priority.
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+func (c *Client) post(ctx context.Context, path string, payload interface{}) (*http.Response, error) {
+	data, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.send(req)
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.priority == gc.PriorityBackground {
+		req.Header.Set(gc.PriorityHeader, "background")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil && req.Context().Err() != nil {
+		return nil, fmt.Errorf("%w: %v", gc.ErrTimeout, err)
+	}
+	return resp, err
+}
+
+// responseError returns the error of a failed GC request, typed from the
+// bot's error header.
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	msg := strings.TrimSpace(string(body))
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		msg = errResp.Error
+	}
+	if msg == "" {
+		msg = fmt.Sprintf("request failed with status %d", resp.StatusCode)
 	}
+	return gc.ErrorFromCode(resp.Header.Get(gc.ErrorHeader), msg)
 }
 
 func (c *Client) Init(user, pass string) error {
@@ -41,7 +97,8 @@ func (c *Client) Init(user, pass string) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("init failed: %s", resp.Status)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("init failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
 	}
 	return nil
 }
@@ -73,10 +130,25 @@ func (c *Client) Disconnect() error {
 	return nil
 }
 
-func (c *Client) GetStatus() ConnectionStatus {
+func (c *Client) ForgetSession(user string) error {
+	payload := map[string]interface{}{"forgetSession": true, "username": user}
+	data, _ := json.Marshal(payload)
+	resp, err := http.Post(c.baseURL+"/disconnect", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("forget session failed: %s", string(body))
+	}
+	return nil
+}
+
+func (c *Client) GetStatus() gc.ConnectionStatus {
 	resp, err := http.Get(c.baseURL + "/status")
 	if err != nil {
-		return StatusDisconnected
+		return gc.StatusDisconnected
 	}
 	defer resp.Body.Close()
 
@@ -85,15 +157,15 @@ func (c *Client) GetStatus() ConnectionStatus {
 		ErrorMessage string `json:"errorMessage,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return StatusDisconnected
+		return gc.StatusDisconnected
 	}
-	return ConnectionStatus(res.Status)
+	return gc.ConnectionStatus(res.Status)
 }
 
-func (c *Client) GetStatusWithError() (ConnectionStatus, string) {
+func (c *Client) GetStatusWithError() (gc.ConnectionStatus, string) {
 	resp, err := http.Get(c.baseURL + "/status")
 	if err != nil {
-		return StatusDisconnected, ""
+		return gc.StatusDisconnected, ""
 	}
 	defer resp.Body.Close()
 
@@ -102,13 +174,13 @@ func (c *Client) GetStatusWithError() (ConnectionStatus, string) {
 		ErrorMessage string `json:"errorMessage,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return StatusDisconnected, ""
+		return gc.StatusDisconnected, ""
 	}
-	return ConnectionStatus(res.Status), res.ErrorMessage
+	return gc.ConnectionStatus(res.Status), res.ErrorMessage
 }
 
-func (c *Client) GetReplayInfo(matchID uint64) (uint32, uint64, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/replay-info?match_id=%d", c.baseURL, matchID))
+func (c *Client) GetReplayInfo(ctx context.Context, matchID uint64) (uint32, uint64, error) {
+	resp, err := c.get(ctx, fmt.Sprintf("/replay-info?match_id=%d", matchID))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -124,23 +196,34 @@ func (c *Client) GetReplayInfo(matchID uint64) (uint32, uint64, error) {
 	}
 
 	if res.Error != "" {
-		return 0, 0, fmt.Errorf(res.Error)
+		return 0, 0, gc.ErrorFromCode(resp.Header.Get(gc.ErrorHeader), res.Error)
 	}
 	return res.Cluster, res.Salt, nil
 }
 
-type Match struct {
-	ID        int64  `json:"id"`
-	GameMode  uint32 `json:"gameMode"`
-	LobbyType uint32 `json:"lobbyType"`
-	StartTime uint32 `json:"startTime"`
+func (c *Client) GetMatchDetails(ctx context.Context, matchID uint64) (*gc.MatchDetails, error) {
+	resp, err := c.get(ctx, fmt.Sprintf("/match-details?match_id=%d", matchID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var details gc.MatchDetails
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return nil, err
+	}
+	return &details, nil
 }
 
-func (c *Client) GetPlayerMatchHistory(steamID64 int64, limit int, turboOnly bool) ([]Match, error) {
-	return c.GetPlayerMatchHistoryPaginated(steamID64, limit, turboOnly, 0)
+func (c *Client) GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]gc.Match, error) {
+	return c.GetPlayerMatchHistoryPaginated(ctx, steamID64, limit, turboOnly, 0)
 }
 
-func (c *Client) GetPlayerMatchHistoryPaginated(steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]Match, error) {
+func (c *Client) GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]gc.Match, error) {
 	payload := map[string]interface{}{
 		"steamId64": steamID64,
 		"limit":     limit,
@@ -149,90 +232,60 @@ func (c *Client) GetPlayerMatchHistoryPaginated(steamID64 int64, limit int, turb
 	if startAtMatchID > 0 {
 		payload["startAtMatchId"] = startAtMatchID
 	}
-	data, _ := json.Marshal(payload)
-	resp, err := http.Post(c.baseURL+"/player-match-history", "application/json", bytes.NewBuffer(data))
+	resp, err := c.post(ctx, "/player-match-history", payload)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		if errResp.Error != "" {
-			return nil, fmt.Errorf(errResp.Error)
-		}
-		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+		return nil, responseError(resp)
 	}
 
-	var matches []Match
+	var matches []gc.Match
 	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
 		return nil, err
 	}
 	return matches, nil
 }
 
-type FatalMatchInfo struct {
-	FatalMatchID       int64   `json:"fatalMatchId"`
-	SingleDraftMatchID int64   `json:"singleDraftMatchId"`
-	SingleDraftDate    uint32  `json:"singleDraftDate"`
-	AdditionalMatchIDs []int64 `json:"additionalMatchIds"`
-}
-
-func (c *Client) FindFatalGames(steamID64 int64, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error) {
+func (c *Client) FindFatalGames(ctx context.Context, steamID64 int64, maxDepth int, gamesPerFatal int) ([]gc.FatalMatchInfo, error) {
 	payload := map[string]interface{}{
 		"steamId64":     steamID64,
 		"maxDepth":      maxDepth,
 		"gamesPerFatal": gamesPerFatal,
 	}
-	data, _ := json.Marshal(payload)
-	resp, err := http.Post(c.baseURL+"/fatal-search", "application/json", bytes.NewBuffer(data))
+	resp, err := c.post(ctx, "/fatal-search", payload)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		if errResp.Error != "" {
-			return nil, fmt.Errorf(errResp.Error)
-		}
-		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+		return nil, responseError(resp)
 	}
 
-	var matches []FatalMatchInfo
+	var matches []gc.FatalMatchInfo
 	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
 		return nil, err
 	}
 	return matches, nil
 }
 
-func (c *Client) GetPlayerConductScorecard() (map[string]interface{}, error) {
-	resp, err := http.Get(c.baseURL + "/conduct-scorecard")
+func (c *Client) GetPlayerConductScorecard(ctx context.Context) (*gc.ConductScorecard, error) {
+	resp, err := c.get(ctx, "/conduct-scorecard")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		if errResp.Error != "" {
-			return nil, fmt.Errorf(errResp.Error)
-		}
-		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+		return nil, responseError(resp)
 	}
 
-	var scorecard map[string]interface{}
+	var scorecard gc.ConductScorecard
 	if err := json.NewDecoder(resp.Body).Decode(&scorecard); err != nil {
 		return nil, err
 	}
-	return scorecard, nil
+	return &scorecard, nil
 }
@@ -0,0 +1,86 @@
+package botclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// Subscribe streams the bot's status changes from its /events endpoint. The
+// stream is opened on first use and reopened whenever the bot restarts.
+func (c *Client) Subscribe() (<-chan gc.StatusEvent, func()) {
+	c.stream.once.Do(func() { go c.streamEvents() })
+	return c.stream.events.Subscribe()
+}
+
+// WaitFor blocks until the bot reports one of statuses, or any status if
+// none are given.
+func (c *Client) WaitFor(ctx context.Context, statuses ...gc.ConnectionStatus) (gc.ConnectionStatus, error) {
+	return gc.WaitFor(ctx, c, statuses...)
+}
+
+// eventStream is the bot's event stream, shared by the priority views of a
+// Client.
+type eventStream struct {
+	events gc.Broadcaster
+	once   sync.Once
+}
+
+// streamEvents relays the bot's event stream into c.stream.events, reconnecting
+// with a growing delay. Losing an established stream is published as a
+// disconnect, so subscribers never keep a stale status.
+func (c *Client) streamEvents() {
+	delay := time.Second
+	for {
+		connected, err := c.readEvents()
+		if connected {
+			delay = time.Second
+			c.stream.events.Publish(gc.NewStatusEvent(gc.StatusDisconnected, "Lost connection to the bot process"))
+		}
+		log.Printf("Bot event stream closed: %v, reconnecting in %v", err, delay)
+		time.Sleep(delay)
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+// readEvents reads the event stream until it ends. connected reports
+// whether the stream was opened.
+func (c *Client) readEvents() (connected bool, err error) {
+	resp, err := http.Get(c.baseURL + "/events")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			// Comments (keepalives), event names and blank separators
+			continue
+		}
+		var ev gc.StatusEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+			log.Printf("Ignoring malformed bot event: %v", err)
+			continue
+		}
+		c.stream.events.Publish(ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, fmt.Errorf("stream ended")
+}
@@ -0,0 +1,164 @@
+// Package conduct keeps every conduct scorecard the GC sent, so changes of
+// the behaviour score can be followed over time.
+package conduct
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// Entry is one stored scorecard. A scorecard covers the MatchesInReport
+// matches up to MatchID, which ended at Date; the GC sends the same one
+// until the next report period ends.
+type Entry struct {
+	gc.ConductScorecard
+	// FirstSeen is when the scorecard was fetched first, LastSeen when it
+	// was fetched last.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// sameReport reports whether e and sc describe the same report period.
+func (e Entry) sameReport(sc gc.ConductScorecard) bool {
+	return e.Date == sc.Date && e.MatchID == sc.MatchID
+}
+
+// account is the scorecard history of one account, oldest first.
+type account struct {
+	Scorecards  []Entry   `json:"scorecards"`
+	LastFetched time.Time `json:"last_fetched"`
+}
+
+// Store is the scorecard history of every account, mirrored to a JSON file.
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	accounts map[uint32]*account
+	// latest is the account of the last recorded scorecard.
+	latest uint32
+}
+
+// DefaultPath returns the scorecard history location under the user's config
+// directory (~/.dota-report-timestamps/conduct-scorecards.json).
+func DefaultPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "conduct-scorecards.json")
+}
+
+// Load reads the scorecard history at path and keeps it updated there. A
+// missing file is an empty history. A file that cannot be parsed is moved
+// aside to path+".corrupt" so new scorecards do not overwrite it; Load then
+// returns the error along with an empty history. The returned Store is
+// always usable, but if the file cannot be read or moved aside it is not
+// saved.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, accounts: make(map[uint32]*account)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		s.path = ""
+		return s, fmt.Errorf("failed to read conduct scorecards: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.accounts); err != nil {
+		s.accounts = make(map[uint32]*account)
+		err = fmt.Errorf("failed to parse conduct scorecards %s: %w", path, err)
+		if rerr := os.Rename(path, path+".corrupt"); rerr != nil {
+			s.path = ""
+			return s, fmt.Errorf("%w; not saving new scorecards: %v", err, rerr)
+		}
+		return s, fmt.Errorf("%w (moved to %s.corrupt)", err, path)
+	}
+	var newest time.Time
+	for id, a := range s.accounts {
+		if a.LastFetched.After(newest) {
+			newest, s.latest = a.LastFetched, id
+		}
+	}
+	return s, nil
+}
+
+// saveLocked writes the history to its file atomically.
+func (s *Store) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.accounts)
+	if err != nil {
+		log.Printf("Failed to encode conduct scorecards: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		log.Printf("Failed to create conduct scorecards directory: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write conduct scorecards: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to replace conduct scorecards: %v", err)
+	}
+}
+
+// Record stores a fetched scorecard and reports whether it is a new one.
+// Fetching the scorecard of a known report period again only updates it.
+func (s *Store) Record(sc gc.ConductScorecard) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	a := s.accounts[sc.AccountID]
+	if a == nil {
+		a = &account{}
+		s.accounts[sc.AccountID] = a
+	}
+	a.LastFetched = now
+	s.latest = sc.AccountID
+	defer s.saveLocked()
+
+	for i, e := range a.Scorecards {
+		if e.sameReport(sc) {
+			a.Scorecards[i].ConductScorecard = sc
+			a.Scorecards[i].LastSeen = now
+			return a.Scorecards[i], false
+		}
+	}
+	e := Entry{ConductScorecard: sc, FirstSeen: now, LastSeen: now}
+	a.Scorecards = append(a.Scorecards, e)
+	sort.Slice(a.Scorecards, func(i, j int) bool {
+		if a.Scorecards[i].Date != a.Scorecards[j].Date {
+			return a.Scorecards[i].Date < a.Scorecards[j].Date
+		}
+		return a.Scorecards[i].MatchID < a.Scorecards[j].MatchID
+	})
+	return e, true
+}
+
+// History returns the scorecards of an account, oldest first, and when the
+// account's scorecard was fetched last.
+func (s *Store) History(accountID uint32) ([]Entry, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.accounts[accountID]
+	if a == nil {
+		return nil, time.Time{}
+	}
+	return append([]Entry(nil), a.Scorecards...), a.LastFetched
+}
+
+// LatestAccount returns the account whose scorecard was recorded last, or 0.
+func (s *Store) LatestAccount() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
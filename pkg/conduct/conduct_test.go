@@ -0,0 +1,124 @@
+package conduct
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+func scorecard(account uint32, date uint32, matchID uint64, score uint32) gc.ConductScorecard {
+	return gc.ConductScorecard{AccountID: account, Date: date, MatchID: matchID, RawBehaviorScore: score}
+}
+
+func TestRecord(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "conduct-scorecards.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, isNew := s.Record(scorecard(1, 2000, 20, 9000))
+	if !isNew {
+		t.Fatal("first scorecard not new")
+	}
+	// Recorded out of order: an older report period and one on the same
+	// date with a lower match.
+	if _, isNew := s.Record(scorecard(1, 1000, 10, 8000)); !isNew {
+		t.Fatal("older report period not new")
+	}
+	if _, isNew := s.Record(scorecard(1, 2000, 15, 8500)); !isNew {
+		t.Fatal("same date with another match not new")
+	}
+
+	time.Sleep(time.Millisecond)
+	again, isNew := s.Record(scorecard(1, 2000, 20, 9100))
+	if isNew {
+		t.Fatal("same report period recorded as new")
+	}
+	if !again.FirstSeen.Equal(first.FirstSeen) || !again.LastSeen.After(first.LastSeen) {
+		t.Fatalf("seen %v..%v, want first seen %v and a later last seen", again.FirstSeen, again.LastSeen, first.FirstSeen)
+	}
+	if again.RawBehaviorScore != 9100 {
+		t.Fatalf("score %d, want the refetched 9100", again.RawBehaviorScore)
+	}
+
+	entries, lastFetched := s.History(1)
+	var got [][2]uint64
+	for _, e := range entries {
+		got = append(got, [2]uint64{uint64(e.Date), e.MatchID})
+	}
+	if want := [][2]uint64{{1000, 10}, {2000, 15}, {2000, 20}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history (date, match) %v, want %v", got, want)
+	}
+	if !lastFetched.Equal(again.LastSeen) {
+		t.Fatalf("last fetched %v, want %v", lastFetched, again.LastSeen)
+	}
+
+	s.Record(scorecard(2, 3000, 30, 10000))
+	if got := s.LatestAccount(); got != 2 {
+		t.Fatalf("latest account %d, want 2", got)
+	}
+	if entries, _ := s.History(1); len(entries) != 3 {
+		t.Fatalf("account 1 has %d scorecards after recording account 2, want 3", len(entries))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conduct", "conduct-scorecards.json")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if s.LatestAccount() != 0 {
+		t.Fatal("empty history has a latest account")
+	}
+	s.Record(scorecard(1, 1000, 10, 8000))
+	time.Sleep(time.Millisecond)
+	s.Record(scorecard(2, 1000, 11, 9000))
+	s.Record(scorecard(2, 2000, 12, 9500))
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []uint32{1, 2} {
+		want, wantFetched := s.History(account)
+		got, gotFetched := loaded.History(account)
+		if len(got) != len(want) || !gotFetched.Equal(wantFetched) {
+			t.Fatalf("account %d: loaded %d scorecards fetched %v, want %d fetched %v", account, len(got), gotFetched, len(want), wantFetched)
+		}
+		for i := range got {
+			if got[i].ConductScorecard != want[i].ConductScorecard || !got[i].FirstSeen.Equal(want[i].FirstSeen) {
+				t.Fatalf("account %d scorecard %d: loaded %+v, want %+v", account, i, got[i], want[i])
+			}
+		}
+	}
+	if got := loaded.LatestAccount(); got != 2 {
+		t.Fatalf("latest account %d, want the last fetched 2", got)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad, err := Load(path)
+	if err == nil {
+		t.Fatal("loading an unparsable file succeeded")
+	}
+	if data, err := os.ReadFile(path + ".corrupt"); err != nil || string(data) != "{" {
+		t.Fatalf("unparsable file not moved aside: %q, %v", data, err)
+	}
+	// The empty history still records, without touching the bad file.
+	bad.Record(scorecard(3, 1000, 13, 7000))
+	if got := bad.LatestAccount(); got != 3 {
+		t.Fatalf("latest account %d after loading an unparsable file, want 3", got)
+	}
+	if data, _ := os.ReadFile(path + ".corrupt"); string(data) != "{" {
+		t.Fatalf("unparsable file overwritten with %q", data)
+	}
+	if reloaded, err := Load(path); err != nil || reloaded.LatestAccount() != 3 {
+		t.Fatalf("reloading the new history: latest account %d, %v, want 3", reloaded.LatestAccount(), err)
+	}
+}
@@ -12,6 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/steamapi"
+	"github.com/d3nd3/dota-report-timestamps/pkg/steamguard"
 	"github.com/paralin/go-dota2"
 	"github.com/paralin/go-dota2/events"
 	"github.com/paralin/go-dota2/protocol"
@@ -20,15 +23,21 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-type ConnectionStatus int
+// The connection states, match and fatal search types are shared with
+// botclient through package gc.
+type (
+	ConnectionStatus = gc.ConnectionStatus
+	Match            = gc.Match
+	FatalMatchInfo   = gc.FatalMatchInfo
+)
 
 const (
-	StatusDisconnected ConnectionStatus = iota
-	StatusConnecting
-	StatusNeedGuardCode
-	StatusConnected
-	StatusGCReady
-	StatusRateLimited
+	StatusDisconnected  = gc.StatusDisconnected
+	StatusConnecting    = gc.StatusConnecting
+	StatusNeedGuardCode = gc.StatusNeedGuardCode
+	StatusConnected     = gc.StatusConnected
+	StatusGCReady       = gc.StatusGCReady
+	StatusRateLimited   = gc.StatusRateLimited
 )
 
 type Client struct {
@@ -36,9 +45,12 @@ type Client struct {
 	dotaClient  *dota2.Dota2
 
 	username string
-	password string
+	password string // dropped once a session is saved
 	authCode string
 
+	passwordMutex sync.Mutex // Mutex for password and sessionSaved
+	sessionSaved  bool       // A saved session logs this account in
+
 	status          ConnectionStatus
 	statusMutex     sync.RWMutex
 	statusSetTime   time.Time
@@ -57,8 +69,59 @@ type Client struct {
 	lastConnectionFailed bool              // Track if last connection attempt failed
 	lastErrorMessage     string            // Track last error message for user display
 	errorMessageMutex    sync.RWMutex      // Mutex for lastErrorMessage
+
+	authenticator     *steamguard.Authenticator // Generates guard codes when a shared secret is set
+	autoGuardAttempts int                       // Generated codes submitted since the last logon (connectMutex)
+	usingLoginKey     bool                      // Last logon used the saved session instead of the password
+
+	onStatusChange func() // Called after every status or error message change
+
+	queue requestQueue // GC requests waiting for this session
 }
 
+// sessionRejected forgets a saved session Steam no longer accepts and logs
+// in with the password instead, if there is one.
+func (c *Client) sessionRejected() {
+	log.Printf("Steam: Saved session was rejected, forgetting it")
+	if err := forgetSession(c.username); err != nil {
+		log.Printf("Steam: Failed to forget session: %v", err)
+	}
+	c.autoReconnectMutex.Lock()
+	c.disableAutoReconnect = true
+	c.autoReconnectMutex.Unlock()
+
+	c.passwordMutex.Lock()
+	c.sessionSaved = false
+	password := c.password
+	c.passwordMutex.Unlock()
+	if password == "" {
+		c.SetStatus(StatusDisconnected)
+		c.setErrorMessage("Saved Steam session expired. Please log in with your password.")
+		return
+	}
+	go func() {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+		log.Printf("Steam: Retrying login with password")
+		c.reconnectMutex.Lock()
+		c.lastConnectionFailed = true
+		c.reconnectMutex.Unlock()
+		// Connect only replaces a connection that is not in use
+		c.SetStatus(StatusDisconnected)
+		if err := c.Connect(); err != nil {
+			log.Printf("Steam: Password login failed: %v", err)
+		}
+	}()
+}
+
+// maxAutoGuardAttempts bounds the generated codes tried per logon, so a wrong
+// shared secret cannot get the account throttled.
+const maxAutoGuardAttempts = 3
+
 func NewClient(username, password string) *Client {
 	logrus.SetLevel(logrus.DebugLevel)
 
@@ -75,6 +138,55 @@ func NewClient(username, password string) *Client {
 	}
 }
 
+// SetSharedSecret makes the client answer Steam Guard prompts itself with
+// codes generated from the base64 shared_secret of a mobile authenticator.
+func (c *Client) SetSharedSecret(sharedSecret string) error {
+	auth, err := steamguard.NewAuthenticator(sharedSecret, steamapi.NewClient("").QueryTime)
+	if err != nil {
+		return err
+	}
+	c.connectMutex.Lock()
+	c.authenticator = auth
+	c.autoGuardAttempts = 0
+	c.connectMutex.Unlock()
+	return nil
+}
+
+// autoSubmitGuardCode answers a Steam Guard prompt with a generated code when
+// a shared secret is set. A rejected code usually means a skewed clock, so
+// retries resync with Steam and wait for the next code.
+func (c *Client) autoSubmitGuardCode() {
+	c.connectMutex.Lock()
+	auth := c.authenticator
+	if auth != nil {
+		c.autoGuardAttempts++
+	}
+	attempt := c.autoGuardAttempts
+	c.connectMutex.Unlock()
+	if auth == nil {
+		return
+	}
+
+	if attempt > maxAutoGuardAttempts {
+		log.Printf("Steam Guard: %d generated codes were rejected, waiting for a manual code", maxAutoGuardAttempts)
+		c.setErrorMessage("Generated Steam Guard codes were rejected. Check the shared secret or enter a code.")
+		return
+	}
+
+	go func() {
+		if attempt > 1 {
+			if err := auth.Sync(); err != nil {
+				log.Printf("Steam Guard: %v", err)
+			}
+			time.Sleep(auth.UntilNextCode())
+		}
+		log.Printf("Steam Guard: submitting generated code (attempt %d/%d)", attempt, maxAutoGuardAttempts)
+		if err := c.SubmitCode(auth.Code()); err != nil {
+			log.Printf("Steam Guard: failed to submit generated code: %v", err)
+		}
+	}()
+}
+
 func (c *Client) SetStatus(s ConnectionStatus) {
 	c.statusMutex.Lock()
 	c.status = s
@@ -83,6 +195,22 @@ func (c *Client) SetStatus(s ConnectionStatus) {
 	c.statusTimeMutex.Lock()
 	c.statusSetTime = time.Now()
 	c.statusTimeMutex.Unlock()
+	c.notifyStatus()
+}
+
+// notifyStatus tells the owner of the client that its status may have
+// changed. The hook must not block.
+func (c *Client) notifyStatus() {
+	if c.onStatusChange != nil {
+		c.onStatusChange()
+	}
+}
+
+func (c *Client) setErrorMessage(msg string) {
+	c.errorMessageMutex.Lock()
+	c.lastErrorMessage = msg
+	c.errorMessageMutex.Unlock()
+	c.notifyStatus()
 }
 
 func (c *Client) GetLastErrorMessage() string {
@@ -92,9 +220,15 @@ func (c *Client) GetLastErrorMessage() string {
 }
 
 func (c *Client) ClearErrorMessage() {
-	c.errorMessageMutex.Lock()
-	defer c.errorMessageMutex.Unlock()
-	c.lastErrorMessage = ""
+	c.setErrorMessage("")
+}
+
+// SessionSaved reports whether a session saved during this login lets the
+// account log in again without its password.
+func (c *Client) SessionSaved() bool {
+	c.passwordMutex.Lock()
+	defer c.passwordMutex.Unlock()
+	return c.sessionSaved
 }
 
 func (c *Client) GetStatus() ConnectionStatus {
@@ -316,10 +450,38 @@ func (c *Client) SubmitCode(code string) error {
 	return nil
 }
 
+// logOn logs in with the saved session, or else the password.
+//
+// This is the LoginKey flow that Steam is retiring in favour of refresh
+// tokens from IAuthenticationService. The vendored go-steam cannot log on
+// with a refresh token (CMsgClientLogon has no access_token field and the
+// authentication service messages are missing), so moving over waits on
+// updating that dependency.
 func (c *Client) logOn() {
+	c.passwordMutex.Lock()
+	password := c.password
+	c.passwordMutex.Unlock()
 	loginDetails := &steam.LogOnDetails{
 		Username: c.username,
-		Password: c.password,
+		Password: password,
+		// Ask for a login key so later runs need no password
+		ShouldRememberPassword: true,
+	}
+
+	c.usingLoginKey = false
+	if loginKey, err := loadSession(c.username); err != nil {
+		log.Printf("Steam: Ignoring saved session: %v", err)
+	} else if loginKey != "" {
+		log.Printf("Steam: Logging in with saved session")
+		loginDetails.LoginKey = loginKey
+		loginDetails.Password = ""
+		c.usingLoginKey = true
+	}
+	if loginDetails.Password == "" && loginDetails.LoginKey == "" {
+		log.Printf("Steam: No password and no saved session for %s", c.username)
+		c.SetStatus(StatusDisconnected)
+		c.setErrorMessage("No saved Steam session. Please log in with your password.")
+		return
 	}
 
 	if sentryFileContent, err := ioutil.ReadFile(c.sentryPath); err == nil && len(sentryFileContent) > 0 {
@@ -334,7 +496,7 @@ func (c *Client) logOn() {
 		// If we don't know (0), default to both or try to guess (usually TwoFactor for mobile, AuthCode for email)
 		// But sending both can cause issues, so let's try to be specific if possible.
 
-		useTwoFactor := false
+		useTwoFactor := c.authenticator != nil
 		if c.lastLogonResult == steamlang.EResult_AccountLoginDeniedNeedTwoFactor ||
 			c.lastLogonResult == steamlang.EResult_AccountLogonDeniedNeedTwoFactorCode ||
 			c.lastLogonResult == steamlang.EResult_TwoFactorCodeMismatch ||
@@ -384,6 +546,7 @@ func (c *Client) eventLoop() {
 				log.Println("Steam: Logged On")
 				c.connectMutex.Lock()
 				c.authCode = ""
+				c.autoGuardAttempts = 0
 				c.connectMutex.Unlock()
 				c.SetStatus(StatusConnected)
 				// Mark connection as successful
@@ -417,13 +580,16 @@ func (c *Client) eventLoop() {
 					}
 					c.connectMutex.Unlock()
 					c.SetStatus(StatusNeedGuardCode)
+					c.autoSubmitGuardCode()
 				} else {
 					c.connectMutex.Lock()
 					hasAuthCode := c.authCode != ""
 					c.connectMutex.Unlock()
 
-					// Check if this is a wrong code error - reset to StatusNeedGuardCode to allow retry
-					if e.Result == steamlang.EResult_TwoFactorCodeMismatch ||
+					// A rejected login key means the saved session expired
+					if c.usingLoginKey && e.Result == steamlang.EResult_InvalidPassword {
+						c.sessionRejected()
+					} else if e.Result == steamlang.EResult_TwoFactorCodeMismatch ||
 						e.Result == steamlang.EResult_InvalidLoginAuthCode {
 						log.Printf("Steam: Wrong guard code, clearing and allowing retry")
 						c.connectMutex.Lock()
@@ -433,9 +599,8 @@ func (c *Client) eventLoop() {
 						c.autoReconnectMutex.Lock()
 						c.disableAutoReconnect = true
 						c.autoReconnectMutex.Unlock()
-						c.errorMessageMutex.Lock()
-						c.lastErrorMessage = "Wrong Steam Guard code. Please try again."
-						c.errorMessageMutex.Unlock()
+						c.setErrorMessage("Wrong Steam Guard code. Please try again.")
+						c.autoSubmitGuardCode()
 					} else if e.Result == steamlang.EResult_InvalidPassword {
 						if hasAuthCode {
 							log.Printf("Steam: InvalidPassword error but auth code was provided - treating as wrong code, allowing retry")
@@ -446,6 +611,7 @@ func (c *Client) eventLoop() {
 							c.autoReconnectMutex.Lock()
 							c.disableAutoReconnect = true
 							c.autoReconnectMutex.Unlock()
+							c.autoSubmitGuardCode()
 						} else {
 							log.Printf("Steam: InvalidPassword error (no auth code provided) - Steam may require 2FA, allowing code entry")
 							c.SetStatus(StatusNeedGuardCode)
@@ -455,12 +621,14 @@ func (c *Client) eventLoop() {
 						}
 					} else if e.Result == steamlang.EResult_RateLimitExceeded {
 						log.Printf("Steam: Rate limit exceeded (E84) - Too many login attempts. Please wait at least 24 hours before retrying.")
+						c.setErrorMessage("Too many login attempts (RateLimitExceeded). Wait at least 24 hours before retrying.")
 						c.SetStatus(StatusRateLimited)
 						c.autoReconnectMutex.Lock()
 						c.disableAutoReconnect = true
 						c.autoReconnectMutex.Unlock()
 					} else if e.Result == steamlang.EResult_AccountLoginDeniedThrottle {
 						log.Printf("Steam: Account login denied due to throttling (E87) - Too many failed login attempts. Please wait at least 24 hours before retrying.")
+						c.setErrorMessage("Too many failed login attempts (AccountLoginDeniedThrottle). Wait at least 24 hours before retrying.")
 						c.SetStatus(StatusRateLimited)
 						c.autoReconnectMutex.Lock()
 						c.disableAutoReconnect = true
@@ -473,6 +641,20 @@ func (c *Client) eventLoop() {
 					}
 				}
 
+			case *steam.LoginKeyEvent:
+				if err := saveSession(c.username, e.LoginKey); err != nil {
+					log.Printf("Steam: Failed to save session: %v", err)
+				} else {
+					log.Printf("Steam: Session saved, later logins need no password")
+					// Reconnects use the session too, so the password
+					// need not stay in memory.
+					c.passwordMutex.Lock()
+					c.password = ""
+					c.sessionSaved = true
+					c.passwordMutex.Unlock()
+					c.notifyStatus()
+				}
+
 			case *steam.MachineAuthUpdateEvent:
 				log.Printf("Steam: Machine Auth Update (Hash: %x, Bytes: %d)", e.Hash, len(e.Bytes))
 				if len(e.Bytes) > 0 {
@@ -591,9 +773,7 @@ func (c *Client) eventLoop() {
 
 					if isEOF && hasAuthCode {
 						// EOF during guard code submission - set error message and status
-						c.errorMessageMutex.Lock()
-						c.lastErrorMessage = "Connection error during code submission. Please try submitting the code again."
-						c.errorMessageMutex.Unlock()
+						c.setErrorMessage("Connection error during code submission. Please try submitting the code again.")
 						log.Printf("Steam: EOF error during guard code submission, setting status to NeedGuardCode")
 						c.SetStatus(StatusNeedGuardCode)
 						// Don't auto-retry immediately for EOF during code submission - let user retry
@@ -671,68 +851,52 @@ func convertSteamID(input uint64, to64 bool) uint64 {
 	}
 }
 
-func (c *Client) GetReplayInfo(matchID uint64) (uint32, uint64, error) {
-	status := c.GetStatus()
-	if status != StatusGCReady && status != StatusConnected {
-		return 0, 0, fmt.Errorf("GC not ready (Status: %d)", status)
-	}
-	if status == StatusConnected {
-		// Relaxed condition: Allow requests when Connected (but not GCReady yet)
-		// log.Println("Warning: Requesting replay info while GC is CONNECTED but not READY. This might fail.")
-		log.Printf("[GetReplayInfo] GC is Connected but not Ready, waiting for GC session...")
-		if c.dotaClient != nil {
-			go c.dotaClient.SayHello()
-		}
-		for i := 0; i < 10; i++ { // Wait up to 2.5 seconds
-			time.Sleep(250 * time.Millisecond)
-			status = c.GetStatus()
-			if status == StatusGCReady {
-				log.Printf("[GetReplayInfo] GC is now Ready")
-				break
-			}
-		}
+func (c *Client) GetReplayInfo(ctx context.Context, matchID uint64) (uint32, uint64, error) {
+	return c.replayInfo(ctx, gc.PriorityInteractive, matchID)
+}
+
+func (c *Client) replayInfo(ctx context.Context, prio gc.Priority, matchID uint64) (uint32, uint64, error) {
+	match, err := c.matchDetails(ctx, prio, matchID)
+	if err != nil {
+		return 0, 0, err
 	}
+	return match.GetCluster(), uint64(match.GetReplaySalt()), nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// GetMatchDetails returns the GC's record of a match.
+func (c *Client) GetMatchDetails(ctx context.Context, matchID uint64) (*protocol.CMsgDOTAMatch, error) {
+	return c.matchDetails(ctx, gc.PriorityInteractive, matchID)
+}
 
-	// RequestMatchDetails returns (*protocol.CMsgGCMatchDetailsResponse, error)
-	res, err := c.dotaClient.RequestMatchDetails(ctx, matchID)
+func (c *Client) matchDetails(ctx context.Context, prio gc.Priority, matchID uint64) (*protocol.CMsgDOTAMatch, error) {
+	var res *protocol.CMsgGCMatchDetailsResponse
+	err := c.request(ctx, prio, "GetMatchDetails", 0, func(ctx context.Context) error {
+		var err error
+		res, err = c.dotaClient.RequestMatchDetails(ctx, matchID)
+		return err
+	})
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to request match details: %w", err)
+		return nil, fmt.Errorf("failed to request match details: %w", err)
 	}
 
 	if res.GetResult() != uint32(steamlang.EResult_OK) {
-		return 0, 0, fmt.Errorf("GC returned error result: %v", res.GetResult())
+		return nil, fmt.Errorf("GC returned error result: %v", res.GetResult())
 	}
 
-	return res.GetMatch().GetCluster(), uint64(res.GetMatch().GetReplaySalt()), nil
+	return res.GetMatch(), nil
 }
 
-func (c *Client) GetPlayerConductScorecard() (*protocol.CMsgPlayerConductScorecard, error) {
-	status := c.GetStatus()
-	if status != StatusGCReady && status != StatusConnected {
-		return nil, fmt.Errorf("GC not ready (Status: %d)", status)
-	}
-	if status == StatusConnected {
-		log.Printf("[GetPlayerConductScorecard] GC is Connected but not Ready, waiting for GC session...")
-		if c.dotaClient != nil {
-			go c.dotaClient.SayHello()
-		}
-		for i := 0; i < 10; i++ {
-			time.Sleep(250 * time.Millisecond)
-			status = c.GetStatus()
-			if status == StatusGCReady {
-				log.Printf("[GetPlayerConductScorecard] GC is now Ready")
-				break
-			}
-		}
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func (c *Client) GetPlayerConductScorecard(ctx context.Context) (*protocol.CMsgPlayerConductScorecard, error) {
+	return c.conductScorecard(ctx, gc.PriorityInteractive)
+}
 
-	res, err := c.dotaClient.RequestLatestConductScorecard(ctx)
+func (c *Client) conductScorecard(ctx context.Context, prio gc.Priority) (*protocol.CMsgPlayerConductScorecard, error) {
+	var res *protocol.CMsgPlayerConductScorecard
+	err := c.request(ctx, prio, "GetPlayerConductScorecard", 0, func(ctx context.Context) error {
+		var err error
+		res, err = c.dotaClient.RequestLatestConductScorecard(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to request conduct scorecard: %w", err)
 	}
@@ -740,47 +904,15 @@ func (c *Client) GetPlayerConductScorecard() (*protocol.CMsgPlayerConductScoreca
 	return res, nil
 }
 
-type Match struct {
-	ID        int64  `json:"id"`
-	GameMode  uint32 `json:"gameMode"`
-	LobbyType uint32 `json:"lobbyType"`
-	StartTime uint32 `json:"startTime"`
+func (c *Client) GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]Match, error) {
+	return c.GetPlayerMatchHistoryPaginated(ctx, steamID64, limit, turboOnly, 0)
 }
 
-func (c *Client) GetPlayerMatchHistory(steamID64 int64, limit int, turboOnly bool) ([]Match, error) {
-	return c.GetPlayerMatchHistoryPaginated(steamID64, limit, turboOnly, 0)
+func (c *Client) GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]Match, error) {
+	return c.matchHistory(ctx, gc.PriorityInteractive, steamID64, limit, turboOnly, startAtMatchID)
 }
 
-func (c *Client) GetPlayerMatchHistoryPaginated(steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]Match, error) {
-	status := c.GetStatus()
-	if status != StatusGCReady && status != StatusConnected {
-		return nil, fmt.Errorf("GC not ready (Status: %d)", status)
-	}
-
-	if status == StatusConnected {
-		log.Printf("[GetPlayerMatchHistory] GC is Connected but not Ready, waiting for GC session...")
-		// Force SayHello to kickstart session if needed
-		if c.dotaClient != nil {
-			go c.dotaClient.SayHello()
-		}
-
-		for i := 0; i < 20; i++ { // Wait up to 5 seconds
-			time.Sleep(250 * time.Millisecond)
-			status = c.GetStatus()
-			if status == StatusGCReady {
-				log.Printf("[GetPlayerMatchHistory] GC is now Ready")
-				break
-			}
-			// Every 1 second (4 iterations), try saying hello again if still not ready
-			if i > 0 && i%4 == 0 && c.dotaClient != nil {
-				go c.dotaClient.SayHello()
-			}
-		}
-		if status != StatusGCReady {
-			log.Printf("[GetPlayerMatchHistory] GC still not Ready (status: %d) after waiting, proceeding anyway", status)
-		}
-	}
-
+func (c *Client) matchHistory(ctx context.Context, prio gc.Priority, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]Match, error) {
 	accountID := uint32(convertSteamID(uint64(steamID64), false))
 	matchesRequested := uint32(limit)
 	if matchesRequested > 20 {
@@ -805,20 +937,16 @@ func (c *Client) GetPlayerMatchHistoryPaginated(steamID64 int64, limit int, turb
 	if limit > 20 || startAtMatchID == 0 {
 		timeout = 30 * time.Second
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
 
 	log.Printf("[GetPlayerMatchHistory] Requesting %d matches (max 20 per request) with timeout %v, startAtMatchID=%d", limit, timeout, startAtMatchID)
-	resp, err := c.dotaClient.GetPlayerMatchHistory(ctx, req)
+	var resp *protocol.CMsgDOTAGetPlayerMatchHistoryResponse
+	err := c.request(ctx, prio, "GetPlayerMatchHistory", timeout, func(ctx context.Context) error {
+		var err error
+		resp, err = c.dotaClient.GetPlayerMatchHistory(ctx, req)
+		return err
+	})
 	if err != nil {
 		log.Printf("[GetPlayerMatchHistory] ERROR: %v", err)
-		if strings.Contains(err.Error(), "context deadline exceeded") || strings.Contains(err.Error(), "timeout") {
-			log.Printf("[GetPlayerMatchHistory] Timeout detected, refreshing GC connection...")
-			if c.dotaClient != nil {
-				c.SetStatus(StatusConnected)
-				c.dotaClient.SayHello()
-			}
-		}
 		return nil, fmt.Errorf("failed to get player match history: %w", err)
 	}
 	log.Printf("[GetPlayerMatchHistory] Successfully got response with %d matches", len(resp.GetMatches()))
@@ -842,8 +970,8 @@ func (c *Client) GetPlayerMatchHistoryPaginated(steamID64 int64, limit int, turb
 }
 
 const (
-	GameModeSingleDraft = 4
-	LobbyTypeRanked     = 7
+	GameModeSingleDraft = gc.GameModeSingleDraft
+	LobbyTypeRanked     = gc.LobbyTypeRanked
 )
 
 func checkIfEnoughMatches(matches []Match, maxDepth int) bool {
@@ -890,25 +1018,18 @@ func checkIfEnoughMatches(matches []Match, maxDepth int) bool {
 	return currentDepth >= maxDepth
 }
 
-type FatalMatchInfo struct {
-	FatalMatchID       int64   `json:"fatalMatchId"`
-	SingleDraftMatchID int64   `json:"singleDraftMatchId"`
-	SingleDraftDate    uint32  `json:"singleDraftDate"`
-	AdditionalMatchIDs []int64 `json:"additionalMatchIds"`
+func (c *Client) FindFatalGames(ctx context.Context, steamID64 int64, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error) {
+	return c.findFatalGames(ctx, gc.PriorityInteractive, steamID64, maxDepth, gamesPerFatal)
 }
 
-func (c *Client) FindFatalGames(steamID64 int64, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error) {
+// findFatalGames fetches the history in batches through the request queue,
+// which spaces them out and retries the ones that time out.
+func (c *Client) findFatalGames(ctx context.Context, prio gc.Priority, steamID64 int64, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error) {
 	log.Printf("[FindFatalGames] Starting: steamID64=%d, maxDepth=%d, gamesPerFatal=%d", steamID64, maxDepth, gamesPerFatal)
 	if maxDepth < 1 {
 		return nil, fmt.Errorf("maxDepth must be at least 1")
 	}
 
-	status := c.GetStatus()
-	log.Printf("[FindFatalGames] GC status: %d", status)
-	if status != StatusGCReady && status != StatusConnected {
-		return nil, fmt.Errorf("GC not ready (Status: %d)", status)
-	}
-
 	var fatalMatches []FatalMatchInfo
 	batchSize := 20
 	maxBatches := 30
@@ -919,51 +1040,15 @@ func (c *Client) FindFatalGames(steamID64 int64, maxDepth int, gamesPerFatal int
 	batchesFetched := 0
 
 	for batchesFetched < maxBatches {
-		if batchesFetched > 0 {
-			time.Sleep(500 * time.Millisecond)
-			status := c.GetStatus()
-			if status != StatusGCReady && status != StatusConnected {
-				log.Printf("[FindFatalGames] GC status degraded to %d, refreshing connection...", status)
-				if c.dotaClient != nil {
-					c.dotaClient.SayHello()
-				}
-				time.Sleep(1 * time.Second)
-				status = c.GetStatus()
-				if status != StatusGCReady && status != StatusConnected {
-					log.Printf("[FindFatalGames] GC still not ready after refresh (status: %d), but continuing", status)
-				}
-			} else if c.dotaClient != nil {
-				go c.dotaClient.SayHello()
-			}
-		}
-
 		log.Printf("[FindFatalGames] Fetching batch %d/%d: %d matches, startAtMatchID=%d", batchesFetched+1, maxBatches, batchSize, startAtMatchID)
-		matches, err := c.GetPlayerMatchHistoryPaginated(steamID64, batchSize, false, startAtMatchID)
+		matches, err := c.matchHistory(ctx, prio, steamID64, batchSize, false, startAtMatchID)
 		if err != nil {
 			log.Printf("[FindFatalGames] ERROR getting match history batch: %v", err)
-			if strings.Contains(err.Error(), "context deadline exceeded") || strings.Contains(err.Error(), "timeout") {
-				log.Printf("[FindFatalGames] Timeout detected, refreshing GC connection and retrying...")
-				if c.dotaClient != nil {
-					c.dotaClient.SayHello()
-				}
-				time.Sleep(2 * time.Second)
-				matches, err = c.GetPlayerMatchHistoryPaginated(steamID64, batchSize, false, startAtMatchID)
-				if err != nil {
-					log.Printf("[FindFatalGames] Retry also failed: %v", err)
-					if len(allMatches) == 0 {
-						return nil, fmt.Errorf("failed to get match history: %w", err)
-					}
-					log.Printf("[FindFatalGames] Using %d matches from previous batches", len(allMatches))
-					break
-				}
-				log.Printf("[FindFatalGames] Retry succeeded after connection refresh")
-			} else {
-				if len(allMatches) == 0 {
-					return nil, fmt.Errorf("failed to get match history: %w", err)
-				}
-				log.Printf("[FindFatalGames] Using %d matches from previous batches", len(allMatches))
-				break
+			if len(allMatches) == 0 {
+				return nil, fmt.Errorf("failed to get match history: %w", err)
 			}
+			log.Printf("[FindFatalGames] Using %d matches from previous batches", len(allMatches))
+			break
 		}
 
 		if len(matches) == 0 {
@@ -1004,81 +1089,7 @@ func (c *Client) FindFatalGames(steamID64 int64, maxDepth int, gamesPerFatal int
 		return fatalMatches, nil
 	}
 
-	currentDepth := 0
-	i := 0
-
-	log.Printf("[FindFatalGames] Starting search loop: maxDepth=%d, totalMatches=%d", maxDepth, len(matches))
-	for currentDepth < maxDepth && i < len(matches) {
-		log.Printf("[FindFatalGames] Depth %d/%d: starting from index %d", currentDepth+1, maxDepth, i)
-		foundSingleDraft := false
-		singleDraftIndex := -1
-
-		for i < len(matches) {
-			m := matches[i]
-			if m.GameMode == GameModeSingleDraft {
-				if m.LobbyType == LobbyTypeRanked {
-					log.Printf("[FindFatalGames] ERROR: Invalid match data at index %d: match %d is single draft but ranked", i, m.ID)
-					return nil, fmt.Errorf("invalid match data: single draft game cannot be ranked (match %d)", m.ID)
-				}
-				foundSingleDraft = true
-				singleDraftIndex = i
-				log.Printf("[FindFatalGames] Found single draft game at index %d: match %d (gameMode=%d, lobbyType=%d)", i, m.ID, m.GameMode, m.LobbyType)
-				break
-			}
-			i++
-		}
-
-		if !foundSingleDraft {
-			log.Printf("[FindFatalGames] No more single draft games found at depth %d", currentDepth+1)
-			break
-		}
-
-		foundRanked := false
-		rankedIndex := -1
-		log.Printf("[FindFatalGames] Searching for ranked game after single draft (starting from index %d)", singleDraftIndex+1)
-		for j := singleDraftIndex + 1; j < len(matches); j++ {
-			m := matches[j]
-			if m.LobbyType == LobbyTypeRanked {
-				log.Printf("[FindFatalGames] Found ranked game at index %d: match %d (gameMode=%d, lobbyType=%d)", j, m.ID, m.GameMode, m.LobbyType)
-				singleDraftMatch := matches[singleDraftIndex]
-
-				// Find additional ranked games BEFORE singledraft (matches are in reverse chronological order, newest first)
-				// So we need to look at indices BEFORE singleDraftIndex (which are older games)
-				var additionalIDs []int64
-				if gamesPerFatal > 0 {
-					// Start from the match right before singledraft and go backwards (to older games)
-					for k := singleDraftIndex - 1; k >= 0 && len(additionalIDs) < gamesPerFatal; k-- {
-						if matches[k].LobbyType == LobbyTypeRanked {
-							additionalIDs = append(additionalIDs, matches[k].ID)
-						}
-					}
-					log.Printf("[FindFatalGames] Found %d ranked games before singledraft (need %d)", len(additionalIDs), gamesPerFatal)
-				}
-
-				fatalMatches = append(fatalMatches, FatalMatchInfo{
-					FatalMatchID:       m.ID,
-					SingleDraftMatchID: singleDraftMatch.ID,
-					SingleDraftDate:    singleDraftMatch.StartTime,
-					AdditionalMatchIDs: additionalIDs,
-				})
-				foundRanked = true
-				rankedIndex = j
-				break
-			}
-		}
-
-		if !foundRanked {
-			log.Printf("[FindFatalGames] No ranked game found after single draft at depth %d", currentDepth+1)
-			break
-		}
-
-		currentDepth++
-		i = rankedIndex + 1
-		log.Printf("[FindFatalGames] Completed depth %d: found fatal match %d (singleDraft: %d), continuing from index %d", currentDepth, fatalMatches[len(fatalMatches)-1].FatalMatchID, fatalMatches[len(fatalMatches)-1].SingleDraftMatchID, i)
-	}
-
-	log.Printf("[FindFatalGames] Search complete: found %d fatal matches", len(fatalMatches))
-	return fatalMatches, nil
+	return gc.FindFatalMatches(matches, maxDepth, gamesPerFatal)
 }
 
 func (c *Client) connectionTimeoutWatchdog() {
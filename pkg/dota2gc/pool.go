@@ -0,0 +1,157 @@
+package dota2gc
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// account is one Steam login of the Service. The primary account is the one
+// logged in through Init; the others come from AddAccount and only serve
+// requests.
+type account struct {
+	client   *Client
+	primary  bool
+	lastUsed time.Time
+	inFlight int
+}
+
+// AccountStatus is the health of one account of the pool.
+type AccountStatus struct {
+	Username     string              `json:"username"`
+	Primary      bool                `json:"primary"`
+	Status       gc.ConnectionStatus `json:"status"`
+	ErrorMessage string              `json:"errorMessage,omitempty"`
+	Stuck        bool                `json:"stuck"`
+	InRotation   bool                `json:"inRotation"`
+	InFlight     int                 `json:"inFlight"`
+	LastUsed     time.Time           `json:"lastUsed"`
+}
+
+// usable reports whether requests may be routed to the account. Rate-limited
+// and stuck accounts stay out of rotation until they reconnect.
+func (a *account) usable() bool {
+	if a.client.IsStatusStuck() {
+		return false
+	}
+	status := a.client.GetStatus()
+	return status == StatusGCReady || status == StatusConnected
+}
+
+// AddAccount logs in an extra account that shares the request load. Its
+// sentry file is kept apart from the primary account's. A non-empty
+// sharedSecret answers its Steam Guard prompts.
+func (s *Service) AddAccount(user, pass, sharedSecret string) error {
+	s.mu.Lock()
+	for _, a := range s.pool {
+		if a.client.username == user {
+			s.mu.Unlock()
+			return fmt.Errorf("account %s is already in the pool", user)
+		}
+	}
+	if pass == "" && !HasSession(user) {
+		s.mu.Unlock()
+		return fmt.Errorf("no saved session for %s, a password is required", user)
+	}
+	c := NewClient(user, pass)
+	c.sentryPath = filepath.Join(filepath.Dir(c.sentryPath), fmt.Sprintf("sentry-%s.bin", user))
+	if sharedSecret != "" {
+		if err := c.SetSharedSecret(sharedSecret); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	c.onStatusChange = s.statusChanged
+	s.pool = append(s.pool, &account{client: c})
+	s.mu.Unlock()
+
+	log.Printf("Adding pool account %s", user)
+	return c.Connect()
+}
+
+// Accounts returns the health of the primary account and the pool.
+func (s *Service) Accounts() []AccountStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []AccountStatus
+	for _, a := range s.accounts() {
+		list = append(list, AccountStatus{
+			Username:     a.client.username,
+			Primary:      a.primary,
+			Status:       a.client.GetStatus(),
+			ErrorMessage: a.client.GetLastErrorMessage(),
+			Stuck:        a.client.IsStatusStuck(),
+			InRotation:   a.usable(),
+			InFlight:     a.inFlight,
+			LastUsed:     a.lastUsed,
+		})
+	}
+	return list
+}
+
+// accounts returns the primary account, if logged in, followed by the pool.
+// s.mu must be held.
+func (s *Service) accounts() []*account {
+	list := make([]*account, 0, len(s.pool)+1)
+	if s.primary != nil {
+		list = append(list, s.primary)
+	}
+	return append(list, s.pool...)
+}
+
+// acquire picks the usable account that is least busy and, among those, least
+// recently used, skipping the ones in tried. With no usable account it falls
+// back to the primary one so callers get its error, as before pooling.
+func (s *Service) acquire(tried map[*account]bool) *account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *account
+	for _, a := range s.accounts() {
+		if tried[a] || !a.usable() {
+			continue
+		}
+		if best == nil || a.inFlight < best.inFlight ||
+			(a.inFlight == best.inFlight && a.lastUsed.Before(best.lastUsed)) {
+			best = a
+		}
+	}
+	if best == nil {
+		if s.primary == nil || tried[s.primary] {
+			return nil
+		}
+		best = s.primary
+	}
+	best.inFlight++
+	best.lastUsed = time.Now()
+	return best
+}
+
+func (s *Service) release(a *account) {
+	s.mu.Lock()
+	a.inFlight--
+	s.mu.Unlock()
+}
+
+// do runs fn on the pool. When an account gets rate-limited or stuck during
+// the call, fn is retried on the next account.
+func (s *Service) do(fn func(c *Client) error) error {
+	tried := make(map[*account]bool)
+	var lastErr error = ErrNotInitialized
+	for {
+		a := s.acquire(tried)
+		if a == nil {
+			return lastErr
+		}
+		tried[a] = true
+		lastErr = fn(a.client)
+		s.release(a)
+		if lastErr == nil || a.usable() {
+			return lastErr
+		}
+		log.Printf("Account %s left rotation (status %d): %v", a.client.username, a.client.GetStatus(), lastErr)
+	}
+}
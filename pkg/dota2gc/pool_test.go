@@ -0,0 +1,128 @@
+package dota2gc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testAccount returns an account whose client is in status without having
+// connected to Steam.
+func testAccount(user string, status ConnectionStatus) *account {
+	return &account{client: &Client{username: user, status: status, statusSetTime: time.Now()}}
+}
+
+// testService returns a Service with primary and pool accounts and no
+// publish loop.
+func testService(primary *account, pool ...*account) *Service {
+	if primary != nil {
+		primary.primary = true
+	}
+	return &Service{primary: primary, pool: pool, changed: make(chan struct{}, 1)}
+}
+
+func TestAcquirePicksLeastBusy(t *testing.T) {
+	a, b, c := testAccount("a", StatusGCReady), testAccount("b", StatusGCReady), testAccount("c", StatusGCReady)
+	s := testService(a, b, c)
+	now := time.Now()
+	a.inFlight, a.lastUsed = 1, now.Add(-time.Hour)
+	b.lastUsed = now.Add(-time.Minute)
+	c.lastUsed = now.Add(-2 * time.Minute)
+
+	if got := s.acquire(nil); got != c {
+		t.Fatalf("got %s, want c: idle and least recently used", got.client.username)
+	}
+	if got := s.acquire(nil); got != b {
+		t.Fatalf("got %s, want b: the only other idle account", got.client.username)
+	}
+	if c.inFlight != 1 || b.inFlight != 1 {
+		t.Fatalf("in flight b=%d c=%d, want 1 each", b.inFlight, c.inFlight)
+	}
+	s.release(c)
+	if c.inFlight != 0 {
+		t.Fatalf("in flight after release = %d, want 0", c.inFlight)
+	}
+}
+
+func TestAcquireSkipsUnusable(t *testing.T) {
+	primary := testAccount("primary", StatusRateLimited)
+	stuck := testAccount("stuck", StatusConnecting)
+	stuck.client.statusSetTime = time.Now().Add(-time.Minute)
+	ready := testAccount("ready", StatusConnected)
+	s := testService(primary, stuck, ready)
+
+	if got := s.acquire(nil); got != ready {
+		t.Fatalf("got %s, want ready", got.client.username)
+	}
+	// With nothing usable left the primary account answers, so its error
+	// reaches the caller.
+	tried := map[*account]bool{ready: true}
+	if got := s.acquire(tried); got != primary {
+		t.Fatalf("got %v, want the primary account", got)
+	}
+	tried[primary] = true
+	if got := s.acquire(tried); got != nil {
+		t.Fatalf("got %s after trying every account, want none", got.client.username)
+	}
+}
+
+func TestDoFailsOver(t *testing.T) {
+	a, b := testAccount("a", StatusGCReady), testAccount("b", StatusGCReady)
+	s := testService(a, b)
+	b.lastUsed = time.Now()
+
+	var calls []string
+	err := s.do(func(c *Client) error {
+		calls = append(calls, c.username)
+		if c == a.client {
+			c.SetStatus(StatusRateLimited)
+			return errors.New("rate limited")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if a.inFlight != 0 || b.inFlight != 0 {
+		t.Fatalf("in flight a=%d b=%d, want 0", a.inFlight, b.inFlight)
+	}
+}
+
+func TestDoKeepsErrorOfUsableAccount(t *testing.T) {
+	a, b := testAccount("a", StatusGCReady), testAccount("b", StatusGCReady)
+	s := testService(a, b)
+	b.lastUsed = time.Now()
+
+	want := errors.New("match not found")
+	calls := 0
+	err := s.do(func(c *Client) error {
+		calls++
+		return want
+	})
+	if err != want || calls != 1 {
+		t.Fatalf("got %v after %d calls, want the error of the first call", err, calls)
+	}
+}
+
+func TestDoReturnsLastError(t *testing.T) {
+	if err := testService(nil).do(func(c *Client) error { return nil }); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("no accounts: %v, want ErrNotInitialized", err)
+	}
+
+	a, b := testAccount("a", StatusGCReady), testAccount("b", StatusGCReady)
+	s := testService(a, b)
+	b.lastUsed = time.Now()
+	calls := 0
+	err := s.do(func(c *Client) error {
+		calls++
+		c.SetStatus(StatusRateLimited)
+		return errors.New(c.username + " rate limited")
+	})
+	if err == nil || err.Error() != "b rate limited" || calls != 2 {
+		t.Fatalf("got %v after %d calls, want b's error after 2", err, calls)
+	}
+}
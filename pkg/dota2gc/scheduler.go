@@ -0,0 +1,239 @@
+package dota2gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// Typed errors of GC requests, shared with botclient through package gc.
+var (
+	ErrNotReady    = gc.ErrNotReady
+	ErrRateLimited = gc.ErrRateLimited
+	ErrTimeout     = gc.ErrTimeout
+)
+
+const (
+	// requestGap is the minimum time between two requests on one session.
+	// The GC drops sessions that send requests back to back.
+	requestGap = 500 * time.Millisecond
+	// requestTimeout is the default deadline of one attempt.
+	requestTimeout = 10 * time.Second
+	// requestRetries is how often a timed out request is sent again after
+	// refreshing the session.
+	requestRetries = 2
+	// readyWait is how long a request waits for a session that is still
+	// being set up.
+	readyWait = 5 * time.Second
+)
+
+// gcRequest is one GC call waiting in a Client's queue.
+type gcRequest struct {
+	name     string
+	priority gc.Priority
+	seq      uint64
+	ctx      context.Context
+	timeout  time.Duration
+	run      func(ctx context.Context) error
+	done     chan error
+}
+
+// requestQueue serialises the GC requests of one Client. Interactive
+// requests go first; requests of the same priority run in the order they
+// were made.
+type requestQueue struct {
+	mu      sync.Mutex
+	pending []*gcRequest
+	seq     uint64
+	running bool
+	last    time.Time
+}
+
+// request runs fn through the client's queue and returns its error. Each
+// attempt gets a context with the given timeout (requestTimeout if zero),
+// bounded by ctx. Attempts that time out refresh the session and are retried
+// with backoff; when all fail the error wraps ErrTimeout.
+func (c *Client) request(ctx context.Context, prio gc.Priority, name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout == 0 {
+		timeout = requestTimeout
+	}
+	r := &gcRequest{
+		name:     name,
+		priority: prio,
+		ctx:      ctx,
+		timeout:  timeout,
+		run:      fn,
+		done:     make(chan error, 1),
+	}
+
+	q := &c.queue
+	q.mu.Lock()
+	q.seq++
+	r.seq = q.seq
+	q.pending = append(q.pending, r)
+	if !q.running {
+		q.running = true
+		go c.runQueue()
+	}
+	q.mu.Unlock()
+
+	select {
+	case err := <-r.done:
+		return err
+	case <-ctx.Done():
+		// The worker drops requests whose context has ended.
+		return fmt.Errorf("%w: %s: %v", ErrTimeout, name, ctx.Err())
+	}
+}
+
+// runQueue executes queued requests one at a time, keeping requestGap
+// between them, and exits once the queue is empty.
+func (c *Client) runQueue() {
+	q := &c.queue
+	for {
+		q.mu.Lock()
+		r := q.next()
+		if r == nil {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		wait := requestGap - time.Since(q.last)
+		q.mu.Unlock()
+
+		if r.ctx.Err() != nil {
+			continue
+		}
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+		r.done <- c.execute(r)
+
+		q.mu.Lock()
+		q.last = time.Now()
+		q.mu.Unlock()
+	}
+}
+
+// next removes and returns the request to run next, or nil. q.mu must be
+// held.
+func (q *requestQueue) next() *gcRequest {
+	best := -1
+	for i, r := range q.pending {
+		if best < 0 || r.priority < q.pending[best].priority ||
+			(r.priority == q.pending[best].priority && r.seq < q.pending[best].seq) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	r := q.pending[best]
+	q.pending = append(q.pending[:best], q.pending[best+1:]...)
+	return r
+}
+
+// execute waits for the session and runs r, retrying attempts that time out
+// or lose the session.
+func (c *Client) execute(r *gcRequest) error {
+	var err error
+	for attempt := 0; attempt <= requestRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Second << uint(attempt-1)
+			log.Printf("[%s] %v; refreshing GC session and retrying in %v (%d/%d)", r.name, err, backoff, attempt, requestRetries)
+			c.refreshSession()
+			select {
+			case <-time.After(backoff):
+			case <-r.ctx.Done():
+				return fmt.Errorf("%w: %s: %v", ErrTimeout, r.name, r.ctx.Err())
+			}
+		}
+		if err := c.waitReady(r.ctx, r.name); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+		err = r.run(ctx)
+		timedOut := ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded)
+		cancel()
+		if err == nil || r.ctx.Err() != nil {
+			break
+		}
+		if !timedOut && !c.sessionDropped() {
+			return err
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case c.sessionDropped():
+		return fmt.Errorf("%w: %s: %v", ErrNotReady, r.name, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrTimeout, r.name, err)
+}
+
+// sessionDropped reports whether the session went away, so a failed request
+// is worth sending again once it is back.
+func (c *Client) sessionDropped() bool {
+	status := c.GetStatus()
+	return status != StatusGCReady && status != StatusConnected
+}
+
+// waitReady returns once the GC session can take requests. A session that
+// is connected to Steam but has no GC welcome yet is greeted for up to
+// readyWait and then used anyway, since the GC often answers before it
+// welcomes us.
+func (c *Client) waitReady(ctx context.Context, name string) error {
+	deadline := time.Now().Add(readyWait)
+	hello := time.Time{}
+	for {
+		status := c.GetStatus()
+		switch status {
+		case StatusGCReady:
+			return nil
+		case StatusRateLimited:
+			if msg := c.GetLastErrorMessage(); msg != "" {
+				return fmt.Errorf("%w: %s", ErrRateLimited, msg)
+			}
+			return ErrRateLimited
+		case StatusConnected:
+			if time.Now().After(deadline) {
+				log.Printf("[%s] GC still not Ready after %v, proceeding anyway", name, readyWait)
+				return nil
+			}
+			if time.Since(hello) >= time.Second && c.dotaClient != nil {
+				go c.dotaClient.SayHello()
+				hello = time.Now()
+			}
+		case StatusConnecting:
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%w (status %d)", ErrNotReady, status)
+			}
+		default:
+			return fmt.Errorf("%w (status %d)", ErrNotReady, status)
+		}
+
+		select {
+		case <-time.After(250 * time.Millisecond):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s: %v", ErrTimeout, name, ctx.Err())
+		}
+	}
+}
+
+// refreshSession asks the GC for a new session after a request went
+// unanswered.
+func (c *Client) refreshSession() {
+	if c.dotaClient == nil {
+		return
+	}
+	if c.GetStatus() == StatusGCReady {
+		c.SetStatus(StatusConnected)
+	}
+	c.dotaClient.SayHello()
+}
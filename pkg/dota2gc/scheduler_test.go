@@ -0,0 +1,177 @@
+package dota2gc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+func TestQueueNext(t *testing.T) {
+	var q requestQueue
+	for _, r := range []struct {
+		name string
+		prio gc.Priority
+	}{
+		{"bg1", gc.PriorityBackground},
+		{"ui1", gc.PriorityInteractive},
+		{"bg2", gc.PriorityBackground},
+		{"ui2", gc.PriorityInteractive},
+	} {
+		q.seq++
+		q.pending = append(q.pending, &gcRequest{name: r.name, priority: r.prio, seq: q.seq})
+	}
+
+	var got []string
+	for r := q.next(); r != nil; r = q.next() {
+		got = append(got, r.name)
+	}
+	if want := []string{"ui1", "ui2", "bg1", "bg2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// queued waits until n requests are waiting in the queue of c.
+func queued(t *testing.T, c *Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.queue.mu.Lock()
+		got := len(c.queue.pending)
+		c.queue.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	c := testAccount("a", StatusGCReady).client
+	var mu sync.Mutex
+	var order []string
+	run := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	// The first request holds the worker until the others are queued.
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.request(context.Background(), gc.PriorityBackground, "first", 0, func(ctx context.Context) error {
+			close(started)
+			<-release
+			return run("first")(ctx)
+		})
+	}()
+	<-started
+	for i, r := range []struct {
+		name string
+		prio gc.Priority
+	}{
+		{"bg1", gc.PriorityBackground},
+		{"bg2", gc.PriorityBackground},
+		{"ui", gc.PriorityInteractive},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.request(context.Background(), r.prio, r.name, 0, run(r.name))
+		}()
+		queued(t, c, i+1)
+	}
+	close(release)
+	wg.Wait()
+
+	if want := []string{"first", "ui", "bg1", "bg2"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+}
+
+func TestRequestGap(t *testing.T) {
+	c := testAccount("a", StatusGCReady).client
+	var times []time.Time
+	for i := 0; i < 3; i++ {
+		err := c.request(context.Background(), gc.PriorityInteractive, "req", 0, func(context.Context) error {
+			times = append(times, time.Now())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < requestGap {
+			t.Errorf("request %d sent %v after the previous one, want at least %v", i, gap, requestGap)
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name   string
+		status ConnectionStatus
+		err    error
+		want   error
+		runs   int
+	}{
+		{"success", StatusGCReady, nil, nil, 1},
+		{"other errors are not retried", StatusGCReady, boom, boom, 1},
+		{"disconnected", StatusDisconnected, nil, ErrNotReady, 0},
+		{"rate limited", StatusRateLimited, nil, ErrRateLimited, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testAccount("a", tt.status).client
+			runs := 0
+			err := c.request(context.Background(), gc.PriorityInteractive, "req", 0, func(context.Context) error {
+				runs++
+				return tt.err
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if runs != tt.runs {
+				t.Errorf("ran %d times, want %d", runs, tt.runs)
+			}
+		})
+	}
+}
+
+func TestRequestEndedContext(t *testing.T) {
+	c := testAccount("a", StatusGCReady).client
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var ran []string
+	run := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	if err := c.request(ctx, gc.PriorityInteractive, "ended", 0, run("ended")); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	// Once a later request is done, the worker has dropped the ended one.
+	if err := c.request(context.Background(), gc.PriorityInteractive, "later", 0, run("later")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"later"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+}
@@ -0,0 +1,401 @@
+package dota2gc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/steamguard"
+	"github.com/paralin/go-dota2/protocol"
+)
+
+// ErrNotInitialized is returned by Service calls made before Init. It wraps
+// ErrNotReady.
+var ErrNotInitialized = fmt.Errorf("%w: client not initialized", gc.ErrNotReady)
+
+// Service is a gc.Service that runs the GC client in this process. It owns
+// the Client of the primary login, replaced on every Init, and a pool of
+// extra accounts that GC requests are spread over.
+type Service struct {
+	mu      sync.Mutex
+	primary *account
+	pool    []*account
+	// sharedSecret generates Steam Guard codes for primary logins.
+	sharedSecret string
+
+	events gc.Broadcaster
+	// changed wakes publishLoop; clients signal it from any goroutine.
+	changed chan struct{}
+}
+
+var _ gc.Service = (*Service)(nil)
+
+// NewService returns a Service with no account logged in.
+func NewService() *Service {
+	s := &Service{changed: make(chan struct{}, 1)}
+	s.events.Publish(gc.NewStatusEvent(gc.StatusDisconnected, ""))
+	go s.publishLoop()
+	return s
+}
+
+// statusChanged is the clients' onStatusChange hook. It only signals, since
+// clients change status while s.mu is held.
+func (s *Service) statusChanged() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// publishLoop publishes the current status after every change. Bursts of
+// changes are coalesced, so the last published event is always current.
+func (s *Service) publishLoop() {
+	for range s.changed {
+		status, reason := s.GetStatusWithError()
+		ev := gc.NewStatusEvent(status, reason)
+		if c := s.current(); c != nil {
+			ev.SessionSaved = c.SessionSaved()
+		}
+		s.events.Publish(ev)
+	}
+}
+
+func (s *Service) Subscribe() (<-chan gc.StatusEvent, func()) {
+	return s.events.Subscribe()
+}
+
+// current returns the client of the primary login, or nil.
+func (s *Service) current() *Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.primary == nil {
+		return nil
+	}
+	return s.primary.client
+}
+
+// Init closes any existing session and logs in as user.
+func (s *Service) Init(user, pass string) error {
+	s.mu.Lock()
+
+	// Close existing client if any, and wait for cleanup
+	if s.primary != nil {
+		log.Printf("Closing existing client before reinitializing...")
+		s.primary.client.Close()
+		s.primary = nil
+		// Give a moment for cleanup to complete
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if pass == "" && !HasSession(user) {
+		s.mu.Unlock()
+		return fmt.Errorf("no saved session for %s, a password is required", user)
+	}
+
+	log.Printf("Initializing bot for user %s", user)
+	c := NewClient(user, pass)
+	if s.sharedSecret != "" {
+		if err := c.SetSharedSecret(s.sharedSecret); err != nil {
+			log.Printf("Ignoring Steam Guard shared secret: %v", err)
+		}
+	}
+	c.onStatusChange = s.statusChanged
+	s.primary = &account{client: c, primary: true}
+
+	s.mu.Unlock() // Release lock before potentially blocking Connect()
+
+	if err := c.Connect(); err != nil {
+		log.Printf("Failed to connect: %v", err)
+		s.mu.Lock()
+		if s.primary != nil && s.primary.client == c {
+			s.primary = nil
+		}
+		s.mu.Unlock()
+		s.statusChanged()
+		return err
+	}
+	return nil
+}
+
+func (s *Service) SubmitCode(code string) error {
+	c := s.current()
+	if c == nil {
+		return ErrNotInitialized
+	}
+	return c.SubmitCode(code)
+}
+
+// SubmitCodeFor submits a Steam Guard code for the account logged in as
+// user, either the primary one or one of the pool.
+func (s *Service) SubmitCodeFor(user, code string) error {
+	s.mu.Lock()
+	var c *Client
+	for _, a := range s.accounts() {
+		if a.client.username == user {
+			c = a.client
+			break
+		}
+	}
+	s.mu.Unlock()
+	if c == nil {
+		return ErrNotInitialized
+	}
+	return c.SubmitCode(code)
+}
+
+// SetSharedSecret makes the following Init logins answer Steam Guard prompts
+// with codes generated from sharedSecret. An empty secret turns it off.
+func (s *Service) SetSharedSecret(sharedSecret string) error {
+	if sharedSecret != "" {
+		if _, err := steamguard.DecodeSecret(sharedSecret); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.sharedSecret = sharedSecret
+	s.mu.Unlock()
+	return nil
+}
+
+// LoggedIn reports whether Init has been called since the last Disconnect.
+func (s *Service) LoggedIn() bool {
+	return s.current() != nil
+}
+
+// Disconnect closes the primary session, if any. Pool accounts stay logged
+// in.
+func (s *Service) Disconnect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.primary != nil {
+		s.primary.client.Close()
+		s.primary = nil
+	}
+	s.statusChanged()
+	return nil
+}
+
+// ForgetSession disconnects the primary login if it is user and deletes the
+// saved session of user.
+func (s *Service) ForgetSession(user string) error {
+	s.mu.Lock()
+	if s.primary != nil && s.primary.client.username == user {
+		s.primary.client.Close()
+		s.primary = nil
+	}
+	s.mu.Unlock()
+	s.statusChanged()
+	log.Printf("Forgetting saved session of %s", user)
+	return forgetSession(user)
+}
+
+func (s *Service) GetStatus() gc.ConnectionStatus {
+	status, _ := s.GetStatusWithError()
+	return status
+}
+
+// GetStatusWithError reports the primary login. While it cannot serve
+// requests but a pool account can, the service is reported ready.
+func (s *Service) GetStatusWithError() (gc.ConnectionStatus, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.primary == nil || !s.primary.usable() {
+		for _, a := range s.pool {
+			if a.usable() {
+				return gc.StatusGCReady, ""
+			}
+		}
+	}
+	if s.primary == nil {
+		return gc.StatusDisconnected, ""
+	}
+	return s.primary.client.GetStatus(), s.primary.client.GetLastErrorMessage()
+}
+
+func (s *Service) GetReplayInfo(ctx context.Context, matchID uint64) (uint32, uint64, error) {
+	return s.replayInfo(ctx, gc.PriorityInteractive, matchID)
+}
+
+func (s *Service) replayInfo(ctx context.Context, prio gc.Priority, matchID uint64) (uint32, uint64, error) {
+	var cluster uint32
+	var salt uint64
+	err := s.do(func(c *Client) error {
+		var err error
+		cluster, salt, err = c.replayInfo(ctx, prio, matchID)
+		return err
+	})
+	return cluster, salt, err
+}
+
+func (s *Service) GetMatchDetails(ctx context.Context, matchID uint64) (*gc.MatchDetails, error) {
+	return s.matchDetails(ctx, gc.PriorityInteractive, matchID)
+}
+
+func (s *Service) matchDetails(ctx context.Context, prio gc.Priority, matchID uint64) (*gc.MatchDetails, error) {
+	var match *protocol.CMsgDOTAMatch
+	err := s.do(func(c *Client) error {
+		var err error
+		match, err = c.matchDetails(ctx, prio, matchID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertMatchDetails(match), nil
+}
+
+// convertMatchDetails copies the parts of a GC match record the server uses.
+func convertMatchDetails(m *protocol.CMsgDOTAMatch) *gc.MatchDetails {
+	d := &gc.MatchDetails{
+		MatchID:   int64(m.GetMatchId()),
+		StartTime: m.GetStarttime(),
+		Duration:  m.GetDuration(),
+		GameMode:  uint32(m.GetGameMode()),
+		LobbyType: m.GetLobbyType(),
+		Cluster:   m.GetCluster(),
+		Salt:      uint64(m.GetReplaySalt()),
+	}
+	switch m.GetMatchOutcome() {
+	case protocol.EMatchOutcome_k_EMatchOutcome_RadVictory:
+		d.Winner = "radiant"
+	case protocol.EMatchOutcome_k_EMatchOutcome_DireVictory:
+		d.Winner = "dire"
+	}
+	for _, p := range m.GetPlayers() {
+		// Older records only have the packed player slot: bit 7 is the
+		// team, the low bits the position in it.
+		dire := p.GetPlayerSlot()&0x80 != 0
+		pos := int(p.GetPlayerSlot() & 0x7)
+		if p.TeamNumber != nil {
+			dire = p.GetTeamNumber() == protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_BAD_GUYS
+			pos = int(p.GetTeamSlot())
+		}
+		player := gc.MatchPlayer{
+			AccountID:    p.GetAccountId(),
+			Slot:         pos,
+			Team:         2,
+			HeroID:       int(p.GetHeroId()),
+			Name:         p.GetPlayerName(),
+			Kills:        p.GetKills(),
+			Deaths:       p.GetDeaths(),
+			Assists:      p.GetAssists(),
+			LeaverStatus: p.GetLeaverStatus(),
+		}
+		if dire {
+			player.Slot += 5
+			player.Team = 3
+		}
+		d.Players = append(d.Players, player)
+	}
+	return d
+}
+
+func (s *Service) GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]gc.Match, error) {
+	return s.GetPlayerMatchHistoryPaginated(ctx, steamID64, limit, turboOnly, 0)
+}
+
+func (s *Service) GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]gc.Match, error) {
+	return s.matchHistory(ctx, gc.PriorityInteractive, steamID64, limit, turboOnly, startAtMatchID)
+}
+
+func (s *Service) matchHistory(ctx context.Context, prio gc.Priority, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]gc.Match, error) {
+	var matches []gc.Match
+	err := s.do(func(c *Client) error {
+		var err error
+		matches, err = c.matchHistory(ctx, prio, steamID64, limit, turboOnly, startAtMatchID)
+		return err
+	})
+	return matches, err
+}
+
+func (s *Service) FindFatalGames(ctx context.Context, steamID64 int64, maxDepth int, gamesPerFatal int) ([]gc.FatalMatchInfo, error) {
+	return s.findFatalGames(ctx, gc.PriorityInteractive, steamID64, maxDepth, gamesPerFatal)
+}
+
+func (s *Service) findFatalGames(ctx context.Context, prio gc.Priority, steamID64 int64, maxDepth int, gamesPerFatal int) ([]gc.FatalMatchInfo, error) {
+	var matches []gc.FatalMatchInfo
+	err := s.do(func(c *Client) error {
+		var err error
+		matches, err = c.findFatalGames(ctx, prio, steamID64, maxDepth, gamesPerFatal)
+		return err
+	})
+	return matches, err
+}
+
+// GetPlayerConductScorecard returns the primary account's own scorecard, so
+// it is never routed to the pool.
+func (s *Service) GetPlayerConductScorecard(ctx context.Context) (*gc.ConductScorecard, error) {
+	return s.conductScorecard(ctx, gc.PriorityInteractive)
+}
+
+func (s *Service) conductScorecard(ctx context.Context, prio gc.Priority) (*gc.ConductScorecard, error) {
+	c := s.current()
+	if c == nil {
+		return nil, ErrNotInitialized
+	}
+	res, err := c.conductScorecard(ctx, prio)
+	if err != nil {
+		return nil, err
+	}
+	return &gc.ConductScorecard{
+		AccountID:           res.GetAccountId(),
+		MatchID:             res.GetMatchId(),
+		SeqNum:              res.GetSeqNum(),
+		Reasons:             res.GetReasons(),
+		MatchesInReport:     res.GetMatchesInReport(),
+		MatchesClean:        res.GetMatchesClean(),
+		MatchesReported:     res.GetMatchesReported(),
+		MatchesAbandoned:    res.GetMatchesAbandoned(),
+		ReportsCount:        res.GetReportsCount(),
+		ReportsParties:      res.GetReportsParties(),
+		CommendCount:        res.GetCommendCount(),
+		Date:                res.GetDate(),
+		RawBehaviorScore:    res.GetRawBehaviorScore(),
+		OldRawBehaviorScore: res.GetOldRawBehaviorScore(),
+		CommsReports:        res.GetCommsReports(),
+		CommsParties:        res.GetCommsParties(),
+		BehaviorRating:      int32(res.GetBehaviorRating()),
+	}, nil
+}
+
+// WithPriority returns a view of s whose GC requests run at prio. Logins and
+// status calls go to s itself.
+func (s *Service) WithPriority(prio gc.Priority) gc.Service {
+	if prio == gc.PriorityInteractive {
+		return s
+	}
+	return &serviceView{Service: s, priority: prio}
+}
+
+// serviceView is a Service whose requests run at a fixed priority.
+type serviceView struct {
+	*Service
+	priority gc.Priority
+}
+
+func (v *serviceView) GetReplayInfo(ctx context.Context, matchID uint64) (uint32, uint64, error) {
+	return v.replayInfo(ctx, v.priority, matchID)
+}
+
+func (v *serviceView) GetMatchDetails(ctx context.Context, matchID uint64) (*gc.MatchDetails, error) {
+	return v.matchDetails(ctx, v.priority, matchID)
+}
+
+func (v *serviceView) GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]gc.Match, error) {
+	return v.matchHistory(ctx, v.priority, steamID64, limit, turboOnly, 0)
+}
+
+func (v *serviceView) GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]gc.Match, error) {
+	return v.matchHistory(ctx, v.priority, steamID64, limit, turboOnly, startAtMatchID)
+}
+
+func (v *serviceView) FindFatalGames(ctx context.Context, steamID64 int64, maxDepth int, gamesPerFatal int) ([]gc.FatalMatchInfo, error) {
+	return v.findFatalGames(ctx, v.priority, steamID64, maxDepth, gamesPerFatal)
+}
+
+func (v *serviceView) GetPlayerConductScorecard(ctx context.Context) (*gc.ConductScorecard, error) {
+	return v.conductScorecard(ctx, v.priority)
+}
@@ -0,0 +1,57 @@
+package dota2gc
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/paralin/go-dota2/protocol"
+)
+
+func TestConvertMatchDetailsSlots(t *testing.T) {
+	radiant := protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_GOOD_GUYS
+	dire := protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_BAD_GUYS
+	tests := []struct {
+		name   string
+		player *protocol.CMsgDOTAMatch_Player
+		slot   int
+		team   int32
+	}{
+		{"packed radiant", &protocol.CMsgDOTAMatch_Player{PlayerSlot: proto.Uint32(3)}, 3, 2},
+		{"packed dire", &protocol.CMsgDOTAMatch_Player{PlayerSlot: proto.Uint32(0x80 | 2)}, 7, 3},
+		{"team radiant", &protocol.CMsgDOTAMatch_Player{TeamNumber: radiant.Enum(), TeamSlot: proto.Uint32(4)}, 4, 2},
+		{"team dire", &protocol.CMsgDOTAMatch_Player{TeamNumber: dire.Enum(), TeamSlot: proto.Uint32(0)}, 5, 3},
+		// The team fields win over a packed slot that disagrees.
+		{"team over packed", &protocol.CMsgDOTAMatch_Player{PlayerSlot: proto.Uint32(1), TeamNumber: dire.Enum(), TeamSlot: proto.Uint32(1)}, 6, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := convertMatchDetails(&protocol.CMsgDOTAMatch{Players: []*protocol.CMsgDOTAMatch_Player{tt.player}})
+			if len(d.Players) != 1 {
+				t.Fatalf("got %d players, want 1", len(d.Players))
+			}
+			if p := d.Players[0]; p.Slot != tt.slot || p.Team != tt.team {
+				t.Fatalf("got slot %d team %d, want slot %d team %d", p.Slot, p.Team, tt.slot, tt.team)
+			}
+		})
+	}
+}
+
+func TestConvertMatchDetailsWinner(t *testing.T) {
+	tests := []struct {
+		outcome protocol.EMatchOutcome
+		want    string
+	}{
+		{protocol.EMatchOutcome_k_EMatchOutcome_RadVictory, "radiant"},
+		{protocol.EMatchOutcome_k_EMatchOutcome_DireVictory, "dire"},
+		{protocol.EMatchOutcome_k_EMatchOutcome_Unknown, ""},
+	}
+	for _, tt := range tests {
+		d := convertMatchDetails(&protocol.CMsgDOTAMatch{MatchId: proto.Uint64(8000000001), MatchOutcome: tt.outcome.Enum()})
+		if d.Winner != tt.want {
+			t.Errorf("outcome %s: got winner %q, want %q", tt.outcome, d.Winner, tt.want)
+		}
+		if d.MatchID != 8000000001 {
+			t.Errorf("outcome %s: got match %d, want 8000000001", tt.outcome, d.MatchID)
+		}
+	}
+}
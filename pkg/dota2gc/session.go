@@ -0,0 +1,216 @@
+package dota2gc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Saved sessions are Steam login keys, handed out after a login with
+// ShouldRememberPassword, that log in again without the password. They are
+// encrypted with AES-GCM under a key derived from the passphrase in
+// STEAM_SESSION_PASSPHRASE, which is never written to disk. Without a
+// passphrase no session is saved and every login needs the password.
+var sessionsMu sync.Mutex
+
+// SessionPassphraseEnv names the environment variable holding the session
+// passphrase.
+const SessionPassphraseEnv = "STEAM_SESSION_PASSPHRASE"
+
+// ErrNoPassphrase is returned when saving a session without a passphrase.
+var ErrNoPassphrase = fmt.Errorf("%s is not set, sessions are not saved", SessionPassphraseEnv)
+
+// sessionKDFIterations is the PBKDF2 work factor of the session key.
+var sessionKDFIterations = 600000
+
+// sessionsFile is the content of sessions.json.
+type sessionsFile struct {
+	// Salt is the base64 PBKDF2 salt of the key.
+	Salt string `json:"salt"`
+	// Sessions are the base64 sealed login keys by username.
+	Sessions map[string]string `json:"sessions"`
+}
+
+func sessionDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps")
+}
+
+// derivedKey caches the last key derived, since PBKDF2 is slow on purpose.
+var derivedKey struct {
+	passphrase string
+	salt       string
+	key        []byte
+}
+
+// sessionAEAD returns the cipher for the sessions in f, creating a salt if
+// f has none.
+func sessionAEAD(f *sessionsFile) (cipher.AEAD, error) {
+	passphrase := os.Getenv(SessionPassphraseEnv)
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	if f.Salt == "" {
+		salt := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
+		f.Salt = base64.StdEncoding.EncodeToString(salt)
+	}
+	if derivedKey.key == nil || derivedKey.passphrase != passphrase || derivedKey.salt != f.Salt {
+		salt, err := base64.StdEncoding.DecodeString(f.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt session salt: %w", err)
+		}
+		key, err := pbkdf2.Key(sha256.New, passphrase, salt, sessionKDFIterations, 32)
+		if err != nil {
+			return nil, err
+		}
+		derivedKey.passphrase, derivedKey.salt, derivedKey.key = passphrase, f.Salt, key
+	}
+	block, err := aes.NewCipher(derivedKey.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readSessions returns the saved sessions. Files from before the
+// passphrase, whose key was stored next to them, read as empty.
+func readSessions() (*sessionsFile, error) {
+	f := &sessionsFile{}
+	data, err := os.ReadFile(filepath.Join(sessionDir(), "sessions.json"))
+	if os.IsNotExist(err) {
+		f.Sessions = make(map[string]string)
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse sessions: %w", err)
+	}
+	if f.Sessions == nil {
+		f.Sessions = make(map[string]string)
+	}
+	return f, nil
+}
+
+func writeSessions(f *sessionsFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(sessionDir(), "sessions.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// The key of older versions decrypts nothing that is left.
+	os.Remove(filepath.Join(sessionDir(), "session.key"))
+	return nil
+}
+
+// loadSession returns the saved login key of user, or "" if there is none.
+func loadSession(user string) (string, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	f, err := readSessions()
+	if err != nil {
+		return "", err
+	}
+	enc, ok := f.Sessions[user]
+	if !ok {
+		return "", nil
+	}
+	aead, err := sessionAEAD(f)
+	if err != nil {
+		return "", err
+	}
+	return openSession(aead, user, enc)
+}
+
+// openSession decrypts a sealed login key of user.
+func openSession(aead cipher.AEAD, user, enc string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", fmt.Errorf("corrupt session for %s: %w", user, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("corrupt session for %s", user)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(user))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt session for %s: %w", user, err)
+	}
+	return string(plain), nil
+}
+
+// saveSession stores the login key of user. Sessions of other users that
+// do not open with the current passphrase, because it changed since they
+// were saved, are dropped so the file never mixes keys.
+func saveSession(user, loginKey string) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	f, err := readSessions()
+	if err != nil {
+		return err
+	}
+	aead, err := sessionAEAD(f)
+	if err != nil {
+		return err
+	}
+	for other, enc := range f.Sessions {
+		if other == user {
+			continue
+		}
+		if _, err := openSession(aead, other, enc); err != nil {
+			log.Printf("Steam: Dropping saved session of %s: %v", other, err)
+			delete(f.Sessions, other)
+		}
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	f.Sessions[user] = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(loginKey), []byte(user)))
+	return writeSessions(f)
+}
+
+// forgetSession deletes the saved login key of user.
+func forgetSession(user string) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	f, err := readSessions()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Sessions[user]; !ok {
+		return nil
+	}
+	delete(f.Sessions, user)
+	return writeSessions(f)
+}
+
+// HasSession reports whether user can log in without a password.
+func HasSession(user string) bool {
+	key, err := loadSession(user)
+	return err == nil && key != ""
+}
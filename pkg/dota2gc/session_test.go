@@ -0,0 +1,178 @@
+package dota2gc
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempSessions keeps sessions under a temporary home with passphrase and
+// a fast key derivation.
+func useTempSessions(t *testing.T, passphrase string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(SessionPassphraseEnv, passphrase)
+	saved := sessionKDFIterations
+	sessionKDFIterations = 1000
+	t.Cleanup(func() { sessionKDFIterations = saved })
+	return filepath.Join(home, ".dota-report-timestamps")
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir := useTempSessions(t, "correct horse")
+	if HasSession("alice") {
+		t.Fatal("HasSession before saving")
+	}
+	if err := saveSession("alice", "key-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSession("bob", "key-b"); err != nil {
+		t.Fatal(err)
+	}
+	for user, want := range map[string]string{"alice": "key-a", "bob": "key-b"} {
+		if got, err := loadSession(user); err != nil || got != want {
+			t.Errorf("loadSession(%s) = %q, %v, want %q", user, got, err, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "key-a") || strings.Contains(string(data), "correct horse") {
+		t.Fatal("sessions.json holds a login key or the passphrase in the clear")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session.key")); err == nil {
+		t.Fatal("a key file was written next to the sessions")
+	}
+
+	if err := forgetSession("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if HasSession("alice") || !HasSession("bob") {
+		t.Fatal("forgetSession(alice) did not forget only alice")
+	}
+}
+
+func TestSessionNeedsPassphrase(t *testing.T) {
+	useTempSessions(t, "")
+	if err := saveSession("alice", "key-a"); !errors.Is(err, ErrNoPassphrase) {
+		t.Fatalf("saveSession without a passphrase: %v, want ErrNoPassphrase", err)
+	}
+	if HasSession("alice") {
+		t.Fatal("HasSession without a passphrase")
+	}
+}
+
+func TestSessionWrongPassphrase(t *testing.T) {
+	useTempSessions(t, "right")
+	if err := saveSession("alice", "key-a"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(SessionPassphraseEnv, "wrong")
+	if _, err := loadSession("alice"); err == nil {
+		t.Fatal("loadSession with the wrong passphrase succeeded")
+	}
+	if HasSession("alice") {
+		t.Fatal("HasSession with the wrong passphrase")
+	}
+}
+
+func TestSessionPassphraseChange(t *testing.T) {
+	useTempSessions(t, "old")
+	if err := saveSession("alice", "key-a"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(SessionPassphraseEnv, "new")
+	if err := saveSession("bob", "key-b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := loadSession("bob"); err != nil || got != "key-b" {
+		t.Fatalf("loadSession(bob) = %q, %v, want key-b", got, err)
+	}
+	f, err := readSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Sessions["alice"]; ok {
+		t.Fatal("session sealed under the old passphrase kept after saving under the new one")
+	}
+
+	t.Setenv(SessionPassphraseEnv, "old")
+	if got, err := loadSession("alice"); err != nil || got != "" {
+		t.Fatalf("loadSession(alice) under the old passphrase = %q, %v, want no session", got, err)
+	}
+}
+
+func TestSessionCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(f *sessionsFile)
+	}{
+		{"not base64", func(f *sessionsFile) { f.Sessions["alice"] = "%%%" }},
+		{"too short", func(f *sessionsFile) { f.Sessions["alice"] = base64.StdEncoding.EncodeToString([]byte("abc")) }},
+		{"tampered", func(f *sessionsFile) {
+			sealed, _ := base64.StdEncoding.DecodeString(f.Sessions["alice"])
+			sealed[len(sealed)-1] ^= 1
+			f.Sessions["alice"] = base64.StdEncoding.EncodeToString(sealed)
+		}},
+		{"moved to another user", func(f *sessionsFile) {
+			f.Sessions["alice"] = f.Sessions["bob"]
+		}},
+		{"bad salt", func(f *sessionsFile) { f.Salt = "%%%" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempSessions(t, "passphrase")
+			saveSession("alice", "key-a")
+			saveSession("bob", "key-b")
+			f, err := readSessions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(f)
+			if err := writeSessions(f); err != nil {
+				t.Fatal(err)
+			}
+			if key, err := loadSession("alice"); err == nil {
+				t.Fatalf("loadSession of a corrupt session = %q, want an error", key)
+			}
+			if HasSession("alice") {
+				t.Fatal("HasSession of a corrupt session")
+			}
+		})
+	}
+
+	t.Run("unreadable file", func(t *testing.T) {
+		dir := useTempSessions(t, "passphrase")
+		os.MkdirAll(dir, 0700)
+		if err := os.WriteFile(filepath.Join(dir, "sessions.json"), []byte("{"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadSession("alice"); err == nil {
+			t.Fatal("loadSession of an unparsable file succeeded")
+		}
+	})
+}
+
+func TestSessionIgnoresOldFormat(t *testing.T) {
+	dir := useTempSessions(t, "passphrase")
+	os.MkdirAll(dir, 0700)
+	// Sessions of older versions, sealed under a key kept next to them.
+	os.WriteFile(filepath.Join(dir, "sessions.json"), []byte(`{"alice": "c2VhbGVk"}`), 0600)
+	os.WriteFile(filepath.Join(dir, "session.key"), make([]byte, 32), 0600)
+
+	if HasSession("alice") {
+		t.Fatal("HasSession for a session of the old format")
+	}
+	if err := saveSession("bob", "key-b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session.key")); !os.IsNotExist(err) {
+		t.Fatalf("old key file still there: %v", err)
+	}
+}
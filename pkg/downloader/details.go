@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// detailsStore keeps the GC match details seen so far and mirrors them to a
+// JSON file. Details of a finished match never change, so they are kept
+// forever, also after the replay expired.
+type detailsStore struct {
+	mu      sync.Mutex
+	path    string
+	details map[int64]gc.MatchDetails
+	saving  *time.Timer // pending save, nil when the file is current
+}
+
+// detailsSaveDelay is how long changes to the match details cache are
+// collected before it is written, so caching the details of many matches
+// does not rewrite the whole file for each of them.
+var detailsSaveDelay = 2 * time.Second
+
+var details = &detailsStore{details: make(map[int64]gc.MatchDetails)}
+
+// DefaultMatchDetailsPath returns the match details cache location under the
+// user's config directory (~/.dota-report-timestamps/match-details.json).
+func DefaultMatchDetailsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "match-details.json")
+}
+
+// LoadMatchDetails reads the match details cache at path and keeps it
+// updated there.
+func LoadMatchDetails(path string) error {
+	details.mu.Lock()
+	defer details.mu.Unlock()
+	details.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read match details cache: %w", err)
+	}
+	var list []gc.MatchDetails
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse match details cache %s: %w", path, err)
+	}
+	for _, d := range list {
+		details.details[d.MatchID] = d
+	}
+	return nil
+}
+
+// scheduleSaveLocked writes the cache detailsSaveDelay from now, together
+// with the changes made until then. s.mu must be held.
+func (s *detailsStore) scheduleSaveLocked() {
+	if s.path == "" || s.saving != nil {
+		return
+	}
+	s.saving = time.AfterFunc(detailsSaveDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.saving = nil
+		s.saveLocked()
+	})
+}
+
+// saveLocked writes the cache to its file atomically.
+func (s *detailsStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	list := make([]gc.MatchDetails, 0, len(s.details))
+	for _, d := range s.details {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].MatchID > list[b].MatchID })
+	data, err := json.Marshal(list)
+	if err != nil {
+		log.Printf("Failed to encode match details: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		log.Printf("Failed to create match details directory: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write match details cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to replace match details cache: %v", err)
+	}
+}
+
+// CachedMatchDetails returns the cached details of a match.
+func CachedMatchDetails(matchID int64) (gc.MatchDetails, bool) {
+	details.mu.Lock()
+	defer details.mu.Unlock()
+	d, ok := details.details[matchID]
+	return d, ok
+}
+
+// SaveMatchDetails caches the details of a match. Their replay locator and
+// start time are recorded too, since the GC sends them along.
+func SaveMatchDetails(d gc.MatchDetails) {
+	if d.MatchID == 0 || len(d.Players) == 0 {
+		return
+	}
+	details.mu.Lock()
+	details.details[d.MatchID] = d
+	details.scheduleSaveLocked()
+	details.mu.Unlock()
+
+	SaveLocator(d.MatchID, d.Cluster, d.Salt, "gc")
+	RecordStartTime(d.MatchID, time.Unix(int64(d.StartTime), 0))
+}
+
+// FetchMatchDetails returns the details of a match from the cache, asking
+// the GC and caching the answer on a miss.
+func FetchMatchDetails(ctx context.Context, matchID int64, gcClient gc.Service) (gc.MatchDetails, error) {
+	if d, ok := CachedMatchDetails(matchID); ok {
+		return d, nil
+	}
+	if gcClient == nil {
+		return gc.MatchDetails{}, fmt.Errorf("no GC bot configured: %w", gc.ErrNotReady)
+	}
+	d, err := gcClient.GetMatchDetails(ctx, uint64(matchID))
+	if err != nil {
+		return gc.MatchDetails{}, err
+	}
+	SaveMatchDetails(*d)
+	return *d, nil
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// useTempDetails keeps match details in a temporary file, saved after
+// delay.
+func useTempDetails(t *testing.T, delay time.Duration) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "match-details.json")
+	saved, savedDelay := details, detailsSaveDelay
+	details = &detailsStore{path: path, details: make(map[int64]gc.MatchDetails)}
+	detailsSaveDelay = delay
+	t.Cleanup(func() {
+		details.mu.Lock()
+		if details.saving != nil {
+			details.saving.Stop()
+		}
+		details.mu.Unlock()
+		details, detailsSaveDelay = saved, savedDelay
+	})
+	return path
+}
+
+func TestSaveMatchDetailsBatchesWrites(t *testing.T) {
+	path := useTempDetails(t, 50*time.Millisecond)
+	useTempJobs(t)
+	for _, id := range []int64{401, 402, 403} {
+		SaveMatchDetails(gc.MatchDetails{MatchID: id, StartTime: 1760000000, Players: []gc.MatchPlayer{{AccountID: 1}}})
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("cache written before the save delay: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var list []gc.MatchDetails
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &list); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache not written after the save delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var ids []int64
+	for _, d := range list {
+		ids = append(ids, d.MatchID)
+	}
+	if len(ids) != 3 || ids[0] != 403 || ids[2] != 401 {
+		t.Fatalf("saved matches %v, want [403 402 401]", ids)
+	}
+
+	// A fresh store reads them back.
+	details = &detailsStore{details: make(map[int64]gc.MatchDetails)}
+	if err := LoadMatchDetails(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := CachedMatchDetails(402); !ok {
+		t.Fatal("match 402 missing after reloading the cache")
+	}
+}
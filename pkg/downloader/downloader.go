@@ -1,25 +1,23 @@
 package downloader
 
 import (
-	"bytes"
 	"compress/bzip2"
-	"compress/gzip"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/d3nd3/dota-report-timestamps/pkg/botclient"
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
 	"github.com/d3nd3/dota-report-timestamps/pkg/parser"
-	"github.com/d3nd3/dota-report-timestamps/pkg/steamapi"
 	"github.com/d3nd3/dota-report-timestamps/pkg/stratz"
-	"github.com/klauspost/compress/zstd"
 )
 
 // ProgressCallback is a function type for reporting download progress (0-100)
@@ -57,6 +55,7 @@ type ProgressWriter struct {
 	Total      int64
 	Written    int64
 	MatchID    int64
+	ReplayDir  string
 	LastUpdate time.Time
 }
 
@@ -66,6 +65,9 @@ func (pw *ProgressWriter) Write(p []byte) (int, error) {
 
 	// Update progress at most every 100ms to avoid lock contention
 	if time.Since(pw.LastUpdate) > 100*time.Millisecond {
+		if jobCancelled(pw.MatchID, pw.ReplayDir) {
+			return 0, ErrCancelled
+		}
 		if pw.Total > 0 {
 			percentage := float64(pw.Written) / float64(pw.Total) * 100
 			SetProgress(pw.MatchID, percentage)
@@ -75,64 +77,10 @@ func (pw *ProgressWriter) Write(p []byte) (int, error) {
 	return n, nil
 }
 
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
-}
-
 var downloadClient = &http.Client{
 	Timeout: 10 * time.Minute,
 }
 
-type pendingMatch struct {
-	matchID     int64
-	jobID       int
-	replayDir   string
-	stratzToken string
-	steamAPIKey string
-	gcClient    *botclient.Client
-	requestedAt time.Time
-}
-
-var (
-	pendingMatches = make(map[int64]*pendingMatch)
-	pendingMu      sync.RWMutex
-)
-
-// Rate limiting: OpenDota free tier is ~60 calls/minute.
-// Special rule: /api/request POST calls count as 10 calls.
-// We'll use a token bucket where 1 token = 1 call cost.
-// Capacity 60, refill 1 per second.
-var rateLimiter = make(chan struct{}, 60)
-
-func init() {
-	// Fill the bucket initially
-	for i := 0; i < 60; i++ {
-		rateLimiter <- struct{}{}
-	}
-
-	// Refill routine: 1 token every second (60/min)
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		for range ticker.C {
-			select {
-			case rateLimiter <- struct{}{}:
-			default:
-				// Bucket full
-			}
-		}
-	}()
-
-	// Background worker to process pending matches
-	go processPendingMatches()
-}
-
-// waitForCost blocks until we can consume 'cost' tokens.
-func waitForCost(cost int) {
-	for i := 0; i < cost; i++ {
-		<-rateLimiter
-	}
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -185,510 +133,230 @@ func extractClusterSaltFromURL(url string, matchID int64) (uint32, uint64, bool)
 // Additional CDN alternatives for better reliability
 // Note: Neither Stratz nor OpenDota host replays - they only provide cluster/salt info
 // to construct Valve CDN URLs. All replays must be downloaded from Valve's CDNs.
+// The hosts are tried in the order given by SetEndpoints.
 func constructReplayURLs(cluster uint32, matchID int64, salt uint64) []string {
 	urls := []string{}
-	
-	// Primary Valve CDN domains (try multiple patterns)
-	valveDomains := []string{
-		"valve.net",
-		"valvesoftware.com",
-	}
-	
-	// Perfect World China CDN domains
-	pwDomains := []string{
-		"wmsj.cn",
-		"pwrd.com",
-	}
-	
-	// Try primary Valve domains first
-	for _, domain := range valveDomains {
-		urls = append(urls, fmt.Sprintf("http://replay%d.%s/570/%d_%d.dem.bz2", cluster, domain, matchID, salt))
-	}
-	
-	// Try Perfect World domains (especially useful for China region clusters)
-	for _, domain := range pwDomains {
-		urls = append(urls, fmt.Sprintf("http://replay%d.%s/570/%d_%d.dem.bz2", cluster, domain, matchID, salt))
-	}
-	
+	for _, host := range replayHosts() {
+		host = strings.ReplaceAll(host, "{cluster}", strconv.FormatUint(uint64(cluster), 10))
+		urls = append(urls, fmt.Sprintf("%s/570/%d_%d.dem.bz2", host, matchID, salt))
+	}
 	return urls
 }
 
 // DownloadReplay downloads a replay for the given match ID to the specified directory.
-// It tries to fetch the replay URL using Stratz (if a token is provided) or falls back to OpenDota.
-func DownloadReplay(matchID int64, replayDir string, stratzToken string, steamAPIKey string, gcClient *botclient.Client) error {
-	demFilePath := filepath.Join(replayDir, fmt.Sprintf("%d.dem", matchID))
-	if _, err := os.Stat(demFilePath); err == nil {
-		log.Printf("Replay file already exists for match %d, skipping download", matchID)
-		return nil
-	}
+// It asks the configured replay providers for the replay URL, using the cached
+// locator of the match when there is one.
+func DownloadReplay(matchID int64, replayDir string, stratzToken string, steamAPIKey string, gcClient gc.Service) error {
+	return DownloadReplayWithPriority(matchID, replayDir, stratzToken, steamAPIKey, gcClient, PriorityInteractive)
+}
 
-	var replayURLs []string
-	// var err error // Removed this declaration to use the one from the loop/calls properly
+// DownloadReplayWithPriority is DownloadReplay for downloads that should
+// wait behind (PriorityBulk, PriorityBackground) or ahead of others for a
+// download slot.
+func DownloadReplayWithPriority(matchID int64, replayDir string, stratzToken string, steamAPIKey string, gcClient gc.Service, priority Priority) error {
+	return runDownload(matchID, replayDir, Sources{
+		StratzToken: stratzToken,
+		SteamAPIKey: steamAPIKey,
+		GCClient:    gcClient,
+	}, priority, true)
+}
 
-	// 0. Try Dota 2 GC Client (Highest Priority, Direct Access)
-	status := botclient.StatusDisconnected
-	if gcClient != nil {
-		status = gcClient.GetStatus()
-	}
+// runDownload downloads a replay into replayDir and records the outcome on
+// its job. Downloads of the same match into the same directory run one at a
+// time. restart starts a cancelled job again; without it a cancelled job is
+// left alone.
+func runDownload(matchID int64, replayDir string, src Sources, priority Priority, restart bool) error {
+	unlock := lockDownload(matchID, replayDir)
+	defer unlock()
 
-	if gcClient != nil && (status == botclient.StatusGCReady || status == botclient.StatusConnected) {
-		log.Printf("Attempting to get replay URL from Dota 2 GC for match %d (Status: %d)...", matchID, status)
-		cluster, salt, err := gcClient.GetReplayInfo(uint64(matchID))
-		if err == nil && cluster > 0 && salt > 0 {
-			replayURLs = constructReplayURLs(cluster, matchID, salt)
-			log.Printf("Found replay URL via Dota 2 GC: %s (and %d alternates)", replayURLs[0], len(replayURLs)-1)
-		} else {
-			log.Printf("Dota 2 GC failed: %v. Falling back to other methods.", err)
-		}
-	} else if gcClient != nil {
-		log.Printf("Skipping Dota 2 GC: Status is %d (Expected %d - GCReady/Connected). Bot might be connecting or stuck.", status, botclient.StatusGCReady)
+	if !restart && jobCancelled(matchID, replayDir) {
+		return ErrCancelled
 	}
-
-	// 1. Try Steam WebAPI first if key is available (Most reliable/authoritative)
-	if len(replayURLs) == 0 && steamAPIKey != "" {
-		log.Printf("Attempting to get replay URL from Steam WebAPI for match %d...", matchID)
-		steamClient := steamapi.NewClient(steamAPIKey)
-		clusterID, replaySalt, err := steamClient.GetReplayInfo(matchID)
-		if err != nil {
-			log.Printf("Failed to get replay URL from Steam WebAPI: %v. Falling back to Stratz.", err)
-		} else if clusterID > 0 && replaySalt > 0 {
-			replayURLs = constructReplayURLs(uint32(clusterID), matchID, uint64(replaySalt))
-			log.Printf("Found replay URL via Steam WebAPI: %s", replayURLs[0])
+	if restart {
+		if j, ok := jobs.get(matchID, replayDir); ok && j.State == JobCancelled {
+			jobs.update(matchID, replayDir, func(j *Job) { j.State = JobResolving })
 		}
 	}
 
-	// 2. Try Stratz if Steam failed or no key
-	// Note: Stratz does not host replays - it only provides cluster/salt to construct Valve CDN URLs
-	if len(replayURLs) == 0 && stratzToken != "" {
-		log.Printf("Attempting to get replay URLs from Stratz for match %d...", matchID)
-		stratzURLs, err := getReplayURLsFromStratz(matchID, stratzToken)
-		if err != nil {
-			log.Printf("Failed to get replay URLs from Stratz: %v. Falling back to OpenDota.", err)
-		} else if len(stratzURLs) > 0 {
-			replayURLs = stratzURLs
-			log.Printf("Found %d replay URLs via Stratz (cluster/salt): %s (and %d alternates)", len(stratzURLs), stratzURLs[0], len(stratzURLs)-1)
+	demFilePath := filepath.Join(replayDir, fmt.Sprintf("%d.dem", matchID))
+	if _, err := os.Stat(demFilePath); err == nil {
+		log.Printf("Replay file already exists for match %d, skipping download", matchID)
+		if j, ok := jobs.get(matchID, replayDir); ok && j.State != JobDone {
+			setJobState(matchID, replayDir, JobDone, nil)
 		}
+		return nil
 	}
 
-	// 3. Fallback to OpenDota if previous methods failed
-	if len(replayURLs) == 0 {
-		log.Printf("Attempting to get replay URL from OpenDota for match %d...", matchID)
-
-		// First check if match is parsed using has_parsed field (most reliable)
-		hasParsed, err := checkOpenDotaParsed(matchID)
-		if err != nil {
-			// If OpenDota is down (521) or having server issues (5xx), queue for retry
-			if strings.Contains(err.Error(), "status code 521") || strings.Contains(err.Error(), "status code 5") {
-				log.Printf("OpenDota is temporarily unavailable for match %d, queueing for background retry...", matchID)
-				pendingMu.Lock()
-				pendingMatches[matchID] = &pendingMatch{
-					matchID:     matchID,
-					jobID:       0, // No job ID yet, will check again later
-					replayDir:   replayDir,
-					stratzToken: stratzToken,
-					steamAPIKey: steamAPIKey,
-					gcClient:    gcClient,
-					requestedAt: time.Now(),
-				}
-				pendingMu.Unlock()
-				return fmt.Errorf("match %d queued for retry (OpenDota temporarily unavailable)", matchID)
-			}
-			return fmt.Errorf("failed to check OpenDota parsed status: %w", err)
-		}
+	setJobState(matchID, replayDir, JobResolving, nil)
+	// The expiry estimate only explains a missing replay; the download is
+	// always attempted, since some replays stay up longer.
+	err := expiredError(matchID, downloadReplay(matchID, replayDir, src, priority))
+	finishJob(matchID, replayDir, err)
+	return err
+}
 
-		if !hasParsed {
-			log.Printf("Match %d not parsed yet on OpenDota, requesting parsing...", matchID)
-			jobID, err := RequestParsing(matchID)
-			if err != nil {
-				// If OpenDota is down (521) or having server issues (5xx), queue for retry
-				if strings.Contains(err.Error(), "status code 521") || strings.Contains(err.Error(), "status code 5") {
-					log.Printf("OpenDota is temporarily unavailable for match %d, queueing for background retry...", matchID)
-					pendingMu.Lock()
-					pendingMatches[matchID] = &pendingMatch{
-						matchID:     matchID,
-						jobID:       0, // No job ID yet, will check again later
-						replayDir:   replayDir,
-						stratzToken: stratzToken,
-						steamAPIKey: steamAPIKey,
-						gcClient:    gcClient,
-						requestedAt: time.Now(),
-					}
-					pendingMu.Unlock()
-					return fmt.Errorf("match %d queued for retry (OpenDota temporarily unavailable)", matchID)
-				}
-				return fmt.Errorf("failed to request parsing: %w", err)
-			}
-			log.Printf("Parsing requested for match %d (Job ID: %d), queueing for background processing...", matchID, jobID)
-
-			// Queue for background processing instead of blocking
-			pendingMu.Lock()
-			pendingMatches[matchID] = &pendingMatch{
-				matchID:     matchID,
-				jobID:       jobID,
-				replayDir:   replayDir,
-				stratzToken: stratzToken,
-				steamAPIKey: steamAPIKey,
-				gcClient:    gcClient,
-				requestedAt: time.Now(),
-			}
-			pendingMu.Unlock()
+// queueForParse parks a job until OpenDota has parsed the match.
+func queueForParse(matchID int64, replayDir string, parseJobID int) {
+	jobs.update(matchID, replayDir, func(j *Job) {
+		j.State = JobWaitingParse
+		j.ParseJobID = parseJobID
+	})
+}
 
-			return fmt.Errorf("match %d queued for parsing, will be processed in background", matchID)
+func downloadReplay(matchID int64, replayDir string, src Sources, priority Priority) error {
+	cached, haveCached := GetLocator(matchID)
+	var cachedErr error
+	if haveCached {
+		log.Printf("Using cached replay locator for match %d (cluster=%d, from %s)", matchID, cached.Cluster, cached.Source)
+		cachedErr = downloadAndExtractReplay(constructReplayURLs(cached.Cluster, matchID, cached.Salt), matchID, replayDir, priority)
+		if cachedErr == nil || errors.Is(cachedErr, ErrCancelled) {
+			return cachedErr
 		}
+		// A locator imported from someone else, or cached from a bad
+		// answer, may be wrong; ask the providers again.
+		log.Printf("Cached replay locator for match %d failed, resolving it again: %v", matchID, cachedErr)
+		forgetLocator(matchID)
+	}
 
-		// Now fetch the replay URL (should be available if parsed)
-		// Note: OpenDota does not host replays - it only provides Valve CDN URLs
-		odURL, err := getReplayURL(matchID)
-		if err != nil {
-			// If OpenDota is down (521) or having server issues (5xx), queue for retry
-			if strings.Contains(err.Error(), "status code 521") || strings.Contains(err.Error(), "status code 5") {
-				log.Printf("OpenDota is temporarily unavailable for match %d, queueing for background retry...", matchID)
-				pendingMu.Lock()
-				pendingMatches[matchID] = &pendingMatch{
-					matchID:     matchID,
-					jobID:       0, // No job ID yet, will check again later
-					replayDir:   replayDir,
-					stratzToken: stratzToken,
-					steamAPIKey: steamAPIKey,
-					gcClient:    gcClient,
-					requestedAt: time.Now(),
-				}
-				pendingMu.Unlock()
-				return fmt.Errorf("match %d queued for retry (OpenDota temporarily unavailable)", matchID)
-			}
-			return fmt.Errorf("failed to get replay URL from OpenDota: %w", err)
+	loc, err := resolveReplay(matchID, src)
+	if err != nil {
+		var parseErr *ParseRequestedError
+		if errors.As(err, &parseErr) {
+			log.Printf("Match %d waiting on %s, queueing for background processing: %v", matchID, parseErr.Provider, parseErr)
+			queueForParse(matchID, replayDir, parseErr.JobID)
+			return fmt.Errorf("match %d queued for parsing, will be processed in background (%v): %w", matchID, parseErr, ErrQueued)
 		}
-		if odURL == "" {
-			return fmt.Errorf("replay URL is missing for match %d (may have expired)", matchID)
-		}
-		
-		// Try to extract cluster/salt from OpenDota's URL to generate alternative CDN URLs
-		if cluster, salt, ok := extractClusterSaltFromURL(odURL, matchID); ok {
-			log.Printf("Extracted cluster=%d, salt=%d from OpenDota URL, generating CDN alternatives", cluster, salt)
-			replayURLs = constructReplayURLs(cluster, matchID, salt)
-		} else {
-			// Fallback: use OpenDota's URL as-is (it's still a Valve CDN URL)
-			log.Printf("Could not extract cluster/salt from OpenDota URL, using URL as-is: %s", odURL)
-			replayURLs = []string{odURL}
+		if cachedErr != nil {
+			return cachedErr
 		}
+		return err
+	}
+	if loc.Cluster > 0 && loc.Salt > 0 {
+		SaveLocator(matchID, loc.Cluster, loc.Salt, loc.Source)
+	}
+	if haveCached && loc.Cluster == cached.Cluster && loc.Salt == cached.Salt {
+		// The same URLs just failed.
+		return cachedErr
 	}
 
-	return downloadAndExtractReplay(replayURLs, matchID, replayDir)
+	return downloadAndExtractReplay(loc.URLs, matchID, replayDir, priority)
 }
-
-// getReplayURLsFromStratz gets cluster/salt from Stratz and constructs all CDN alternative URLs.
-// Note: Stratz does not host replays - it only provides cluster/salt to construct Valve CDN URLs.
-func getReplayURLsFromStratz(matchID int64, token string) ([]string, error) {
+func getReplayLocationFromStratz(matchID int64, token string) (ReplayLocation, error) {
 	client := stratz.NewClient(token)
 	info, err := client.GetReplayInfo(matchID)
 	if err != nil {
-		return nil, err
+		return ReplayLocation{}, err
 	}
 
 	if info.ClusterID == 0 || info.ReplaySalt == 0 {
 		// Log more details about why we're missing this data
 		if info.ClusterID == 0 && info.ReplaySalt == 0 {
-			return nil, fmt.Errorf("missing cluster or salt info from Stratz (match %d may not have replay data available yet)", matchID)
+			return ReplayLocation{}, fmt.Errorf("missing cluster or salt info from Stratz (match %d may not have replay data available yet): %w", matchID, ErrNoReplayInfo)
 		} else if info.ClusterID == 0 {
-			return nil, fmt.Errorf("missing cluster ID from Stratz for match %d (replaySalt: %d)", matchID, info.ReplaySalt)
+			return ReplayLocation{}, fmt.Errorf("missing cluster ID from Stratz for match %d (replaySalt: %d): %w", matchID, info.ReplaySalt, ErrNoReplayInfo)
 		} else {
-			return nil, fmt.Errorf("missing replay salt from Stratz for match %d (clusterID: %d)", matchID, info.ClusterID)
+			return ReplayLocation{}, fmt.Errorf("missing replay salt from Stratz for match %d (clusterID: %d): %w", matchID, info.ClusterID, ErrNoReplayInfo)
 		}
 	}
 
-	// Use constructReplayURLs to generate all CDN alternatives
-	urls := constructReplayURLs(uint32(info.ClusterID), matchID, uint64(info.ReplaySalt))
-	return urls, nil
+	// Generate all CDN alternatives
+	return locationFor(uint32(info.ClusterID), matchID, uint64(info.ReplaySalt)), nil
 }
 
+// RequestParsing asks OpenDota to parse a match and returns the parse job ID.
 func RequestParsing(matchID int64) (int, error) {
-	url := fmt.Sprintf("https://api.opendota.com/api/request/%d", matchID)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Cost is 10 tokens for a POST request
-	waitForCost(10)
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("failed to request parsing: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle Cloudflare 521 (Web Server Is Down) and other transient errors
-	if resp.StatusCode == 521 {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("unexpected status code 521: OpenDota server is down (Cloudflare error). Body: %s", string(body))
-	}
-
-	// Handle other 5xx errors as potentially transient
-	if resp.StatusCode >= 500 && resp.StatusCode < 600 {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("unexpected status code %d: OpenDota server error (may be temporary). Body: %s", resp.StatusCode, string(body))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var jobResp struct {
-		Job struct {
-			JobID int `json:"jobId"`
-		} `json:"job"`
-		JobID int `json:"jobId"` // Sometimes it's top level
-	}
-
-	if err := json.Unmarshal(body, &jobResp); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal job response: %w", err)
-	}
-
-	id := jobResp.Job.JobID
-	if id == 0 {
-		id = jobResp.JobID
-	}
-
-	return id, nil
+	return openDota().RequestParse(matchID)
 }
 
+// GetReplayURL returns the replay URL OpenDota has for a match, or "" if it
+// has none (not parsed yet, or expired).
 func GetReplayURL(matchID int64) (string, error) {
 	return getReplayURL(matchID)
 }
 
 // checkOpenDotaParsed checks if a match has been parsed on OpenDota by checking the has_parsed field
 func checkOpenDotaParsed(matchID int64) (bool, error) {
-	url := fmt.Sprintf("https://api.opendota.com/api/matches/%d", matchID)
-
-	req, err := http.NewRequest("GET", url, nil)
+	m, err := openDota().GetMatch(matchID)
 	if err != nil {
 		return false, err
 	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
-	req.Header.Set("Accept-Encoding", "gzip, zstd")
-
-	waitForCost(1)
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("failed to fetch match data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle Cloudflare 521 (Web Server Is Down) and other transient errors
-	if resp.StatusCode == 521 {
-		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("unexpected status code 521: OpenDota server is down (Cloudflare error). Body: %s", string(body))
-	}
-
-	// Handle other 5xx errors as potentially transient
-	if resp.StatusCode >= 500 && resp.StatusCode < 600 {
-		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("unexpected status code %d: OpenDota server error (may be temporary). Body: %s", resp.StatusCode, string(body))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
-
-	rawContent, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		return false, fmt.Errorf("failed to read response body: %w", readErr)
-	}
-
-	// Handle compression (zstd, gzip, or none)
-	var bodyContent []byte
-	contentEncoding := resp.Header.Get("Content-Encoding")
-
-	switch contentEncoding {
-	case "zstd":
-		if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
-			defer r.Close()
-			bodyContent, _ = io.ReadAll(r)
-		}
-	case "gzip":
-		if r, err := gzip.NewReader(bytes.NewReader(rawContent)); err == nil {
-			defer r.Close()
-			bodyContent, _ = io.ReadAll(r)
-		}
-	default:
-		// Auto-detect zstd magic bytes if no header
-		if len(rawContent) > 0 && rawContent[0] != '{' && rawContent[0] != '[' {
-			if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
-				defer r.Close()
-				if d, err := io.ReadAll(r); err == nil && len(d) > 0 {
-					bodyContent = d
-				}
-			}
-		}
-	}
-
-	if len(bodyContent) == 0 {
-		bodyContent = rawContent
-	}
-
-	// Strip non-JSON prefix if any
-	if len(bodyContent) > 0 && bodyContent[0] != '{' && bodyContent[0] != '[' {
-		start := bytes.IndexByte(bodyContent, '{')
-		if start != -1 {
-			bodyContent = bodyContent[start:]
-		}
-	}
-
-	var apiResp struct {
-		OdData struct {
-			HasParsed bool `json:"has_parsed"`
-		} `json:"od_data"`
-	}
-
-	if err := json.Unmarshal(bodyContent, &apiResp); err != nil {
-		// If we can't parse, assume not parsed
-		return false, nil
-	}
-
-	return apiResp.OdData.HasParsed, nil
+	return m.Parsed(), nil
 }
 
 func getReplayURL(matchID int64) (string, error) {
-	url := fmt.Sprintf("https://api.opendota.com/api/matches/%d", matchID)
-
-	req, err := http.NewRequest("GET", url, nil)
+	m, err := openDota().GetMatch(matchID)
 	if err != nil {
 		return "", err
 	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
-	req.Header.Set("Accept-Encoding", "gzip, zstd")
-
-	// Cost is 1 token for GET
-	waitForCost(1)
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch match data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle Cloudflare 521 (Web Server Is Down) and other transient errors
-	if resp.StatusCode == 521 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status code 521: OpenDota server is down (Cloudflare error). Body: %s", string(body))
-	}
-
-	// Handle other 5xx errors as potentially transient
-	if resp.StatusCode >= 500 && resp.StatusCode < 600 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status code %d: OpenDota server error (may be temporary). Body: %s", resp.StatusCode, string(body))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
-
-	rawContent, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		return "", fmt.Errorf("failed to read response body: %w", readErr)
-	}
-
-	// Handle compression (zstd, gzip, or none)
-	var bodyContent []byte
-	contentEncoding := resp.Header.Get("Content-Encoding")
-
-	switch contentEncoding {
-	case "zstd":
-		if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
-			defer r.Close()
-			bodyContent, _ = io.ReadAll(r)
-		}
-	case "gzip":
-		if r, err := gzip.NewReader(bytes.NewReader(rawContent)); err == nil {
-			defer r.Close()
-			bodyContent, _ = io.ReadAll(r)
-		}
-	default:
-		// Auto-detect zstd magic bytes if no header
-		if len(rawContent) > 0 && rawContent[0] != '{' && rawContent[0] != '[' {
-			if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
-				defer r.Close()
-				if d, err := io.ReadAll(r); err == nil && len(d) > 0 {
-					bodyContent = d
-				}
-			}
-		}
-	}
-
-	if len(bodyContent) == 0 {
-		bodyContent = rawContent
-	}
-
-	// Strip non-JSON prefix if any
-	if len(bodyContent) > 0 && bodyContent[0] != '{' && bodyContent[0] != '[' {
-		start := bytes.IndexByte(bodyContent, '{')
-		if start != -1 {
-			bodyContent = bodyContent[start:]
-		}
-	}
-
-	var apiResp struct {
-		ReplayURL string `json:"replay_url"`
-		OdData    struct {
-			HasParsed bool `json:"has_parsed"`
-		} `json:"od_data"`
-	}
-
-	if err := json.Unmarshal(bodyContent, &apiResp); err != nil {
-		// Don't fail hard on unmarshal, just return empty
-		return "", nil
-	}
-
-	if apiResp.ReplayURL != "" {
-		return apiResp.ReplayURL, nil
-	}
-
 	// If parsed but no URL, it might have expired
-	return "", nil
+	return m.ReplayURL, nil
 }
 
-func downloadAndExtractReplay(replayURLs []string, matchID int64, replayDir string) error {
+func downloadAndExtractReplay(replayURLs []string, matchID int64, replayDir string, priority Priority) error {
 	if err := os.MkdirAll(replayDir, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	demFilePath := filepath.Join(replayDir, fmt.Sprintf("%d.dem", matchID))
+	// The replay is decompressed while it downloads into a temp file that is
+	// only renamed to .dem once complete, so DownloadReplay's existence check
+	// never sees a half-written replay.
+	tmpFilePath := demFilePath + ".tmp"
+	// Archives left behind by older versions are no longer used.
 	bz2FilePath := filepath.Join(replayDir, fmt.Sprintf("%d.bz2", matchID))
+	os.Remove(bz2FilePath)
+	// The compressed archive is also kept in a .part file until the replay
+	// is complete, so every attempt, from any of the CDN mirrors and after a
+	// restart, resumes the transfer with a Range request.
+	partFilePath := bz2FilePath + ".part"
+	setJobState(matchID, replayDir, JobQueued, nil)
 
 	err := func() error {
+		SetProgress(matchID, 0)
+		defer ClearProgress(matchID)
+
+		release, err := scheduler.acquire(matchID, replayDir, priority)
+		if err != nil {
+			return err
+		}
+		defer release()
+		setJobState(matchID, replayDir, JobDownloading, nil)
+
 		// Try each URL in the list
 		var lastErr error
 		notFoundCount := 0 // Track 404s to fail fast if all URLs return 404
-		
+
 		for _, url := range replayURLs {
 			log.Printf("Downloading replay from: %s", url)
 
 			// Determine max retries based on error type
 			maxRetries := 3 // Default for transient errors
 			retryCount := 0
-			
+
 			for retryCount < maxRetries {
 				if retryCount > 0 {
 					backoff := time.Duration(retryCount) * 2 * time.Second
 					time.Sleep(backoff)
 				}
 
-				getResp, reqErr := downloadClient.Get(url)
+				offset := fileSize(partFilePath)
+				req, err := http.NewRequest("GET", url, nil)
+				if err != nil {
+					lastErr = err
+					break
+				}
+				if offset > 0 {
+					req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+					log.Printf("Resuming download of match %d from byte %d", matchID, offset)
+				}
+
+				getResp, reqErr := downloadClient.Do(req)
 				if reqErr != nil {
 					lastErr = reqErr
-					errStr := reqErr.Error()
-					
+
 					// DNS lookup failures are usually permanent - fail after 1 retry
-					if strings.Contains(errStr, "no such host") || strings.Contains(errStr, "lookup") {
+					var dnsErr *net.DNSError
+					if errors.As(reqErr, &dnsErr) {
 						log.Printf("DNS error downloading %s: %v", url, reqErr)
 						if retryCount >= 1 {
 							log.Printf("DNS lookup failed after retry, skipping this URL")
@@ -697,53 +365,56 @@ func downloadAndExtractReplay(replayURLs []string, matchID int64, replayDir stri
 						retryCount++
 						continue
 					}
-					
+
 					// Other network errors - retry a few times
 					log.Printf("Network error downloading %s: %v", url, reqErr)
 					retryCount++
 					continue
 				}
 
-				if getResp.StatusCode == http.StatusOK {
-					// Success! Save and return
-					defer getResp.Body.Close()
-
-					// Initialize progress
-					SetProgress(matchID, 0)
-					defer ClearProgress(matchID)
-
-					bz2File, err := os.Create(bz2FilePath)
-					if err != nil {
-						return fmt.Errorf("failed to create .bz2 file: %w", err)
+				if getResp.StatusCode == http.StatusOK || getResp.StatusCode == http.StatusPartialContent ||
+					getResp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+					var err error
+					if getResp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+						err = extractPart(getResp, partFilePath, tmpFilePath, offset, matchID, replayDir)
+					} else {
+						err = streamReplay(getResp, url, partFilePath, tmpFilePath, offset, matchID, replayDir)
 					}
-					defer bz2File.Close()
-
-					// Wrap response body with progress writer
-					contentLength := getResp.ContentLength
-					progressWriter := &ProgressWriter{
-						Total:   contentLength,
-						MatchID: matchID,
+					if err == nil {
+						if err := os.Rename(tmpFilePath, demFilePath); err != nil {
+							os.Remove(tmpFilePath)
+							return fmt.Errorf("failed to finalize .dem file: %w", err)
+						}
+						os.Remove(partFilePath)
+						return nil
 					}
-
-					reader := io.TeeReader(getResp.Body, progressWriter)
-
-					if _, err := io.Copy(bz2File, reader); err != nil {
-						return fmt.Errorf("failed to save .bz2 file: %w", err)
+					os.Remove(tmpFilePath)
+					if errors.Is(err, ErrCancelled) {
+						os.Remove(partFilePath)
+						return err
 					}
-
-					// Ensure 100% at end
-					SetProgress(matchID, 100)
-					return nil
+					// The .part file is kept unless it is unusable; the retry
+					// resumes from its end.
+					var corrupt bzip2.StructuralError
+					if errors.As(err, &corrupt) || errors.Is(err, errBadPart) {
+						os.Remove(partFilePath)
+					}
+					setJobState(matchID, replayDir, JobDownloading, nil)
+					lastErr = err
+					log.Printf("Download of %s failed at %d bytes: %v (retry %d/%d)", url, fileSize(partFilePath), err, retryCount+1, maxRetries)
+					retryCount++
+					continue
 				}
 
 				// 404 = Replay expired/not found - fail immediately, no retries
 				if getResp.StatusCode == http.StatusNotFound {
 					getResp.Body.Close()
 					notFoundCount++
-					lastErr = fmt.Errorf("replay not found (404) - may have expired")
+					lastErr = fmt.Errorf("replay not found (404) - may have expired: %w", ErrExpired)
 					log.Printf("Replay not found (404) for %s - replay may have expired (7-14 day limit)", url)
 					// If multiple URLs all return 404, fail fast
 					if notFoundCount >= 2 {
+						os.Remove(partFilePath)
 						return fmt.Errorf("replay not found on multiple CDNs (404) - replay has likely expired (7-14 day limit): %w", lastErr)
 					}
 					break // Try next URL immediately
@@ -771,57 +442,307 @@ func downloadAndExtractReplay(replayURLs []string, matchID int64, replayDir stri
 			return fmt.Errorf("replay not found (404) - replay has likely expired (7-14 day limit): %w", lastErr)
 		}
 
-		return fmt.Errorf("failed to download replay after trying all URLs: %w", lastErr)
+		return fmt.Errorf("%w after trying all URLs: %w", ErrDownloadFailed, lastErr)
 	}()
 
 	if err != nil {
 		return err
 	}
 
-	demFilePath := filepath.Join(replayDir, fmt.Sprintf("%d.dem", matchID))
-
-	err = func() error {
-		bz2FileReader, err := os.Open(bz2FilePath)
-		if err != nil {
-			return fmt.Errorf("failed to open .bz2 file: %w", err)
+	demFile, err := os.Open(demFilePath)
+	if err == nil {
+		if date, err := parser.GetReplayDate(demFile); err == nil {
+			log.Printf("Extracted match date for match %d: %v", matchID, date)
+		} else {
+			log.Printf("Could not extract date from replay %d: %v (using file mod time)", matchID, err)
 		}
-		defer bz2FileReader.Close()
+		demFile.Close()
+	}
 
-		bzip2Reader := bzip2.NewReader(bz2FileReader)
+	return nil
+}
 
-		demFile, err := os.Create(demFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to create .dem file: %w", err)
-		}
-		defer demFile.Close()
+// errBadPart is wrapped by errors that leave the .part file unusable for a
+// resume.
+var errBadPart = errors.New("partial replay does not match the file on the server")
 
-		if _, err := io.Copy(demFile, bzip2Reader); err != nil {
-			return fmt.Errorf("failed to write decompressed data: %w", err)
+// fileSize returns the size of the file at path, or 0 if it does not exist.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// streamReplay appends the body of resp, which starts at byte offset of the
+// archive, to partPath and decompresses the whole archive into tmpPath as it
+// arrives: first the offset bytes already in partPath, then the body. A 200
+// response means the server ignored the Range header, so partPath is
+// rewritten from the start. Progress counts compressed bytes handed to the
+// decompressor, so it covers transfer and decompression together and
+// reaches 100 only once the file is fully written.
+func streamReplay(resp *http.Response, url string, partPath string, tmpPath string, offset int64, matchID int64, replayDir string) error {
+	total := resp.ContentLength
+	appendFlag := os.O_APPEND
+	if resp.StatusCode == http.StatusPartialContent {
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			resp.Body.Close()
+			return fmt.Errorf("unexpected Content-Range %q for resume at byte %d: %w", resp.Header.Get("Content-Range"), offset, errBadPart)
 		}
-		return nil
-	}()
+		total = size
+		if total < 0 && resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+	} else {
+		offset = 0
+		appendFlag = os.O_TRUNC
+	}
+	body := &resumingBody{
+		url:     url,
+		matchID: matchID,
+		total:   total,
+		offset:  offset,
+		body:    resp.Body,
+	}
+	defer body.Close()
 
+	have, err := os.Open(partPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to open .part file: %w", err)
+	}
+	if have != nil {
+		defer have.Close()
+	}
+	part, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|appendFlag, 0644)
 	if err != nil {
+		return fmt.Errorf("failed to create .part file: %w", err)
+	}
+	defer part.Close()
+
+	archive := io.TeeReader(throttledReader{body}, part)
+	if offset > 0 {
+		archive = io.MultiReader(io.NewSectionReader(have, 0, offset), archive)
+	}
+	// Once the transfer is complete only decompression is left.
+	archive = &onEOF{r: archive, fn: func() { setJobState(matchID, replayDir, JobExtracting, nil) }}
+	if err := decompress(archive, total, tmpPath, matchID, replayDir); err != nil {
 		return err
 	}
+	if err := part.Close(); err != nil {
+		return fmt.Errorf("failed to save .part file: %w", err)
+	}
+	if body.total > 0 && body.offset != body.total {
+		return fmt.Errorf("downloaded size mismatch: have %d bytes, expected %d: %w", body.offset, body.total, errBadPart)
+	}
+	return nil
+}
 
-	if err := os.Remove(bz2FilePath); err != nil {
-		log.Printf("Warning: failed to remove temp file %s: %v", bz2FilePath, err)
+// extractPart handles a 416 answer to a resume: if partPath already holds
+// the whole archive, as when an earlier attempt downloaded it but failed to
+// decompress it, it is decompressed into tmpPath without a transfer.
+func extractPart(resp *http.Response, partPath string, tmpPath string, offset int64, matchID int64, replayDir string) error {
+	resp.Body.Close()
+	// An unsatisfied range is answered with "bytes */<size>".
+	sizePart, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	size, err := strconv.ParseInt(sizePart, 10, 64)
+	if !ok || err != nil || size != offset {
+		return fmt.Errorf("server rejected resume at byte %d (%s): %w", offset, resp.Header.Get("Content-Range"), errBadPart)
+	}
+	f, err := os.Open(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to open .part file: %w", err)
 	}
+	defer f.Close()
+	log.Printf("Archive of match %d is already downloaded, extracting it", matchID)
+	setJobState(matchID, replayDir, JobExtracting, nil)
+	return decompress(f, size, tmpPath, matchID, replayDir)
+}
 
-	demFile, err := os.Open(demFilePath)
-	if err == nil {
-		if date, err := parser.GetReplayDate(demFile); err == nil {
-			log.Printf("Extracted match date for match %d: %v", matchID, date)
-		} else {
-			log.Printf("Could not extract date from replay %d: %v (using file mod time)", matchID, err)
+// decompress writes the bzip2 archive read from r, of total bytes (-1 if
+// unknown), to tmpPath.
+func decompress(r io.Reader, total int64, tmpPath string, matchID int64, replayDir string) error {
+	demFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create .dem file: %w", err)
+	}
+
+	progressWriter := &ProgressWriter{
+		Total:     total,
+		MatchID:   matchID,
+		ReplayDir: replayDir,
+	}
+	reader := bzip2.NewReader(io.TeeReader(r, progressWriter))
+	if _, err := io.Copy(demFile, reader); err != nil {
+		demFile.Close()
+		if errors.Is(err, ErrCancelled) {
+			return err
 		}
+		return fmt.Errorf("failed to write decompressed data: %w", err)
+	}
+	if err := demFile.Sync(); err != nil {
 		demFile.Close()
+		return fmt.Errorf("failed to write decompressed data: %w", err)
+	}
+	if err := demFile.Close(); err != nil {
+		return fmt.Errorf("failed to write decompressed data: %w", err)
+	}
+
+	// Ensure 100% at end
+	SetProgress(matchID, 100)
+	return nil
+}
+
+// onEOF calls fn the first time r reports io.EOF.
+type onEOF struct {
+	r    io.Reader
+	fn   func()
+	done bool
+}
+
+func (o *onEOF) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	if err == io.EOF && !o.done {
+		o.done = true
+		o.fn()
+	}
+	return n, err
+}
+
+// maxResumes is how many times a dropped replay transfer is resumed before
+// the download attempt fails.
+const maxResumes = 5
+
+// resumingBody reads a replay download and, when the connection drops,
+// continues it with a Range request from the last byte received. The
+// decompressor reading from it keeps its state, so nothing is written to
+// disk twice. A later attempt, also after a restart, resumes from the .part
+// file instead.
+type resumingBody struct {
+	url     string
+	matchID int64
+	total   int64 // -1 when the server sent no Content-Length
+	offset  int64
+	body    io.ReadCloser
+	resumes int
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		if b.body == nil {
+			if err := b.reopen(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if err == io.EOF && (b.total < 0 || b.offset >= b.total) {
+			return n, io.EOF
+		}
+		if err == nil || n > 0 {
+			if err != nil {
+				b.drop(err)
+			}
+			return n, nil
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		b.drop(err)
+		if b.resumes >= maxResumes {
+			return 0, fmt.Errorf("transfer interrupted at byte %d: %w", b.offset, err)
+		}
+	}
+}
+
+// drop closes the current connection so the next Read resumes the transfer.
+func (b *resumingBody) drop(err error) {
+	log.Printf("Replay transfer for match %d interrupted at byte %d: %v", b.matchID, b.offset, err)
+	b.body.Close()
+	b.body = nil
+}
+
+// reopen requests the rest of the file starting at offset.
+func (b *resumingBody) reopen() error {
+	b.resumes++
+	if b.resumes > maxResumes {
+		return fmt.Errorf("transfer interrupted at byte %d after %d resumes", b.offset, maxResumes)
+	}
+	time.Sleep(time.Duration(b.resumes) * time.Second)
+
+	req, err := http.NewRequest("GET", b.url, nil)
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	log.Printf("Resuming download of match %d from byte %d", b.matchID, b.offset)
 
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to resume download: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != b.offset || (size >= 0 && b.total >= 0 && size != b.total) {
+			resp.Body.Close()
+			return fmt.Errorf("unexpected Content-Range %q for resume at byte %d", resp.Header.Get("Content-Range"), b.offset)
+		}
+	case http.StatusOK:
+		// Range not supported; skip what the decompressor already has.
+		if resp.ContentLength >= 0 && b.total >= 0 && resp.ContentLength != b.total {
+			resp.Body.Close()
+			return fmt.Errorf("replay size changed from %d to %d bytes during download", b.total, resp.ContentLength)
+		}
+		if _, err := io.CopyN(io.Discard, resp.Body, b.offset); err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("failed to resume download: %w", err)
+		}
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("failed to resume download: status %s", resp.Status)
+	}
+	b.body = resp.Body
 	return nil
 }
 
+func (b *resumingBody) Close() error {
+	if b.body == nil {
+		return nil
+	}
+	return b.body.Close()
+}
+
+// parseContentRange parses a "bytes start-end/size" Content-Range header.
+// size is -1 when the server reports it as unknown ("*").
+func parseContentRange(header string) (start int64, size int64, ok bool) {
+	rest, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rangePart, sizePart, found := strings.Cut(rest, "/")
+	if !found {
+		return 0, 0, false
+	}
+	startPart, _, found := strings.Cut(rangePart, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if sizePart == "*" {
+		return start, -1, true
+	}
+	size, err = strconv.ParseInt(sizePart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
 func WaitForParsing(matchID int64, jobID int, maxWaitTime time.Duration) error {
 	deadline := time.Now().Add(maxWaitTime)
 	// Poll every 10s (polite)
@@ -839,12 +760,9 @@ func WaitForParsing(matchID int64, jobID int, maxWaitTime time.Duration) error {
 
 			// 1. Poll job status first
 			if jobID > 0 {
-				url := fmt.Sprintf("https://api.opendota.com/api/request/%d", jobID)
-				req, _ := http.NewRequest("GET", url, nil)
-				waitForCost(1)
-				resp, err := httpClient.Do(req)
-				if err == nil {
-					resp.Body.Close()
+				if pending, err := openDota().ParseJobPending(jobID); err == nil && pending {
+					log.Printf("Match %d parse job %d still pending...", matchID, jobID)
+					continue
 				}
 			}
 
@@ -874,71 +792,3 @@ func WaitForParsing(matchID int64, jobID int, maxWaitTime time.Duration) error {
 		}
 	}
 }
-
-func processPendingMatches() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		pendingMu.Lock()
-		var toProcess []*pendingMatch
-		for matchID, pm := range pendingMatches {
-			if time.Since(pm.requestedAt) > 10*time.Minute {
-				log.Printf("Removing expired pending match %d", matchID)
-				delete(pendingMatches, matchID)
-				continue
-			}
-			toProcess = append(toProcess, pm)
-		}
-		pendingMu.Unlock()
-
-		for _, pm := range toProcess {
-			hasParsed, err := checkOpenDotaParsed(pm.matchID)
-			if err != nil {
-				// If OpenDota is still down, log and continue (will retry next cycle)
-				if strings.Contains(err.Error(), "status code 521") || strings.Contains(err.Error(), "status code 5") {
-					log.Printf("OpenDota still unavailable for pending match %d: %v (will retry)", pm.matchID, err)
-					continue
-				}
-				log.Printf("Error checking parsed status for pending match %d: %v", pm.matchID, err)
-				continue
-			}
-
-			if hasParsed {
-				log.Printf("Pending match %d is now parsed, checking if already exists...", pm.matchID)
-
-				demFilePath := filepath.Join(pm.replayDir, fmt.Sprintf("%d.dem", pm.matchID))
-				if _, err := os.Stat(demFilePath); err == nil {
-					log.Printf("Replay file already exists for match %d, skipping download", pm.matchID)
-					pendingMu.Lock()
-					delete(pendingMatches, pm.matchID)
-					pendingMu.Unlock()
-					continue
-				}
-
-				log.Printf("Completing download for match %d...", pm.matchID)
-				pendingMu.Lock()
-				delete(pendingMatches, pm.matchID)
-				pendingMu.Unlock()
-
-				replayURL, err := getReplayURL(pm.matchID)
-				if err != nil {
-					log.Printf("Error getting replay URL for match %d: %v", pm.matchID, err)
-					continue
-				}
-				if replayURL == "" {
-					log.Printf("Replay URL missing for match %d (may have expired)", pm.matchID)
-					continue
-				}
-
-				if err := downloadAndExtractReplay([]string{replayURL}, pm.matchID, pm.replayDir); err != nil {
-					log.Printf("Error downloading replay for match %d: %v", pm.matchID, err)
-				} else {
-					log.Printf("Successfully downloaded replay for match %d", pm.matchID)
-				}
-			} else {
-				log.Printf("Pending match %d still waiting for parsing...", pm.matchID)
-			}
-		}
-	}
-}
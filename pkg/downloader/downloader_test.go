@@ -0,0 +1,198 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// replayServer serves testdata/replay.dem.bz2 with Range support and
+// records the Range header of every request.
+func replayServer(t *testing.T) (archive, replay []byte, url string, ranges func() []string) {
+	t.Helper()
+	archive, err := os.ReadFile("testdata/replay.dem.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err = io.ReadAll(bzip2.NewReader(bytes.NewReader(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	t.Cleanup(srv.Close)
+	return archive, replay, srv.URL + "/570/1_1.dem.bz2", func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func checkReplay(t *testing.T, dir string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(dir, "1.dem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("replay has %d bytes, want the %d of the archive", len(got), len(want))
+	}
+	for _, leftover := range []string{"1.dem.tmp", "1.bz2.part"} {
+		if _, err := os.Stat(filepath.Join(dir, leftover)); err == nil {
+			t.Errorf("%s left behind", leftover)
+		}
+	}
+}
+
+func TestDownloadResumesFromPart(t *testing.T) {
+	useTempJobs(t)
+	archive, replay, url, ranges := replayServer(t)
+	dir := t.TempDir()
+	// An earlier attempt got this far.
+	if err := os.WriteFile(filepath.Join(dir, "1.bz2.part"), archive[:100000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadAndExtractReplay([]string{url}, 1, dir, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, dir, replay)
+	if got := ranges(); len(got) != 1 || got[0] != "bytes=100000-" {
+		t.Fatalf("requests sent Range %q, want one resume at byte 100000", got)
+	}
+}
+
+func TestDownloadExtractsCompletePart(t *testing.T) {
+	useTempJobs(t)
+	archive, replay, url, ranges := replayServer(t)
+	dir := t.TempDir()
+	// The archive was downloaded, but the replay was never written.
+	if err := os.WriteFile(filepath.Join(dir, "1.bz2.part"), archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadAndExtractReplay([]string{url}, 1, dir, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, dir, replay)
+	if got := ranges(); len(got) != 1 {
+		t.Fatalf("sent %d requests, want only the resume probe", len(got))
+	}
+}
+
+func TestDownloadFromStart(t *testing.T) {
+	useTempJobs(t)
+	_, replay, url, ranges := replayServer(t)
+	dir := t.TempDir()
+
+	if err := downloadAndExtractReplay([]string{url}, 1, dir, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, dir, replay)
+	if got := ranges(); len(got) != 1 || got[0] != "" {
+		t.Fatalf("requests sent Range %q, want one plain request", got)
+	}
+}
+
+// fakeResolver answers every lookup with a fixed locator.
+type fakeResolver struct {
+	cluster uint32
+	salt    uint64
+}
+
+func (fakeResolver) Name() string { return "fake" }
+
+func (r fakeResolver) Resolve(matchID int64, src Sources) (ReplayLocation, error) {
+	return locationFor(r.cluster, matchID, r.salt), nil
+}
+
+func TestStaleLocatorFallsBackToResolver(t *testing.T) {
+	useTempJobs(t)
+	archive, replay, _, _ := replayServer(t)
+	// Only salt 2 is on the CDN.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/570/1_2.dem.bz2" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer srv.Close()
+	SetEndpoints(Endpoints{ReplayHosts: []string{srv.URL}})
+	defer SetEndpoints(Endpoints{})
+
+	saved := locators
+	locators = &locatorStore{locators: make(map[int64]Locator)}
+	defer func() { locators = saved }()
+	ImportLocators([]Locator{{MatchID: 1, Cluster: 100, Salt: 1}})
+
+	RegisterResolver(fakeResolver{cluster: 100, salt: 2})
+	if err := SetProviderOrder([]string{"fake"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetProviderOrder(nil)
+
+	dir := t.TempDir()
+	if err := downloadReplay(1, dir, Sources{}, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, dir, replay)
+	if l, _ := GetLocator(1); l.Salt != 2 || l.Source != "fake" {
+		t.Fatalf("cached locator after fallback = %+v, want salt 2 from fake", l)
+	}
+}
+
+func TestOldReplayIsStillAttempted(t *testing.T) {
+	useTempJobs(t)
+	archive, _, _, _ := replayServer(t)
+	// Only match 301 is still on the CDN.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/570/301_1.dem.bz2" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer srv.Close()
+	SetEndpoints(Endpoints{ReplayHosts: []string{srv.URL}})
+	defer SetEndpoints(Endpoints{})
+	RegisterResolver(fakeResolver{cluster: 1, salt: 1})
+	if err := SetProviderOrder([]string{"fake"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetProviderOrder(nil)
+	saved := locators
+	locators = &locatorStore{locators: make(map[int64]Locator)}
+	defer func() { locators = saved }()
+
+	month := 30 * 24 * time.Hour
+	RecordStartTime(301, time.Now().Add(-month))
+	RecordStartTime(302, time.Now().Add(-month))
+	dir := t.TempDir()
+	if err := DownloadReplay(301, dir, "", "", nil); err != nil {
+		t.Fatalf("old replay still on the CDN: %v", err)
+	}
+	err := DownloadReplay(302, dir, "", "", nil)
+	if !errors.Is(err, ErrExpired) || !strings.Contains(err.Error(), "likely expired") {
+		t.Fatalf("old replay gone from the CDN: %v, want a labelled ErrExpired", err)
+	}
+	if j, _ := jobs.get(302, dir); j.State != JobExpired {
+		t.Fatalf("job state = %s, want %s", j.State, JobExpired)
+	}
+}
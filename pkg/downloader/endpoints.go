@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/opendota"
+)
+
+// DefaultReplayHosts are the replay CDN hosts, primary Valve domains first,
+// then the Perfect World (China) ones. {cluster} is replaced by the replay
+// cluster of the match.
+var DefaultReplayHosts = []string{
+	"http://replay{cluster}.valve.net",
+	"http://replay{cluster}.valvesoftware.com",
+	"http://replay{cluster}.wmsj.cn",
+	"http://replay{cluster}.pwrd.com",
+}
+
+// Endpoints are the external services the downloader talks to. Empty fields
+// use the defaults.
+type Endpoints struct {
+	// OpenDota is the client used for OpenDota lookups and parse requests.
+	OpenDota    *opendota.Client
+	ReplayHosts []string
+}
+
+var (
+	endpointsMu sync.RWMutex
+	endpoints   = Endpoints{OpenDota: opendota.NewClient("", "", nil), ReplayHosts: DefaultReplayHosts}
+)
+
+// SetEndpoints replaces the service clients and URLs, for example to point
+// the downloader at the stand-ins of cmd/fakeproviders.
+func SetEndpoints(e Endpoints) {
+	if e.OpenDota == nil {
+		e.OpenDota = opendota.NewClient("", "", nil)
+	}
+	if len(e.ReplayHosts) == 0 {
+		e.ReplayHosts = DefaultReplayHosts
+	}
+	hosts := make([]string, 0, len(e.ReplayHosts))
+	for _, h := range e.ReplayHosts {
+		if h = strings.TrimRight(strings.TrimSpace(h), "/"); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	e.ReplayHosts = hosts
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	endpoints = e
+}
+
+// openDota returns the configured OpenDota client.
+func openDota() *opendota.Client {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	return endpoints.OpenDota
+}
+
+func replayHosts() []string {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	return endpoints.ReplayHosts
+}
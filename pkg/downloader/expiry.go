@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Valve keeps replays on its CDNs for roughly one to two weeks after the
+// match. Before ReplayMinRetention a replay is almost always there; after
+// ReplayMaxRetention it is almost always gone.
+const (
+	ReplayMinRetention = 7 * 24 * time.Hour
+	ReplayMaxRetention = 14 * 24 * time.Hour
+)
+
+// Availability is how likely a replay is to still be on the CDN, judged
+// from the match start time alone.
+type Availability string
+
+const (
+	AvailabilityUnknown       Availability = "unknown"
+	AvailabilityAvailable     Availability = "available"
+	AvailabilityExpiring      Availability = "expiring"
+	AvailabilityLikelyExpired Availability = "likely_expired"
+)
+
+// ReplayExpiry is the estimated CDN availability of a replay.
+type ReplayExpiry struct {
+	StartTime    time.Time    `json:"startTime,omitempty"`
+	ExpiresAt    time.Time    `json:"expiresAt,omitempty"` // earliest expected expiry
+	Availability Availability `json:"availability"`
+}
+
+// startTimes holds the start times of matches seen in GC match history.
+var startTimes = struct {
+	sync.Mutex
+	m map[int64]time.Time
+}{m: make(map[int64]time.Time)}
+
+// RecordStartTime remembers when a match started so its replay expiry can be
+// estimated without asking any provider. Zero times are ignored.
+func RecordStartTime(matchID int64, start time.Time) {
+	if matchID == 0 || start.IsZero() {
+		return
+	}
+	startTimes.Lock()
+	startTimes.m[matchID] = start
+	startTimes.Unlock()
+
+	for _, j := range jobs.forMatch(matchID) {
+		if j.StartTime.IsZero() {
+			jobs.update(matchID, j.ReplayDir, func(j *Job) { j.StartTime = start })
+		}
+	}
+}
+
+// startTime returns the known start time of a match, from the history seen
+// this run, its job or its cached match details.
+func startTime(matchID int64) time.Time {
+	startTimes.Lock()
+	t, ok := startTimes.m[matchID]
+	startTimes.Unlock()
+	if ok {
+		return t
+	}
+	for _, j := range jobs.forMatch(matchID) {
+		if !j.StartTime.IsZero() {
+			return j.StartTime
+		}
+	}
+	if d, ok := CachedMatchDetails(matchID); ok && d.StartTime > 0 {
+		return time.Unix(int64(d.StartTime), 0)
+	}
+	return time.Time{}
+}
+
+// ExpiryFor estimates replay availability for a match that started at start.
+func ExpiryFor(start time.Time) ReplayExpiry {
+	if start.IsZero() {
+		return ReplayExpiry{Availability: AvailabilityUnknown}
+	}
+	e := ReplayExpiry{StartTime: start, ExpiresAt: start.Add(ReplayMinRetention)}
+	switch age := time.Since(start); {
+	case age >= ReplayMaxRetention:
+		e.Availability = AvailabilityLikelyExpired
+	case age >= ReplayMinRetention:
+		e.Availability = AvailabilityExpiring
+	default:
+		e.Availability = AvailabilityAvailable
+	}
+	return e
+}
+
+// Expiry estimates replay availability for a match from its recorded start
+// time. It makes no network calls.
+func Expiry(matchID int64) ReplayExpiry {
+	return ExpiryFor(startTime(matchID))
+}
+
+// likelyExpiredError returns an ErrExpired error if the match is old enough
+// that its replay is almost certainly gone, or nil.
+func likelyExpiredError(matchID int64) error {
+	e := Expiry(matchID)
+	if e.Availability != AvailabilityLikelyExpired {
+		return nil
+	}
+	age := time.Since(e.StartTime).Round(time.Hour)
+	return fmt.Errorf("match %d was played %v ago, replay has likely expired: %w", matchID, age, ErrExpired)
+}
+
+// expiredError adds the age of the match to an ErrExpired download error
+// when the replay was expected to be gone. Other errors are returned as is.
+func expiredError(matchID int64, err error) error {
+	if !errors.Is(err, ErrExpired) {
+		return err
+	}
+	e := Expiry(matchID)
+	if e.Availability != AvailabilityLikelyExpired {
+		return err
+	}
+	age := time.Since(e.StartTime).Round(time.Hour)
+	return fmt.Errorf("match %d was played %v ago, replay has likely expired: %w", matchID, age, err)
+}
+
+// moreUrgent orders two deadlines: the earlier one first, unknown (zero)
+// deadlines last.
+func moreUrgent(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+	return a.Before(b)
+}
+
+// sortByUrgency orders jobs by how soon their replays expire.
+func sortByUrgency(list []Job) {
+	sort.SliceStable(list, func(a, b int) bool {
+		return moreUrgent(Expiry(list[a].MatchID).ExpiresAt, Expiry(list[b].MatchID).ExpiresAt)
+	})
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiryFor(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		want Availability
+	}{
+		{day, AvailabilityAvailable},
+		{ReplayMinRetention - time.Hour, AvailabilityAvailable},
+		{ReplayMinRetention + time.Hour, AvailabilityExpiring},
+		{ReplayMaxRetention - time.Hour, AvailabilityExpiring},
+		{ReplayMaxRetention + time.Hour, AvailabilityLikelyExpired},
+	}
+	for _, tt := range tests {
+		start := time.Now().Add(-tt.age)
+		e := ExpiryFor(start)
+		if e.Availability != tt.want {
+			t.Errorf("age %v: %s, want %s", tt.age, e.Availability, tt.want)
+		}
+		if !e.ExpiresAt.Equal(start.Add(ReplayMinRetention)) {
+			t.Errorf("age %v: expires at %v, want %v", tt.age, e.ExpiresAt, start.Add(ReplayMinRetention))
+		}
+	}
+	if e := ExpiryFor(time.Time{}); e.Availability != AvailabilityUnknown || !e.ExpiresAt.IsZero() {
+		t.Errorf("unknown start: %+v", e)
+	}
+}
+
+func TestMoreUrgent(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	tests := []struct {
+		a, b time.Time
+		want bool
+	}{
+		{now, later, true},
+		{later, now, false},
+		{now, now, false},
+		{now, time.Time{}, true},
+		{time.Time{}, now, false},
+		{time.Time{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		if got := moreUrgent(tt.a, tt.b); got != tt.want {
+			t.Errorf("moreUrgent(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortByUrgency(t *testing.T) {
+	useTempJobs(t)
+	now := time.Now()
+	RecordStartTime(101, now.Add(-time.Hour))
+	RecordStartTime(102, now.Add(-3*24*time.Hour))
+	list := []Job{{MatchID: 100}, {MatchID: 101}, {MatchID: 102}}
+	sortByUrgency(list)
+	if got := []int64{list[0].MatchID, list[1].MatchID, list[2].MatchID}; fmt.Sprint(got) != "[102 101 100]" {
+		t.Fatalf("sortByUrgency order = %v, want oldest match first and unknown last", got)
+	}
+}
+
+func TestExpiredErrorLabelsOldMatches(t *testing.T) {
+	RecordStartTime(201, time.Now().Add(-30*24*time.Hour))
+	RecordStartTime(202, time.Now().Add(-time.Hour))
+	notFound := fmt.Errorf("replay not found (404): %w", ErrExpired)
+
+	err := expiredError(201, notFound)
+	if !errors.Is(err, ErrExpired) || !strings.Contains(err.Error(), "likely expired") {
+		t.Errorf("old match: %v, want it labelled as likely expired", err)
+	}
+	if err := expiredError(202, notFound); err != notFound {
+		t.Errorf("recent match: %v, want the 404 unchanged", err)
+	}
+	other := errors.New("connection refused")
+	if err := expiredError(201, other); err != other {
+		t.Errorf("old match, other error: %v, want it unchanged", err)
+	}
+}
@@ -0,0 +1,439 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// JobState is the state of a download job.
+type JobState string
+
+const (
+	JobResolving    JobState = "resolving_url"
+	JobWaitingParse JobState = "waiting_parse"
+	JobQueued       JobState = "queued" // waiting for a download slot
+	JobDownloading  JobState = "downloading"
+	JobExtracting   JobState = "extracting"
+	JobDone         JobState = "done"
+	JobFailed       JobState = "failed"
+	JobExpired      JobState = "expired"
+	JobCancelled    JobState = "cancelled"
+)
+
+// finished reports whether a job in state s needs no further work.
+func (s JobState) finished() bool {
+	return s == JobDone || s == JobFailed || s == JobExpired || s == JobCancelled
+}
+
+// Job is a persisted replay download.
+type Job struct {
+	MatchID    int64     `json:"matchId"`
+	ReplayDir  string    `json:"replayDir"`
+	State      JobState  `json:"state"`
+	ParseJobID int       `json:"parseJobId,omitempty"` // OpenDota parse request job ID
+	Retries    int       `json:"retries"`
+	LastError  string    `json:"lastError,omitempty"`
+	StartTime  time.Time `json:"startTime,omitempty"` // match start, for expiry estimates
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Sources holds what DownloadReplay needs to resolve replay URLs. Jobs
+// resumed in the background get it from the function passed to StartJobs,
+// so credentials are never written to the job file.
+type Sources struct {
+	StratzToken string
+	SteamAPIKey string
+	GCClient    gc.Service
+}
+
+var (
+	// ErrQueued is wrapped by DownloadReplay errors when the match was
+	// queued for background processing rather than failing.
+	ErrQueued = errors.New("queued for background processing")
+	// ErrExpired is wrapped by download errors when the replay is gone
+	// from the CDN.
+	ErrExpired = errors.New("replay expired")
+	// ErrDownloadFailed is wrapped by download errors when every CDN URL
+	// of the replay failed for another reason.
+	ErrDownloadFailed = errors.New("failed to download replay")
+	// ErrCancelled is returned when a job is cancelled mid-download.
+	ErrCancelled = errors.New("download cancelled")
+	// ErrNoJob is wrapped by job actions naming a job that does not exist.
+	ErrNoJob = errors.New("no such download job")
+)
+
+// IsQueued reports whether a DownloadReplay error means the match was queued
+// for background processing.
+func IsQueued(err error) bool {
+	return errors.Is(err, ErrQueued)
+}
+
+const (
+	// parseWaitLimit is how long a job may wait for OpenDota to parse the
+	// match before it is marked failed.
+	parseWaitLimit = 6 * time.Hour
+	// finishedJobRetention is how long finished jobs stay in the list.
+	finishedJobRetention = 7 * 24 * time.Hour
+)
+
+// jobKey identifies a job: the same match may be downloaded into several
+// replay directories, each with its own job.
+type jobKey struct {
+	matchID   int64
+	replayDir string
+}
+
+func keyFor(matchID int64, replayDir string) jobKey {
+	if replayDir != "" {
+		replayDir = filepath.Clean(replayDir)
+	}
+	return jobKey{matchID, replayDir}
+}
+
+// jobStore keeps jobs in memory and mirrors them to a JSON file.
+type jobStore struct {
+	mu      sync.Mutex
+	path    string
+	jobs    map[jobKey]*Job
+	sources func() Sources
+}
+
+var jobs = &jobStore{jobs: make(map[jobKey]*Job)}
+
+// DefaultJobsPath returns the job file location under the user's config
+// directory (~/.dota-report-timestamps/jobs.json).
+func DefaultJobsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "jobs.json")
+}
+
+// StartJobs loads the job file at path, resumes unfinished jobs and starts
+// the background worker. sources is called whenever a job needs to resolve
+// replay URLs.
+func StartJobs(path string, sources func() Sources) error {
+	jobs.mu.Lock()
+	jobs.path = path
+	jobs.sources = sources
+	err := jobs.loadLocked()
+	var resume []Job
+	for _, j := range jobs.jobs {
+		switch j.State {
+		case JobResolving, JobQueued, JobDownloading, JobExtracting:
+			// Interrupted by a restart; start over.
+			resume = append(resume, *j)
+		}
+	}
+	jobs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, j := range resume {
+		log.Printf("Resuming interrupted download job for match %d (was %s)", j.MatchID, j.State)
+		go runJob(j.MatchID, j.ReplayDir, true)
+	}
+	go processPendingJobs()
+	return nil
+}
+
+// loadLocked reads the job file, dropping old finished jobs.
+func (s *jobStore) loadLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read job file: %w", err)
+	}
+	var list []*Job
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse job file %s: %w", s.path, err)
+	}
+	for _, j := range list {
+		if j.State.finished() && time.Since(j.UpdatedAt) > finishedJobRetention {
+			continue
+		}
+		s.jobs[keyFor(j.MatchID, j.ReplayDir)] = j
+	}
+	return nil
+}
+
+// saveLocked writes all jobs to the job file atomically.
+func (s *jobStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	list := s.listLocked()
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode download jobs: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		log.Printf("Failed to create job directory: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write job file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to replace job file: %v", err)
+	}
+}
+
+func (s *jobStore) listLocked() []Job {
+	list := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, *j)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return list
+}
+
+// update applies fn to the job for matchID in replayDir, creating it if
+// needed, and persists the result.
+func (s *jobStore) update(matchID int64, replayDir string, fn func(j *Job)) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := keyFor(matchID, replayDir)
+	j, ok := s.jobs[key]
+	if !ok {
+		j = &Job{MatchID: matchID, ReplayDir: key.replayDir, CreatedAt: time.Now()}
+		s.jobs[key] = j
+	}
+	if j.StartTime.IsZero() {
+		startTimes.Lock()
+		j.StartTime = startTimes.m[matchID]
+		startTimes.Unlock()
+	}
+	fn(j)
+	j.UpdatedAt = time.Now()
+	s.saveLocked()
+	return *j
+}
+
+func (s *jobStore) get(matchID int64, replayDir string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[keyFor(matchID, replayDir)]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// forMatch returns the jobs of a match in every replay directory.
+func (s *jobStore) forMatch(matchID int64) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Job
+	for k, j := range s.jobs {
+		if k.matchID == matchID {
+			list = append(list, *j)
+		}
+	}
+	return list
+}
+
+// find returns the job for matchID in replayDir. An empty replayDir names
+// the only job of the match.
+func (s *jobStore) find(matchID int64, replayDir string) (Job, error) {
+	if replayDir != "" {
+		if j, ok := s.get(matchID, replayDir); ok {
+			return j, nil
+		}
+		return Job{}, fmt.Errorf("no download job for match %d in %s: %w", matchID, replayDir, ErrNoJob)
+	}
+	switch list := s.forMatch(matchID); len(list) {
+	case 0:
+		return Job{}, fmt.Errorf("no download job for match %d: %w", matchID, ErrNoJob)
+	case 1:
+		return list[0], nil
+	default:
+		return Job{}, fmt.Errorf("match %d has jobs in %d replay directories, name one", matchID, len(list))
+	}
+}
+
+// setJobState moves a job to state, recording err as the last error when set.
+// A cancelled job stays cancelled: the download still running for it must
+// not bring it back. Only RetryJob and a new DownloadReplay call restart it.
+func setJobState(matchID int64, replayDir string, state JobState, err error) {
+	jobs.update(matchID, replayDir, func(j *Job) {
+		if j.State == JobCancelled {
+			return
+		}
+		j.State = state
+		if err != nil {
+			j.LastError = err.Error()
+		} else if state == JobDone {
+			j.LastError = ""
+		}
+	})
+}
+
+// jobCancelled reports whether the job for matchID in replayDir has been
+// cancelled.
+func jobCancelled(matchID int64, replayDir string) bool {
+	j, ok := jobs.get(matchID, replayDir)
+	return ok && j.State == JobCancelled
+}
+
+// downloadLocks serialises downloads of the same match into the same replay
+// directory, whether started by the user, a job or the watcher.
+var downloadLocks sync.Map // jobKey -> *sync.Mutex
+
+func lockDownload(matchID int64, replayDir string) func() {
+	l, _ := downloadLocks.LoadOrStore(keyFor(matchID, replayDir), &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// ListJobs returns all known download jobs, oldest first.
+func ListJobs() []Job {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	return jobs.listLocked()
+}
+
+// GetJob returns the job for a match in replayDir. An empty replayDir names
+// the only job of the match.
+func GetJob(matchID int64, replayDir string) (Job, bool) {
+	j, err := jobs.find(matchID, replayDir)
+	return j, err == nil
+}
+
+// RetryJob restarts a job in the background and returns its new state. An
+// empty replayDir names the only job of the match.
+func RetryJob(matchID int64, replayDir string) (Job, error) {
+	j, err := jobs.find(matchID, replayDir)
+	if err != nil {
+		return Job{}, err
+	}
+	if !j.State.finished() && j.State != JobWaitingParse {
+		return j, fmt.Errorf("job for match %d is already running (%s)", matchID, j.State)
+	}
+	j = jobs.update(matchID, j.ReplayDir, func(j *Job) {
+		j.State = JobResolving
+	})
+	go runJob(matchID, j.ReplayDir, true)
+	return j, nil
+}
+
+// CancelJob marks a job cancelled. A download in progress stops at the next
+// write. An empty replayDir names the only job of the match.
+func CancelJob(matchID int64, replayDir string) (Job, error) {
+	j, err := jobs.find(matchID, replayDir)
+	if err != nil {
+		return Job{}, err
+	}
+	if j.State.finished() {
+		return j, fmt.Errorf("job for match %d has already finished (%s)", matchID, j.State)
+	}
+	return jobs.update(matchID, j.ReplayDir, func(j *Job) {
+		j.State = JobCancelled
+		j.LastError = "cancelled by user"
+	}), nil
+}
+
+// jobSources returns the sources configured with StartJobs.
+func jobSources() Sources {
+	jobs.mu.Lock()
+	sources := jobs.sources
+	jobs.mu.Unlock()
+	if sources == nil {
+		return Sources{}
+	}
+	return sources()
+}
+
+// runJob downloads the replay of a job using the configured sources. A job
+// cancelled before it gets to run stays cancelled.
+func runJob(matchID int64, replayDir string, countRetry bool) {
+	if countRetry {
+		jobs.update(matchID, replayDir, func(j *Job) { j.Retries++ })
+	}
+	if err := runDownload(matchID, replayDir, jobSources(), PriorityBackground, false); err != nil {
+		if !errors.Is(err, ErrQueued) {
+			log.Printf("Download job for match %d failed: %v", matchID, err)
+		}
+		return
+	}
+	log.Printf("Download job for match %d finished", matchID)
+}
+
+// finishJob records the outcome of DownloadReplay on its job.
+func finishJob(matchID int64, replayDir string, err error) {
+	switch {
+	case err == nil:
+		setJobState(matchID, replayDir, JobDone, nil)
+	case errors.Is(err, ErrQueued):
+		// State already set to waiting_parse by the caller.
+	case errors.Is(err, ErrCancelled):
+		setJobState(matchID, replayDir, JobCancelled, err)
+	case errors.Is(err, ErrExpired):
+		setJobState(matchID, replayDir, JobExpired, err)
+	default:
+		setJobState(matchID, replayDir, JobFailed, err)
+	}
+}
+
+// processPendingJobs polls OpenDota for jobs waiting on a parse and starts
+// their download once the match is parsed. Jobs whose replays expire soonest
+// are checked first; those that have likely expired are given up on.
+func processPendingJobs() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pending := ListJobs()
+		sortByUrgency(pending)
+		for _, j := range pending {
+			if j.State != JobWaitingParse {
+				continue
+			}
+			if err := likelyExpiredError(j.MatchID); err != nil {
+				log.Printf("Giving up on pending match %d: %v", j.MatchID, err)
+				setJobState(j.MatchID, j.ReplayDir, JobExpired, err)
+				continue
+			}
+			if time.Since(j.UpdatedAt) > parseWaitLimit {
+				log.Printf("Giving up on match %d after waiting %v for OpenDota to parse it", j.MatchID, parseWaitLimit)
+				setJobState(j.MatchID, j.ReplayDir, JobFailed, fmt.Errorf("timed out waiting for OpenDota to parse the match"))
+				continue
+			}
+
+			hasParsed, err := checkOpenDotaParsed(j.MatchID)
+			if err != nil {
+				if IsTransient(err) {
+					log.Printf("OpenDota still unavailable for pending match %d: %v (will retry)", j.MatchID, err)
+					continue
+				}
+				log.Printf("Error checking parsed status for pending match %d: %v", j.MatchID, err)
+				continue
+			}
+			if !hasParsed {
+				log.Printf("Pending match %d still waiting for parsing...", j.MatchID)
+				continue
+			}
+
+			log.Printf("Pending match %d is now parsed, completing download...", j.MatchID)
+			runJob(j.MatchID, j.ReplayDir, false)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempJobs replaces the job store with an empty one saved under a
+// temporary directory for the duration of the test.
+func useTempJobs(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	saved := jobs
+	jobs = &jobStore{path: path, jobs: make(map[jobKey]*Job)}
+	t.Cleanup(func() { jobs = saved })
+	return path
+}
+
+func TestCancelledJobStaysCancelled(t *testing.T) {
+	useTempJobs(t)
+	setJobState(1, "replays", JobDownloading, nil)
+	if _, err := CancelJob(1, "replays"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The goroutine still running the download moves on.
+	setJobState(1, "replays", JobExtracting, nil)
+	finishJob(1, "replays", nil)
+	if j, _ := jobs.get(1, "replays"); j.State != JobCancelled {
+		t.Fatalf("state after cancel = %s, want %s", j.State, JobCancelled)
+	}
+	if !jobCancelled(1, "replays") {
+		t.Fatal("jobCancelled = false")
+	}
+}
+
+func TestJobActionsCheckState(t *testing.T) {
+	useTempJobs(t)
+	setJobState(1, "replays", JobDone, nil)
+	setJobState(2, "replays", JobDownloading, nil)
+
+	if _, err := CancelJob(1, ""); err == nil {
+		t.Error("CancelJob of a finished job succeeded")
+	}
+	if _, err := RetryJob(2, ""); err == nil {
+		t.Error("RetryJob of a running job succeeded")
+	}
+	if _, err := RetryJob(3, ""); !errors.Is(err, ErrNoJob) {
+		t.Errorf("RetryJob of an unknown job: %v, want ErrNoJob", err)
+	}
+	if _, err := CancelJob(1, "other"); !errors.Is(err, ErrNoJob) {
+		t.Errorf("CancelJob in another directory: %v, want ErrNoJob", err)
+	}
+}
+
+func TestJobsPerReplayDir(t *testing.T) {
+	path := useTempJobs(t)
+	setJobState(1, "a", JobDownloading, nil)
+	setJobState(1, "b/", JobDownloading, nil)
+	if n := len(ListJobs()); n != 2 {
+		t.Fatalf("%d jobs for one match in two directories, want 2", n)
+	}
+
+	if _, err := CancelJob(1, ""); err == nil || errors.Is(err, ErrNoJob) {
+		t.Fatalf("CancelJob without a directory: %v, want an ambiguity error", err)
+	}
+	if _, err := CancelJob(1, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if jobCancelled(1, "a") || !jobCancelled(1, "b") {
+		t.Fatal("cancelling the job in b affected the job in a")
+	}
+
+	// Both jobs survive a restart.
+	reloaded := &jobStore{path: path, jobs: make(map[jobKey]*Job)}
+	if err := reloaded.loadLocked(); err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := reloaded.get(1, "a"); !ok || a.State != JobDownloading {
+		t.Errorf("reloaded job in a = %+v, %v", a, ok)
+	}
+	if b, ok := reloaded.get(1, "b"); !ok || b.State != JobCancelled {
+		t.Errorf("reloaded job in b = %+v, %v", b, ok)
+	}
+}
+
+func TestProgressWriterStopsOnCancel(t *testing.T) {
+	useTempJobs(t)
+	setJobState(1, "replays", JobDownloading, nil)
+	pw := &ProgressWriter{MatchID: 1, ReplayDir: "replays"}
+	if _, err := pw.Write(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	CancelJob(1, "replays")
+	pw.LastUpdate = pw.LastUpdate.AddDate(0, 0, -1)
+	if _, err := pw.Write(make([]byte, 10)); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("Write after cancel: %v, want ErrCancelled", err)
+	}
+}
+
+func TestRunDownloadRestartRules(t *testing.T) {
+	useTempJobs(t)
+	dir := t.TempDir()
+	setJobState(1, dir, JobDownloading, nil)
+	CancelJob(1, dir)
+
+	// Jobs and the watcher leave a cancelled job alone.
+	if err := runDownload(1, dir, Sources{}, PriorityBackground, false); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("background download of a cancelled job: %v, want ErrCancelled", err)
+	}
+	if !jobCancelled(1, dir) {
+		t.Fatal("background download restarted a cancelled job")
+	}
+
+	// A download the user asks for again restarts it.
+	if err := os.WriteFile(filepath.Join(dir, "1.dem"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDownload(1, dir, Sources{}, PriorityInteractive, true); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := jobs.get(1, dir); j.State != JobDone {
+		t.Fatalf("state after restart = %s, want %s", j.State, JobDone)
+	}
+}
@@ -0,0 +1,232 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// Locator is the replay cluster and salt of a match. They never change, so
+// once known the replay URL can be rebuilt without asking any provider.
+type Locator struct {
+	MatchID   int64     `json:"matchId"`
+	Cluster   uint32    `json:"cluster"`
+	Salt      uint64    `json:"salt"`
+	Source    string    `json:"source"`
+	FirstSeen time.Time `json:"firstSeen"`
+}
+
+// locatorStore keeps locators in memory and mirrors them to a JSON file.
+type locatorStore struct {
+	mu       sync.Mutex
+	path     string
+	locators map[int64]Locator
+}
+
+var locators = &locatorStore{locators: make(map[int64]Locator)}
+
+// prefillGap is the pause between GC match detail requests when filling the
+// cache in the background.
+const prefillGap = time.Second
+
+// DefaultLocatorsPath returns the locator cache location under the user's
+// config directory (~/.dota-report-timestamps/locators.json).
+func DefaultLocatorsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "locators.json")
+}
+
+// LoadLocators reads the locator cache at path and keeps it updated there.
+func LoadLocators(path string) error {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	locators.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read locator cache: %w", err)
+	}
+	var list []Locator
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse locator cache %s: %w", path, err)
+	}
+	for _, l := range list {
+		locators.locators[l.MatchID] = l
+	}
+	return nil
+}
+
+// saveLocked writes the cache to its file atomically.
+func (s *locatorStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.listLocked(), "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode replay locators: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		log.Printf("Failed to create locator directory: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write locator cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("Failed to replace locator cache: %v", err)
+	}
+}
+
+func (s *locatorStore) listLocked() []Locator {
+	list := make([]Locator, 0, len(s.locators))
+	for _, l := range s.locators {
+		list = append(list, l)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].MatchID > list[b].MatchID })
+	return list
+}
+
+// addLocked stores l unless the match is already known. It reports whether
+// the cache changed.
+func (s *locatorStore) addLocked(l Locator) bool {
+	if l.MatchID == 0 || l.Cluster == 0 || l.Salt == 0 {
+		return false
+	}
+	if _, ok := s.locators[l.MatchID]; ok {
+		return false
+	}
+	if l.FirstSeen.IsZero() {
+		l.FirstSeen = time.Now()
+	}
+	s.locators[l.MatchID] = l
+	return true
+}
+
+// GetLocator returns the cached locator of a match.
+func GetLocator(matchID int64) (Locator, bool) {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	l, ok := locators.locators[matchID]
+	return l, ok
+}
+
+// SaveLocator caches the cluster and salt of a match. An existing entry is
+// kept so FirstSeen and Source record the first lookup.
+func SaveLocator(matchID int64, cluster uint32, salt uint64, source string) {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	if locators.addLocked(Locator{MatchID: matchID, Cluster: cluster, Salt: salt, Source: source}) {
+		locators.saveLocked()
+	}
+}
+
+// forgetLocator drops the cached locator of a match, after its replay URLs
+// failed, so the next lookup asks the providers again.
+func forgetLocator(matchID int64) {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	if _, ok := locators.locators[matchID]; ok {
+		delete(locators.locators, matchID)
+		locators.saveLocked()
+	}
+}
+
+// ExportLocators returns every cached locator, newest match first.
+func ExportLocators() []Locator {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	return locators.listLocked()
+}
+
+// ImportLocators adds locators shared by someone else and returns how many
+// were new. Their Source is kept; entries already cached are skipped.
+func ImportLocators(list []Locator) int {
+	locators.mu.Lock()
+	defer locators.mu.Unlock()
+	added := 0
+	for _, l := range list {
+		if l.Source == "" {
+			l.Source = "import"
+		}
+		if locators.addLocked(l) {
+			added++
+		}
+	}
+	if added > 0 {
+		locators.saveLocked()
+	}
+	return added
+}
+
+// prefilling holds the players whose match lists are being prefilled.
+var prefilling = struct {
+	sync.Mutex
+	players map[int64]bool
+}{players: make(map[int64]bool)}
+
+// PrefillLocators looks up the matches missing from the cache in the GC's
+// match details, one at a time, and caches the details as well. It stops
+// quietly when the GC is not ready, so it can be started after any match
+// list is fetched. While a prefill for the matches of steamID64 runs,
+// further ones for that player return at once.
+func PrefillLocators(steamID64 int64, matchIDs []int64, gcClient gc.Service) {
+	if gcClient == nil {
+		return
+	}
+	prefilling.Lock()
+	if prefilling.players[steamID64] {
+		prefilling.Unlock()
+		return
+	}
+	prefilling.players[steamID64] = true
+	prefilling.Unlock()
+	defer func() {
+		prefilling.Lock()
+		delete(prefilling.players, steamID64)
+		prefilling.Unlock()
+	}()
+
+	// Prefilling yields to the requests of the user.
+	bg := gc.Background(gcClient)
+	filled := 0
+	for _, id := range matchIDs {
+		if _, ok := CachedMatchDetails(id); ok || id == 0 {
+			continue
+		}
+		status := gcClient.GetStatus()
+		if status != gc.StatusGCReady && status != gc.StatusConnected {
+			break
+		}
+		d, err := bg.GetMatchDetails(context.Background(), uint64(id))
+		if errors.Is(err, gc.ErrNotReady) || errors.Is(err, gc.ErrRateLimited) {
+			log.Printf("Stopped caching replay locators: %v", err)
+			break
+		}
+		if err != nil {
+			log.Printf("Could not cache replay locator for match %d: %v", id, err)
+		} else {
+			SaveMatchDetails(*d)
+			if d.Cluster > 0 && d.Salt > 0 {
+				filled++
+			}
+		}
+		time.Sleep(prefillGap)
+	}
+	if filled > 0 {
+		log.Printf("Cached replay locators for %d matches", filled)
+	}
+}
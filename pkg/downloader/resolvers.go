@@ -0,0 +1,409 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/opendota"
+	"github.com/d3nd3/dota-report-timestamps/pkg/steamapi"
+)
+
+// ReplayLocation is where a replay can be downloaded from. Cluster and Salt
+// are zero when the provider only returned a URL they could not be read from.
+type ReplayLocation struct {
+	URLs    []string
+	Cluster uint32
+	Salt    uint64
+	Source  string
+}
+
+// ReplayURLResolver looks up the download location of a replay. Resolvers
+// are tried in the configured order until one succeeds.
+type ReplayURLResolver interface {
+	// Name identifies the provider in the configured order and in
+	// /api/providers.
+	Name() string
+	// Resolve returns the replay location of matchID. It returns an error
+	// wrapping ErrProviderUnavailable when it cannot be used right now (no
+	// API key, bot not connected), and ErrNoReplayInfo when the provider
+	// answered but has nothing for this match. Neither counts against the
+	// provider's health.
+	Resolve(matchID int64, src Sources) (ReplayLocation, error)
+}
+
+var (
+	// ErrProviderUnavailable means a resolver is not configured or not ready.
+	ErrProviderUnavailable = errors.New("provider unavailable")
+	// ErrNoReplayInfo means a resolver has no replay location for the match.
+	ErrNoReplayInfo = errors.New("no replay info")
+)
+
+// ParseRequestedError is returned by a resolver that asked a provider to
+// parse the match, or that should be asked again later because the provider
+// is temporarily down (Err is then set). The download continues in the
+// background.
+type ParseRequestedError struct {
+	Provider string
+	JobID    int // Provider's parse job ID, 0 if none was created
+	Err      error
+}
+
+func (e *ParseRequestedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s temporarily unavailable: %v", e.Provider, e.Err)
+	}
+	return fmt.Sprintf("%s parse requested (job %d)", e.Provider, e.JobID)
+}
+
+func (e *ParseRequestedError) Unwrap() error { return e.Err }
+
+// IsTransient reports whether err is worth retrying later: it wraps an
+// error with a Transient method that returns true (such as a 5xx
+// opendota.StatusError), or the OpenDota daily budget is used up.
+func IsTransient(err error) bool {
+	var t interface{ Transient() bool }
+	if errors.As(err, &t) && t.Transient() {
+		return true
+	}
+	return errors.Is(err, opendota.ErrBudgetExhausted)
+}
+
+// DefaultProviderOrder is the order resolvers are tried in when none is
+// configured.
+var DefaultProviderOrder = []string{"gc", "steam", "stratz", "opendota"}
+
+const (
+	defaultFailureThreshold = 3
+	defaultProviderCooldown = 10 * time.Minute
+)
+
+// ProviderStatus is the health of one resolver as shown by /api/providers.
+type ProviderStatus struct {
+	Name                string    `json:"name"`
+	Enabled             bool      `json:"enabled"` // Part of the configured order
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	Successes           int       `json:"successes"`
+	Failures            int       `json:"failures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastFailure         time.Time `json:"lastFailure,omitempty"`
+	SkippedUntil        time.Time `json:"skippedUntil,omitempty"`
+}
+
+// resolverChain holds the registered resolvers, their order and health.
+type resolverChain struct {
+	mu        sync.Mutex
+	resolvers map[string]ReplayURLResolver
+	order     []string
+	health    map[string]*ProviderStatus
+	threshold int
+	cooldown  time.Duration
+}
+
+var chain = &resolverChain{
+	resolvers: make(map[string]ReplayURLResolver),
+	health:    make(map[string]*ProviderStatus),
+	threshold: defaultFailureThreshold,
+	cooldown:  defaultProviderCooldown,
+}
+
+func init() {
+	RegisterResolver(gcResolver{})
+	RegisterResolver(steamResolver{})
+	RegisterResolver(stratzResolver{})
+	RegisterResolver(openDotaResolver{})
+	chain.order = append([]string(nil), DefaultProviderOrder...)
+}
+
+// RegisterResolver adds a resolver, replacing one with the same name. A new
+// resolver is not used until it is part of the order set with
+// SetProviderOrder.
+func RegisterResolver(r ReplayURLResolver) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.resolvers[r.Name()] = r
+	if _, ok := chain.health[r.Name()]; !ok {
+		chain.health[r.Name()] = &ProviderStatus{Name: r.Name()}
+	}
+}
+
+// SetProviderOrder sets the order resolvers are tried in. An empty order
+// restores DefaultProviderOrder.
+func SetProviderOrder(names []string) error {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if len(names) == 0 {
+		names = DefaultProviderOrder
+	}
+	order := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := chain.resolvers[name]; !ok {
+			return fmt.Errorf("unknown replay provider %q", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			order = append(order, name)
+		}
+	}
+	chain.order = order
+	return nil
+}
+
+// SetProviderPolicy sets how many consecutive failures take a provider out
+// of the chain and for how long. Zero values keep the defaults.
+func SetProviderPolicy(threshold int, cooldown time.Duration) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.threshold = defaultFailureThreshold
+	if threshold > 0 {
+		chain.threshold = threshold
+	}
+	chain.cooldown = defaultProviderCooldown
+	if cooldown > 0 {
+		chain.cooldown = cooldown
+	}
+}
+
+// ProviderOrder returns the configured resolver order.
+func ProviderOrder() []string {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	return append([]string(nil), chain.order...)
+}
+
+// Providers returns the health of every registered resolver, configured
+// ones first in order.
+func Providers() []ProviderStatus {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	var list []ProviderStatus
+	enabled := make(map[string]bool)
+	for _, name := range chain.order {
+		enabled[name] = true
+		list = append(list, chain.statusLocked(name, true))
+	}
+	var others []string
+	for name := range chain.resolvers {
+		if !enabled[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		list = append(list, chain.statusLocked(name, false))
+	}
+	return list
+}
+
+func (c *resolverChain) statusLocked(name string, enabled bool) ProviderStatus {
+	s := *c.health[name]
+	s.Enabled = enabled
+	s.Healthy = time.Now().After(s.SkippedUntil)
+	return s
+}
+
+// next returns the resolvers to try, in order, skipping those cooling down.
+func (c *resolverChain) next() []ReplayURLResolver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []ReplayURLResolver
+	for _, name := range c.order {
+		if until := c.health[name].SkippedUntil; time.Now().Before(until) {
+			log.Printf("Skipping replay provider %s: cooling down until %s", name, until.Format(time.RFC3339))
+			continue
+		}
+		list = append(list, c.resolvers[name])
+	}
+	return list
+}
+
+// record updates a provider's health after a Resolve call.
+func (c *resolverChain) record(name string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.health[name]
+	var parseErr *ParseRequestedError
+	switch {
+	case err == nil, errors.Is(err, ErrNoReplayInfo), errors.As(err, &parseErr) && parseErr.Err == nil:
+		// The provider answered.
+		h.Successes++
+		h.ConsecutiveFailures = 0
+		h.LastSuccess = time.Now()
+		h.SkippedUntil = time.Time{}
+	case errors.Is(err, ErrProviderUnavailable):
+	default:
+		h.Failures++
+		h.ConsecutiveFailures++
+		h.LastError = err.Error()
+		h.LastFailure = time.Now()
+		if h.ConsecutiveFailures >= c.threshold {
+			h.SkippedUntil = time.Now().Add(c.cooldown)
+			log.Printf("Replay provider %s failed %d times in a row, skipping it for %v", name, h.ConsecutiveFailures, c.cooldown)
+		}
+	}
+}
+
+// resolveReplay asks each resolver in turn for the location of matchID. If
+// none has it but one queued a parse, that ParseRequestedError is returned.
+func resolveReplay(matchID int64, src Sources) (ReplayLocation, error) {
+	var errs []string
+	var pending *ParseRequestedError
+	expired := false
+	for _, r := range chain.next() {
+		log.Printf("Attempting to get replay URL from %s for match %d...", r.Name(), matchID)
+		loc, err := r.Resolve(matchID, src)
+		chain.record(r.Name(), err)
+		if err == nil && len(loc.URLs) > 0 {
+			loc.Source = r.Name()
+			log.Printf("Found replay URL via %s: %s (and %d alternates)", r.Name(), loc.URLs[0], len(loc.URLs)-1)
+			return loc, nil
+		}
+		if err == nil {
+			err = ErrNoReplayInfo
+		}
+		var parseErr *ParseRequestedError
+		if errors.As(err, &parseErr) && pending == nil {
+			pending = parseErr
+		}
+		expired = expired || errors.Is(err, ErrExpired)
+		log.Printf("Replay provider %s failed for match %d: %v", r.Name(), matchID, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", r.Name(), err))
+	}
+	if pending != nil {
+		return ReplayLocation{}, pending
+	}
+	if len(errs) == 0 {
+		return ReplayLocation{}, fmt.Errorf("no replay provider available for match %d", matchID)
+	}
+	if expired {
+		return ReplayLocation{}, fmt.Errorf("no replay provider found match %d (%s): %w", matchID, strings.Join(errs, "; "), ErrExpired)
+	}
+	return ReplayLocation{}, fmt.Errorf("no replay provider found match %d (%s)", matchID, strings.Join(errs, "; "))
+}
+
+// locationFor builds a ReplayLocation for all CDN alternatives of a
+// cluster/salt pair.
+func locationFor(cluster uint32, matchID int64, salt uint64) ReplayLocation {
+	return ReplayLocation{
+		URLs:    constructReplayURLs(cluster, matchID, salt),
+		Cluster: cluster,
+		Salt:    salt,
+	}
+}
+
+// gcResolver asks the Dota 2 Game Coordinator through the bot.
+type gcResolver struct{}
+
+func (gcResolver) Name() string { return "gc" }
+
+func (gcResolver) Resolve(matchID int64, src Sources) (ReplayLocation, error) {
+	if src.GCClient == nil {
+		return ReplayLocation{}, fmt.Errorf("no GC bot configured: %w", ErrProviderUnavailable)
+	}
+	status := src.GCClient.GetStatus()
+	if status != gc.StatusGCReady && status != gc.StatusConnected {
+		return ReplayLocation{}, fmt.Errorf("bot status is %d, expected GCReady/Connected: %w", status, ErrProviderUnavailable)
+	}
+	cluster, salt, err := src.GCClient.GetReplayInfo(context.Background(), uint64(matchID))
+	if errors.Is(err, gc.ErrNotReady) || errors.Is(err, gc.ErrRateLimited) {
+		return ReplayLocation{}, fmt.Errorf("%v: %w", err, ErrProviderUnavailable)
+	}
+	if err != nil {
+		return ReplayLocation{}, err
+	}
+	if cluster == 0 || salt == 0 {
+		return ReplayLocation{}, fmt.Errorf("GC returned no cluster/salt: %w", ErrNoReplayInfo)
+	}
+	return locationFor(cluster, matchID, salt), nil
+}
+
+// steamResolver uses the Steam WebAPI match details.
+type steamResolver struct{}
+
+func (steamResolver) Name() string { return "steam" }
+
+func (steamResolver) Resolve(matchID int64, src Sources) (ReplayLocation, error) {
+	if src.SteamAPIKey == "" {
+		return ReplayLocation{}, fmt.Errorf("no Steam API key: %w", ErrProviderUnavailable)
+	}
+	clusterID, replaySalt, err := steamapi.NewClient(src.SteamAPIKey).GetReplayInfo(matchID)
+	if err != nil {
+		return ReplayLocation{}, err
+	}
+	if clusterID <= 0 || replaySalt <= 0 {
+		return ReplayLocation{}, fmt.Errorf("Steam WebAPI returned no cluster/salt: %w", ErrNoReplayInfo)
+	}
+	return locationFor(uint32(clusterID), matchID, uint64(replaySalt)), nil
+}
+
+// stratzResolver uses the Stratz GraphQL API. Stratz does not host replays;
+// it only provides the cluster/salt for the Valve CDN URLs.
+type stratzResolver struct{}
+
+func (stratzResolver) Name() string { return "stratz" }
+
+func (stratzResolver) Resolve(matchID int64, src Sources) (ReplayLocation, error) {
+	if src.StratzToken == "" {
+		return ReplayLocation{}, fmt.Errorf("no Stratz API token: %w", ErrProviderUnavailable)
+	}
+	return getReplayLocationFromStratz(matchID, src.StratzToken)
+}
+
+// openDotaResolver uses OpenDota, asking it to parse the match first when
+// needed. OpenDota does not host replays; it returns Valve CDN URLs.
+type openDotaResolver struct{}
+
+func (openDotaResolver) Name() string { return "opendota" }
+
+func (openDotaResolver) Resolve(matchID int64, src Sources) (ReplayLocation, error) {
+	hasParsed, err := checkOpenDotaParsed(matchID)
+	if err != nil {
+		if IsTransient(err) {
+			return ReplayLocation{}, &ParseRequestedError{Provider: "opendota", Err: err}
+		}
+		return ReplayLocation{}, fmt.Errorf("failed to check OpenDota parsed status: %w", err)
+	}
+
+	if !hasParsed {
+		log.Printf("Match %d not parsed yet on OpenDota, requesting parsing...", matchID)
+		jobID, err := RequestParsing(matchID)
+		if err != nil {
+			if IsTransient(err) {
+				return ReplayLocation{}, &ParseRequestedError{Provider: "opendota", Err: err}
+			}
+			return ReplayLocation{}, fmt.Errorf("failed to request parsing: %w", err)
+		}
+		return ReplayLocation{}, &ParseRequestedError{Provider: "opendota", JobID: jobID}
+	}
+
+	// Now fetch the replay URL (should be available if parsed)
+	odURL, err := getReplayURL(matchID)
+	if err != nil {
+		if IsTransient(err) {
+			return ReplayLocation{}, &ParseRequestedError{Provider: "opendota", Err: err}
+		}
+		return ReplayLocation{}, fmt.Errorf("failed to get replay URL from OpenDota: %w", err)
+	}
+	if odURL == "" {
+		// OpenDota has parsed the match, so the replay is gone.
+		return ReplayLocation{}, fmt.Errorf("replay URL is missing for match %d: %w (%w)", matchID, ErrNoReplayInfo, ErrExpired)
+	}
+
+	// Try to extract cluster/salt from OpenDota's URL to generate alternative CDN URLs
+	if cluster, salt, ok := extractClusterSaltFromURL(odURL, matchID); ok {
+		return locationFor(cluster, matchID, salt), nil
+	}
+	// Fallback: use OpenDota's URL as-is (it's still a Valve CDN URL)
+	log.Printf("Could not extract cluster/salt from OpenDota URL, using URL as-is: %s", odURL)
+	return ReplayLocation{URLs: []string{odURL}}, nil
+}
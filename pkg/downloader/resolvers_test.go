@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// namedResolver is a resolver whose answers are never asked for; only its
+// health is.
+type namedResolver string
+
+func (r namedResolver) Name() string { return string(r) }
+
+func (namedResolver) Resolve(int64, Sources) (ReplayLocation, error) {
+	return ReplayLocation{}, ErrNoReplayInfo
+}
+
+// testChain returns a chain of the resolvers a and b, tried in that order.
+func testChain(threshold int, cooldown time.Duration) *resolverChain {
+	c := &resolverChain{
+		resolvers: make(map[string]ReplayURLResolver),
+		health:    make(map[string]*ProviderStatus),
+		order:     []string{"a", "b"},
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+	for _, name := range c.order {
+		c.resolvers[name] = namedResolver(name)
+		c.health[name] = &ProviderStatus{Name: name}
+	}
+	return c
+}
+
+func chainNames(c *resolverChain) []string {
+	var names []string
+	for _, r := range c.next() {
+		names = append(names, r.Name())
+	}
+	return names
+}
+
+func TestResolverChainRecord(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name        string
+		errs        []error // recorded for a, in order
+		consecutive int
+		failures    int
+		want        []string
+	}{
+		{"below the threshold", []error{failure, failure}, 2, 2, []string{"a", "b"}},
+		{"threshold reached", []error{failure, failure, failure}, 3, 3, []string{"b"}},
+		{"unavailable is not a failure", []error{failure, failure, ErrProviderUnavailable, fmt.Errorf("no key: %w", ErrProviderUnavailable)}, 2, 2, []string{"a", "b"}},
+		{"no replay info is an answer", []error{failure, failure, fmt.Errorf("no salt: %w", ErrNoReplayInfo), failure}, 1, 3, []string{"a", "b"}},
+		{"parse requested is an answer", []error{failure, failure, &ParseRequestedError{Provider: "a", JobID: 1}, failure}, 1, 3, []string{"a", "b"}},
+		{"provider down is a failure", []error{failure, failure, &ParseRequestedError{Provider: "a", Err: failure}}, 3, 3, []string{"b"}},
+		{"success resets the count", []error{failure, failure, nil, failure, failure}, 2, 4, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testChain(3, time.Hour)
+			for _, err := range tt.errs {
+				c.record("a", err)
+			}
+			h := c.health["a"]
+			if h.ConsecutiveFailures != tt.consecutive || h.Failures != tt.failures {
+				t.Errorf("got %d consecutive of %d failures, want %d of %d", h.ConsecutiveFailures, h.Failures, tt.consecutive, tt.failures)
+			}
+			if got := chainNames(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("next = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverChainCooldown(t *testing.T) {
+	c := testChain(2, 20*time.Millisecond)
+	failure := errors.New("timeout")
+	c.record("a", failure)
+	c.record("a", failure)
+	if got := chainNames(c); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("next after reaching the threshold = %v, want [b]", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if got := chainNames(c); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("next after the cooldown = %v, want [a b]", got)
+	}
+
+	// Still failing after the cooldown: skipped again straight away.
+	c.record("a", failure)
+	if got := chainNames(c); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("next after failing again = %v, want [b]", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	c.record("a", nil)
+	c.record("a", failure)
+	if h := c.health["a"]; h.ConsecutiveFailures != 1 || !h.SkippedUntil.IsZero() {
+		t.Fatalf("after a success and a failure: %d consecutive failures, skipped until %v, want 1 and not skipped", h.ConsecutiveFailures, h.SkippedUntil)
+	}
+}
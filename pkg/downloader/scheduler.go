@@ -0,0 +1,209 @@
+package downloader
+
+import (
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Priority orders downloads waiting for a slot. Lower values go first.
+type Priority int
+
+const (
+	// PriorityInteractive is a download the user is waiting on.
+	PriorityInteractive Priority = iota
+	// PriorityBulk is one of many downloads started together, such as
+	// "Download all fatal replays" or report card validation.
+	PriorityBulk
+	// PriorityBackground is a job resumed or retried without the user.
+	PriorityBackground
+)
+
+const defaultMaxConcurrentDownloads = 2
+
+// ticket is a download waiting for or holding a slot.
+type ticket struct {
+	matchID  int64
+	priority Priority
+	deadline time.Time // earliest expected replay expiry, zero if unknown
+	seq      uint64
+	ready    chan struct{}
+}
+
+// downloadScheduler caps the number of CDN transfers running at once and
+// hands free slots to the highest priority waiting download; within a
+// priority, the replay closest to expiring, then the oldest request.
+type downloadScheduler struct {
+	mu      sync.Mutex
+	max     int
+	running int
+	seq     uint64
+	queue   []*ticket
+}
+
+var scheduler = &downloadScheduler{max: defaultMaxConcurrentDownloads}
+
+// SetDownloadLimits sets how many replays download at once and the total
+// bandwidth they may use. maxConcurrent < 1 keeps the default; bytesPerSec
+// <= 0 removes the bandwidth limit.
+func SetDownloadLimits(maxConcurrent int, bytesPerSec int64) {
+	if maxConcurrent < 1 {
+		maxConcurrent = defaultMaxConcurrentDownloads
+	}
+	scheduler.mu.Lock()
+	scheduler.max = maxConcurrent
+	scheduler.dispatchLocked()
+	scheduler.mu.Unlock()
+	bandwidth.setRate(bytesPerSec)
+}
+
+// QueuePosition returns the 1-based position of a match among downloads
+// waiting for a slot, or 0 if it is not waiting.
+func QueuePosition(matchID int64) int {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	for i, t := range scheduler.queue {
+		if t.matchID == matchID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// acquire waits for a download slot. It returns ErrCancelled if the job for
+// matchID in replayDir is cancelled while waiting. The returned function
+// releases the slot.
+func (s *downloadScheduler) acquire(matchID int64, replayDir string, priority Priority) (func(), error) {
+	s.mu.Lock()
+	s.seq++
+	t := &ticket{matchID: matchID, priority: priority, deadline: Expiry(matchID).ExpiresAt, seq: s.seq, ready: make(chan struct{})}
+	s.queue = append(s.queue, t)
+	sort.SliceStable(s.queue, func(a, b int) bool {
+		qa, qb := s.queue[a], s.queue[b]
+		if qa.priority != qb.priority {
+			return qa.priority < qb.priority
+		}
+		if !qa.deadline.Equal(qb.deadline) {
+			return moreUrgent(qa.deadline, qb.deadline)
+		}
+		return qa.seq < qb.seq
+	})
+	s.dispatchLocked()
+	if pos := s.positionLocked(t); pos > 0 {
+		log.Printf("Download of match %d queued at position %d (%d running)", matchID, pos, s.running)
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ready:
+			return s.release, nil
+		case <-ticker.C:
+			if !jobCancelled(matchID, replayDir) {
+				continue
+			}
+			s.mu.Lock()
+			if pos := s.positionLocked(t); pos > 0 {
+				s.queue = append(s.queue[:pos-1], s.queue[pos:]...)
+				s.mu.Unlock()
+				return nil, ErrCancelled
+			}
+			// Got a slot at the same moment; give it back.
+			s.mu.Unlock()
+			s.release()
+			return nil, ErrCancelled
+		}
+	}
+}
+
+func (s *downloadScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	s.dispatchLocked()
+}
+
+// dispatchLocked starts waiting downloads while slots are free.
+func (s *downloadScheduler) dispatchLocked() {
+	for s.running < s.max && len(s.queue) > 0 {
+		t := s.queue[0]
+		s.queue = s.queue[1:]
+		s.running++
+		close(t.ready)
+	}
+}
+
+func (s *downloadScheduler) positionLocked(t *ticket) int {
+	for i, q := range s.queue {
+		if q == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// bandwidthLimiter is a token bucket shared by all downloads. A rate of 0
+// means unlimited.
+type bandwidthLimiter struct {
+	mu        sync.Mutex
+	rate      int64
+	available float64
+	last      time.Time
+}
+
+var bandwidth = &bandwidthLimiter{}
+
+func (b *bandwidthLimiter) setRate(bytesPerSec int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	b.rate = bytesPerSec
+	b.available = 0
+	b.last = time.Now()
+}
+
+// wait blocks until n bytes may be transferred.
+func (b *bandwidthLimiter) wait(n int) {
+	b.mu.Lock()
+	if b.rate == 0 {
+		b.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	b.available += now.Sub(b.last).Seconds() * float64(b.rate)
+	// Allow at most one second of burst.
+	if b.available > float64(b.rate) {
+		b.available = float64(b.rate)
+	}
+	b.last = now
+	b.available -= float64(n)
+	var delay time.Duration
+	if b.available < 0 {
+		delay = time.Duration(-b.available / float64(b.rate) * float64(time.Second))
+	}
+	b.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// throttledReader limits reads to the shared bandwidth.
+type throttledReader struct {
+	r io.Reader
+}
+
+func (t throttledReader) Read(p []byte) (int, error) {
+	// Keep chunks small so a low limit still gives smooth progress.
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		bandwidth.wait(n)
+	}
+	return n, err
+}
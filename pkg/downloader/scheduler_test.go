@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n downloads are waiting for a slot of s.
+func waitQueued(t *testing.T, s *downloadScheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		queued := len(s.queue)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d downloads queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// acquireAsync asks s for a slot in the background. The match ID is sent
+// on got once the slot is granted; the test gives it back with s.release.
+func acquireAsync(t *testing.T, s *downloadScheduler, matchID int64, priority Priority, got chan<- int64) {
+	go func() {
+		if _, err := s.acquire(matchID, "replays", priority); err != nil {
+			t.Errorf("acquire(%d): %v", matchID, err)
+			return
+		}
+		got <- matchID
+	}()
+}
+
+func (s *downloadScheduler) counts() (running, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running, len(s.queue)
+}
+
+func TestSchedulerCap(t *testing.T) {
+	useTempJobs(t)
+	s := &downloadScheduler{max: 2}
+	got := make(chan int64, 3)
+	for id := int64(1); id <= 3; id++ {
+		acquireAsync(t, s, id, PriorityInteractive, got)
+	}
+	<-got
+	<-got
+	waitQueued(t, s, 1)
+	if running, _ := s.counts(); running != 2 {
+		t.Fatalf("%d downloads running, want the cap of 2", running)
+	}
+	select {
+	case id := <-got:
+		t.Fatalf("match %d got a slot over the cap", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.release()
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatal("queued download did not get the released slot")
+	}
+	if running, queued := s.counts(); running != 2 || queued != 0 {
+		t.Fatalf("%d running and %d queued, want 2 and 0", running, queued)
+	}
+}
+
+func TestSchedulerInteractiveFirst(t *testing.T) {
+	useTempJobs(t)
+	s := &downloadScheduler{max: 1}
+	release, err := s.acquire(1, "replays", PriorityBackground)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan int64, 4)
+	acquireAsync(t, s, 2, PriorityBackground, got)
+	waitQueued(t, s, 1)
+	acquireAsync(t, s, 3, PriorityBulk, got)
+	waitQueued(t, s, 2)
+	acquireAsync(t, s, 4, PriorityBackground, got)
+	waitQueued(t, s, 3)
+	acquireAsync(t, s, 5, PriorityInteractive, got)
+	waitQueued(t, s, 4)
+
+	var order []int64
+	for i := 0; i < 4; i++ {
+		release()
+		select {
+		case id := <-got:
+			order = append(order, id)
+		case <-time.After(time.Second):
+			t.Fatalf("no download started after %v", order)
+		}
+		release = s.release
+	}
+	want := []int64{5, 3, 2, 4}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("started in order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestSchedulerCancelWhileQueued(t *testing.T) {
+	useTempJobs(t)
+	s := &downloadScheduler{max: 1}
+	release, err := s.acquire(1, "replays", PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setJobState(2, "replays", JobQueued, nil)
+	done := make(chan error, 1)
+	go func() {
+		release, err := s.acquire(2, "replays", PriorityInteractive)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	waitQueued(t, s, 1)
+	if _, err := CancelJob(2, "replays"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCancelled) {
+			t.Fatalf("acquire of a cancelled job: %v, want ErrCancelled", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("cancelled download stayed queued")
+	}
+	if running, queued := s.counts(); running != 1 || queued != 0 {
+		t.Fatalf("%d running and %d queued after cancelling, want 1 and 0", running, queued)
+	}
+
+	// The cancelled download took no slot: once the first is done, the
+	// next gets one straight away.
+	release()
+	next, err := s.acquire(3, "replays", PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next()
+	if running, _ := s.counts(); running != 0 {
+		t.Fatalf("%d downloads running after all released, want 0", running)
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	saved := bandwidth
+	bandwidth = &bandwidthLimiter{}
+	defer func() { bandwidth = saved }()
+
+	const rate = 256 * 1024
+	bandwidth.setRate(rate)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, throttledReader{bytes.NewReader(make([]byte, rate/2))})
+	if err != nil || n != rate/2 {
+		t.Fatalf("copied %d bytes, %v", n, err)
+	}
+	// Half a second of data at the full rate, starting with an empty
+	// bucket.
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("reading %d bytes at %d bytes/s took %v, want about 500ms", rate/2, rate, elapsed)
+	}
+
+	bandwidth.setRate(0)
+	start = time.Now()
+	io.Copy(io.Discard, throttledReader{bytes.NewReader(make([]byte, rate))})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("unlimited read took %v", elapsed)
+	}
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// WatchTarget is a profile whose new matches are archived automatically.
+type WatchTarget struct {
+	Name      string
+	SteamID   int64
+	ReplayDir string
+}
+
+// WatchStatus is the outcome of the last poll of a watched profile.
+type WatchStatus struct {
+	Name       string    `json:"name"`
+	SteamID    int64     `json:"steamId"`
+	LastPoll   time.Time `json:"lastPoll"`
+	LastError  string    `json:"lastError,omitempty"`
+	Downloaded int       `json:"downloaded"` // replays archived since startup
+}
+
+const (
+	defaultWatchInterval = 30 * time.Minute
+	// watchHistoryLimit is how many recent matches each poll looks at.
+	watchHistoryLimit = 20
+	// watchMaxRetries is how many times later polls retry a failed download.
+	watchMaxRetries = 3
+)
+
+var watcher = struct {
+	sync.Mutex
+	interval time.Duration
+	status   map[string]*WatchStatus
+	wake     chan struct{}
+}{
+	interval: defaultWatchInterval,
+	status:   make(map[string]*WatchStatus),
+	wake:     make(chan struct{}, 1),
+}
+
+// SetWatchInterval sets how often watched profiles are polled. d <= 0 keeps
+// the default of 30 minutes.
+func SetWatchInterval(d time.Duration) {
+	if d <= 0 {
+		d = defaultWatchInterval
+	}
+	watcher.Lock()
+	watcher.interval = d
+	watcher.Unlock()
+	PollWatchedNow()
+}
+
+// PollWatchedNow makes the watcher poll without waiting for the interval.
+func PollWatchedNow() {
+	select {
+	case watcher.wake <- struct{}{}:
+	default:
+	}
+}
+
+// WatcherStatus returns the last poll outcome of each watched profile.
+func WatcherStatus() []WatchStatus {
+	watcher.Lock()
+	defer watcher.Unlock()
+	list := make([]WatchStatus, 0, len(watcher.status))
+	for _, s := range watcher.status {
+		list = append(list, *s)
+	}
+	return list
+}
+
+// StartWatcher polls the match history of the profiles returned by targets
+// through the GC and downloads new ranked and Single Draft matches before
+// their replays expire. targets is called before every poll, so watching is
+// off while it returns nothing.
+func StartWatcher(targets func() []WatchTarget, gcClient gc.Service) {
+	go func() {
+		for {
+			for _, t := range targets() {
+				n, err := pollWatchTarget(t, gcClient)
+				recordWatchPoll(t, n, err)
+			}
+
+			watcher.Lock()
+			interval := watcher.interval
+			watcher.Unlock()
+			select {
+			case <-time.After(interval):
+			case <-watcher.wake:
+			}
+		}
+	}()
+}
+
+func recordWatchPoll(t WatchTarget, downloaded int, err error) {
+	watcher.Lock()
+	defer watcher.Unlock()
+	s, ok := watcher.status[t.Name]
+	if !ok {
+		s = &WatchStatus{Name: t.Name}
+		watcher.status[t.Name] = s
+	}
+	s.SteamID = t.SteamID
+	s.LastPoll = time.Now()
+	s.Downloaded += downloaded
+	s.LastError = ""
+	if err != nil {
+		s.LastError = err.Error()
+	}
+}
+
+// pollWatchTarget downloads the profile's recent matches that are worth
+// archiving and not already on disk or known to the job list. Failed jobs
+// are retried up to watchMaxRetries times. It returns how many replays were
+// downloaded.
+func pollWatchTarget(t WatchTarget, gcClient gc.Service) (int, error) {
+	const (
+		LobbyTypeRanked     = 7
+		GameModeSingleDraft = 4
+	)
+
+	if gcClient == nil {
+		return 0, fmt.Errorf("GC client not available")
+	}
+	if status := gcClient.GetStatus(); status != gc.StatusGCReady && status != gc.StatusConnected {
+		return 0, fmt.Errorf("%w (status %d)", gc.ErrNotReady, status)
+	}
+
+	// Polls yield to the requests of the user.
+	matches, err := gc.Background(gcClient).GetPlayerMatchHistory(context.Background(), t.SteamID, watchHistoryLimit, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch match history: %w", err)
+	}
+
+	var candidates []Job
+	for _, m := range matches {
+		RecordStartTime(m.ID, time.Unix(int64(m.StartTime), 0))
+		if m.LobbyType != LobbyTypeRanked && m.GameMode != GameModeSingleDraft {
+			continue
+		}
+		if j, ok := jobs.get(m.ID, t.ReplayDir); ok && (j.State != JobFailed || j.Retries >= watchMaxRetries) {
+			// Done, running, cancelled, expired or failed too often.
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(t.ReplayDir, fmt.Sprintf("%d.dem", m.ID))); err == nil {
+			continue
+		}
+		if Expiry(m.ID).Availability == AvailabilityLikelyExpired {
+			continue
+		}
+		candidates = append(candidates, Job{MatchID: m.ID})
+	}
+	sortByUrgency(candidates)
+
+	downloaded := 0
+	for _, c := range candidates {
+		if j, ok := jobs.get(c.MatchID, t.ReplayDir); ok {
+			j = jobs.update(c.MatchID, t.ReplayDir, func(j *Job) { j.Retries++ })
+			log.Printf("Watcher: retrying match %d for %s (attempt %d of %d)", c.MatchID, t.Name, j.Retries, watchMaxRetries)
+		} else {
+			log.Printf("Watcher: archiving new match %d for %s", c.MatchID, t.Name)
+		}
+		src := jobSources()
+		src.GCClient = gcClient
+		err := runDownload(c.MatchID, t.ReplayDir, src, PriorityBackground, false)
+		switch {
+		case err == nil:
+			downloaded++
+		case errors.Is(err, ErrQueued):
+			// Finished later by the pending job worker.
+		default:
+			log.Printf("Watcher: failed to archive match %d for %s: %v", c.MatchID, t.Name, err)
+		}
+	}
+	return downloaded, nil
+}
@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// historyGC is a GC that only knows the match history of one player.
+type historyGC struct {
+	gc.Service
+	matches []gc.Match
+}
+
+func (historyGC) GetStatus() gc.ConnectionStatus { return gc.StatusGCReady }
+
+func (h historyGC) GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]gc.Match, error) {
+	return h.matches, nil
+}
+
+func TestWatcherRetriesFailedJobs(t *testing.T) {
+	useTempJobs(t)
+	_, _, url, _ := replayServer(t)
+	SetEndpoints(Endpoints{ReplayHosts: []string{strings.TrimSuffix(url, "/570/1_1.dem.bz2")}})
+	defer SetEndpoints(Endpoints{})
+	RegisterResolver(fakeResolver{cluster: 1, salt: 1})
+	if err := SetProviderOrder([]string{"fake"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetProviderOrder(nil)
+	saved := locators
+	locators = &locatorStore{locators: make(map[int64]Locator)}
+	defer func() { locators = saved }()
+
+	dir := t.TempDir()
+	start := uint32(time.Now().Add(-time.Hour).Unix())
+	var history []gc.Match
+	for id := int64(1); id <= 4; id++ {
+		history = append(history, gc.Match{ID: id, StartTime: start, LobbyType: 7})
+	}
+	setJobState(1, dir, JobFailed, nil)
+	setJobState(2, dir, JobFailed, nil)
+	jobs.update(2, dir, func(j *Job) { j.Retries = watchMaxRetries })
+	setJobState(3, dir, JobCancelled, nil)
+	// Match 4 is new.
+
+	n, err := pollWatchTarget(WatchTarget{Name: "p", SteamID: 1, ReplayDir: dir}, historyGC{matches: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("downloaded %d replays, want the failed one and the new one", n)
+	}
+	for id, want := range map[int64]bool{1: true, 2: false, 3: false, 4: true} {
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%d.dem", id)))
+		if got := err == nil; got != want {
+			t.Errorf("match %d downloaded = %v, want %v", id, got, want)
+		}
+	}
+	if j, _ := jobs.get(1, dir); j.State != JobDone || j.Retries != 1 {
+		t.Errorf("retried job = %s after %d retries, want done after 1", j.State, j.Retries)
+	}
+}
@@ -0,0 +1,100 @@
+package gc
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errors of GC requests. Services wrap them, so callers test with errors.Is
+// instead of matching messages.
+var (
+	// ErrNotReady means there is no GC session to send the request on.
+	ErrNotReady = errors.New("GC not ready")
+	// ErrRateLimited means Steam throttled the account.
+	ErrRateLimited = errors.New("rate limited by Steam")
+	// ErrTimeout means the GC did not answer in time, even after retries.
+	ErrTimeout = errors.New("GC request timed out")
+)
+
+// Priority orders GC requests waiting for the same session.
+type Priority int
+
+const (
+	// PriorityInteractive is for requests a user is waiting on.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for watchers and prefetching; such requests
+	// wait while interactive ones are queued.
+	PriorityBackground
+)
+
+// Background returns a view of s whose requests run at PriorityBackground,
+// or s itself if it has no priorities.
+func Background(s Service) Service {
+	if p, ok := s.(interface{ WithPriority(Priority) Service }); ok {
+		return p.WithPriority(PriorityBackground)
+	}
+	return s
+}
+
+// Error codes carry the typed errors across the bot's HTTP API in the
+// ErrorHeader response header.
+const (
+	ErrorHeader    = "X-GC-Error"
+	PriorityHeader = "X-GC-Priority"
+
+	codeNotReady    = "not_ready"
+	codeRateLimited = "rate_limited"
+	codeTimeout     = "timeout"
+)
+
+// ErrorCode returns the code of a typed error, or "".
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrNotReady):
+		return codeNotReady
+	case errors.Is(err, ErrRateLimited):
+		return codeRateLimited
+	case errors.Is(err, ErrTimeout):
+		return codeTimeout
+	}
+	return ""
+}
+
+// remoteError keeps the message of an error received over HTTP while
+// unwrapping to its typed error.
+type remoteError struct {
+	typed error
+	msg   string
+}
+
+func (e *remoteError) Error() string { return e.msg }
+func (e *remoteError) Unwrap() error { return e.typed }
+
+// ErrorFromCode rebuilds an error received with code, so errors.Is works on
+// the client side.
+func ErrorFromCode(code, msg string) error {
+	switch code {
+	case codeNotReady:
+		return &remoteError{ErrNotReady, msg}
+	case codeRateLimited:
+		return &remoteError{ErrRateLimited, msg}
+	case codeTimeout:
+		return &remoteError{ErrTimeout, msg}
+	}
+	return errors.New(msg)
+}
+
+// SetErrorHeader tags an HTTP error response with the code of err.
+func SetErrorHeader(w http.ResponseWriter, err error) {
+	if code := ErrorCode(err); code != "" {
+		w.Header().Set(ErrorHeader, code)
+	}
+}
+
+// PriorityFromRequest returns the priority a bot request asked for.
+func PriorityFromRequest(r *http.Request) Priority {
+	if r.Header.Get(PriorityHeader) == "background" {
+		return PriorityBackground
+	}
+	return PriorityInteractive
+}
@@ -0,0 +1,158 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StatusEvent is a change of a Service's connection status.
+type StatusEvent struct {
+	Status     ConnectionStatus `json:"status"`
+	StatusText string           `json:"statusText"`
+	// Reason is the login error behind the status, if any.
+	Reason string `json:"reason,omitempty"`
+	// SessionSaved reports that the login saved a session, so the password
+	// is no longer needed.
+	SessionSaved bool      `json:"sessionSaved,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// NewStatusEvent returns the event for status with reason, stamped now.
+func NewStatusEvent(status ConnectionStatus, reason string) StatusEvent {
+	return StatusEvent{Status: status, StatusText: StatusText(status), Reason: reason, Time: time.Now()}
+}
+
+// StatusText is the display name of a status.
+func StatusText(s ConnectionStatus) string {
+	switch s {
+	case StatusConnecting:
+		return "Connecting"
+	case StatusNeedGuardCode:
+		return "Need Steam Guard Code"
+	case StatusConnected:
+		return "Connected to Steam"
+	case StatusGCReady:
+		return "Dota 2 GC Ready"
+	case StatusRateLimited:
+		return "Rate Limited (Wait 24h)"
+	}
+	return "Disconnected"
+}
+
+// Broadcaster fans status events out to subscribers. New subscribers get the
+// last event first, so they start from the current status.
+type Broadcaster struct {
+	mu   sync.Mutex
+	last *StatusEvent
+	subs map[chan StatusEvent]struct{}
+}
+
+// Publish sends ev to every subscriber unless it repeats the last event.
+// Subscribers that are not keeping up lose their oldest events rather than
+// block, so the latest status always reaches them.
+func (b *Broadcaster) Publish(ev StatusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last != nil && b.last.Status == ev.Status && b.last.Reason == ev.Reason && b.last.SessionSaved == ev.SessionSaved {
+		return
+	}
+	b.last = &ev
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Only Publish sends, under b.mu, so after dropping the
+			// oldest event there is room.
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- ev
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that ends the
+// subscription.
+func (b *Broadcaster) Subscribe() (<-chan StatusEvent, func()) {
+	ch := make(chan StatusEvent, 16)
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan StatusEvent]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	if b.last != nil {
+		ch <- *b.last
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// ServeEvents streams the events of subscribe to an HTTP client as
+// Server-Sent Events ("event: status") until the client goes away.
+func ServeEvents(w http.ResponseWriter, r *http.Request, subscribe func() (<-chan StatusEvent, func())) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events, cancel := subscribe()
+	defer cancel()
+
+	// Keepalives let proxies and clients notice dead connections.
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case ev := <-events:
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+// WaitFor blocks until s reports one of statuses, or any status if none are
+// given, and returns it. It fails when ctx ends first.
+func WaitFor(ctx context.Context, s Service, statuses ...ConnectionStatus) (ConnectionStatus, error) {
+	events, cancel := s.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case ev := <-events:
+			if len(statuses) == 0 {
+				return ev.Status, nil
+			}
+			for _, want := range statuses {
+				if ev.Status == want {
+					return ev.Status, nil
+				}
+			}
+		case <-ctx.Done():
+			return s.GetStatus(), ctx.Err()
+		}
+	}
+}
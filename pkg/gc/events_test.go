@@ -0,0 +1,58 @@
+package gc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPublishDropsOldestForSlowSubscriber(t *testing.T) {
+	var b Broadcaster
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	// Distinct reasons, so none is skipped as a repeat.
+	const n = 40
+	for i := 0; i < n; i++ {
+		b.Publish(NewStatusEvent(StatusConnecting, fmt.Sprintf("attempt %d", i)))
+	}
+	b.Publish(NewStatusEvent(StatusGCReady, ""))
+
+	var got []StatusEvent
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	if len(got) != cap(events) {
+		t.Fatalf("got %d buffered events, want a full buffer of %d", len(got), cap(events))
+	}
+	if last := got[len(got)-1]; last.Status != StatusGCReady {
+		t.Fatalf("last event = %s %q, want the latest status %s", last.StatusText, last.Reason, StatusText(StatusGCReady))
+	}
+	// The rest are the newest of the earlier events, in order.
+	for i, ev := range got[:len(got)-1] {
+		want := fmt.Sprintf("attempt %d", n-cap(events)+1+i)
+		if ev.Reason != want {
+			t.Fatalf("event %d = %q, want %q", i, ev.Reason, want)
+		}
+	}
+}
+
+func TestPublishSkipsRepeats(t *testing.T) {
+	var b Broadcaster
+	b.Publish(NewStatusEvent(StatusConnecting, ""))
+	events, cancel := b.Subscribe()
+	defer cancel()
+	if ev := <-events; ev.Status != StatusConnecting {
+		t.Fatalf("first event = %s, want the current status", ev.StatusText)
+	}
+
+	b.Publish(NewStatusEvent(StatusConnecting, ""))
+	if len(events) != 0 {
+		t.Fatal("repeated status was published")
+	}
+	ev := NewStatusEvent(StatusConnecting, "")
+	ev.SessionSaved = true
+	b.Publish(ev)
+	if len(events) != 1 {
+		t.Fatal("a saved session was not published")
+	}
+}
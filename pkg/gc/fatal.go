@@ -0,0 +1,95 @@
+package gc
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	GameModeSingleDraft = 4
+	GameModeTurbo       = 23
+	LobbyTypeRanked     = 7
+)
+
+// FindFatalMatches looks through a match history, newest first, for up to
+// maxDepth Single Draft matches and, for each, the nearest older ranked
+// match (the fatal match). Up to gamesPerFatal ranked matches listed before
+// each Single Draft match are returned with it as AdditionalMatchIDs.
+func FindFatalMatches(matches []Match, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error) {
+	var fatalMatches []FatalMatchInfo
+	currentDepth := 0
+	i := 0
+
+	log.Printf("[FindFatalMatches] Starting search loop: maxDepth=%d, totalMatches=%d", maxDepth, len(matches))
+	for currentDepth < maxDepth && i < len(matches) {
+		log.Printf("[FindFatalMatches] Depth %d/%d: starting from index %d", currentDepth+1, maxDepth, i)
+		foundSingleDraft := false
+		singleDraftIndex := -1
+
+		for i < len(matches) {
+			m := matches[i]
+			if m.GameMode == GameModeSingleDraft {
+				if m.LobbyType == LobbyTypeRanked {
+					log.Printf("[FindFatalMatches] ERROR: Invalid match data at index %d: match %d is single draft but ranked", i, m.ID)
+					return nil, fmt.Errorf("invalid match data: single draft game cannot be ranked (match %d)", m.ID)
+				}
+				foundSingleDraft = true
+				singleDraftIndex = i
+				log.Printf("[FindFatalMatches] Found single draft game at index %d: match %d (gameMode=%d, lobbyType=%d)", i, m.ID, m.GameMode, m.LobbyType)
+				break
+			}
+			i++
+		}
+
+		if !foundSingleDraft {
+			log.Printf("[FindFatalMatches] No more single draft games found at depth %d", currentDepth+1)
+			break
+		}
+
+		foundRanked := false
+		rankedIndex := -1
+		log.Printf("[FindFatalMatches] Searching for ranked game after single draft (starting from index %d)", singleDraftIndex+1)
+		for j := singleDraftIndex + 1; j < len(matches); j++ {
+			m := matches[j]
+			if m.LobbyType == LobbyTypeRanked {
+				log.Printf("[FindFatalMatches] Found ranked game at index %d: match %d (gameMode=%d, lobbyType=%d)", j, m.ID, m.GameMode, m.LobbyType)
+				singleDraftMatch := matches[singleDraftIndex]
+
+				// Find additional ranked games BEFORE singledraft (matches are in reverse chronological order, newest first)
+				// So we need to look at indices BEFORE singleDraftIndex (which are older games)
+				var additionalIDs []int64
+				if gamesPerFatal > 0 {
+					// Start from the match right before singledraft and go backwards (to older games)
+					for k := singleDraftIndex - 1; k >= 0 && len(additionalIDs) < gamesPerFatal; k-- {
+						if matches[k].LobbyType == LobbyTypeRanked {
+							additionalIDs = append(additionalIDs, matches[k].ID)
+						}
+					}
+					log.Printf("[FindFatalMatches] Found %d ranked games before singledraft (need %d)", len(additionalIDs), gamesPerFatal)
+				}
+
+				fatalMatches = append(fatalMatches, FatalMatchInfo{
+					FatalMatchID:       m.ID,
+					SingleDraftMatchID: singleDraftMatch.ID,
+					SingleDraftDate:    singleDraftMatch.StartTime,
+					AdditionalMatchIDs: additionalIDs,
+				})
+				foundRanked = true
+				rankedIndex = j
+				break
+			}
+		}
+
+		if !foundRanked {
+			log.Printf("[FindFatalMatches] No ranked game found after single draft at depth %d", currentDepth+1)
+			break
+		}
+
+		currentDepth++
+		i = rankedIndex + 1
+		log.Printf("[FindFatalMatches] Completed depth %d: found fatal match %d (singleDraft: %d), continuing from index %d", currentDepth, fatalMatches[len(fatalMatches)-1].FatalMatchID, fatalMatches[len(fatalMatches)-1].SingleDraftMatchID, i)
+	}
+
+	log.Printf("[FindFatalMatches] Search complete: found %d fatal matches", len(fatalMatches))
+	return fatalMatches, nil
+}
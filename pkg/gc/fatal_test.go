@@ -0,0 +1,86 @@
+package gc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const gameModeAllPick = 22
+
+func ranked(id int64) Match {
+	return Match{ID: id, GameMode: gameModeAllPick, LobbyType: LobbyTypeRanked, StartTime: uint32(id)}
+}
+
+func unranked(id int64) Match {
+	return Match{ID: id, GameMode: gameModeAllPick, StartTime: uint32(id)}
+}
+
+func singleDraft(id int64) Match {
+	return Match{ID: id, GameMode: GameModeSingleDraft, StartTime: uint32(id)}
+}
+
+func TestFindFatalMatches(t *testing.T) {
+	// Newest first.
+	history := []Match{
+		ranked(12), unranked(11), ranked(10), singleDraft(9), unranked(8), ranked(7),
+		ranked(6), singleDraft(5), ranked(4), ranked(3),
+	}
+	tests := []struct {
+		name          string
+		matches       []Match
+		maxDepth      int
+		gamesPerFatal int
+		want          []FatalMatchInfo
+	}{
+		{
+			name: "two fatal matches", matches: history, maxDepth: 5, gamesPerFatal: 2,
+			want: []FatalMatchInfo{
+				{FatalMatchID: 7, SingleDraftMatchID: 9, SingleDraftDate: 9, AdditionalMatchIDs: []int64{10, 12}},
+				{FatalMatchID: 4, SingleDraftMatchID: 5, SingleDraftDate: 5, AdditionalMatchIDs: []int64{6, 7}},
+			},
+		},
+		{
+			name: "depth limit", matches: history, maxDepth: 1, gamesPerFatal: 1,
+			want: []FatalMatchInfo{
+				{FatalMatchID: 7, SingleDraftMatchID: 9, SingleDraftDate: 9, AdditionalMatchIDs: []int64{10}},
+			},
+		},
+		{
+			name: "no games after", matches: history, maxDepth: 1, gamesPerFatal: 0,
+			want: []FatalMatchInfo{
+				{FatalMatchID: 7, SingleDraftMatchID: 9, SingleDraftDate: 9},
+			},
+		},
+		{
+			name: "too few games after", matches: []Match{ranked(3), singleDraft(2), ranked(1)}, maxDepth: 1, gamesPerFatal: 5,
+			want: []FatalMatchInfo{
+				{FatalMatchID: 1, SingleDraftMatchID: 2, SingleDraftDate: 2, AdditionalMatchIDs: []int64{3}},
+			},
+		},
+		{
+			name: "no ranked match before the single draft", matches: []Match{ranked(3), singleDraft(2), unranked(1)}, maxDepth: 1, gamesPerFatal: 2,
+		},
+		{
+			name: "no single draft", matches: []Match{ranked(2), ranked(1)}, maxDepth: 1, gamesPerFatal: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindFatalMatches(tt.matches, tt.maxDepth, tt.gamesPerFatal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindFatalMatchesRejectsRankedSingleDraft(t *testing.T) {
+	sd := singleDraft(2)
+	sd.LobbyType = LobbyTypeRanked
+	if _, err := FindFatalMatches([]Match{sd, ranked(1)}, 1, 2); err == nil {
+		t.Fatal("ranked Single Draft accepted")
+	}
+}
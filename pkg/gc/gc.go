@@ -0,0 +1,127 @@
+// Package gc defines the Dota 2 Game Coordinator operations the server
+// needs. botclient implements them by calling the bot process over HTTP and
+// dota2gc implements them in-process.
+//
+// The package must not import go-dota2: its protobuf registrations clash
+// with manta's, so the server binary only links go-dota2 when built with
+// the embedded GC.
+package gc
+
+import "context"
+
+// ConnectionStatus is the state of the Steam and GC connection.
+type ConnectionStatus int
+
+const (
+	StatusDisconnected ConnectionStatus = iota
+	StatusConnecting
+	StatusNeedGuardCode
+	StatusConnected
+	StatusGCReady
+	StatusRateLimited
+)
+
+// Match is an entry of a player's match history.
+type Match struct {
+	ID        int64  `json:"id"`
+	GameMode  uint32 `json:"gameMode"`
+	LobbyType uint32 `json:"lobbyType"`
+	StartTime uint32 `json:"startTime"`
+}
+
+// MatchDetails is the GC's summary of a finished match, enough to label its
+// players without the replay.
+type MatchDetails struct {
+	MatchID   int64  `json:"matchId"`
+	StartTime uint32 `json:"startTime"`
+	Duration  uint32 `json:"duration"` // seconds
+	GameMode  uint32 `json:"gameMode"`
+	LobbyType uint32 `json:"lobbyType"`
+	// Winner is "radiant", "dire" or "" when the match had no result.
+	Winner  string        `json:"winner"`
+	Cluster uint32        `json:"cluster"`
+	Salt    uint64        `json:"salt"`
+	Players []MatchPlayer `json:"players"`
+}
+
+// MatchPlayer is one player of MatchDetails. Slot and Team use the replay
+// parser's numbering: slots 0-4 are Radiant (team 2), 5-9 Dire (team 3).
+type MatchPlayer struct {
+	AccountID    uint32 `json:"accountId"`
+	Slot         int    `json:"slot"`
+	Team         int32  `json:"team"`
+	HeroID       int    `json:"heroId"`
+	Name         string `json:"name"`
+	Kills        uint32 `json:"kills"`
+	Deaths       uint32 `json:"deaths"`
+	Assists      uint32 `json:"assists"`
+	LeaverStatus uint32 `json:"leaverStatus"`
+}
+
+// FatalMatchInfo is a Single Draft match found by FindFatalGames and the
+// ranked match before it.
+type FatalMatchInfo struct {
+	FatalMatchID       int64   `json:"fatalMatchId"`
+	SingleDraftMatchID int64   `json:"singleDraftMatchId"`
+	SingleDraftDate    uint32  `json:"singleDraftDate"`
+	AdditionalMatchIDs []int64 `json:"additionalMatchIds"`
+}
+
+// ConductScorecard is the player's latest conduct summary. The JSON names
+// match the GC's CMsgPlayerConductScorecard.
+type ConductScorecard struct {
+	AccountID           uint32 `json:"account_id"`
+	MatchID             uint64 `json:"match_id"`
+	SeqNum              uint32 `json:"seq_num"`
+	Reasons             uint32 `json:"reasons"`
+	MatchesInReport     uint32 `json:"matches_in_report"`
+	MatchesClean        uint32 `json:"matches_clean"`
+	MatchesReported     uint32 `json:"matches_reported"`
+	MatchesAbandoned    uint32 `json:"matches_abandoned"`
+	ReportsCount        uint32 `json:"reports_count"`
+	ReportsParties      uint32 `json:"reports_parties"`
+	CommendCount        uint32 `json:"commend_count"`
+	Date                uint32 `json:"date"`
+	RawBehaviorScore    uint32 `json:"raw_behavior_score"`
+	OldRawBehaviorScore uint32 `json:"old_raw_behavior_score"`
+	CommsReports        uint32 `json:"comms_reports"`
+	CommsParties        uint32 `json:"comms_parties"`
+	BehaviorRating      int32  `json:"behavior_rating"`
+}
+
+// Service is a logged-in (or logging-in) Steam account with a Dota 2 GC
+// session.
+type Service interface {
+	// Init logs in with the given credentials, replacing any session. pass
+	// may be empty when user has a saved session.
+	Init(user, pass string) error
+	// SubmitCode sends a Steam Guard code when the status is
+	// StatusNeedGuardCode.
+	SubmitCode(code string) error
+	// Disconnect logs out. It does nothing if there is no session.
+	Disconnect() error
+	// ForgetSession logs out and deletes the saved session of user, so the
+	// next login needs the password again.
+	ForgetSession(user string) error
+
+	GetStatus() ConnectionStatus
+	// GetStatusWithError also returns the last login error for display.
+	GetStatusWithError() (ConnectionStatus, string)
+	// Subscribe streams status changes, starting with the current status,
+	// until the returned function is called.
+	Subscribe() (<-chan StatusEvent, func())
+
+	// The requests below give up when ctx ends, with an error wrapping
+	// ErrTimeout.
+
+	// GetReplayInfo returns the replay cluster and salt of a match.
+	GetReplayInfo(ctx context.Context, matchID uint64) (cluster uint32, salt uint64, err error)
+	// GetMatchDetails returns the players, heroes and result of a match.
+	GetMatchDetails(ctx context.Context, matchID uint64) (*MatchDetails, error)
+	GetPlayerMatchHistory(ctx context.Context, steamID64 int64, limit int, turboOnly bool) ([]Match, error)
+	// GetPlayerMatchHistoryPaginated returns matches older than
+	// startAtMatchID (0 for the most recent).
+	GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]Match, error)
+	FindFatalGames(ctx context.Context, steamID64 int64, maxDepth int, gamesPerFatal int) ([]FatalMatchInfo, error)
+	GetPlayerConductScorecard(ctx context.Context) (*ConductScorecard, error)
+}
@@ -0,0 +1,79 @@
+//go:build ignore
+
+// gen.go reads heroes.csv and writes table.go, or the file named by -o.
+// Run via `go generate`.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strconv"
+)
+
+func main() {
+	out := flag.String("o", "table.go", "file to write the table to")
+	flag.Parse()
+
+	f, err := os.Open("heroes.csv")
+	if err != nil {
+		log.Fatalf("open heroes.csv: %v", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		log.Fatalf("read heroes.csv: %v", err)
+	}
+	if len(records) < 2 {
+		log.Fatalf("heroes.csv has no rows")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go from heroes.csv; DO NOT EDIT.\n\n")
+	buf.WriteString("package heroes\n\n")
+	buf.WriteString("var table = []Hero{\n")
+
+	seenIDs := make(map[int]bool)
+	seenNames := make(map[string]bool)
+	for i, rec := range records[1:] {
+		line := i + 2
+		if len(rec) != 5 {
+			log.Fatalf("heroes.csv row %d: expected 5 columns, got %d", line, len(rec))
+		}
+		id, err := strconv.Atoi(rec[0])
+		if err != nil || id <= 0 {
+			log.Fatalf("heroes.csv row %d: invalid id %q", line, rec[0])
+		}
+		hasIcon, err := strconv.ParseBool(rec[4])
+		if err != nil {
+			log.Fatalf("heroes.csv row %d: invalid has_icon %q", line, rec[4])
+		}
+		if seenIDs[id] {
+			log.Fatalf("heroes.csv row %d: duplicate id %d", line, id)
+		}
+		if seenNames[rec[1]] {
+			log.Fatalf("heroes.csv row %d: duplicate name %q", line, rec[1])
+		}
+		seenIDs[id] = true
+		seenNames[rec[1]] = true
+
+		fmt.Fprintf(&buf, "\t{ID: %d, Name: %q, ClassName: %q, LocalizedName: %q, HasIcon: %t},\n",
+			id, rec[1], rec[2], rec[3], hasIcon)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("format generated source: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("write %s: %v", *out, err)
+	}
+}
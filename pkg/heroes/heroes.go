@@ -0,0 +1,100 @@
+// Package heroes provides the Dota 2 hero identity table shared by the
+// replay parser, the server and the hero icon proxy.
+//
+// The table is generated from heroes.csv; edit the CSV and run
+// `go generate ./pkg/heroes` to update it.
+package heroes
+
+//go:generate go run gen.go
+
+import "strings"
+
+// ClassPrefix is the entity class prefix used for hero units in replays.
+const ClassPrefix = "CDOTA_Unit_Hero_"
+
+// NamePrefix is the prefix of internal hero names (npc_dota_hero_<name>).
+const NamePrefix = "npc_dota_hero_"
+
+// Hero identifies a single hero across the different naming schemes.
+type Hero struct {
+	// ID is the hero ID used by m_nSelectedHeroID and the GC.
+	ID int `json:"id"`
+	// Name is the internal name without the npc_dota_hero_ prefix (e.g. "zuus").
+	Name string `json:"name"`
+	// ClassName is the entity class suffix after CDOTA_Unit_Hero_ (e.g. "Zuus").
+	ClassName string `json:"className"`
+	// LocalizedName is the English display name (e.g. "Zeus").
+	LocalizedName string `json:"localizedName"`
+	// HasIcon reports whether the CDN serves a small _icon.png for the hero.
+	HasIcon bool `json:"hasIcon"`
+}
+
+var (
+	byID    = make(map[int]Hero)
+	byClass = make(map[string]Hero)
+	byKey   = make(map[string]Hero)
+)
+
+func init() {
+	for _, h := range table {
+		byID[h.ID] = h
+		byClass[h.ClassName] = h
+		for _, k := range []string{h.Name, h.ClassName, h.LocalizedName} {
+			key := normalize(k)
+			if _, exists := byKey[key]; !exists {
+				byKey[key] = h
+			}
+		}
+	}
+}
+
+// normalize lowercases s, strips known prefixes and drops everything that is
+// not a letter or digit, so "Queen of Pain", "queenofpain" and
+// "CDOTA_Unit_Hero_QueenOfPain" all map to the same key.
+func normalize(s string) string {
+	s = strings.TrimPrefix(s, ClassPrefix)
+	s = strings.ToLower(s)
+	s = strings.TrimPrefix(s, NamePrefix)
+	var b strings.Builder
+	for _, r := range s {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// All returns a copy of the hero table ordered by ID.
+func All() []Hero {
+	out := make([]Hero, len(table))
+	copy(out, table)
+	return out
+}
+
+// ByID looks up a hero by its numeric ID.
+func ByID(id int) (Hero, bool) {
+	h, ok := byID[id]
+	return h, ok
+}
+
+// ByClassName looks up a hero by entity class name, with or without the
+// CDOTA_Unit_Hero_ prefix.
+func ByClassName(className string) (Hero, bool) {
+	h, ok := byClass[strings.TrimPrefix(className, ClassPrefix)]
+	if !ok {
+		return ByName(className)
+	}
+	return h, ok
+}
+
+// ByName looks up a hero by any of its names: internal name (with or without
+// npc_dota_hero_), class name or localized name. Matching ignores case,
+// spaces, underscores and punctuation.
+func ByName(name string) (Hero, bool) {
+	key := normalize(name)
+	if key == "" {
+		return Hero{}, false
+	}
+	h, ok := byKey[key]
+	return h, ok
+}
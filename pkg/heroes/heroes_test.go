@@ -0,0 +1,83 @@
+package heroes
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestByName(t *testing.T) {
+	tests := []struct {
+		name string
+		id   int // 0 for no hero
+	}{
+		{"zuus", 22},
+		{"Zeus", 22},
+		{"ZEUS", 22},
+		{"npc_dota_hero_zuus", 22},
+		{"NPC_DOTA_HERO_ZUUS", 22},
+		{"Queen of Pain", 39},
+		{"queen_of_pain", 39},
+		{"ember_spirit", 106},
+		{"Shadow Fiend", 11},
+		{"nevermore", 11},
+		{"CDOTA_Unit_Hero_Invoker", 74},
+		{"not a hero", 0},
+		{"npc_dota_hero_", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		h, ok := ByName(tt.name)
+		if ok != (tt.id != 0) || h.ID != tt.id {
+			t.Errorf("ByName(%q) = %d, %v, want %d", tt.name, h.ID, ok, tt.id)
+		}
+	}
+}
+
+func TestByClassName(t *testing.T) {
+	tests := []struct {
+		className string
+		id        int // 0 for no hero
+	}{
+		{"CDOTA_Unit_Hero_QueenOfPain", 39},
+		{"QueenOfPain", 39},
+		{"queenofpain", 39},
+		{"CDOTA_Unit_Hero_Zuus", 22},
+		{"npc_dota_hero_zuus", 22},
+		{"CDOTA_Unit_Hero_Nobody", 0},
+		{"CDOTA_Unit_Hero_", 0},
+	}
+	for _, tt := range tests {
+		h, ok := ByClassName(tt.className)
+		if ok != (tt.id != 0) || h.ID != tt.id {
+			t.Errorf("ByClassName(%q) = %d, %v, want %d", tt.className, h.ID, ok, tt.id)
+		}
+	}
+}
+
+// TestTableIsGenerated checks that table.go is what gen.go makes of
+// heroes.csv.
+func TestTableIsGenerated(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	out := filepath.Join(t.TempDir(), "table.go")
+	cmd := exec.Command(goTool, "run", "gen.go", "-o", out)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run gen.go: %v\n%s", err, msg)
+	}
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("table.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("table.go is out of date with heroes.csv; run go generate ./pkg/heroes")
+	}
+}
@@ -0,0 +1,133 @@
+// Code generated by gen.go from heroes.csv; DO NOT EDIT.
+
+package heroes
+
+var table = []Hero{
+	{ID: 1, Name: "antimage", ClassName: "AntiMage", LocalizedName: "Anti-Mage", HasIcon: true},
+	{ID: 2, Name: "axe", ClassName: "Axe", LocalizedName: "Axe", HasIcon: true},
+	{ID: 3, Name: "bane", ClassName: "Bane", LocalizedName: "Bane", HasIcon: true},
+	{ID: 4, Name: "bloodseeker", ClassName: "Bloodseeker", LocalizedName: "Bloodseeker", HasIcon: true},
+	{ID: 5, Name: "crystal_maiden", ClassName: "CrystalMaiden", LocalizedName: "Crystal Maiden", HasIcon: true},
+	{ID: 6, Name: "drow_ranger", ClassName: "DrowRanger", LocalizedName: "Drow Ranger", HasIcon: true},
+	{ID: 7, Name: "earthshaker", ClassName: "Earthshaker", LocalizedName: "Earthshaker", HasIcon: true},
+	{ID: 8, Name: "juggernaut", ClassName: "Juggernaut", LocalizedName: "Juggernaut", HasIcon: true},
+	{ID: 9, Name: "mirana", ClassName: "Mirana", LocalizedName: "Mirana", HasIcon: true},
+	{ID: 10, Name: "morphling", ClassName: "Morphling", LocalizedName: "Morphling", HasIcon: true},
+	{ID: 11, Name: "nevermore", ClassName: "Nevermore", LocalizedName: "Shadow Fiend", HasIcon: true},
+	{ID: 12, Name: "phantom_lancer", ClassName: "PhantomLancer", LocalizedName: "Phantom Lancer", HasIcon: true},
+	{ID: 13, Name: "puck", ClassName: "Puck", LocalizedName: "Puck", HasIcon: true},
+	{ID: 14, Name: "pudge", ClassName: "Pudge", LocalizedName: "Pudge", HasIcon: true},
+	{ID: 15, Name: "razor", ClassName: "Razor", LocalizedName: "Razor", HasIcon: true},
+	{ID: 16, Name: "sand_king", ClassName: "SandKing", LocalizedName: "Sand King", HasIcon: true},
+	{ID: 17, Name: "storm_spirit", ClassName: "StormSpirit", LocalizedName: "Storm Spirit", HasIcon: true},
+	{ID: 18, Name: "sven", ClassName: "Sven", LocalizedName: "Sven", HasIcon: true},
+	{ID: 19, Name: "tiny", ClassName: "Tiny", LocalizedName: "Tiny", HasIcon: true},
+	{ID: 20, Name: "vengefulspirit", ClassName: "VengefulSpirit", LocalizedName: "Vengeful Spirit", HasIcon: true},
+	{ID: 21, Name: "windrunner", ClassName: "Windrunner", LocalizedName: "Windranger", HasIcon: true},
+	{ID: 22, Name: "zuus", ClassName: "Zuus", LocalizedName: "Zeus", HasIcon: true},
+	{ID: 23, Name: "kunkka", ClassName: "Kunkka", LocalizedName: "Kunkka", HasIcon: true},
+	{ID: 25, Name: "lina", ClassName: "Lina", LocalizedName: "Lina", HasIcon: true},
+	{ID: 26, Name: "lion", ClassName: "Lion", LocalizedName: "Lion", HasIcon: true},
+	{ID: 27, Name: "shadow_shaman", ClassName: "ShadowShaman", LocalizedName: "Shadow Shaman", HasIcon: true},
+	{ID: 28, Name: "slardar", ClassName: "Slardar", LocalizedName: "Slardar", HasIcon: true},
+	{ID: 29, Name: "tidehunter", ClassName: "Tidehunter", LocalizedName: "Tidehunter", HasIcon: true},
+	{ID: 30, Name: "witch_doctor", ClassName: "WitchDoctor", LocalizedName: "Witch Doctor", HasIcon: true},
+	{ID: 31, Name: "lich", ClassName: "Lich", LocalizedName: "Lich", HasIcon: true},
+	{ID: 32, Name: "riki", ClassName: "Riki", LocalizedName: "Riki", HasIcon: true},
+	{ID: 33, Name: "enigma", ClassName: "Enigma", LocalizedName: "Enigma", HasIcon: true},
+	{ID: 34, Name: "tinker", ClassName: "Tinker", LocalizedName: "Tinker", HasIcon: true},
+	{ID: 35, Name: "sniper", ClassName: "Sniper", LocalizedName: "Sniper", HasIcon: true},
+	{ID: 36, Name: "necrolyte", ClassName: "Necrolyte", LocalizedName: "Necrophos", HasIcon: true},
+	{ID: 37, Name: "warlock", ClassName: "Warlock", LocalizedName: "Warlock", HasIcon: true},
+	{ID: 38, Name: "beastmaster", ClassName: "Beastmaster", LocalizedName: "Beastmaster", HasIcon: true},
+	{ID: 39, Name: "queenofpain", ClassName: "QueenOfPain", LocalizedName: "Queen of Pain", HasIcon: true},
+	{ID: 40, Name: "venomancer", ClassName: "Venomancer", LocalizedName: "Venomancer", HasIcon: true},
+	{ID: 41, Name: "faceless_void", ClassName: "FacelessVoid", LocalizedName: "Faceless Void", HasIcon: true},
+	{ID: 42, Name: "skeleton_king", ClassName: "SkeletonKing", LocalizedName: "Wraith King", HasIcon: true},
+	{ID: 43, Name: "death_prophet", ClassName: "DeathProphet", LocalizedName: "Death Prophet", HasIcon: true},
+	{ID: 44, Name: "phantom_assassin", ClassName: "PhantomAssassin", LocalizedName: "Phantom Assassin", HasIcon: true},
+	{ID: 45, Name: "pugna", ClassName: "Pugna", LocalizedName: "Pugna", HasIcon: true},
+	{ID: 46, Name: "templar_assassin", ClassName: "TemplarAssassin", LocalizedName: "Templar Assassin", HasIcon: true},
+	{ID: 47, Name: "viper", ClassName: "Viper", LocalizedName: "Viper", HasIcon: true},
+	{ID: 48, Name: "luna", ClassName: "Luna", LocalizedName: "Luna", HasIcon: true},
+	{ID: 49, Name: "dragon_knight", ClassName: "DragonKnight", LocalizedName: "Dragon Knight", HasIcon: true},
+	{ID: 50, Name: "dazzle", ClassName: "Dazzle", LocalizedName: "Dazzle", HasIcon: true},
+	{ID: 51, Name: "rattletrap", ClassName: "Rattletrap", LocalizedName: "Clockwerk", HasIcon: true},
+	{ID: 52, Name: "leshrac", ClassName: "Leshrac", LocalizedName: "Leshrac", HasIcon: true},
+	{ID: 53, Name: "furion", ClassName: "Furion", LocalizedName: "Nature's Prophet", HasIcon: true},
+	{ID: 54, Name: "life_stealer", ClassName: "Life_Stealer", LocalizedName: "Lifestealer", HasIcon: true},
+	{ID: 55, Name: "dark_seer", ClassName: "DarkSeer", LocalizedName: "Dark Seer", HasIcon: true},
+	{ID: 56, Name: "clinkz", ClassName: "Clinkz", LocalizedName: "Clinkz", HasIcon: true},
+	{ID: 57, Name: "omniknight", ClassName: "Omniknight", LocalizedName: "Omniknight", HasIcon: true},
+	{ID: 58, Name: "enchantress", ClassName: "Enchantress", LocalizedName: "Enchantress", HasIcon: true},
+	{ID: 59, Name: "huskar", ClassName: "Huskar", LocalizedName: "Huskar", HasIcon: true},
+	{ID: 60, Name: "night_stalker", ClassName: "NightStalker", LocalizedName: "Night Stalker", HasIcon: true},
+	{ID: 61, Name: "broodmother", ClassName: "Broodmother", LocalizedName: "Broodmother", HasIcon: true},
+	{ID: 62, Name: "bounty_hunter", ClassName: "BountyHunter", LocalizedName: "Bounty Hunter", HasIcon: true},
+	{ID: 63, Name: "weaver", ClassName: "Weaver", LocalizedName: "Weaver", HasIcon: true},
+	{ID: 64, Name: "jakiro", ClassName: "Jakiro", LocalizedName: "Jakiro", HasIcon: true},
+	{ID: 65, Name: "batrider", ClassName: "Batrider", LocalizedName: "Batrider", HasIcon: true},
+	{ID: 66, Name: "chen", ClassName: "Chen", LocalizedName: "Chen", HasIcon: true},
+	{ID: 67, Name: "spectre", ClassName: "Spectre", LocalizedName: "Spectre", HasIcon: true},
+	{ID: 68, Name: "ancient_apparition", ClassName: "AncientApparition", LocalizedName: "Ancient Apparition", HasIcon: true},
+	{ID: 69, Name: "doom_bringer", ClassName: "DoomBringer", LocalizedName: "Doom", HasIcon: true},
+	{ID: 70, Name: "ursa", ClassName: "Ursa", LocalizedName: "Ursa", HasIcon: true},
+	{ID: 71, Name: "spirit_breaker", ClassName: "SpiritBreaker", LocalizedName: "Spirit Breaker", HasIcon: true},
+	{ID: 72, Name: "gyrocopter", ClassName: "Gyrocopter", LocalizedName: "Gyrocopter", HasIcon: true},
+	{ID: 73, Name: "alchemist", ClassName: "Alchemist", LocalizedName: "Alchemist", HasIcon: true},
+	{ID: 74, Name: "invoker", ClassName: "Invoker", LocalizedName: "Invoker", HasIcon: true},
+	{ID: 75, Name: "silencer", ClassName: "Silencer", LocalizedName: "Silencer", HasIcon: true},
+	{ID: 76, Name: "obsidian_destroyer", ClassName: "Obsidian_Destroyer", LocalizedName: "Outworld Destroyer", HasIcon: true},
+	{ID: 77, Name: "lycan", ClassName: "Lycan", LocalizedName: "Lycan", HasIcon: true},
+	{ID: 78, Name: "brewmaster", ClassName: "Brewmaster", LocalizedName: "Brewmaster", HasIcon: true},
+	{ID: 79, Name: "shadow_demon", ClassName: "Shadow_Demon", LocalizedName: "Shadow Demon", HasIcon: true},
+	{ID: 80, Name: "lone_druid", ClassName: "LoneDruid", LocalizedName: "Lone Druid", HasIcon: true},
+	{ID: 81, Name: "chaos_knight", ClassName: "ChaosKnight", LocalizedName: "Chaos Knight", HasIcon: true},
+	{ID: 82, Name: "meepo", ClassName: "Meepo", LocalizedName: "Meepo", HasIcon: true},
+	{ID: 83, Name: "treant", ClassName: "Treant", LocalizedName: "Treant Protector", HasIcon: true},
+	{ID: 84, Name: "ogre_magi", ClassName: "Ogre_Magi", LocalizedName: "Ogre Magi", HasIcon: true},
+	{ID: 85, Name: "undying", ClassName: "Undying", LocalizedName: "Undying", HasIcon: true},
+	{ID: 86, Name: "rubick", ClassName: "Rubick", LocalizedName: "Rubick", HasIcon: true},
+	{ID: 87, Name: "disruptor", ClassName: "Disruptor", LocalizedName: "Disruptor", HasIcon: true},
+	{ID: 88, Name: "nyx_assassin", ClassName: "Nyx_Assassin", LocalizedName: "Nyx Assassin", HasIcon: true},
+	{ID: 89, Name: "naga_siren", ClassName: "Naga_Siren", LocalizedName: "Naga Siren", HasIcon: true},
+	{ID: 90, Name: "keeper_of_the_light", ClassName: "KeeperOfTheLight", LocalizedName: "Keeper of the Light", HasIcon: true},
+	{ID: 91, Name: "wisp", ClassName: "Wisp", LocalizedName: "Io", HasIcon: true},
+	{ID: 92, Name: "visage", ClassName: "Visage", LocalizedName: "Visage", HasIcon: true},
+	{ID: 93, Name: "slark", ClassName: "Slark", LocalizedName: "Slark", HasIcon: true},
+	{ID: 94, Name: "medusa", ClassName: "Medusa", LocalizedName: "Medusa", HasIcon: true},
+	{ID: 95, Name: "troll_warlord", ClassName: "TrollWarlord", LocalizedName: "Troll Warlord", HasIcon: true},
+	{ID: 96, Name: "centaur", ClassName: "Centaur", LocalizedName: "Centaur Warrunner", HasIcon: true},
+	{ID: 97, Name: "magnataur", ClassName: "Magnataur", LocalizedName: "Magnus", HasIcon: true},
+	{ID: 98, Name: "shredder", ClassName: "Shredder", LocalizedName: "Timbersaw", HasIcon: true},
+	{ID: 99, Name: "bristleback", ClassName: "Bristleback", LocalizedName: "Bristleback", HasIcon: true},
+	{ID: 100, Name: "tusk", ClassName: "Tusk", LocalizedName: "Tusk", HasIcon: true},
+	{ID: 101, Name: "skywrath_mage", ClassName: "Skywrath_Mage", LocalizedName: "Skywrath Mage", HasIcon: true},
+	{ID: 102, Name: "abaddon", ClassName: "Abaddon", LocalizedName: "Abaddon", HasIcon: true},
+	{ID: 103, Name: "elder_titan", ClassName: "Elder_Titan", LocalizedName: "Elder Titan", HasIcon: true},
+	{ID: 104, Name: "legion_commander", ClassName: "Legion_Commander", LocalizedName: "Legion Commander", HasIcon: true},
+	{ID: 105, Name: "techies", ClassName: "Techies", LocalizedName: "Techies", HasIcon: true},
+	{ID: 106, Name: "ember_spirit", ClassName: "EmberSpirit", LocalizedName: "Ember Spirit", HasIcon: true},
+	{ID: 107, Name: "earth_spirit", ClassName: "EarthSpirit", LocalizedName: "Earth Spirit", HasIcon: true},
+	{ID: 108, Name: "abyssal_underlord", ClassName: "AbyssalUnderlord", LocalizedName: "Underlord", HasIcon: true},
+	{ID: 109, Name: "terrorblade", ClassName: "Terrorblade", LocalizedName: "Terrorblade", HasIcon: true},
+	{ID: 110, Name: "phoenix", ClassName: "Phoenix", LocalizedName: "Phoenix", HasIcon: true},
+	{ID: 111, Name: "oracle", ClassName: "Oracle", LocalizedName: "Oracle", HasIcon: true},
+	{ID: 112, Name: "winter_wyvern", ClassName: "Winter_Wyvern", LocalizedName: "Winter Wyvern", HasIcon: true},
+	{ID: 113, Name: "arc_warden", ClassName: "ArcWarden", LocalizedName: "Arc Warden", HasIcon: true},
+	{ID: 114, Name: "monkey_king", ClassName: "MonkeyKing", LocalizedName: "Monkey King", HasIcon: true},
+	{ID: 119, Name: "dark_willow", ClassName: "DarkWillow", LocalizedName: "Dark Willow", HasIcon: true},
+	{ID: 120, Name: "pangolier", ClassName: "Pangolier", LocalizedName: "Pangolier", HasIcon: true},
+	{ID: 121, Name: "grimstroke", ClassName: "Grimstroke", LocalizedName: "Grimstroke", HasIcon: true},
+	{ID: 123, Name: "hoodwink", ClassName: "Hoodwink", LocalizedName: "Hoodwink", HasIcon: true},
+	{ID: 126, Name: "void_spirit", ClassName: "Void_Spirit", LocalizedName: "Void Spirit", HasIcon: true},
+	{ID: 128, Name: "snapfire", ClassName: "Snapfire", LocalizedName: "Snapfire", HasIcon: true},
+	{ID: 129, Name: "mars", ClassName: "Mars", LocalizedName: "Mars", HasIcon: true},
+	{ID: 131, Name: "ringmaster", ClassName: "Ringmaster", LocalizedName: "Ringmaster", HasIcon: false},
+	{ID: 135, Name: "dawnbreaker", ClassName: "Dawnbreaker", LocalizedName: "Dawnbreaker", HasIcon: true},
+	{ID: 136, Name: "marci", ClassName: "Marci", LocalizedName: "Marci", HasIcon: false},
+	{ID: 137, Name: "primal_beast", ClassName: "PrimalBeast", LocalizedName: "Primal Beast", HasIcon: false},
+	{ID: 138, Name: "muerta", ClassName: "Muerta", LocalizedName: "Muerta", HasIcon: false},
+	{ID: 145, Name: "kez", ClassName: "Kez", LocalizedName: "Kez", HasIcon: false},
+	{ID: 155, Name: "largo", ClassName: "Largo", LocalizedName: "Largo", HasIcon: false},
+}
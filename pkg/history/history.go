@@ -0,0 +1,336 @@
+// Package history keeps the GC match history of each player on disk, so
+// searches over it run against local data instead of re-crawling the GC.
+//
+// A sync only fetches the matches newer than the newest stored one; older
+// pages are backfilled in the background until the GC has no more.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+// pageSize is the most matches the GC returns per history request.
+const pageSize = 20
+
+// Status describes the stored history of a player.
+type Status struct {
+	SteamID64   int64     `json:"steamId64"`
+	Matches     int       `json:"matches"`
+	NewestMatch int64     `json:"newestMatch,omitempty"`
+	OldestMatch int64     `json:"oldestMatch,omitempty"`
+	Complete    bool      `json:"complete"`
+	Backfilling bool      `json:"backfilling"`
+	LastSync    time.Time `json:"lastSync"`
+}
+
+// player is the stored history of one player, newest match first.
+type player struct {
+	// mu serialises GC fetches for the player, so syncs and the backfill do
+	// not request the same pages twice. The fields are guarded by Store.mu.
+	mu sync.Mutex
+
+	SteamID64 int64      `json:"steamId64"`
+	Matches   []gc.Match `json:"matches"`
+	// Complete means the backfill reached the oldest match the GC returns.
+	Complete bool      `json:"complete"`
+	LastSync time.Time `json:"lastSync"`
+
+	backfilling bool
+}
+
+// Store is the match history of every player synced so far.
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	players map[int64]*player
+}
+
+// DefaultDir returns the history location under the user's config directory
+// (~/.dota-report-timestamps/history).
+func DefaultDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "history")
+}
+
+// NewStore returns a store that keeps one <steamID64>.json file per player
+// in dir, loaded on first use.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, players: make(map[int64]*player)}
+}
+
+// get returns the history of a player, loading it from disk on first use.
+func (s *Store) get(steamID64 int64) *player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.players[steamID64]; ok {
+		return p
+	}
+	p := &player{SteamID64: steamID64}
+	data, err := os.ReadFile(s.path(steamID64))
+	if err == nil {
+		if err := json.Unmarshal(data, p); err != nil {
+			log.Printf("Ignoring corrupt match history of %d: %v", steamID64, err)
+			p = &player{SteamID64: steamID64}
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Failed to read match history of %d: %v", steamID64, err)
+	}
+	s.players[steamID64] = p
+	return p
+}
+
+func (s *Store) path(steamID64 int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", steamID64))
+}
+
+// saveLocked writes the history of p atomically. s.mu must be held.
+func (s *Store) saveLocked(p *player) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("Failed to encode match history: %v", err)
+		return
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		log.Printf("Failed to create match history directory: %v", err)
+		return
+	}
+	path := s.path(p.SteamID64)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write match history: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Failed to replace match history: %v", err)
+	}
+}
+
+// save writes the history of p.
+func (s *Store) save(p *player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveLocked(p)
+}
+
+// merge adds matches to the history of p and returns how many were new. The
+// caller saves p once it is done fetching.
+func (s *Store) merge(p *player, matches []gc.Match, complete bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	known := make(map[int64]bool, len(p.Matches))
+	for _, m := range p.Matches {
+		known[m.ID] = true
+	}
+	added := 0
+	for _, m := range matches {
+		if !known[m.ID] {
+			known[m.ID] = true
+			p.Matches = append(p.Matches, m)
+			added++
+		}
+	}
+	sort.Slice(p.Matches, func(a, b int) bool { return p.Matches[a].ID > p.Matches[b].ID })
+	p.Complete = p.Complete || complete
+	p.LastSync = time.Now()
+	return added
+}
+
+// bounds returns the newest and oldest stored match IDs, or zeros.
+func (s *Store) bounds(p *player) (newest, oldest int64, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(p.Matches) == 0 {
+		return 0, 0, p.Complete
+	}
+	return p.Matches[0].ID, p.Matches[len(p.Matches)-1].ID, p.Complete
+}
+
+// Matches returns the stored history of a player, newest first.
+func (s *Store) Matches(steamID64 int64) []gc.Match {
+	p := s.get(steamID64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]gc.Match(nil), p.Matches...)
+}
+
+// Status describes the stored history of a player.
+func (s *Store) Status(steamID64 int64) Status {
+	p := s.get(steamID64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{
+		SteamID64:   steamID64,
+		Matches:     len(p.Matches),
+		Complete:    p.Complete,
+		Backfilling: p.backfilling,
+		LastSync:    p.LastSync,
+	}
+	if len(p.Matches) > 0 {
+		st.NewestMatch = p.Matches[0].ID
+		st.OldestMatch = p.Matches[len(p.Matches)-1].ID
+	}
+	return st
+}
+
+// Sync fetches the matches played since the newest stored one and returns
+// how many were added. A player seen for the first time gets only the
+// latest page; the older ones are backfilled in the background.
+func (s *Store) Sync(ctx context.Context, steamID64 int64, svc gc.Service) (int, error) {
+	p := s.get(steamID64)
+	p.mu.Lock()
+	added, err := s.syncNewer(ctx, p, svc)
+	p.mu.Unlock()
+	if err != nil {
+		return added, err
+	}
+	s.startBackfill(p, svc)
+	return added, nil
+}
+
+// syncNewer fetches pages from the newest match until one overlaps the
+// stored history, and stores them all at once. Pages fetched before a
+// failure are dropped, since storing them would leave a hole between them
+// and the stored history that no later sync fills. p.mu must be held.
+func (s *Store) syncNewer(ctx context.Context, p *player, svc gc.Service) (int, error) {
+	newest, _, _ := s.bounds(p)
+	var fetched []gc.Match
+	var startAt uint64
+	complete := false
+	for {
+		page, err := svc.GetPlayerMatchHistoryPaginated(ctx, p.SteamID64, pageSize, false, startAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to sync match history: %w", err)
+		}
+		fetched = append(fetched, page...)
+		overlap := false
+		for _, m := range page {
+			if m.ID <= newest {
+				overlap = true
+			}
+		}
+		// A short page from the start of the history means there is
+		// nothing older left to backfill.
+		end := len(page) < pageSize
+		if overlap || end || newest == 0 {
+			complete = end && (newest == 0 || !overlap)
+			break
+		}
+		startAt = uint64(page[len(page)-1].ID)
+	}
+	added := s.merge(p, fetched, complete)
+	s.save(p)
+	if added > 0 {
+		log.Printf("Synced %d new matches of %d", added, p.SteamID64)
+	}
+	return added, nil
+}
+
+// backfillPage fetches the page before the oldest stored match. It reports
+// whether the history is complete. p.mu must be held.
+func (s *Store) backfillPage(ctx context.Context, p *player, svc gc.Service) (bool, error) {
+	_, oldest, complete := s.bounds(p)
+	if complete {
+		return true, nil
+	}
+	page, err := svc.GetPlayerMatchHistoryPaginated(ctx, p.SteamID64, pageSize, false, uint64(oldest))
+	if err != nil {
+		return false, fmt.Errorf("failed to backfill match history: %w", err)
+	}
+	older := 0
+	for _, m := range page {
+		if m.ID < oldest || oldest == 0 {
+			older++
+		}
+	}
+	// The GC may include the match it started at; a page without older
+	// matches is the end of the history.
+	end := older == 0 || len(page) < pageSize
+	s.merge(p, page, end)
+	return end, nil
+}
+
+// startBackfill backfills the history of p in the background at
+// gc.PriorityBackground, unless it is complete or already running.
+func (s *Store) startBackfill(p *player, svc gc.Service) {
+	s.mu.Lock()
+	if p.Complete || p.backfilling {
+		s.mu.Unlock()
+		return
+	}
+	p.backfilling = true
+	s.mu.Unlock()
+
+	bg := gc.Background(svc)
+	go func() {
+		pages := 0
+		defer func() {
+			s.mu.Lock()
+			p.backfilling = false
+			if pages > 0 {
+				s.saveLocked(p)
+			}
+			s.mu.Unlock()
+		}()
+		for {
+			p.mu.Lock()
+			done, err := s.backfillPage(context.Background(), p, bg)
+			p.mu.Unlock()
+			if err != nil {
+				// The next sync starts it again.
+				log.Printf("Stopped backfilling match history of %d: %v", p.SteamID64, err)
+				return
+			}
+			pages++
+			if done {
+				log.Printf("Backfilled match history of %d (%d pages)", p.SteamID64, pages)
+				return
+			}
+		}
+	}()
+}
+
+// ErrIncomplete is returned by Ensure when the page limit was reached before
+// the history was deep enough.
+var ErrIncomplete = errors.New("match history not fully synced yet")
+
+// Ensure backfills the history of a player in the foreground until enough
+// accepts it, the history is complete or maxPages pages were fetched. It is
+// for requests that cannot wait for the background backfill.
+func (s *Store) Ensure(ctx context.Context, steamID64 int64, svc gc.Service, maxPages int, enough func([]gc.Match) bool) error {
+	p := s.get(steamID64)
+	pages := 0
+	defer func() {
+		if pages > 0 {
+			s.save(p)
+		}
+	}()
+	for {
+		if enough(s.Matches(steamID64)) {
+			return nil
+		}
+		if pages >= maxPages {
+			return ErrIncomplete
+		}
+		p.mu.Lock()
+		done, err := s.backfillPage(ctx, p, svc)
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		pages++
+		if done {
+			return nil
+		}
+	}
+}
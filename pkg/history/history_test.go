@@ -0,0 +1,205 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+)
+
+const testPlayer = 76561198000000001
+
+// fakeGC serves a match history with the IDs newest down to 1, newest
+// first.
+type fakeGC struct {
+	gc.Service
+	mu     sync.Mutex
+	newest int64
+	// inclusive pages start at the match they were asked to start at.
+	inclusive bool
+	// fail holds the errors of calls by number, counting from 1.
+	fail  map[int]error
+	calls int
+}
+
+func (f *fakeGC) GetPlayerMatchHistoryPaginated(ctx context.Context, steamID64 int64, limit int, turboOnly bool, startAtMatchID uint64) ([]gc.Match, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if err := f.fail[f.calls]; err != nil {
+		return nil, err
+	}
+	id := f.newest
+	if startAtMatchID != 0 {
+		id = int64(startAtMatchID)
+		if !f.inclusive {
+			id--
+		}
+	}
+	var page []gc.Match
+	for ; id > 0 && len(page) < limit; id-- {
+		page = append(page, gc.Match{ID: id})
+	}
+	return page, nil
+}
+
+// seed stores the matches newest down to oldest for testPlayer.
+func seed(s *Store, newest, oldest int64, complete bool) {
+	var ms []gc.Match
+	for id := newest; id >= oldest; id-- {
+		ms = append(ms, gc.Match{ID: id})
+	}
+	s.merge(s.get(testPlayer), ms, complete)
+}
+
+// span returns the newest and oldest stored IDs and whether they have no
+// gaps.
+func span(ms []gc.Match) (newest, oldest int64, contiguous bool) {
+	if len(ms) == 0 {
+		return 0, 0, true
+	}
+	newest, oldest = ms[0].ID, ms[len(ms)-1].ID
+	return newest, oldest, newest-oldest+1 == int64(len(ms))
+}
+
+func TestSyncNewer(t *testing.T) {
+	tests := []struct {
+		name         string
+		stored       [2]int64 // newest and oldest, zeros for none
+		gcNewest     int64
+		added, calls int
+		wantComplete bool
+		wantSpan     [2]int64
+	}{
+		{"first sync of a short history", [2]int64{}, 5, 5, 1, true, [2]int64{5, 1}},
+		{"first sync fetches one page", [2]int64{}, 50, 20, 1, false, [2]int64{50, 31}},
+		{"overlap on the first page", [2]int64{60, 41}, 65, 5, 1, false, [2]int64{65, 41}},
+		{"overlap after three pages", [2]int64{60, 41}, 100, 40, 3, false, [2]int64{100, 41}},
+		{"nothing new", [2]int64{60, 41}, 60, 0, 1, false, [2]int64{60, 41}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(t.TempDir())
+			if tt.stored[0] != 0 {
+				seed(s, tt.stored[0], tt.stored[1], false)
+			}
+			f := &fakeGC{newest: tt.gcNewest}
+			p := s.get(testPlayer)
+			p.mu.Lock()
+			added, err := s.syncNewer(context.Background(), p, f)
+			p.mu.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != tt.added || f.calls != tt.calls {
+				t.Errorf("added %d in %d calls, want %d in %d", added, f.calls, tt.added, tt.calls)
+			}
+			if st := s.Status(testPlayer); st.Complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", st.Complete, tt.wantComplete)
+			}
+			newest, oldest, contiguous := span(s.Matches(testPlayer))
+			if [2]int64{newest, oldest} != tt.wantSpan || !contiguous {
+				t.Errorf("stored %d..%d (contiguous %v), want %d..%d", newest, oldest, contiguous, tt.wantSpan[0], tt.wantSpan[1])
+			}
+		})
+	}
+}
+
+func TestSyncFailureLeavesNoHole(t *testing.T) {
+	s := NewStore(t.TempDir())
+	seed(s, 30, 1, true)
+	f := &fakeGC{newest: 75, fail: map[int]error{2: gc.ErrTimeout}}
+
+	added, err := s.Sync(context.Background(), testPlayer, f)
+	if !errors.Is(err, gc.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	if added != 0 {
+		t.Fatalf("added %d, want 0", added)
+	}
+	if newest, _, _ := span(s.Matches(testPlayer)); newest != 30 {
+		t.Fatalf("newest stored match %d after a failed sync, want 30", newest)
+	}
+
+	if _, err := s.Sync(context.Background(), testPlayer, f); err != nil {
+		t.Fatal(err)
+	}
+	if newest, oldest, contiguous := span(s.Matches(testPlayer)); newest != 75 || oldest != 1 || !contiguous {
+		t.Fatalf("stored %d..%d (contiguous %v), want 75..1 without gaps", newest, oldest, contiguous)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	never := func([]gc.Match) bool { return false }
+	tests := []struct {
+		name         string
+		inclusive    bool
+		fail         map[int]error
+		maxPages     int
+		enough       func([]gc.Match) bool
+		want         error
+		oldest       int64
+		wantComplete bool
+	}{
+		// A short page is the end of the history.
+		{"short last page", false, nil, 10, never, nil, 1, true},
+		// So is a page with nothing older than the match it started at.
+		{"page without older matches", true, nil, 10, never, nil, 1, true},
+		{"page limit", false, nil, 2, never, ErrIncomplete, 41, false},
+		{"enough", false, nil, 10, func(ms []gc.Match) bool { return len(ms) >= 40 }, nil, 61, false},
+		{"failure keeps the pages before it", false, map[int]error{2: gc.ErrNotReady}, 10, never, gc.ErrNotReady, 61, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewStore(dir)
+			seed(s, 100, 81, false)
+			f := &fakeGC{newest: 100, inclusive: tt.inclusive, fail: tt.fail}
+
+			err := s.Ensure(context.Background(), testPlayer, f, tt.maxPages, tt.enough)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			st := s.Status(testPlayer)
+			if st.OldestMatch != tt.oldest || st.Complete != tt.wantComplete {
+				t.Fatalf("oldest %d complete %v, want %d %v", st.OldestMatch, st.Complete, tt.oldest, tt.wantComplete)
+			}
+			if _, _, contiguous := span(s.Matches(testPlayer)); !contiguous {
+				t.Fatal("stored history has gaps")
+			}
+
+			// The fetched pages were saved.
+			if got := NewStore(dir).Status(testPlayer); got.OldestMatch != st.OldestMatch || got.Complete != st.Complete {
+				t.Fatalf("saved oldest %d complete %v, want %d %v", got.OldestMatch, got.Complete, st.OldestMatch, st.Complete)
+			}
+		})
+	}
+}
+
+func TestSyncBackfillsInBackground(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	f := &fakeGC{newest: 50}
+	if added, err := s.Sync(context.Background(), testPlayer, f); err != nil || added != 20 {
+		t.Fatalf("Sync = %d, %v, want 20 new matches", added, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Status(testPlayer).Backfilling {
+		if time.Now().After(deadline) {
+			t.Fatal("backfill did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	want := Status{SteamID64: testPlayer, Matches: 50, NewestMatch: 50, OldestMatch: 1, Complete: true}
+	for name, st := range map[string]Status{"stored": s.Status(testPlayer), "saved": NewStore(dir).Status(testPlayer)} {
+		st.LastSync = time.Time{}
+		if !reflect.DeepEqual(st, want) {
+			t.Errorf("%s status %+v, want %+v", name, st, want)
+		}
+	}
+}
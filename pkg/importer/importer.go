@@ -0,0 +1,427 @@
+// Package importer adds replays that were not downloaded by the tool, such
+// as those in the Dota client's replay folder or shared by friends, to a
+// profile's replay directory.
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/parser"
+)
+
+// Mode is how a replay is placed in the profile.
+type Mode string
+
+const (
+	// ModeLink hardlinks the file, copying it when the source is on another
+	// file system.
+	ModeLink Mode = "link"
+	// ModeCopy always copies the file.
+	ModeCopy Mode = "copy"
+)
+
+// Actions reported in Result.
+const (
+	ActionLinked    = "linked"
+	ActionCopied    = "copied"
+	ActionDuplicate = "duplicate" // same content already in the profile
+	ActionConflict  = "conflict"  // a different file for the match is already there
+	ActionFailed    = "failed"
+)
+
+// defaultGamesPerFatal and maxGamesPerFatal bound GamesPerFatal the same
+// way the server bounds it for fatal downloads.
+const (
+	defaultGamesPerFatal = 2
+	maxGamesPerFatal     = 15
+)
+
+// Options describes an import.
+type Options struct {
+	SourceDir string
+	// ProfileDir is the profile's replay directory. Replays are placed in it
+	// as <matchID>.dem, or under ProfileDir/fatal when Fatal is set.
+	ProfileDir string
+	// Fatal files each Single Draft replay in fatal/<date>, dated by its
+	// start time, together with the ranked replay played just before it and
+	// the GamesPerFatal ranked replays played after it, as a fatal download
+	// would.
+	Fatal bool
+	// GamesPerFatal defaults to 2 and is capped at 15.
+	GamesPerFatal int
+	Mode          Mode
+	Recursive     bool
+	// MatchDetails, if set, returns what is known about a match from the GC.
+	// Its start time is preferred to the one estimated from the replay, and
+	// its lobby type tells ranked matches apart; without it every replay
+	// that is not Single Draft counts as ranked.
+	MatchDetails func(matchID int64) (gc.MatchDetails, bool)
+}
+
+// Result is the outcome for one source file.
+type Result struct {
+	Source  string `json:"source"`
+	MatchID int64  `json:"matchId,omitempty"`
+	Dest    string `json:"dest,omitempty"`
+	Action  string `json:"action"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Summary is the outcome of an import.
+type Summary struct {
+	Imported   int      `json:"imported"`
+	Duplicates int      `json:"duplicates"`
+	Conflicts  int      `json:"conflicts"`
+	Failed     int      `json:"failed"`
+	Results    []Result `json:"results"`
+}
+
+// replay is a source file whose footer could be read.
+type replay struct {
+	path string
+	info parser.ReplayInfo
+}
+
+// Import scans opts.SourceDir for .dem files, identifies each match from
+// the replay's CDemoFileInfo rather than its file name, and adds the ones
+// whose content is not already in the profile.
+func Import(opts Options) (Summary, error) {
+	var summary Summary
+	if opts.SourceDir == "" || opts.ProfileDir == "" {
+		return summary, fmt.Errorf("source and profile directories are required")
+	}
+	if opts.Mode == "" {
+		opts.Mode = ModeLink
+	}
+	if opts.Mode != ModeLink && opts.Mode != ModeCopy {
+		return summary, fmt.Errorf("unknown import mode %q", opts.Mode)
+	}
+	src, err := filepath.Abs(opts.SourceDir)
+	if err != nil {
+		return summary, err
+	}
+	dest, err := filepath.Abs(opts.ProfileDir)
+	if err != nil {
+		return summary, err
+	}
+	if st, err := os.Stat(src); err != nil {
+		return summary, fmt.Errorf("failed to open source directory: %w", err)
+	} else if !st.IsDir() {
+		return summary, fmt.Errorf("%s is not a directory", src)
+	}
+
+	files, err := scan(src, dest, opts.Recursive)
+	if err != nil {
+		return summary, err
+	}
+
+	var replays []replay
+	for _, path := range files {
+		info, err := readInfo(path)
+		if err != nil {
+			summary.add(Result{Source: path, Action: ActionFailed, Error: err.Error()})
+			continue
+		}
+		replays = append(replays, replay{path: path, info: info})
+	}
+
+	dirs := make(map[string]string)
+	if opts.Fatal {
+		dirs = fatalDirs(replays, dest, opts.GamesPerFatal, opts.MatchDetails)
+	}
+	existing := newHashIndex(dest)
+	for _, r := range replays {
+		dir, ok := dirs[r.path]
+		if !ok {
+			dir = dest
+		}
+		summary.add(importOne(r, dir, opts.Mode, existing))
+	}
+	log.Printf("Imported %d replay(s) from %s into %s (%d duplicate, %d conflict, %d failed)",
+		summary.Imported, src, dest, summary.Duplicates, summary.Conflicts, summary.Failed)
+	return summary, nil
+}
+
+func (s *Summary) add(r Result) {
+	switch r.Action {
+	case ActionLinked, ActionCopied:
+		s.Imported++
+	case ActionDuplicate:
+		s.Duplicates++
+	case ActionConflict:
+		s.Conflicts++
+	default:
+		s.Failed++
+	}
+	s.Results = append(s.Results, r)
+}
+
+// scan lists the .dem files in dir. When recursing it stays out of dest so
+// replays already in the profile are not offered again.
+func scan(dir, dest string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if !recursive || path == dest {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".dem") && d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func readInfo(path string) (parser.ReplayInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return parser.ReplayInfo{}, err
+	}
+	defer f.Close()
+	info, err := parser.GetReplayInfo(f)
+	if err != nil {
+		return info, fmt.Errorf("not a readable replay: %w", err)
+	}
+	if info.MatchID == 0 {
+		return info, fmt.Errorf("replay has no match ID")
+	}
+	return info, nil
+}
+
+// fatalDirs picks the folder of every replay in a fatal import, following
+// gc.FindFatalMatches: a Single Draft replay goes to fatal/<date of its
+// start>, along with the nearest ranked replay played before it (the fatal
+// match) and the next gamesPerFatal ranked replays played after it. A
+// ranked replay that is both goes with the Single Draft it is fatal for.
+// Anything else goes to fatal/.
+func fatalDirs(replays []replay, profileDir string, gamesPerFatal int, details func(int64) (gc.MatchDetails, bool)) map[string]string {
+	if gamesPerFatal < 1 {
+		gamesPerFatal = defaultGamesPerFatal
+	}
+	if gamesPerFatal > maxGamesPerFatal {
+		gamesPerFatal = maxGamesPerFatal
+	}
+	fatalDir := filepath.Join(profileDir, "fatal")
+
+	type game struct {
+		replay
+		start  time.Time
+		sd     bool
+		ranked bool
+	}
+	games := make([]game, 0, len(replays))
+	for _, r := range replays {
+		g := game{replay: r, start: r.info.StartTime, sd: r.info.GameMode == gc.GameModeSingleDraft}
+		g.ranked = !g.sd
+		if details != nil {
+			if d, ok := details(r.info.MatchID); ok {
+				if d.StartTime > 0 {
+					g.start = time.Unix(int64(d.StartTime), 0)
+				}
+				if d.LobbyType != 0 || d.GameMode != 0 {
+					g.ranked = d.LobbyType == gc.LobbyTypeRanked
+				}
+			}
+		}
+		if g.start.IsZero() {
+			g.start = r.info.EndTime
+		}
+		games = append(games, g)
+	}
+	// Oldest first; replays with no time at all cannot be placed.
+	sort.SliceStable(games, func(a, b int) bool { return games[a].start.Before(games[b].start) })
+
+	dirs := make(map[string]string, len(games))
+	for _, g := range games {
+		dirs[g.path] = fatalDir
+	}
+	fatal := make(map[string]bool)
+	for i, g := range games {
+		if !g.sd || g.start.IsZero() {
+			continue
+		}
+		dir := filepath.Join(fatalDir, g.start.Format("2006-01-02"))
+		dirs[g.path] = dir
+		for j := i - 1; j >= 0; j-- {
+			if games[j].ranked && !games[j].start.IsZero() {
+				dirs[games[j].path] = dir
+				fatal[games[j].path] = true
+				break
+			}
+		}
+		n := 0
+		for j := i + 1; j < len(games) && n < gamesPerFatal; j++ {
+			if !games[j].ranked {
+				continue
+			}
+			n++
+			if !fatal[games[j].path] {
+				dirs[games[j].path] = dir
+			}
+		}
+	}
+	return dirs
+}
+
+func importOne(r replay, dir string, mode Mode, existing *hashIndex) Result {
+	res := Result{Source: r.path, MatchID: r.info.MatchID}
+	fail := func(err error) Result {
+		res.Action = ActionFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	hash, err := hashFile(r.path)
+	if err != nil {
+		return fail(err)
+	}
+	if dup, ok := existing.find(r.path, hash); ok {
+		res.Action = ActionDuplicate
+		res.Dest = dup
+		return res
+	}
+
+	res.Dest = filepath.Join(dir, fmt.Sprintf("%d.dem", r.info.MatchID))
+	if _, err := os.Stat(res.Dest); err == nil {
+		res.Action = ActionConflict
+		res.Error = "a different replay for this match is already in the profile"
+		return res
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fail(fmt.Errorf("failed to create %s: %w", dir, err))
+	}
+
+	res.Action = ActionCopied
+	if mode == ModeLink {
+		if err := os.Link(r.path, res.Dest); err == nil {
+			res.Action = ActionLinked
+		} else {
+			log.Printf("Cannot hardlink %s, copying instead: %v", r.path, err)
+		}
+	}
+	if res.Action == ActionCopied {
+		if err := copyFile(r.path, res.Dest); err != nil {
+			return fail(err)
+		}
+	}
+	existing.add(res.Dest, hash)
+	return res
+}
+
+// copyFile copies src to dst through a temporary file so an interrupted
+// copy never leaves a truncated replay behind.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if st, err := os.Stat(src); err == nil {
+		os.Chtimes(dst, st.ModTime(), st.ModTime())
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashIndex finds replays already in the profile by content. Files are
+// only hashed when a source file of the same size turns up.
+type hashIndex struct {
+	bySize map[int64][]string
+	hashes map[string]string // path -> hash
+}
+
+func newHashIndex(dir string) *hashIndex {
+	idx := &hashIndex{bySize: make(map[int64][]string), hashes: make(map[string]string)}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".dem") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			idx.bySize[info.Size()] = append(idx.bySize[info.Size()], path)
+		}
+		return nil
+	})
+	return idx
+}
+
+// find returns a file in the profile with the same content as src.
+func (idx *hashIndex) find(src, hash string) (string, bool) {
+	st, err := os.Stat(src)
+	if err != nil {
+		return "", false
+	}
+	for _, path := range idx.bySize[st.Size()] {
+		h, ok := idx.hashes[path]
+		if !ok {
+			if h, err = hashFile(path); err != nil {
+				continue
+			}
+			idx.hashes[path] = h
+		}
+		if h == hash {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (idx *hashIndex) add(path, hash string) {
+	if st, err := os.Stat(path); err == nil {
+		idx.bySize[st.Size()] = append(idx.bySize[st.Size()], path)
+		idx.hashes[path] = hash
+	}
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/gc"
+	"github.com/d3nd3/dota-report-timestamps/pkg/parser"
+)
+
+const gameModeAllPick = 22
+
+// game makes a replay of matchID that started at start and lasted 40
+// minutes.
+func game(matchID int64, mode int32, start time.Time) replay {
+	return replay{
+		path: filepath.Join("src", fmt.Sprintf("%d.dem", matchID)),
+		info: parser.ReplayInfo{MatchID: matchID, GameMode: mode, StartTime: start, EndTime: start.Add(40 * time.Minute)},
+	}
+}
+
+func TestFatalDirs(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	// Oldest first: a ranked game, the fatal ranked game, the Single Draft
+	// late in the evening, then ranked games after midnight.
+	replays := []replay{
+		game(1, gameModeAllPick, at(18)),
+		game(2, gameModeAllPick, at(21)),
+		game(3, gc.GameModeSingleDraft, at(23)),
+		game(4, gameModeAllPick, at(25)),
+		game(5, gameModeAllPick, at(26)),
+		game(6, gameModeAllPick, at(27)),
+	}
+	// The Single Draft ends after midnight; its start time dates the folder.
+	replays[2].info.EndTime = at(24).Add(30 * time.Minute)
+
+	tests := []struct {
+		name          string
+		gamesPerFatal int
+		want          map[int64]string
+	}{
+		{"default", 0, map[int64]string{1: "fatal", 2: "fatal/2026-03-10", 3: "fatal/2026-03-10", 4: "fatal/2026-03-10", 5: "fatal/2026-03-10", 6: "fatal"}},
+		{"one", 1, map[int64]string{1: "fatal", 2: "fatal/2026-03-10", 3: "fatal/2026-03-10", 4: "fatal/2026-03-10", 5: "fatal", 6: "fatal"}},
+		{"capped", 100, map[int64]string{1: "fatal", 2: "fatal/2026-03-10", 3: "fatal/2026-03-10", 4: "fatal/2026-03-10", 5: "fatal/2026-03-10", 6: "fatal/2026-03-10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs := fatalDirs(replays, "profile", tt.gamesPerFatal, nil)
+			for _, r := range replays {
+				want := filepath.Join("profile", filepath.FromSlash(tt.want[r.info.MatchID]))
+				if got := dirs[r.path]; got != want {
+					t.Errorf("match %d: got %s, want %s", r.info.MatchID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFatalDirsUseMatchDetails(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	replays := []replay{
+		game(1, gameModeAllPick, at(20)),
+		game(2, gameModeAllPick, at(21)),
+		// The replay puts the Single Draft on the 11th, the GC on the 10th.
+		game(3, gc.GameModeSingleDraft, at(24)),
+		game(4, gameModeAllPick, at(25)),
+		game(5, gameModeAllPick, at(26)),
+		game(6, gameModeAllPick, at(27)),
+	}
+	details := func(matchID int64) (gc.MatchDetails, bool) {
+		switch matchID {
+		case 2, 4:
+			// Unranked: not the fatal match, not counted after it.
+			return gc.MatchDetails{MatchID: matchID, GameMode: gameModeAllPick, LobbyType: 0}, true
+		case 3:
+			return gc.MatchDetails{MatchID: 3, StartTime: uint32(at(22).Unix())}, true
+		}
+		return gc.MatchDetails{}, false
+	}
+
+	dirs := fatalDirs(replays, "profile", 2, details)
+	want := map[int64]string{1: "fatal/2026-03-10", 2: "fatal", 3: "fatal/2026-03-10", 4: "fatal", 5: "fatal/2026-03-10", 6: "fatal/2026-03-10"}
+	for _, r := range replays {
+		w := filepath.Join("profile", filepath.FromSlash(want[r.info.MatchID]))
+		if got := dirs[r.path]; got != w {
+			t.Errorf("match %d: got %s, want %s", r.info.MatchID, got, w)
+		}
+	}
+}
+
+func TestFatalDirsPreferFatalMatch(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	// Match 3 is played after the first Single Draft and is the fatal match
+	// of the second, so it goes with the second.
+	replays := []replay{
+		game(1, gameModeAllPick, at(10)),
+		game(2, gc.GameModeSingleDraft, at(12)),
+		game(3, gameModeAllPick, at(30)),
+		game(4, gc.GameModeSingleDraft, at(32)),
+	}
+	dirs := fatalDirs(replays, "profile", 2, nil)
+	want := map[int64]string{1: "fatal/2026-03-10", 2: "fatal/2026-03-10", 3: "fatal/2026-03-11", 4: "fatal/2026-03-11"}
+	for _, r := range replays {
+		w := filepath.Join("profile", filepath.FromSlash(want[r.info.MatchID]))
+		if got := dirs[r.path]; got != w {
+			t.Errorf("match %d: got %s, want %s", r.info.MatchID, got, w)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package opendota
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits of the free and API key tiers. A daily limit of 0 means unlimited.
+const (
+	FreePerMinute  = 60
+	FreePerDay     = 2000
+	KeyedPerMinute = 1200
+	KeyedPerDay    = 0
+)
+
+// Budget tracks how many OpenDota calls were made this minute and today.
+// It is saved to a file after every call so the count survives restarts,
+// and corrected from the X-Rate-Limit-Remaining-* headers OpenDota sends.
+type Budget struct {
+	mu   sync.Mutex
+	path string
+
+	PerMinute int `json:"perMinute"`
+	PerDay    int `json:"perDay"`
+
+	Day         string    `json:"day"` // UTC date the daily count belongs to
+	UsedToday   int       `json:"usedToday"`
+	MinuteStart time.Time `json:"minuteStart"`
+	UsedMinute  int       `json:"usedMinute"`
+	// BlockedUntil is set when OpenDota answers 429.
+	BlockedUntil time.Time `json:"blockedUntil,omitempty"`
+}
+
+// BudgetStatus is a snapshot of a Budget for display.
+type BudgetStatus struct {
+	PerMinute       int       `json:"perMinute"`
+	PerDay          int       `json:"perDay"`
+	UsedMinute      int       `json:"usedMinute"`
+	UsedToday       int       `json:"usedToday"`
+	RemainingMinute int       `json:"remainingMinute"`
+	RemainingDay    int       `json:"remainingDay"` // -1 when unlimited
+	BlockedUntil    time.Time `json:"blockedUntil,omitempty"`
+}
+
+// DefaultBudgetPath returns the budget file location under the user's config
+// directory (~/.dota-report-timestamps/opendota_budget.json).
+func DefaultBudgetPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".dota-report-timestamps", "opendota_budget.json")
+}
+
+// NewBudget returns an in-memory budget with the given limits.
+func NewBudget(perMinute, perDay int) *Budget {
+	return &Budget{PerMinute: perMinute, PerDay: perDay}
+}
+
+// LoadBudget reads the budget saved at path, or starts a new one, and sets
+// its limits. The file is updated as calls are made.
+func LoadBudget(path string, perMinute, perDay int) (*Budget, error) {
+	b := NewBudget(perMinute, perDay)
+	b.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return b, fmt.Errorf("failed to read OpenDota budget: %w", err)
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return b, fmt.Errorf("failed to parse OpenDota budget %s: %w", path, err)
+	}
+	b.PerMinute = perMinute
+	b.PerDay = perDay
+	return b, nil
+}
+
+// SetLimits changes the limits, e.g. when an API key is added.
+func (b *Budget) SetLimits(perMinute, perDay int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.PerMinute = perMinute
+	b.PerDay = perDay
+}
+
+// rollLocked resets the counters when a new minute or day has started.
+func (b *Budget) rollLocked(now time.Time) {
+	if day := now.UTC().Format("2006-01-02"); day != b.Day {
+		b.Day = day
+		b.UsedToday = 0
+	}
+	if now.Sub(b.MinuteStart) >= time.Minute {
+		b.MinuteStart = now
+		b.UsedMinute = 0
+	}
+}
+
+// reserve waits until cost calls fit into the per-minute budget and books
+// them. It returns ErrBudgetExhausted without waiting when the daily budget
+// is used up.
+func (b *Budget) reserve(cost int) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.rollLocked(now)
+		if b.PerDay > 0 && b.UsedToday+cost > b.PerDay {
+			b.mu.Unlock()
+			return ErrBudgetExhausted
+		}
+		var wait time.Duration
+		switch {
+		case now.Before(b.BlockedUntil):
+			wait = b.BlockedUntil.Sub(now)
+		case b.PerMinute > 0 && b.UsedMinute+cost > b.PerMinute && b.UsedMinute > 0:
+			wait = b.MinuteStart.Add(time.Minute).Sub(now)
+		default:
+			b.UsedMinute += cost
+			b.UsedToday += cost
+			b.saveLocked()
+			b.mu.Unlock()
+			return nil
+		}
+		b.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// update corrects the counters from OpenDota's rate-limit headers and
+// handles 429 answers.
+func (b *Budget) update(resp *http.Response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed := false
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining-Minute")); err == nil && b.PerMinute > 0 {
+		if used := b.PerMinute - remaining; used > b.UsedMinute {
+			b.UsedMinute = used
+			changed = true
+		}
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining-Day")); err == nil && b.PerDay > 0 {
+		if used := b.PerDay - remaining; used > b.UsedToday {
+			b.UsedToday = used
+			changed = true
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := time.Minute
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			wait = time.Duration(secs) * time.Second
+		}
+		b.BlockedUntil = time.Now().Add(wait)
+		log.Printf("OpenDota rate limit hit, pausing requests for %v", wait)
+		changed = true
+	}
+	if changed {
+		b.saveLocked()
+	}
+}
+
+// Status returns the current usage.
+func (b *Budget) Status() BudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollLocked(time.Now())
+	s := BudgetStatus{
+		PerMinute:       b.PerMinute,
+		PerDay:          b.PerDay,
+		UsedMinute:      b.UsedMinute,
+		UsedToday:       b.UsedToday,
+		RemainingMinute: max(b.PerMinute-b.UsedMinute, 0),
+		RemainingDay:    -1,
+		BlockedUntil:    b.BlockedUntil,
+	}
+	if b.PerDay > 0 {
+		s.RemainingDay = max(b.PerDay-b.UsedToday, 0)
+	}
+	return s
+}
+
+// saveLocked writes the budget to its file atomically.
+func (b *Budget) saveLocked() {
+	if b.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode OpenDota budget: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		log.Printf("Failed to create OpenDota budget directory: %v", err)
+		return
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write OpenDota budget: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		log.Printf("Failed to replace OpenDota budget: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package opendota
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func response(status int, headers map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func TestUpdateFromRemainingHeaders(t *testing.T) {
+	b := NewBudget(FreePerMinute, FreePerDay)
+	if err := b.reserve(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls made elsewhere with the same IP count too.
+	b.update(response(http.StatusOK, map[string]string{
+		"X-Rate-Limit-Remaining-Minute": "50",
+		"X-Rate-Limit-Remaining-Day":    "1990",
+	}))
+	if s := b.Status(); s.UsedMinute != 10 || s.UsedToday != 10 {
+		t.Fatalf("after headers: used %d/min, %d/day; want 10 and 10", s.UsedMinute, s.UsedToday)
+	}
+
+	// Headers never lower the local count, and bad ones are ignored.
+	b.update(response(http.StatusOK, map[string]string{
+		"X-Rate-Limit-Remaining-Minute": "59",
+		"X-Rate-Limit-Remaining-Day":    "many",
+	}))
+	if s := b.Status(); s.UsedMinute != 10 || s.UsedToday != 10 {
+		t.Fatalf("after stale headers: used %d/min, %d/day; want 10 and 10", s.UsedMinute, s.UsedToday)
+	}
+}
+
+func TestUpdateUnlimitedDay(t *testing.T) {
+	b := NewBudget(KeyedPerMinute, KeyedPerDay)
+	b.update(response(http.StatusOK, map[string]string{"X-Rate-Limit-Remaining-Day": "0"}))
+	if s := b.Status(); s.UsedToday != 0 || s.RemainingDay != -1 {
+		t.Fatalf("keyed budget took a daily header: %+v", s)
+	}
+}
+
+func TestUpdateTooManyRequests(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"5", 5 * time.Second},
+		{"", time.Minute},
+		{"soon", time.Minute},
+	}
+	for _, tt := range tests {
+		b := NewBudget(FreePerMinute, FreePerDay)
+		before := time.Now()
+		b.update(response(http.StatusTooManyRequests, map[string]string{"Retry-After": tt.retryAfter}))
+		got := b.Status().BlockedUntil.Sub(before)
+		if got < tt.want || got > tt.want+time.Second {
+			t.Errorf("Retry-After %q: blocked for %v, want %v", tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestReserveWaitsWhileBlocked(t *testing.T) {
+	b := NewBudget(FreePerMinute, FreePerDay)
+	b.BlockedUntil = time.Now().Add(100 * time.Millisecond)
+	start := time.Now()
+	if err := b.reserve(1); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Fatalf("reserve returned after %v while blocked", waited)
+	}
+}
+
+func TestReserveDailyLimit(t *testing.T) {
+	b := NewBudget(FreePerMinute, 2)
+	if err := b.reserve(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.reserve(1); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("reserve over the daily limit: %v, want ErrBudgetExhausted", err)
+	}
+}
+
+func TestClientBlocksAfter429(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("X-Rate-Limit-Remaining-Day", "0")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "budget.json")
+	budget, err := LoadBudget(path, FreePerMinute, FreePerDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(srv.URL, "", budget)
+	_, err = c.GetMatch(1)
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("GetMatch: %v, want a 429 StatusError", err)
+	}
+
+	// The block and the used-up day survive a restart.
+	reloaded, err := LoadBudget(path, FreePerMinute, FreePerDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := reloaded.Status()
+	if time.Until(s.BlockedUntil) < 25*time.Second || s.RemainingDay != 0 {
+		t.Fatalf("reloaded budget = %+v, want blocked for ~30s with no calls left today", s)
+	}
+	if err := reloaded.reserve(1); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("reserve on a used-up day: %v, want ErrBudgetExhausted", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package opendota is a client for the OpenDota API that keeps within the
+// account's rate limits.
+package opendota
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultBaseURL is the OpenDota API root.
+const DefaultBaseURL = "https://api.opendota.com/api"
+
+// requestParseCost is what a parse request counts against the budget.
+const requestParseCost = 10
+
+var (
+	// ErrBudgetExhausted is returned when today's call budget is used up.
+	ErrBudgetExhausted = errors.New("OpenDota daily call budget exhausted")
+	// ErrNotFound is returned for unknown matches and parse jobs.
+	ErrNotFound = errors.New("not found on OpenDota")
+)
+
+// StatusError is an unexpected HTTP status from OpenDota.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d from OpenDota: %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// Transient reports whether the request is worth retrying later: server
+// errors (including Cloudflare's 521 when the origin is down) and 429.
+func (e *StatusError) Transient() bool {
+	return e.StatusCode >= 500 && e.StatusCode < 600 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Client calls the OpenDota API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	budget     *Budget
+	httpClient *http.Client
+}
+
+// NewClient returns a client for baseURL (DefaultBaseURL when empty). An API
+// key raises the rate limits. budget may be shared by several clients; nil
+// uses an unsaved budget with the limits of the key's tier.
+func NewClient(baseURL, apiKey string, budget *Budget) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if budget == nil {
+		budget = NewBudget(Limits(apiKey))
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		budget:  budget,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Limits returns the per-minute and daily limits for an API key ("" for the
+// free tier).
+func Limits(apiKey string) (perMinute, perDay int) {
+	if apiKey != "" {
+		return KeyedPerMinute, KeyedPerDay
+	}
+	return FreePerMinute, FreePerDay
+}
+
+// Budget returns the client's rate-limit budget.
+func (c *Client) Budget() *Budget {
+	return c.budget
+}
+
+// do sends a request costing cost calls and decodes a JSON answer into out.
+func (c *Client) do(method, path string, cost int, out interface{}) error {
+	if err := c.budget.reserve(cost); err != nil {
+		return err
+	}
+
+	u := c.baseURL + path
+	if c.apiKey != "" {
+		u += "?api_key=" + url.QueryEscape(c.apiKey)
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call OpenDota: %w", err)
+	}
+	defer resp.Body.Close()
+	c.budget.update(resp)
+
+	body, err := readBody(resp)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode OpenDota response: %w", err)
+	}
+	return nil
+}
+
+// readBody reads and decompresses a response (zstd, gzip, or none) and
+// strips any non-JSON prefix.
+func readBody(resp *http.Response) ([]byte, error) {
+	rawContent, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyContent []byte
+	switch resp.Header.Get("Content-Encoding") {
+	case "zstd":
+		if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
+			defer r.Close()
+			bodyContent, _ = io.ReadAll(r)
+		}
+	case "gzip":
+		if r, err := gzip.NewReader(bytes.NewReader(rawContent)); err == nil {
+			defer r.Close()
+			bodyContent, _ = io.ReadAll(r)
+		}
+	default:
+		// Auto-detect zstd magic bytes if no header
+		if len(rawContent) > 0 && rawContent[0] != '{' && rawContent[0] != '[' {
+			if r, err := zstd.NewReader(bytes.NewReader(rawContent)); err == nil {
+				defer r.Close()
+				if d, err := io.ReadAll(r); err == nil && len(d) > 0 {
+					bodyContent = d
+				}
+			}
+		}
+	}
+	if len(bodyContent) == 0 {
+		bodyContent = rawContent
+	}
+
+	if len(bodyContent) > 0 && bodyContent[0] != '{' && bodyContent[0] != '[' {
+		if start := bytes.IndexAny(bodyContent, "{["); start != -1 {
+			bodyContent = bodyContent[start:]
+		}
+	}
+	return bodyContent, nil
+}
+
+// GetMatch returns a match. Players' parsed data, Chat and Objectives are
+// only filled once the match has been parsed (see Match.Parsed).
+func (c *Client) GetMatch(matchID int64) (*Match, error) {
+	var m Match
+	if err := c.do(http.MethodGet, fmt.Sprintf("/matches/%d", matchID), 1, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RequestParse asks OpenDota to parse a match and returns the parse job ID.
+func (c *Client) RequestParse(matchID int64) (int, error) {
+	var jobResp struct {
+		Job struct {
+			JobID int `json:"jobId"`
+		} `json:"job"`
+		JobID int `json:"jobId"` // Sometimes it's top level
+	}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/request/%d", matchID), requestParseCost, &jobResp); err != nil {
+		return 0, err
+	}
+	if jobResp.Job.JobID != 0 {
+		return jobResp.Job.JobID, nil
+	}
+	return jobResp.JobID, nil
+}
+
+// ParseJobPending reports whether a parse job is still queued or running.
+// OpenDota answers null once the job is gone.
+func (c *Client) ParseJobPending(jobID int) (bool, error) {
+	var job json.RawMessage
+	if err := c.do(http.MethodGet, fmt.Sprintf("/request/%d", jobID), 1, &job); err != nil {
+		return false, err
+	}
+	return len(job) > 0 && string(job) != "null", nil
+}
@@ -0,0 +1,71 @@
+package opendota
+
+// Match is the subset of OpenDota's /matches/{id} answer used by the tool.
+type Match struct {
+	MatchID    int64  `json:"match_id"`
+	StartTime  int64  `json:"start_time"`
+	Duration   int    `json:"duration"`
+	RadiantWin bool   `json:"radiant_win"`
+	GameMode   int    `json:"game_mode"`
+	LobbyType  int    `json:"lobby_type"`
+	Cluster    uint32 `json:"cluster"`
+	ReplaySalt uint64 `json:"replay_salt"`
+	ReplayURL  string `json:"replay_url"`
+
+	Players    []Player    `json:"players"`
+	Chat       []ChatEvent `json:"chat"`
+	Objectives []Objective `json:"objectives"`
+
+	OdData struct {
+		HasParsed bool `json:"has_parsed"`
+	} `json:"od_data"`
+}
+
+// Parsed reports whether OpenDota has parsed the replay, so Chat,
+// Objectives and the players' parsed fields are available.
+func (m *Match) Parsed() bool {
+	return m.OdData.HasParsed
+}
+
+// Player is one player of a match. AccountID is nil for anonymous players.
+type Player struct {
+	AccountID    *int64 `json:"account_id"`
+	PlayerSlot   int    `json:"player_slot"` // 0-4 Radiant, 128-132 Dire
+	HeroID       int    `json:"hero_id"`
+	PersonaName  string `json:"personaname"`
+	IsRadiant    bool   `json:"isRadiant"`
+	Kills        int    `json:"kills"`
+	Deaths       int    `json:"deaths"`
+	Assists      int    `json:"assists"`
+	LeaverStatus int    `json:"leaver_status"`
+}
+
+// Slot returns the player's 0-9 slot as used by the replay parser.
+func (p Player) Slot() int {
+	if p.PlayerSlot >= 128 {
+		return p.PlayerSlot - 128 + 5
+	}
+	return p.PlayerSlot
+}
+
+// ChatEvent is a chat message or chat wheel line. Time is in seconds of
+// game time (negative before the horn).
+type ChatEvent struct {
+	Time       int    `json:"time"`
+	Type       string `json:"type"` // "chat" or "chatwheel"
+	Key        string `json:"key"`
+	Slot       int    `json:"slot"`
+	PlayerSlot int    `json:"player_slot"`
+	Unit       string `json:"unit"`
+}
+
+// Objective is a building kill, Roshan, first blood or similar event.
+type Objective struct {
+	Time       int         `json:"time"`
+	Type       string      `json:"type"`
+	Slot       int         `json:"slot"`
+	PlayerSlot int         `json:"player_slot"`
+	Key        interface{} `json:"key"` // building name or number depending on Type
+	Unit       string      `json:"unit"`
+	Team       int         `json:"team"`
+}
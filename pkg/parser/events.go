@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event is emitted while a replay is being parsed. Every event carries the
+// tick it happened at and the game time derived from it.
+type Event interface {
+	EventTick() int
+	EventGameTime() time.Duration
+}
+
+// EventBase holds the fields shared by all events. GameTime is the in-game
+// clock with pauses removed; it is zero before the horn.
+type EventBase struct {
+	Tick     int           `json:"tick"`
+	GameTime time.Duration `json:"gameTime"`
+}
+
+func (b EventBase) EventTick() int               { return b.Tick }
+func (b EventBase) EventGameTime() time.Duration { return b.GameTime }
+
+// GameStateChanged is emitted when the game rules state changes
+// (DOTA_GameState, e.g. 5 = game in progress).
+type GameStateChanged struct {
+	EventBase
+	State int32 `json:"state"`
+}
+
+// ScoreboardOpened is emitted when a player opens the scoreboard.
+type ScoreboardOpened struct {
+	EventBase
+	Slot    int    `json:"slot"`
+	SteamID uint64 `json:"steamId"`
+}
+
+// ScoreboardClosed is emitted when a player closes the scoreboard.
+type ScoreboardClosed struct {
+	EventBase
+	Slot    int    `json:"slot"`
+	SteamID uint64 `json:"steamId"`
+}
+
+// ReportHover is emitted when a player's cursor moves onto another
+// player's report button.
+type ReportHover struct {
+	EventBase
+	Slot          int    `json:"slot"`
+	SteamID       uint64 `json:"steamId"`
+	TargetSlot    int    `json:"targetSlot"`
+	TargetSteamID uint64 `json:"targetSteamId"`
+}
+
+// ReportConfirmed is emitted when a report is detected. Report is a copy
+// taken at that point and is not changed afterwards. Heroes are only
+// resolved at the end of the replay, so the final ParseResult's report may
+// have Hero and TargetHero filled in or corrected and HeroID and
+// TargetHeroID set; every other field is final.
+type ReportConfirmed struct {
+	EventBase
+	Report *Report `json:"report"`
+}
+
+// Pause is emitted when the game is paused or unpaused.
+type Pause struct {
+	EventBase
+	Paused           bool `json:"paused"`
+	TotalPausedTicks int  `json:"totalPausedTicks"`
+}
+
+// PlayerResolved is emitted once per player when their slot, team, name and
+// hero are known.
+type PlayerResolved struct {
+	EventBase
+	Player PlayerResource `json:"player"`
+}
+
+// Finished is the last event sent by Stream. It carries the result of the
+// parse, or the error that stopped it.
+type Finished struct {
+	EventBase
+	Result ParseResult `json:"result"`
+	Err    error       `json:"-"`
+}
+
+// EventHandler receives parser events. Returning an error stops parsing and
+// the error is returned from Run.
+type EventHandler func(Event) error
+
+// Options configures a parse.
+type Options struct {
+	MatchID int64
+	// ReportedSlot and ReportedSteamID select the player whose reports are
+	// ignored (the reported player cannot report themselves). Leave both
+	// at -1/0 to collect every report.
+	ReportedSlot    int
+	ReportedSteamID uint64
+
+	// Logger receives the parser's log output, tagged with match_id. When
+	// nil only warnings and errors are logged.
+	Logger logrus.FieldLogger
+	// Trace, when set, receives every cursor sample of players with the
+	// scoreboard open as tab separated values. It is meant for calibrating
+	// the scoreboard layouts against a single match.
+	Trace io.Writer
+}
+
+// Run parses a replay, calling handler for each event, and returns the
+// final result. handler may be nil. Parsing stops when ctx is cancelled.
+func Run(ctx context.Context, r io.Reader, opts Options, handler EventHandler) (ParseResult, error) {
+	return parse(ctx, r, opts, handler)
+}
+
+// Stream parses a replay in the background and delivers its events on the
+// returned channel. The last event is a Finished event, after which the
+// channel is closed. Cancelling ctx stops the parse; Finished is then only
+// delivered if the channel has room.
+func Stream(ctx context.Context, r io.Reader, opts Options) <-chan Event {
+	events := make(chan Event, 64)
+	go func() {
+		defer close(events)
+		var last EventBase
+		result, err := parse(ctx, r, opts, func(ev Event) error {
+			last = EventBase{Tick: ev.EventTick(), GameTime: ev.EventGameTime()}
+			select {
+			case events <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		fin := Finished{EventBase: last, Result: result, Err: err}
+		select {
+		case events <- fin:
+		case <-ctx.Done():
+			// The receiver may have stopped reading; deliver only if
+			// there is room so the goroutine cannot leak.
+			select {
+			case events <- fin:
+			default:
+			}
+		}
+	}()
+	return events
+}
+
+// gameTime converts a tick to in-game time, like ticksToMinutesAndSeconds.
+func gameTime(begin_tick int, pausedTicks int, tick int) time.Duration {
+	if begin_tick == 0 || tick < begin_tick {
+		return 0
+	}
+	return time.Duration(tick-begin_tick-pausedTicks) * time.Second / 30
+}
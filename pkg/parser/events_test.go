@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dotabuff/manta/dota"
+	"github.com/golang/protobuf/proto"
+)
+
+// bitWriter packs values the way manta's reader unpacks them, least
+// significant bit first.
+type bitWriter struct {
+	buf   []byte
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nbits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v&(1<<i) != 0 {
+			w.buf[len(w.buf)-1] |= 1 << (w.nbits % 8)
+		}
+		w.nbits++
+	}
+}
+
+func (w *bitWriter) writeUBitVar(v uint32) {
+	switch {
+	case v < 16:
+		w.writeBits(v, 6)
+	case v < 1<<8:
+		w.writeBits(v&15|16, 6)
+		w.writeBits(v>>4, 4)
+	case v < 1<<12:
+		w.writeBits(v&15|32, 6)
+		w.writeBits(v>>4, 8)
+	default:
+		w.writeBits(v&15|48, 6)
+		w.writeBits(v>>4, 28)
+	}
+}
+
+func (w *bitWriter) writeVarUint32(v uint32) {
+	for v >= 0x80 {
+		w.writeBits(v&0x7f|0x80, 8)
+		v >>= 7
+	}
+	w.writeBits(v, 8)
+}
+
+func appendVarUint32(b []byte, v uint32) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v&0x7f|0x80))
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// demoMessage appends an outer demo message to b.
+func demoMessage(t *testing.T, b []byte, cmd dota.EDemoCommands, tick uint32, m proto.Message) []byte {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = appendVarUint32(b, uint32(cmd))
+	b = appendVarUint32(b, tick)
+	b = appendVarUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// tickPacket appends a packet holding a net_Tick message, followed by a
+// gamerules state change when state is not zero.
+func tickPacket(t *testing.T, b []byte, tick uint32, state uint32) []byte {
+	var w bitWriter
+	inner := func(kind int32, m proto.Message) {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.writeUBitVar(uint32(kind))
+		w.writeVarUint32(uint32(len(data)))
+		for _, c := range data {
+			w.writeBits(uint32(c), 8)
+		}
+	}
+	inner(int32(dota.NET_Messages_net_Tick), &dota.CNETMsg_Tick{Tick: proto.Uint32(tick)})
+	if state != 0 {
+		inner(int32(dota.EDotaUserMessages_DOTA_UM_GamerulesStateChanged), &dota.CDOTAUserMsg_GamerulesStateChanged{State: proto.Uint32(state)})
+	}
+	return demoMessage(t, b, dota.EDemoCommands_DEM_Packet, tick, &dota.CDemoPacket{Data: w.buf})
+}
+
+// demoHeader returns the start of a Source 2 demo.
+func demoHeader(t *testing.T) []byte {
+	b := append([]byte("PBDEMS2\x00"), make([]byte, 8)...)
+	return demoMessage(t, b, dota.EDemoCommands_DEM_FileHeader, 0, &dota.CDemoFileHeader{DemoFileStamp: proto.String("PBDEMS2")})
+}
+
+// stateReplay is a replay without entities that only goes through the
+// game states, one every 30 ticks.
+func stateReplay(t *testing.T, states ...uint32) []byte {
+	b := demoHeader(t)
+	for i, state := range states {
+		tick := uint32(i+1) * 30
+		b = tickPacket(t, b, tick, 0)
+		b = tickPacket(t, b, tick+15, state)
+	}
+	return demoMessage(t, b, dota.EDemoCommands_DEM_Stop, uint32(len(states)+1)*30, &dota.CDemoStop{})
+}
+
+func TestRunEventOrder(t *testing.T) {
+	// Waiting for players, hero selection, pre-game, game in progress,
+	// post-game.
+	replay := stateReplay(t, 1, 2, 4, 5, 6)
+	var got []GameStateChanged
+	_, err := Run(context.Background(), bytes.NewReader(replay), Options{ReportedSlot: -1}, func(ev Event) error {
+		sc, ok := ev.(GameStateChanged)
+		if !ok {
+			t.Fatalf("unexpected event %T", ev)
+		}
+		got = append(got, sc)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []GameStateChanged{
+		{EventBase{Tick: 45}, 1},
+		{EventBase{Tick: 75}, 2},
+		{EventBase{Tick: 105}, 4},
+		// The horn starts the game clock.
+		{EventBase{Tick: 135}, 5},
+		{EventBase{Tick: 165, GameTime: time.Second}, 6},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %+v, want %+v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRunHandlerErrorStopsParse(t *testing.T) {
+	stop := errors.New("stop")
+	events := 0
+	_, err := Run(context.Background(), bytes.NewReader(stateReplay(t, 1, 2, 4, 5, 6)), Options{ReportedSlot: -1}, func(Event) error {
+		events++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("got %v, want the handler's error", err)
+	}
+	if events != 1 {
+		t.Fatalf("handler called %d times, want 1", events)
+	}
+}
+
+func TestStreamEndsWithFinished(t *testing.T) {
+	var states []int32
+	var fin *Finished
+	for ev := range Stream(context.Background(), bytes.NewReader(stateReplay(t, 2, 5)), Options{ReportedSlot: -1}) {
+		if fin != nil {
+			t.Fatalf("%T after Finished", ev)
+		}
+		switch ev := ev.(type) {
+		case GameStateChanged:
+			states = append(states, ev.State)
+		case Finished:
+			fin = &ev
+		}
+	}
+	if fin == nil {
+		t.Fatal("channel closed without Finished")
+	}
+	if fin.Err != nil {
+		t.Fatal(fin.Err)
+	}
+	if len(states) != 2 || states[0] != 2 || states[1] != 5 {
+		t.Fatalf("got states %v, want [2 5]", states)
+	}
+	if fin.Tick != 75 {
+		t.Fatalf("Finished at tick %d, want the last event's 75", fin.Tick)
+	}
+}
+
+func TestStreamStopsOnCancel(t *testing.T) {
+	// An endless replay: one game state change per packet until the reader
+	// is closed.
+	r, w := io.Pipe()
+	defer r.Close()
+	go func() {
+		b := demoHeader(t)
+		for tick := uint32(30); ; tick += 30 {
+			b = tickPacket(t, b, tick, 4)
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+			b = b[:0]
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := Stream(ctx, r, Options{ReportedSlot: -1})
+	for i := 0; i < 3; i++ {
+		if _, ok := (<-events).(GameStateChanged); !ok {
+			t.Fatal("want a game state change before cancelling")
+		}
+	}
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if fin, isFin := ev.(Finished); isFin && !errors.Is(fin.Err, context.Canceled) {
+				t.Fatalf("Finished with %v, want context.Canceled", fin.Err)
+			}
+		case <-timeout:
+			t.Fatal("Stream did not stop after ctx was cancelled")
+		}
+	}
+}
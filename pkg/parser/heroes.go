@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/d3nd3/dota-report-timestamps/pkg/heroes"
+	"github.com/dotabuff/manta"
+)
+
+// selectedHeroID reads m_nSelectedHeroID for the given player slot from the
+// CDOTA_PlayerResource entity. The field width differs between replay
+// versions, so all integer representations are accepted.
+func selectedHeroID(e *manta.Entity, slot int) int {
+	switch v := e.Get(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_nSelectedHeroID", slot)).(type) {
+	case int32:
+		return int(v)
+	case uint32:
+		return int(v)
+	case uint64:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+// resolveHeroes normalises each player's hero against the hero table. The
+// selected hero ID takes precedence; otherwise the entity class suffix
+// collected while parsing is used. Unknown heroes keep their raw name.
+func resolveHeroes(players []PlayerResource) {
+	for i := range players {
+		p := &players[i]
+		hero, ok := heroes.ByID(p.HeroID)
+		if !ok && p.Hero != "" {
+			hero, ok = heroes.ByClassName(p.Hero)
+		}
+		if !ok {
+			p.HeroName = p.Hero
+			continue
+		}
+		p.HeroID = hero.ID
+		p.Hero = hero.Name
+		p.HeroName = hero.LocalizedName
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultLogger is used when Options.Logger is nil. It only reports
+// warnings and errors so normal runs stay quiet.
+var defaultLogger = func() *logrus.Logger {
+	l := logrus.New()
+	l.SetLevel(logrus.WarnLevel)
+	return l
+}()
+
+// logger returns the logger for a parse, tagged with the match ID.
+func (o Options) logger() *logrus.Entry {
+	var l logrus.FieldLogger = defaultLogger
+	if o.Logger != nil {
+		l = o.Logger
+	}
+	return l.WithField("match_id", o.MatchID)
+}
+
+// traceHeader is the first line written to Options.Trace.
+const traceHeader = "tick\tgame_time\tslot\tsteam_id\tx\ty\taspect\tlayout\ttarget_slot\n"
+
+// tracer writes cursor samples to Options.Trace as tab separated values.
+// Write errors disable tracing after being logged once.
+type tracer struct {
+	w   io.Writer
+	log *logrus.Entry
+}
+
+func newTracer(w io.Writer, log *logrus.Entry) *tracer {
+	t := &tracer{w: w, log: log}
+	if w != nil {
+		t.write(traceHeader)
+	}
+	return t
+}
+
+func (t *tracer) write(line string) {
+	if t.w == nil {
+		return
+	}
+	if _, err := io.WriteString(t.w, line); err != nil {
+		t.log.Warnf("Disabling cursor trace: %v", err)
+		t.w = nil
+	}
+}
+
+// sample records one cursor position of a player with the scoreboard open.
+func (t *tracer) sample(base EventBase, slot int, steamID uint64, x, y int32, aspect float32, layout string, targetSlot int) {
+	if t.w == nil {
+		return
+	}
+	t.write(fmt.Sprintf("%d\t%s\t%d\t%d\t%d\t%d\t%.4f\t%s\t%d\n",
+		base.Tick, base.GameTime, slot, steamID, x, y, aspect, layout, targetSlot))
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -20,7 +21,10 @@ type PlayerResource struct {
 	EntIndex uint32
 	Team     int32  `json:"Team"` // 2 = radiant, 3 = dire
 	Name     string `json:"Name"`
-	Hero     string `json:"Hero"`
+	IsBot    bool   `json:"IsBot"`
+	Hero     string `json:"Hero"`     // internal hero name, e.g. "zuus"
+	HeroID   int    `json:"HeroID"`   // hero ID, 0 if unknown
+	HeroName string `json:"HeroName"` // localized hero name, e.g. "Zeus"
 }
 
 type Report struct {
@@ -30,10 +34,13 @@ type Report struct {
 	Slot          int
 	Name          string
 	Hero          string
+	HeroID        int
 	TargetSlot    int    `json:"TargetSlot"`    // The slot of the player who was reported
 	TargetSteamID uint64 `json:"TargetSteamID"` // The SteamID of the player who was reported
 	TargetName    string `json:"TargetName"`    // The name of the player who was reported
 	TargetHero    string `json:"TargetHero"`    // The hero of the player who was reported
+	TargetHeroID  int    `json:"TargetHeroID"`  // The hero ID of the player who was reported
+	TargetIsBot   bool   `json:"TargetIsBot"`   // The reported player is a bot (practice/calibration only)
 }
 
 type ParseResult struct {
@@ -41,6 +48,11 @@ type ParseResult struct {
 	TeamReports  int       `json:"TeamReports"`
 	EnemyReports int       `json:"EnemyReports"`
 	Reports      []*Report `json:"Reports"`
+	// GameMode is the DOTA_GameMode of the match, 0 if it could not be read.
+	GameMode int32 `json:"GameMode"`
+	// ScoreboardLayout is the name of the scoreboard layout used to map
+	// cursor positions to report buttons.
+	ScoreboardLayout string `json:"ScoreboardLayout"`
 }
 
 // reader performs read operations against a buffer
@@ -110,51 +122,11 @@ func formatDuration(d time.Duration) string {
 	return time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).Add(d).Truncate(time.Second).Format("15:04:05.999999999")
 }
 
-func isReportButton(x int, y int, aspect float32) int {
-	// width of their scoreboard in 1920 pixels, based on thier custom resolution,aspectRatio.
-	score_width := int(math.Round(float64((1.77777777778 * (920.0 / 1920)) / float64(aspect) * 1920)))
-	score_width_no_tips := int(math.Round(float64((1.77777777778 * (820.0 / 1920)) / float64(aspect) * 1920)))
-
-	lower_x_bound := 865.0
-	upper_x_bound := 893.0
-
-	lower_x_bound_r := lower_x_bound / 920
-	upper_x_bound_r := upper_x_bound / 920
-
-	lower_x_bound_no_tips_r := (lower_x_bound - 100) / 820
-	upper_x_bound_no_tips_r := (upper_x_bound - 100) / 820
-
-	if (x >= int(math.Floor(float64(lower_x_bound_no_tips_r*float64(score_width_no_tips)))) && x <= int(math.Ceil(float64(upper_x_bound_no_tips_r*float64(score_width_no_tips))))) || (x >= int(math.Floor(float64(lower_x_bound_r*float64(score_width)))) && x <= int(math.Ceil(float64(upper_x_bound_r*float64(score_width))))) /*|| ( x >= 658 && x <= 679 )*/ {
-		if y >= 106 && y <= 134 { //120+70x
-			return 0
-		} else if y >= 176 && y <= 204 {
-			return 1
-		} else if y >= 246 && y <= 274 {
-			return 2
-		} else if y >= 316 && y <= 344 {
-			return 3
-		} else if y >= 386 && y <= 414 {
-			return 4
-		} else if y >= 486 && y <= 514 {
-			return 5
-		} else if y >= 556 && y <= 584 {
-			return 6
-		} else if y >= 626 && y <= 654 {
-			return 7
-		} else if y >= 696 && y <= 724 {
-			return 8
-		} else if y >= 766 && y <= 794 {
-			return 9
-		}
-	}
-	return -1
-}
-
 func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error) {
-	var player_resources [10]PlayerResource
+	var player_resources [maxPlayerEntries]PlayerResource
 	maxTicks := 150000
 
-	for i := 0; i < 10; i++ {
+	for i := 0; i < maxPlayerEntries; i++ {
 		player_resources[i].Slot = i
 	}
 
@@ -171,28 +143,40 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 	heroHandles := make(map[int]uint64)
 	allHeroEntities := make(map[uint32]string)
 	playerSteamIDs := make(map[int]uint64)
-	slotsWithPlayers := 0
-	slotsWithHeroes := 0
+	gameStarted := false
 
+	// checkComplete reports whether every team member has a hero. Once the
+	// game is in progress, team members still without a hero are coaches,
+	// so it is enough that the others have one.
 	checkComplete := func() bool {
-		if !playerDataFound || slotsWithPlayers == 0 {
+		if !playerDataFound {
 			return false
 		}
-		slotsWithHeroes = 0
-		for i := 0; i < 10; i++ {
-			if player_resources[i].Name != "" {
-				if player_resources[i].Hero != "" {
-					slotsWithHeroes++
-				}
+		players, withHeroes := 0, 0
+		for i := 0; i < maxPlayerEntries; i++ {
+			pr := player_resources[i]
+			if !isTeamPlayer(pr) || (pr.Name == "" && pr.SteamID == 0) {
+				continue
+			}
+			players++
+			if pr.Hero != "" || pr.HeroID > 0 {
+				withHeroes++
 			}
 		}
-		return slotsWithHeroes >= slotsWithPlayers
+		if players == 0 {
+			return false
+		}
+		return withHeroes >= players || (gameStarted && withHeroes > 0)
 	}
 
 	p.Callbacks.OnCNETMsg_Tick(func(m *dota.CNETMsg_Tick) error {
 		current_tick = int(m.GetTick())
-		if current_tick > maxTicks && !playerDataFound {
-			return fmt.Errorf("timeout: player data not found within %d ticks", maxTicks)
+		if current_tick > maxTicks {
+			if !playerDataFound {
+				return fmt.Errorf("timeout: player data not found within %d ticks", maxTicks)
+			}
+			// Return what we have rather than scanning the rest of the replay.
+			return fmt.Errorf("early_stop_complete")
 		}
 		if checkComplete() {
 			return fmt.Errorf("early_stop_complete")
@@ -200,39 +184,49 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 		return nil
 	})
 
+	p.Callbacks.OnCDOTAUserMsg_GamerulesStateChanged(func(m *dota.CDOTAUserMsg_GamerulesStateChanged) error {
+		if m.GetState() >= 5 {
+			gameStarted = true
+		}
+		return nil
+	})
+
 	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
 		className := e.GetClassName()
 		entIndex := uint32(e.GetIndex())
 
 		if className == "CDOTA_PlayerResource" {
 			playerDataFound = true
-			for i := 0; i < 10; i++ {
+			for i := 0; i < maxPlayerEntries; i++ {
 				player_resources[i].Slot = i
 
-				if steamid, steamidok := e.GetUint64(fmt.Sprintf("m_vecPlayerData.000%d.m_iPlayerSteamID", i)); steamidok {
+				if steamid, steamidok := e.GetUint64(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerSteamID", i)); steamidok {
 					player_resources[i].SteamID = steamid
 					if steamid > 0 {
 						playerSteamIDs[i] = steamid
 					}
 				}
 
-				if entindex, entindexok := e.GetUint32(fmt.Sprintf("m_vecPlayerData.000%d.m_nPlayerSlot", i)); entindexok {
+				if entindex, entindexok := e.GetUint32(fmt.Sprintf("m_vecPlayerData.%04d.m_nPlayerSlot", i)); entindexok {
 					player_resources[i].EntIndex = entindex
 					entIndexToSlot[entindex] = i
 				}
 
-				if team, teamok := e.GetInt32(fmt.Sprintf("m_vecPlayerData.000%d.m_iPlayerTeam", i)); teamok {
+				if team, teamok := e.GetInt32(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerTeam", i)); teamok {
 					player_resources[i].Team = team
 				}
 
-				if name, nameok := e.GetString(fmt.Sprintf("m_vecPlayerData.000%d.m_iszPlayerName", i)); nameok {
-					if player_resources[i].Name == "" && name != "" {
-						slotsWithPlayers++
-					}
+				if name, nameok := e.GetString(fmt.Sprintf("m_vecPlayerData.%04d.m_iszPlayerName", i)); nameok {
 					player_resources[i].Name = name
 				}
 
-				if heroHandle64, heroHandleOk := e.GetUint64(fmt.Sprintf("m_vecPlayerTeamData.000%d.m_hSelectedHero", i)); heroHandleOk {
+				player_resources[i].IsBot = isBotEntry(e, i)
+
+				if heroID := selectedHeroID(e, i); heroID > 0 {
+					player_resources[i].HeroID = heroID
+				}
+
+				if heroHandle64, heroHandleOk := e.GetUint64(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_hSelectedHero", i)); heroHandleOk {
 					if heroHandle64 != 0 && heroHandle64 != 16777215 {
 						heroHandles[i] = heroHandle64
 					}
@@ -241,7 +235,7 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 		}
 
 		if className == "CDOTAPlayerController" {
-			if playerID, ok := e.GetInt32("m_nPlayerID"); ok && playerID >= 0 && playerID < 10 {
+			if playerID, ok := e.GetInt32("m_nPlayerID"); ok && playerID >= 0 && playerID < maxPlayerEntries {
 				if entindex, ok := e.GetUint32("m_nPlayerSlot"); ok {
 					entIndexToSlot[entindex] = int(playerID)
 				}
@@ -253,7 +247,7 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 			heroMapByHandle[entIndex] = heroName
 			allHeroEntities[entIndex] = heroName
 
-			if playerID, ok := e.GetInt32("m_iPlayerID"); ok && playerID >= 0 && playerID < 10 {
+			if playerID, ok := e.GetInt32("m_iPlayerID"); ok && playerID >= 0 && playerID < maxPlayerEntries {
 				if player_resources[playerID].Hero == "" {
 					player_resources[playerID].Hero = heroName
 				}
@@ -299,7 +293,7 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 		}
 	}
 
-	for i := 0; i < 10; i++ {
+	for i := 0; i < maxPlayerEntries; i++ {
 		if player_resources[i].Hero == "" {
 			if heroHandle64, ok := heroHandles[i]; ok {
 				if heroEntity := p.FindEntityByHandle(heroHandle64); heroEntity != nil {
@@ -339,16 +333,28 @@ func ExtractPlayerInfo(matchID int64, file io.Reader) ([]PlayerResource, error)
 		}
 	}
 
-	result := make([]PlayerResource, 10)
-	copy(result[:], player_resources[:])
+	resolveHeroes(player_resources[:])
 
-	return result, nil
+	return activePlayers(player_resources[:]), nil
 }
 
+// ParseReplay parses a replay and returns every detected report. It is
+// Run without a context or event handler.
 func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamID uint64) (ParseResult, error) {
-	fmt.Printf("[PARSER] Starting ParseReplay - matchID: %d, reportedSlot: %d, reportedSteamID: %d\n", matchID, reportedSlot, reportedSteamID)
+	return parse(context.Background(), file, Options{
+		MatchID:         matchID,
+		ReportedSlot:    reportedSlot,
+		ReportedSteamID: reportedSteamID,
+	}, nil)
+}
+
+func parse(ctx context.Context, file io.Reader, opts Options, handler EventHandler) (ParseResult, error) {
+	matchID, reportedSlot, reportedSteamID := opts.MatchID, opts.ReportedSlot, opts.ReportedSteamID
+	log := opts.logger()
+	trace := newTracer(opts.Trace, log)
+	log.Debugf("Starting ParseReplay - reportedSlot: %d, reportedSteamID: %d", reportedSlot, reportedSteamID)
 
-	var player_resources [10]PlayerResource
+	var player_resources [maxPlayerEntries]PlayerResource
 
 	var teamReports int = 0
 	var enemyReports int = 0
@@ -364,8 +370,45 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 
 	entityCounts := make(map[string]int)
 
-	hoverDurations := make(map[int]map[int]int) // reporter slot -> target slot -> duration in ticks
-	lastHoverTime := make(map[int]int)          // reporter slot -> last tick any report button was hovered
+	// Hover tracking is keyed by playerKey rather than slot so that a
+	// reconnect that moves a player to another slot does not mix up data.
+	hoverDurations := make(map[uint64]map[uint64]int) // reporter key -> target key -> duration in ticks
+	lastHoverTime := make(map[uint64]int)             // reporter key -> last tick any report button was hovered
+	layout := LayoutStandard
+	var gameMode int32
+
+	// Event bookkeeping; only used when a handler is set.
+	lastHoverTarget := make(map[uint64]int) // reporter key -> slot currently hovered
+	resolvedPlayers := make(map[int]bool)
+	gamePaused := false
+	var handlerErr error
+
+	emit := func(ev Event) {
+		if handler == nil || handlerErr != nil {
+			return
+		}
+		handlerErr = handler(ev)
+	}
+	base := func() EventBase {
+		return EventBase{Tick: current_tick, GameTime: gameTime(begin_tick, pausedTicks, current_tick)}
+	}
+	// emitResolvedPlayers reports players whose name, team and hero have
+	// become known since the last call.
+	emitResolvedPlayers := func() {
+		if handler == nil {
+			return
+		}
+		for i := 0; i < maxPlayerEntries; i++ {
+			pr := player_resources[i]
+			if resolvedPlayers[i] || !isTeamPlayer(pr) || pr.Name == "" || (pr.Hero == "" && pr.HeroID == 0) {
+				continue
+			}
+			resolvedPlayers[i] = true
+			resolved := []PlayerResource{pr}
+			resolveHeroes(resolved)
+			emit(PlayerResolved{EventBase: base(), Player: resolved[0]})
+		}
+	}
 
 	heroMapByEntIndex := make(map[uint32]string)
 	heroMapByHandle := make(map[uint32]string)
@@ -384,7 +427,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 	// This prevents accidental default to slot 0 if reportedSlot was passed as 0 (default int)
 	if actualReportedSteamID > 0 {
 		actualReportedSlot = -1
-		fmt.Printf("[PARSER] SteamID provided, resetting slot to -1 for lookup\n")
+		log.Debugf("SteamID provided, resetting slot to -1 for lookup")
 	}
 
 	// If only SteamID is provided, we'll find the slot later
@@ -393,24 +436,38 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 	// Initialize the map
 	scoreboardOpen = make(map[uint64]bool)
 
-	fmt.Printf("[PARSER] Creating stream parser...\n")
+	log.Debugf("Creating stream parser...")
 	p, err := manta.NewStreamParser(file)
 	if err != nil {
-		fmt.Printf("[PARSER] ERROR: Failed to create parser: %s\n", err)
+		log.Errorf("Failed to create parser: %s", err)
 		return ParseResult{}, fmt.Errorf("unable to create parser: %s", err)
 	}
-	fmt.Printf("[PARSER] Parser created successfully\n")
+	log.Debugf("Parser created successfully")
 
 	p.Callbacks.OnCNETMsg_Tick(func(m *dota.CNETMsg_Tick) error {
 		current_tick = int(m.GetTick())
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return handlerErr
+	})
+
+	// The file info is written at the end of the replay; it only matters
+	// when the gamerules never reported a game mode.
+	p.Callbacks.OnCDemoFileInfo(func(m *dota.CDemoFileInfo) error {
+		if gameMode == 0 {
+			gameMode = m.GetGameInfo().GetDota().GetGameMode()
+			log.Debugf("Game mode from file info: %d", gameMode)
+		}
 		return nil
 	})
 
 	p.Callbacks.OnCDOTAUserMsg_GamerulesStateChanged(func(m *dota.CDOTAUserMsg_GamerulesStateChanged) error {
 		if m.GetState() == 5 {
 			begin_tick = current_tick
-			fmt.Printf("[PARSER] Game started! begin_tick set to: %d\n", begin_tick)
+			log.Debugf("Game started! begin_tick set to: %d", begin_tick)
 		}
+		emit(GameStateChanged{EventBase: base(), State: int32(m.GetState())})
 		return nil
 	})
 
@@ -419,13 +476,13 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		entityCounts[className]++
 
 		if className == "CDOTA_PlayerResource" {
-			for i := 0; i < 10; i++ {
+			for i := 0; i < maxPlayerEntries; i++ {
 				isVictim := false
 				if actualReportedSlot != -1 && i == actualReportedSlot {
 					isVictim = true
 				}
 
-				if steamid, steamidok := e.GetUint64(fmt.Sprintf("m_vecPlayerData.000%d.m_iPlayerSteamID", i)); steamidok {
+				if steamid, steamidok := e.GetUint64(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerSteamID", i)); steamidok {
 					player_resources[i].SteamID = steamid
 					if steamid > 0 {
 						playerSteamIDs[i] = steamid
@@ -437,7 +494,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 							if actualReportedSlot != i {
 								actualReportedSlot = i
 								if !reportedPlayerFound {
-									fmt.Printf("[PARSER] Found reported player by SteamID! Slot: %d, SteamID: %d\n", i, steamid)
+									log.Debugf("Found reported player by SteamID! Slot: %d, SteamID: %d", i, steamid)
 									reportedPlayerFound = true
 								}
 							}
@@ -446,30 +503,36 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 						if actualReportedSteamID != steamid {
 							actualReportedSteamID = steamid
 							if !reportedPlayerFound {
-								fmt.Printf("[PARSER] Found reported player by slot! Slot: %d, SteamID: %d\n", i, steamid)
+								log.Debugf("Found reported player by slot! Slot: %d, SteamID: %d", i, steamid)
 								reportedPlayerFound = true
 							}
 						}
 					}
 				}
 
-				if entindex, entindexok := e.GetUint32(fmt.Sprintf("m_vecPlayerData.000%d.m_nPlayerSlot", i)); entindexok {
+				if entindex, entindexok := e.GetUint32(fmt.Sprintf("m_vecPlayerData.%04d.m_nPlayerSlot", i)); entindexok {
 					player_resources[i].EntIndex = entindex
 					entIndexToSlot[entindex] = i
 				}
 
-				if team, teamok := e.GetInt32(fmt.Sprintf("m_vecPlayerData.000%d.m_iPlayerTeam", i)); teamok {
+				if team, teamok := e.GetInt32(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerTeam", i)); teamok {
 					player_resources[i].Team = team
 					if isVictim {
 						reportedTeam = int(team)
 					}
 				}
 
-				if name, nameok := e.GetString(fmt.Sprintf("m_vecPlayerData.000%d.m_iszPlayerName", i)); nameok {
+				if name, nameok := e.GetString(fmt.Sprintf("m_vecPlayerData.%04d.m_iszPlayerName", i)); nameok {
 					player_resources[i].Name = name
 				}
 
-				if heroHandle64, heroHandleOk := e.GetUint64(fmt.Sprintf("m_vecPlayerTeamData.000%d.m_hSelectedHero", i)); heroHandleOk {
+				player_resources[i].IsBot = isBotEntry(e, i)
+
+				if heroID := selectedHeroID(e, i); heroID > 0 {
+					player_resources[i].HeroID = heroID
+				}
+
+				if heroHandle64, heroHandleOk := e.GetUint64(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_hSelectedHero", i)); heroHandleOk {
 					if heroHandle64 != 0 && heroHandle64 != 16777215 {
 						heroHandles[i] = heroHandle64
 					}
@@ -482,10 +545,18 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 					pausedTicks = int(v)
 				}
 			}
+			if v, ok := e.GetBool("m_pGameRules.m_bGamePaused"); ok && v != gamePaused {
+				gamePaused = v
+				emit(Pause{EventBase: base(), Paused: v, TotalPausedTicks: pausedTicks})
+			}
+			if v, ok := e.GetInt32("m_pGameRules.m_iGameMode"); ok && v > 0 && v != gameMode {
+				gameMode = v
+				log.Debugf("Game mode from gamerules: %d", gameMode)
+			}
 		}
 
 		if className == "CDOTAPlayerController" {
-			if playerID, ok := e.GetInt32("m_nPlayerID"); ok && playerID >= 0 && playerID < 10 {
+			if playerID, ok := e.GetInt32("m_nPlayerID"); ok && playerID >= 0 && playerID < maxPlayerEntries {
 				if entindex, ok := e.GetUint32("m_nEntityIndex"); ok {
 					entIndexToSlot[entindex] = int(playerID)
 				}
@@ -498,7 +569,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 			heroMapByHandle[entIndex] = heroName
 			allHeroEntities[entIndex] = heroName
 
-			if playerID, ok := e.GetInt32("m_iPlayerID"); ok && playerID >= 0 && playerID < 10 {
+			if playerID, ok := e.GetInt32("m_iPlayerID"); ok && playerID >= 0 && playerID < maxPlayerEntries {
 				if player_resources[playerID].Hero == "" {
 					player_resources[playerID].Hero = heroName
 				}
@@ -533,6 +604,10 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 			}
 		}
 
+		if className == "CDOTA_PlayerResource" || strings.HasPrefix(className, "CDOTA_Unit_Hero_") {
+			emitResolvedPlayers()
+		}
+
 		if begin_tick == 0 {
 			if className != "CDOTA_PlayerResource" && className != "CDOTAGamerulesProxy" {
 				return nil
@@ -546,6 +621,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 						if statsPanel, ok := e.GetInt32("m_iStatsPanel"); ok {
 							if statsPanel == 1 {
 								if !scoreboardOpen[steamid] {
+									emit(ScoreboardOpened{EventBase: base(), Slot: slotForKey(player_resources[:], steamid), SteamID: steamid})
 								}
 								if xpos, xposok := e.GetInt32("m_iCursor.0000"); xposok {
 									if ypos, yposok := e.GetInt32("m_iCursor.0001"); yposok {
@@ -553,46 +629,65 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 										ypos = int32(math.Round(float64(ypos) / 383 * 1080))
 
 										if aspect, aspectok := e.GetFloat32("m_flAspectRatio"); aspectok {
-											targetSlot := isReportButton(int(xpos), int(ypos), aspect)
+											layout = selectLayout(player_resources[:], gameMode)
+											rows, radiantRows := scoreboardRows(player_resources[:])
+											targetSlot := layout.reportButtonAt(rows, radiantRows, int(xpos), int(ypos), aspect)
+											trace.sample(base(), slotForKey(player_resources[:], steamid), steamid, xpos, ypos, aspect, layout.Name, targetSlot)
 
-											for i := 0; i < 10; i++ {
+											for i := 0; i < maxPlayerEntries; i++ {
 												if player_resources[i].SteamID == steamid {
+													reporterKey := playerKey(player_resources[i])
+
 													// Initialize hover map for this reporter if needed
-													if _, ok := hoverDurations[i]; !ok {
-														hoverDurations[i] = make(map[int]int)
+													if _, ok := hoverDurations[reporterKey]; !ok {
+														hoverDurations[reporterKey] = make(map[uint64]int)
 													}
 
 													// Track hover duration
 													if targetSlot != -1 && targetSlot != i {
-														hoverDurations[i][targetSlot]++
-														lastHoverTime[i] = current_tick
+														hoverDurations[reporterKey][playerKey(player_resources[targetSlot])]++
+														lastHoverTime[reporterKey] = current_tick
+														if prev, hovering := lastHoverTarget[reporterKey]; !hovering || prev != targetSlot {
+															emit(ReportHover{
+																EventBase:     base(),
+																Slot:          i,
+																SteamID:       steamid,
+																TargetSlot:    targetSlot,
+																TargetSteamID: player_resources[targetSlot].SteamID,
+															})
+														}
+														lastHoverTarget[reporterKey] = targetSlot
+													} else {
+														delete(lastHoverTarget, reporterKey)
 													}
 
 													inConfirmBox := xpos >= 956 && xpos <= 1170 && ypos >= 847 && ypos <= 888
 
 													if inConfirmBox {
-														if lastTick, exists := lastHoverTime[i]; exists {
+														if lastTick, exists := lastHoverTime[reporterKey]; exists {
 															tickDiff := current_tick - lastTick
 															if tickDiff >= 0 && tickDiff <= 120 { // 4 seconds window
 																// Find target with highest duration
 																bestTarget := -1
 																maxDuration := 0
-																for tSlot, duration := range hoverDurations[i] {
+																for targetKey, duration := range hoverDurations[reporterKey] {
 																	if duration > maxDuration {
 																		maxDuration = duration
-																		bestTarget = tSlot
+																		bestTarget = slotForKey(player_resources[:], targetKey)
 																	}
 																}
 
 																if bestTarget != -1 && bestTarget != i {
 																	finalTargetSlot := bestTarget
 
-																	if finalTargetSlot >= 0 && finalTargetSlot < 10 {
+																	if finalTargetSlot >= 0 && finalTargetSlot < maxPlayerEntries {
 																		targetSteamID := player_resources[finalTargetSlot].SteamID
 																		targetName := player_resources[finalTargetSlot].Name
 																		targetHero := player_resources[finalTargetSlot].Hero
 
-																		if targetSteamID == 0 {
+																		targetIsBot := player_resources[finalTargetSlot].IsBot
+
+																		if targetSteamID == 0 && !targetIsBot {
 																			if foundSteamID, exists := playerSteamIDs[finalTargetSlot]; exists && foundSteamID > 0 {
 																				targetSteamID = foundSteamID
 																			} else {
@@ -626,13 +721,16 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 																			TargetSteamID: targetSteamID,
 																			TargetName:    targetName,
 																			TargetHero:    targetHero,
+																			TargetIsBot:   targetIsBot,
 																		}
 
 																		reports = append(reports, newReport)
+																		confirmed := *newReport
+																		emit(ReportConfirmed{EventBase: base(), Report: &confirmed})
 
 																		// Reset tracking
-																		delete(hoverDurations, i)
-																		delete(lastHoverTime, i)
+																		delete(hoverDurations, reporterKey)
+																		delete(lastHoverTime, reporterKey)
 																	}
 																}
 															}
@@ -646,6 +744,9 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 								}
 								scoreboardOpen[steamid] = true
 							} else if statsPanel == 0 {
+								if scoreboardOpen[steamid] {
+									emit(ScoreboardClosed{EventBase: base(), Slot: slotForKey(player_resources[:], steamid), SteamID: steamid})
+								}
 								scoreboardOpen[steamid] = false
 							}
 						}
@@ -657,8 +758,8 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		return nil
 	})
 
-	fmt.Printf("[PARSER] Starting parser execution...\n")
-	fmt.Printf("[PARSER] Current state before Start - begin_tick: %d, current_tick: %d\n", begin_tick, current_tick)
+	log.Debugf("Starting parser execution...")
+	log.Debugf("Current state before Start - begin_tick: %d, current_tick: %d", begin_tick, current_tick)
 
 	packetEntityCount := 0
 	packetCount := 0
@@ -700,7 +801,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		lastCDemoPacketTick = p.Tick
 
 		if dataSize == 0 {
-			fmt.Printf("[PARSER] WARNING: CDemoPacket has empty data buffer at tick %d\n", p.Tick)
+			log.Warnf("CDemoPacket has empty data buffer at tick %d", p.Tick)
 		}
 
 		lastPacketTick = p.Tick
@@ -730,18 +831,18 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		lastPacketEntityMaxEntries = maxEntries
 
 		if bufferSize == 0 && updatedEntries > 0 {
-			fmt.Printf("[PARSER] WARNING: PacketEntities has updatedEntries=%d but empty buffer at tick %d\n", updatedEntries, serverTick)
+			log.Warnf("PacketEntities has updatedEntries=%d but empty buffer at tick %d", updatedEntries, serverTick)
 		}
 
 		if updatedEntries > 0 {
 			minExpectedSize := int(updatedEntries) * 2
 			if bufferSize < minExpectedSize {
-				fmt.Printf("[PARSER] WARNING: PacketEntities buffer may be too small - updatedEntries=%d, bufferSize=%d, minExpectedSize=%d at tick %d\n",
+				log.Warnf("PacketEntities buffer may be too small - updatedEntries=%d, bufferSize=%d, minExpectedSize=%d at tick %d",
 					updatedEntries, bufferSize, minExpectedSize, serverTick)
 			}
 
 			if bufferSize < int(updatedEntries) {
-				fmt.Printf("[PARSER] CRITICAL: PacketEntities buffer is smaller than entry count - updatedEntries=%d, bufferSize=%d at tick %d. Buffer underflow likely!\n",
+				log.Errorf("PacketEntities buffer is smaller than entry count - updatedEntries=%d, bufferSize=%d at tick %d. Buffer underflow likely!",
 					updatedEntries, bufferSize, serverTick)
 			}
 		}
@@ -763,19 +864,25 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		parseError = p.Start()
 	}()
 
+	if parseError != nil && ctx.Err() != nil {
+		return ParseResult{}, ctx.Err()
+	}
+	if parseError != nil && handlerErr != nil {
+		return ParseResult{}, handlerErr
+	}
 	if parseError != nil {
 		errMsg := parseError.Error()
-		fmt.Printf("[PARSER] ERROR: Parser.Start() returned error: %v\n", parseError)
-		fmt.Printf("[PARSER] ERROR context - demoMessageCount: %d, lastPacketTick: %d, packetCount: %d, packetEntityCount: %d, current_tick: %d, begin_tick: %d\n",
+		log.Errorf("Parser.Start() returned error: %v", parseError)
+		log.Errorf("Error context - demoMessageCount: %d, lastPacketTick: %d, packetCount: %d, packetEntityCount: %d, current_tick: %d, begin_tick: %d",
 			demoMessageCount, lastPacketTick, packetCount, packetEntityCount, current_tick, begin_tick)
 
 		if lastCDemoPacketTick > 0 {
-			fmt.Printf("[PARSER] ERROR - Last CDemoPacket context - tick: %d, dataSize: %d\n",
+			log.Errorf("Last CDemoPacket context - tick: %d, dataSize: %d",
 				lastCDemoPacketTick, lastCDemoPacketDataSize)
 		}
 
 		if lastPacketEntityTick > 0 {
-			fmt.Printf("[PARSER] ERROR - Last PacketEntity context - tick: %d, updatedEntries: %d, maxEntries: %d, bufferSize: %d\n",
+			log.Errorf("Last PacketEntity context - tick: %d, updatedEntries: %d, maxEntries: %d, bufferSize: %d",
 				lastPacketEntityTick, lastPacketEntityUpdatedEntries, lastPacketEntityMaxEntries, lastPacketEntityBufferSize)
 		}
 
@@ -799,7 +906,7 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		return ParseResult{}, fmt.Errorf("parser error at tick %d: %v", current_tick, parseError)
 	}
 
-	for i := 0; i < 10; i++ {
+	for i := 0; i < maxPlayerEntries; i++ {
 		if player_resources[i].Hero == "" {
 			if heroHandle64, ok := heroHandles[i]; ok {
 				if heroEntity := p.FindEntityByHandle(heroHandle64); heroEntity != nil {
@@ -839,42 +946,104 @@ func ParseReplay(matchID int64, file io.Reader, reportedSlot int, reportedSteamI
 		}
 	}
 
-	fmt.Printf("[PARSER] Parser execution completed - begin_tick: %d, current_tick: %d, packetEntityCount: %d\n", begin_tick, current_tick, packetEntityCount)
-	fmt.Printf("[PARSER] Final results - TeamReports: %d, EnemyReports: %d, TotalReports: %d\n",
+	log.Debugf("Parser execution completed - begin_tick: %d, current_tick: %d, packetEntityCount: %d", begin_tick, current_tick, packetEntityCount)
+	log.Infof("Final results - TeamReports: %d, EnemyReports: %d, TotalReports: %d",
 		teamReports, enemyReports, len(reports))
-	fmt.Printf("[PARSER] Reported player - Slot: %d, SteamID: %d, Team: %d\n", actualReportedSlot, actualReportedSteamID, reportedTeam)
-	fmt.Printf("[PARSER] Game state - begin_tick: %d, pausedTicks: %d, final_tick: %d\n", begin_tick, pausedTicks, current_tick)
+	log.Infof("Reported player - Slot: %d, SteamID: %d, Team: %d", actualReportedSlot, actualReportedSteamID, reportedTeam)
+	log.Debugf("Game state - begin_tick: %d, pausedTicks: %d, final_tick: %d", begin_tick, pausedTicks, current_tick)
+
+	layout = selectLayout(player_resources[:], gameMode)
+	log.Debugf("Scoreboard layout: %s (game mode %d)", layout.Name, gameMode)
+
+	resolveHeroes(player_resources[:])
+	emitResolvedPlayers()
+	if handlerErr != nil {
+		return ParseResult{}, handlerErr
+	}
 
 	for _, report := range reports {
-		if report.Hero == "" && report.Slot >= 0 && report.Slot < 10 {
+		if report.Slot >= 0 && report.Slot < maxPlayerEntries && player_resources[report.Slot].Hero != "" {
 			report.Hero = player_resources[report.Slot].Hero
+			report.HeroID = player_resources[report.Slot].HeroID
+		}
+		if report.TargetSlot >= 0 && report.TargetSlot < maxPlayerEntries && player_resources[report.TargetSlot].Hero != "" {
+			report.TargetHero = player_resources[report.TargetSlot].Hero
+			report.TargetHeroID = player_resources[report.TargetSlot].HeroID
 		}
 	}
 
 	return ParseResult{
-		MatchID:      matchID,
-		TeamReports:  teamReports,
-		EnemyReports: enemyReports,
-		Reports:      reports,
+		MatchID:          matchID,
+		TeamReports:      teamReports,
+		EnemyReports:     enemyReports,
+		Reports:          reports,
+		GameMode:         gameMode,
+		ScoreboardLayout: layout.Name,
 	}, nil
 }
 
+// ReplayInfo is the match summary stored in a replay's CDemoFileInfo.
+type ReplayInfo struct {
+	MatchID  int64
+	GameMode int32
+	EndTime  time.Time
+	// StartTime is EndTime less the replay's playback time, so it includes
+	// the draft. Zero if either is missing.
+	StartTime time.Time
+}
+
 // GetReplayDate extracts the match date from the replay file header/summary.
 // This is extremely fast as it jumps to the footer directly.
 func GetReplayDate(file io.Reader) (time.Time, error) {
+	info, err := GetReplayInfo(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if info.EndTime.IsZero() {
+		return time.Time{}, fmt.Errorf("end_time not found in GameInfo")
+	}
+	return info.EndTime, nil
+}
+
+// GetReplayInfo reads the match ID, game mode and end time from the
+// CDemoFileInfo in the replay footer, without parsing the replay.
+func GetReplayInfo(file io.Reader) (ReplayInfo, error) {
+	info, err := readFileInfo(file)
+	if err != nil {
+		return ReplayInfo{}, err
+	}
+	if info.GameInfo == nil || info.GameInfo.Dota == nil {
+		return ReplayInfo{}, fmt.Errorf("GameInfo not found in CDemoFileInfo")
+	}
+	d := info.GameInfo.Dota
+	ri := ReplayInfo{
+		MatchID:  int64(d.GetMatchId()),
+		GameMode: d.GetGameMode(),
+	}
+	if endTime := d.GetEndTime(); endTime > 0 {
+		ri.EndTime = time.Unix(int64(endTime), 0)
+		if playback := info.GetPlaybackTime(); playback > 0 {
+			ri.StartTime = ri.EndTime.Add(-time.Duration(float64(playback) * float64(time.Second)))
+		}
+	}
+	return ri, nil
+}
+
+// readFileInfo jumps to the CDemoFileInfo message the header points at.
+func readFileInfo(file io.Reader) (*dota.CDemoFileInfo, error) {
 	// We need a ReadSeeker to jump to the footer.
 	rs, ok := file.(io.ReadSeeker)
 	if !ok {
-		return time.Time{}, fmt.Errorf("file must be an io.ReadSeeker to parse header")
+		return nil, fmt.Errorf("file must be an io.ReadSeeker to parse header")
 	}
 
 	// Read header (16 bytes)
 	header := make([]byte, 16)
 	if _, err := rs.Seek(0, io.SeekStart); err != nil {
-		return time.Time{}, fmt.Errorf("failed to seek start: %v", err)
+		return nil, fmt.Errorf("failed to seek start: %v", err)
 	}
 	if _, err := io.ReadFull(rs, header); err != nil {
-		return time.Time{}, fmt.Errorf("failed to read header: %v", err)
+		return nil, fmt.Errorf("failed to read header: %v", err)
 	}
 
 	// Check magic
@@ -888,23 +1057,23 @@ func GetReplayDate(file io.Reader) (time.Time, error) {
 	// Check file size to ensure offset is valid
 	endPos, err := rs.Seek(0, io.SeekEnd)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to seek end: %v", err)
+		return nil, fmt.Errorf("failed to seek end: %v", err)
 	}
 
 	if int64(offset1) >= endPos {
-		return time.Time{}, fmt.Errorf("invalid offset in header")
+		return nil, fmt.Errorf("invalid offset in header")
 	}
 
 	// Seek to offset1
 	if _, err := rs.Seek(int64(offset1), io.SeekStart); err != nil {
-		return time.Time{}, fmt.Errorf("failed to seek to offset1: %v", err)
+		return nil, fmt.Errorf("failed to seek to offset1: %v", err)
 	}
 
 	// Read Cmd (varint)
 	br := &byteReader{r: rs}
 	cmd, err := binary.ReadUvarint(br)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to read cmd: %v", err)
+		return nil, fmt.Errorf("failed to read cmd: %v", err)
 	}
 
 	isCompressed := (cmd & 0x40) != 0
@@ -913,24 +1082,24 @@ func GetReplayDate(file io.Reader) (time.Time, error) {
 	// Read Tick (varint)
 	_, err = binary.ReadUvarint(br)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to read tick: %v", err)
+		return nil, fmt.Errorf("failed to read tick: %v", err)
 	}
 
 	// Read Size (varint)
 	size, err := binary.ReadUvarint(br)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to read size: %v", err)
+		return nil, fmt.Errorf("failed to read size: %v", err)
 	}
 
 	data := make([]byte, size)
 	if _, err := io.ReadFull(rs, data); err != nil {
-		return time.Time{}, fmt.Errorf("failed to read data: %v", err)
+		return nil, fmt.Errorf("failed to read data: %v", err)
 	}
 
 	if isCompressed {
 		decoded, err := snappy.Decode(nil, data)
 		if err != nil {
-			return time.Time{}, fmt.Errorf("failed to decode snappy: %v", err)
+			return nil, fmt.Errorf("failed to decode snappy: %v", err)
 		}
 		data = decoded
 	}
@@ -938,17 +1107,10 @@ func GetReplayDate(file io.Reader) (time.Time, error) {
 	// Unmarshal CDemoFileInfo
 	info := &dota.CDemoFileInfo{}
 	if err := proto.Unmarshal(data, info); err != nil {
-		return time.Time{}, fmt.Errorf("failed to unmarshal CDemoFileInfo: %v", err)
-	}
-
-	if info.GameInfo != nil && info.GameInfo.Dota != nil {
-		endTime := info.GameInfo.Dota.GetEndTime()
-		if endTime > 0 {
-			return time.Unix(int64(endTime), 0), nil
-		}
+		return nil, fmt.Errorf("failed to unmarshal CDemoFileInfo: %v", err)
 	}
 
-	return time.Time{}, fmt.Errorf("end_time not found in GameInfo")
+	return info, nil
 }
 
 type byteReader struct {
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// maxPlayerEntries is the number of m_vecPlayerData entries inspected.
+// Player IDs 0-9 are the usual team slots; coaches, broadcasters and
+// reconnecting players can occupy higher IDs.
+const maxPlayerEntries = 24
+
+const (
+	teamRadiant = 2
+	teamDire    = 3
+)
+
+// ScoreboardLayout describes where the report buttons are drawn on the
+// in-game scoreboard, in pixels of a 1920x1080 16:9 screen.
+type ScoreboardLayout struct {
+	Name string
+	// Widths lists the scoreboard widths the layout can appear at. The
+	// tipping column adds 100px and is not always shown.
+	Widths []float64
+	// ReportMinX and ReportMaxX bound the report button on a scoreboard
+	// of the first width in Widths.
+	ReportMinX float64
+	ReportMaxX float64
+	// FirstRowTop is the top of the report button in the first row,
+	// RowHeight its height and RowPitch the distance between rows.
+	FirstRowTop int
+	RowHeight   int
+	RowPitch    int
+	// TeamGap is the extra space taken by the Dire header.
+	TeamGap int
+}
+
+// LayoutStandard is the scoreboard of a regular match between ten players.
+var LayoutStandard = ScoreboardLayout{
+	Name:        "standard",
+	Widths:      []float64{920, 820},
+	ReportMinX:  865,
+	ReportMaxX:  893,
+	FirstRowTop: 106,
+	RowHeight:   28,
+	RowPitch:    70,
+	TeamGap:     30,
+}
+
+// LayoutBots is the scoreboard of lobbies with bots (see
+// assets/dota_scoreboard_bots.png). Its rows sit where they do in
+// LayoutStandard, but the tipping column is always shown. Bot rows have no
+// report button; hovering where it would be is still recorded so practice
+// matches can be used for calibration.
+var LayoutBots = func() ScoreboardLayout {
+	l := LayoutStandard
+	l.Name = "bots"
+	l.Widths = []float64{920}
+	return l
+}()
+
+// LayoutSingleDraft is the Single Draft scoreboard (see
+// assets/scoreboard_sd.png). The tipping column is always shown and the
+// report column holds the mute button as well. Its rows are closer together
+// than the standard ones; the report button bounds and row positions are
+// measured from the asset (scoreboard_test.go checks them against it).
+var LayoutSingleDraft = ScoreboardLayout{
+	Name:        "single_draft",
+	Widths:      []float64{920},
+	ReportMinX:  866,
+	ReportMaxX:  893,
+	FirstRowTop: 101,
+	RowHeight:   28,
+	RowPitch:    57,
+	TeamGap:     33,
+}
+
+// selectLayout picks the scoreboard layout matching the players and game
+// mode of the match. gameMode is 0 when not known yet.
+func selectLayout(players []PlayerResource, gameMode int32) ScoreboardLayout {
+	for _, p := range players {
+		if p.IsBot && isTeamPlayer(p) {
+			return LayoutBots
+		}
+	}
+	if gameMode == int32(dota.DOTA_GameMode_DOTA_GAMEMODE_SD) {
+		return LayoutSingleDraft
+	}
+	return LayoutStandard
+}
+
+// isTeamPlayer reports whether p occupies a Radiant or Dire row. Coaches
+// share a team but never get a hero, which is filtered out later once
+// heroes are known.
+func isTeamPlayer(p PlayerResource) bool {
+	return p.Team == teamRadiant || p.Team == teamDire
+}
+
+// scoreboardRows returns the player slot shown in each scoreboard row and
+// the number of Radiant rows. Empty slots and coaches are not drawn, so in
+// lobbies with fewer than ten players the rows below shift up.
+func scoreboardRows(players []PlayerResource) ([]int, int) {
+	var radiant, dire []int
+	for _, p := range activePlayers(players) {
+		switch p.Team {
+		case teamRadiant:
+			radiant = append(radiant, p.Slot)
+		case teamDire:
+			dire = append(dire, p.Slot)
+		}
+	}
+	if len(radiant) == 0 && len(dire) == 0 {
+		// Teams not known yet; assume the regular 5v5 order.
+		return []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 5
+	}
+	return append(radiant, dire...), len(radiant)
+}
+
+// reportButtonAt returns the slot whose report button is under the cursor,
+// or -1. x and y are in 1920x1080 pixels and aspect is the player's screen
+// aspect ratio.
+func (l ScoreboardLayout) reportButtonAt(rows []int, radiantRows int, x int, y int, aspect float32) int {
+	if aspect <= 0 {
+		return -1
+	}
+
+	widest := l.Widths[0]
+	inColumn := false
+	for _, w := range l.Widths {
+		// width of their scoreboard in 1920 pixels, based on their custom resolution/aspect ratio.
+		scaled := math.Round((1.77777777778 * (w / 1920)) / float64(aspect) * 1920)
+		offset := widest - w
+		lower := (l.ReportMinX - offset) / w
+		upper := (l.ReportMaxX - offset) / w
+		if x >= int(math.Floor(lower*scaled)) && x <= int(math.Ceil(upper*scaled)) {
+			inColumn = true
+			break
+		}
+	}
+	if !inColumn {
+		return -1
+	}
+
+	for row, slot := range rows {
+		top := l.FirstRowTop + row*l.RowPitch
+		if row >= radiantRows {
+			top += l.TeamGap
+		}
+		if y >= top && y <= top+l.RowHeight {
+			return slot
+		}
+	}
+	return -1
+}
+
+// isBotEntry reports whether player entry i of CDOTA_PlayerResource is a
+// bot or other fake client.
+func isBotEntry(e *manta.Entity, i int) bool {
+	if v, ok := e.GetBool(fmt.Sprintf("m_vecPlayerData.%04d.m_bFakeClient", i)); ok && v {
+		return true
+	}
+	if v, ok := e.GetBool(fmt.Sprintf("m_vecPlayerData.%04d.m_bIsBot", i)); ok && v {
+		return true
+	}
+	return false
+}
+
+// playerKey identifies a player across reconnects. Humans are keyed by
+// SteamID; bots have none and are keyed by slot, which never changes for
+// them. SteamIDs are far above maxPlayerEntries so the keys cannot collide.
+func playerKey(p PlayerResource) uint64 {
+	if p.SteamID != 0 {
+		return p.SteamID
+	}
+	return uint64(p.Slot) + 1
+}
+
+// slotForKey returns the current slot of the player identified by key, or
+// -1 if the player is no longer in the match.
+func slotForKey(players []PlayerResource, key uint64) int {
+	for _, p := range players {
+		if (p.Name != "" || p.SteamID != 0) && playerKey(p) == key {
+			return p.Slot
+		}
+	}
+	return -1
+}
+
+// activePlayers returns the entries that played in the match, dropping
+// empty slots, broadcasters and coaches. Coaches sit on a team but never
+// get a hero; if no heroes were resolved at all every team member is kept.
+func activePlayers(players []PlayerResource) []PlayerResource {
+	anyHero := false
+	for _, p := range players {
+		if isTeamPlayer(p) && (p.Hero != "" || p.HeroID > 0) {
+			anyHero = true
+			break
+		}
+	}
+
+	var out []PlayerResource
+	for _, p := range players {
+		if !isTeamPlayer(p) {
+			continue
+		}
+		if p.Name == "" && p.SteamID == 0 && !p.IsBot {
+			continue
+		}
+		if anyHero && p.Hero == "" && p.HeroID == 0 {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/dotabuff/manta/dota"
+)
+
+const aspect16x9 = float32(16.0 / 9.0)
+
+func player(slot int, team int32, name string, heroID int) PlayerResource {
+	return PlayerResource{Slot: slot, SteamID: uint64(1000 + slot), Team: team, Name: name, HeroID: heroID}
+}
+
+func fullLobby() []PlayerResource {
+	var players []PlayerResource
+	for i := 0; i < 10; i++ {
+		team := int32(teamRadiant)
+		if i >= 5 {
+			team = teamDire
+		}
+		players = append(players, player(i, team, "p", i+1))
+	}
+	return players
+}
+
+func TestScoreboardRowsShortLobby(t *testing.T) {
+	players := []PlayerResource{
+		player(0, teamRadiant, "a", 1),
+		player(1, teamRadiant, "b", 2),
+		player(2, teamRadiant, "c", 3),
+		player(5, teamDire, "d", 4),
+		player(6, teamDire, "e", 5),
+		{Slot: 3}, // empty slot
+	}
+	rows, radiant := scoreboardRows(players)
+	if want := []int{0, 1, 2, 5, 6}; !reflect.DeepEqual(rows, want) || radiant != 3 {
+		t.Fatalf("scoreboardRows = %v, %d; want %v, 3", rows, radiant, want)
+	}
+}
+
+func TestScoreboardRowsSkipsCoachesAndBroadcasters(t *testing.T) {
+	players := append(fullLobby(),
+		player(10, teamRadiant, "coach", 0),
+		player(11, 1, "broadcaster", 0),
+	)
+	rows, radiant := scoreboardRows(players)
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(rows, want) || radiant != 5 {
+		t.Fatalf("scoreboardRows = %v, %d; want %v, 5", rows, radiant, want)
+	}
+}
+
+func TestScoreboardRowsKeepsBots(t *testing.T) {
+	players := fullLobby()
+	for i := 1; i < 10; i++ {
+		players[i].SteamID = 0
+		players[i].Name = ""
+		players[i].IsBot = true
+	}
+	rows, _ := scoreboardRows(players)
+	if len(rows) != 10 {
+		t.Fatalf("scoreboardRows returned %d rows for a bot lobby, want 10", len(rows))
+	}
+}
+
+func TestScoreboardRowsUnknownTeams(t *testing.T) {
+	rows, radiant := scoreboardRows(make([]PlayerResource, 10))
+	if len(rows) != 10 || radiant != 5 {
+		t.Fatalf("scoreboardRows = %v, %d; want the 5v5 default", rows, radiant)
+	}
+}
+
+func TestSelectLayout(t *testing.T) {
+	withBot := fullLobby()
+	withBot[7].IsBot = true
+	botSpectator := append(fullLobby(), PlayerResource{Slot: 12, Team: 1, Name: "bot", IsBot: true})
+
+	sd := int32(dota.DOTA_GameMode_DOTA_GAMEMODE_SD)
+	tests := []struct {
+		name     string
+		players  []PlayerResource
+		gameMode int32
+		want     string
+	}{
+		{"ranked", fullLobby(), 0, "standard"},
+		{"bots", withBot, 0, "bots"},
+		{"bots in single draft", withBot, sd, "bots"},
+		{"bot off the teams", botSpectator, 0, "standard"},
+		{"single draft", fullLobby(), sd, "single_draft"},
+	}
+	for _, tt := range tests {
+		if got := selectLayout(tt.players, tt.gameMode).Name; got != tt.want {
+			t.Errorf("%s: selectLayout = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutBotsSharesStandardRows(t *testing.T) {
+	bots, std := LayoutBots, LayoutStandard
+	bots.Name, bots.Widths = std.Name, std.Widths
+	if !reflect.DeepEqual(bots, std) {
+		t.Fatalf("LayoutBots differs from LayoutStandard beyond name and widths: %+v", LayoutBots)
+	}
+	if !reflect.DeepEqual(LayoutBots.Widths, []float64{920}) {
+		t.Fatalf("LayoutBots.Widths = %v, want the tipping column only", LayoutBots.Widths)
+	}
+}
+
+func TestReportButtonAtShortLobby(t *testing.T) {
+	l := LayoutStandard
+	rows, radiant := []int{0, 1, 2, 5, 6}, 3
+	x := int((l.ReportMinX + l.ReportMaxX) / 2)
+
+	// The first Dire row follows the third Radiant row.
+	y := l.FirstRowTop + 3*l.RowPitch + l.TeamGap + 1
+	if got := l.reportButtonAt(rows, radiant, x, y, aspect16x9); got != 5 {
+		t.Errorf("first Dire row: got slot %d, want 5", got)
+	}
+	// Where the fourth Radiant row would be in a full lobby is now the
+	// gap above the Dire rows.
+	y = l.FirstRowTop + 3*l.RowPitch + 1
+	if got := l.reportButtonAt(rows, radiant, x, y, aspect16x9); got != -1 {
+		t.Errorf("gap above Dire: got slot %d, want -1", got)
+	}
+	// Below the last row.
+	y = l.FirstRowTop + 5*l.RowPitch + l.TeamGap + 1
+	if got := l.reportButtonAt(rows, radiant, x, y, aspect16x9); got != -1 {
+		t.Errorf("below the last row: got slot %d, want -1", got)
+	}
+}
+
+func TestReconnectKeepsPlayerKey(t *testing.T) {
+	before := fullLobby()
+	key := playerKey(before[3])
+
+	// The player reconnects into a higher entry; their old slot is empty.
+	after := fullLobby()
+	after[3] = PlayerResource{Slot: 3}
+	moved := before[3]
+	moved.Slot = 14
+	after = append(after, moved)
+
+	if got := slotForKey(after, key); got != 14 {
+		t.Fatalf("slotForKey after reconnect = %d, want 14", got)
+	}
+	if got := slotForKey(after[:10], key); got != -1 {
+		t.Fatalf("slotForKey for a player who left = %d, want -1", got)
+	}
+}
+
+func TestBotKeysBySlot(t *testing.T) {
+	bot := PlayerResource{Slot: 4, Team: teamRadiant, Name: "Bot", IsBot: true}
+	other := PlayerResource{Slot: 5, Team: teamDire, Name: "Bot", IsBot: true}
+	if playerKey(bot) == playerKey(other) {
+		t.Fatal("bots in different slots share a key")
+	}
+	if got := slotForKey([]PlayerResource{bot, other}, playerKey(other)); got != 5 {
+		t.Fatalf("slotForKey(bot) = %d, want 5", got)
+	}
+}
+
+// buttonRuns returns the horizontal runs of red pixels in the report column
+// of the screenshot between rows top and bottom, and the vertical extent of
+// the last (rightmost) run.
+func buttonRuns(img image.Image, top, bottom int) (runs [][2]int, minY, maxY int) {
+	isRed := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		r, g, b = r>>8, g>>8, b>>8
+		return r > 100 && r > g+50 && r > b+50
+	}
+	start := -1
+	for x := 780; x <= 920; x++ {
+		found := false
+		for y := top; y < bottom && !found; y++ {
+			found = isRed(x, y)
+		}
+		switch {
+		case found && start < 0:
+			start = x
+		case !found && start >= 0:
+			runs = append(runs, [2]int{start, x - 1})
+			start = -1
+		}
+	}
+	if len(runs) == 0 {
+		return nil, 0, 0
+	}
+	last := runs[len(runs)-1]
+	minY, maxY = -1, -1
+	for y := top; y < bottom; y++ {
+		for x := last[0]; x <= last[1]; x++ {
+			if isRed(x, y) {
+				if minY < 0 {
+					minY = y
+				}
+				maxY = y
+			}
+		}
+	}
+	return runs, minY, maxY
+}
+
+// rowRuns returns the vertical runs of screenshot rows with red pixels
+// between x columns left and right, as first and last rows.
+func rowRuns(img image.Image, left, right int) (runs [][2]int) {
+	start := -1
+	for y := 0; y <= img.Bounds().Dy(); y++ {
+		red := false
+		for x := left; x <= right && !red && y < img.Bounds().Dy(); x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			red = r > 100 && r > g+50 && r > b+50
+		}
+		switch {
+		case red && start < 0:
+			start = y
+		case !red && start >= 0:
+			runs = append(runs, [2]int{start, y - 1})
+			start = -1
+		}
+	}
+	return runs
+}
+
+func TestLayoutSingleDraftMatchesAsset(t *testing.T) {
+	f, err := os.Open("../../assets/scoreboard_sd.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := img.Bounds().Dx(); w != 1920 {
+		t.Fatalf("asset is %dpx wide, want a 1920x1080 screenshot", w)
+	}
+
+	l := LayoutSingleDraft
+	runs, _, _ := buttonRuns(img, 0, img.Bounds().Dy())
+	if len(runs) != 2 {
+		t.Fatalf("found %d columns of red buttons %v, want mute and report", len(runs), runs)
+	}
+	mute, report := runs[0], runs[1]
+	if float64(report[0]) != l.ReportMinX || float64(report[1]) != l.ReportMaxX {
+		t.Errorf("report button spans x %d-%d, layout has %v-%v", report[0], report[1], l.ReportMinX, l.ReportMaxX)
+	}
+
+	// The player's own row (row 0) has no buttons, so the asset shows the
+	// buttons of rows 1-9.
+	buttons := rowRuns(img, report[0], report[1])
+	if len(buttons) != 9 {
+		t.Fatalf("found %d report buttons %v, want 9", len(buttons), buttons)
+	}
+	rows, radiant := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 5
+	for i, b := range buttons {
+		row := i + 1
+		want := l.FirstRowTop + row*l.RowPitch
+		if row >= radiant {
+			want += l.TeamGap
+		}
+		if b[0] != want {
+			t.Errorf("row %d: report button starts at y %d, layout has %d", row, b[0], want)
+		}
+		if h := b[1] - b[0] + 1; h != l.RowHeight {
+			t.Errorf("row %d: report button is %dpx high, layout has %d", row, h, l.RowHeight)
+		}
+
+		y := (b[0] + b[1]) / 2
+		if got := l.reportButtonAt(rows, radiant, (mute[0]+mute[1])/2, y, aspect16x9); got != -1 {
+			t.Errorf("row %d: mute button hit slot %d, want -1", row, got)
+		}
+		if got := l.reportButtonAt(rows, radiant, (report[0]+report[1])/2, y, aspect16x9); got != row {
+			t.Errorf("row %d: report button hit slot %d, want %d", row, got, row)
+		}
+	}
+}
@@ -5,17 +5,42 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultBaseURL is the Steam WebAPI host.
+const DefaultBaseURL = "https://api.steampowered.com"
+
+var (
+	baseURLMu sync.RWMutex
+	baseURL   = DefaultBaseURL
+)
+
+// SetBaseURL points new clients at another WebAPI host, such as a local
+// stand-in. An empty url restores DefaultBaseURL.
+func SetBaseURL(url string) {
+	baseURLMu.Lock()
+	defer baseURLMu.Unlock()
+	if url == "" {
+		url = DefaultBaseURL
+	}
+	baseURL = strings.TrimRight(url, "/")
+}
+
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
 }
 
 func NewClient(apiKey string) *Client {
+	baseURLMu.RLock()
+	defer baseURLMu.RUnlock()
 	return &Client{
-		apiKey: apiKey,
+		apiKey:  apiKey,
+		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -41,7 +66,7 @@ type Match struct {
 }
 
 func (c *Client) GetPlayerMatchHistory(accountID int64, startAtMatchID int64, matchesRequested int) ([]Match, error) {
-	url := fmt.Sprintf("https://api.steampowered.com/IDOTA2Match_570/GetMatchHistory/v1/?key=%s&account_id=%d&matches_requested=%d", c.apiKey, accountID, matchesRequested)
+	url := fmt.Sprintf("%s/IDOTA2Match_570/GetMatchHistory/v1/?key=%s&account_id=%d&matches_requested=%d", c.baseURL, c.apiKey, accountID, matchesRequested)
 	if startAtMatchID > 0 {
 		url += fmt.Sprintf("&start_at_match_id=%d", startAtMatchID)
 	}
@@ -87,7 +112,7 @@ type MatchDetailsResponse struct {
 }
 
 func (c *Client) GetReplayInfo(matchID int64) (clusterID int, replaySalt int64, err error) {
-	url := fmt.Sprintf("https://api.steampowered.com/IDOTA2Match_570/GetMatchDetails/v1/?key=%s&match_id=%d", c.apiKey, matchID)
+	url := fmt.Sprintf("%s/IDOTA2Match_570/GetMatchDetails/v1/?key=%s&match_id=%d", c.baseURL, c.apiKey, matchID)
 
 	resp, err := c.httpClient.Get(url)
 	if err != nil {
@@ -134,3 +159,35 @@ func (c *Client) GetReplayInfo(matchID int64) (clusterID int, replaySalt int64,
 
 	return details.Result.Cluster, details.Result.ReplaySalt, nil
 }
+
+type QueryTimeResponse struct {
+	Response struct {
+		ServerTime int64 `json:"server_time,string"`
+	} `json:"response"`
+}
+
+// QueryTime returns Steam's clock, which two-factor codes are computed from.
+// It needs no API key.
+func (c *Client) QueryTime() (time.Time, error) {
+	url := fmt.Sprintf("%s/ITwoFactorService/QueryTime/v0001/", c.baseURL)
+
+	resp, err := c.httpClient.Post(url, "application/x-www-form-urlencoded", strings.NewReader("steamid=0"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query time: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return time.Time{}, fmt.Errorf("unexpected status code from Steam API: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var result QueryTimeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if result.Response.ServerTime == 0 {
+		return time.Time{}, fmt.Errorf("steam api returned no server time")
+	}
+	return time.Unix(result.Response.ServerTime, 0), nil
+}
@@ -0,0 +1,136 @@
+// Package steamguard generates Steam Guard two-factor codes from the
+// shared_secret of a Steam mobile authenticator, so logins need nobody to
+// type a code.
+package steamguard
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Period is how long one code is valid.
+const Period = 30 * time.Second
+
+// codeAlphabet is the character set of Steam's TOTP variant. Codes are five
+// characters long instead of six decimal digits.
+const codeAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+const codeLength = 5
+
+// GenerateCode returns the code of secret for time t.
+func GenerateCode(secret []byte, t time.Time) string {
+	v := truncate(secret, uint64(t.Unix()/int64(Period/time.Second)))
+	code := make([]byte, codeLength)
+	for i := range code {
+		code[i] = codeAlphabet[v%uint32(len(codeAlphabet))]
+		v /= uint32(len(codeAlphabet))
+	}
+	return string(code)
+}
+
+// truncate is the HOTP dynamic truncation (RFC 4226) of HMAC-SHA1(secret,
+// counter), before any reduction to digits.
+func truncate(secret []byte, counter uint64) uint32 {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+}
+
+// DecodeSecret decodes a base64 shared_secret as found in maFiles and
+// authenticator exports.
+func DecodeSecret(sharedSecret string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sharedSecret))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shared secret: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("invalid shared secret: empty")
+	}
+	return secret, nil
+}
+
+// Authenticator generates codes for one account, correcting for the
+// difference between the local clock and Steam's.
+type Authenticator struct {
+	secret []byte
+	// serverTime returns Steam's current time. It is used by Sync.
+	serverTime func() (time.Time, error)
+
+	mu     sync.Mutex
+	offset time.Duration
+	synced bool
+}
+
+// NewAuthenticator returns an Authenticator for a base64 shared_secret.
+// serverTime reports Steam's clock (see steamapi.Client.QueryTime); it may
+// be nil to trust the local clock.
+func NewAuthenticator(sharedSecret string, serverTime func() (time.Time, error)) (*Authenticator, error) {
+	secret, err := DecodeSecret(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{secret: secret, serverTime: serverTime}, nil
+}
+
+// Sync measures the offset of the local clock from Steam's.
+func (a *Authenticator) Sync() error {
+	if a.serverTime == nil {
+		return nil
+	}
+	before := time.Now()
+	server, err := a.serverTime()
+	if err != nil {
+		return fmt.Errorf("failed to query Steam time: %w", err)
+	}
+	// Assume the server answered halfway through the round trip.
+	local := before.Add(time.Since(before) / 2)
+	offset := server.Sub(local)
+
+	a.mu.Lock()
+	a.offset = offset
+	a.synced = true
+	a.mu.Unlock()
+	log.Printf("Steam Guard: clock offset to Steam is %v", offset.Round(time.Second))
+	return nil
+}
+
+// Now returns the current time on Steam's clock. The offset is measured on
+// first use.
+func (a *Authenticator) Now() time.Time {
+	a.mu.Lock()
+	synced := a.synced
+	a.mu.Unlock()
+	if !synced {
+		if err := a.Sync(); err != nil {
+			log.Printf("Steam Guard: %v, using the local clock", err)
+			a.mu.Lock()
+			a.synced = true
+			a.mu.Unlock()
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Now().Add(a.offset)
+}
+
+// Code returns the code valid now.
+func (a *Authenticator) Code() string {
+	return GenerateCode(a.secret, a.Now())
+}
+
+// UntilNextCode returns how long the current code stays valid.
+func (a *Authenticator) UntilNextCode() time.Duration {
+	now := a.Now()
+	return now.Truncate(Period).Add(Period).Sub(now)
+}
@@ -0,0 +1,82 @@
+package steamguard
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the test secret of RFC 4226 appendix D, base64 encoded.
+const rfcSecret = "MTIzNDU2Nzg5MDEyMzQ1Njc4OTA="
+
+func TestTruncateRFC4226(t *testing.T) {
+	secret, err := DecodeSecret(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decimal values of the truncated HMACs from RFC 4226 appendix D.
+	want := []uint32{1284755224, 1094287082, 137359152, 1726969429, 1640338314}
+	for counter, w := range want {
+		if got := truncate(secret, uint64(counter)); got != w {
+			t.Errorf("truncate(%d) = %d, want %d", counter, got, w)
+		}
+	}
+}
+
+func TestGenerateCode(t *testing.T) {
+	tests := []struct {
+		secret string
+		unix   int64
+		want   string
+	}{
+		{rfcSecret, 0, "GG5F5"},
+		{rfcSecret, 29, "GG5F5"},
+		{rfcSecret, 59, "PV9M4"},
+		{rfcSecret, 1111111109, "PY4YB"},
+		{rfcSecret, 1234567890, "VHHQY"},
+		{rfcSecret, 2000000000, "9N776"},
+		{"cnOgv/KdpLoP6Nbh0GMkXkPXALQ=", 0, "W3J46"},
+		{"cnOgv/KdpLoP6Nbh0GMkXkPXALQ=", 1634603498, "4JRHF"},
+	}
+	for _, tt := range tests {
+		secret, err := DecodeSecret(tt.secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := GenerateCode(secret, time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("GenerateCode(%s, %d) = %s, want %s", tt.secret, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeSecretInvalid(t *testing.T) {
+	for _, s := range []string{"", "not base64!"} {
+		if _, err := DecodeSecret(s); err == nil {
+			t.Errorf("DecodeSecret(%q) succeeded", s)
+		}
+	}
+}
+
+func TestAuthenticatorClockSkew(t *testing.T) {
+	// Steam's clock runs 70s ahead of ours, so the code is the one of the
+	// window after next.
+	skew := 70 * time.Second
+	a, err := NewAuthenticator(rfcSecret, func() (time.Time, error) {
+		return time.Now().Add(skew), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := a.Now()
+	if d := now.Sub(time.Now().Add(skew)); d < -time.Second || d > time.Second {
+		t.Fatalf("Now() is off Steam's clock by %v", d)
+	}
+	if got, want := a.Code(), GenerateCode(a.secret, time.Now().Add(skew)); got != want {
+		// Only differs when a window boundary passed between the calls.
+		if got != GenerateCode(a.secret, time.Now().Add(skew-time.Second)) {
+			t.Errorf("Code() = %s, want %s", got, want)
+		}
+	}
+	if d := a.UntilNextCode(); d <= 0 || d > Period {
+		t.Errorf("UntilNextCode() = %v", d)
+	}
+}
@@ -7,13 +7,34 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/shurcooL/graphql"
 )
 
+// DefaultEndpoint is the Stratz GraphQL endpoint.
+const DefaultEndpoint = "https://api.stratz.com/graphql"
+
+var (
+	endpointMu sync.RWMutex
+	endpoint   = DefaultEndpoint
+)
+
+// SetEndpoint points new clients at another GraphQL endpoint, such as a
+// local stand-in. An empty url restores DefaultEndpoint.
+func SetEndpoint(url string) {
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+	if url == "" {
+		url = DefaultEndpoint
+	}
+	endpoint = url
+}
+
 type Client struct {
-	client *graphql.Client
-	token  string
+	client   *graphql.Client
+	token    string
+	endpoint string
 }
 
 func NewClient(token string) *Client {
@@ -23,9 +44,12 @@ func NewClient(token string) *Client {
 			wrapped: http.DefaultTransport,
 		},
 	}
+	endpointMu.RLock()
+	defer endpointMu.RUnlock()
 	return &Client{
-		client: graphql.NewClient("https://api.stratz.com/graphql", httpClient),
-		token:  token,
+		client:   graphql.NewClient(endpoint, httpClient),
+		token:    token,
+		endpoint: endpoint,
 	}
 }
 
@@ -71,7 +95,7 @@ func (c *Client) GetReplayInfo(matchID int64) (*ReplayInfo, error) {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), "POST", "https://api.stratz.com/graphql", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(context.Background(), "POST", c.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -151,7 +175,7 @@ func (c *Client) GetLastMatches(steamID int64, limit int, turboOnly bool) ([]Mat
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), "POST", "https://api.stratz.com/graphql", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(context.Background(), "POST", c.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -9,11 +9,21 @@ export GOLANG_PROTOBUF_REGISTRATION_CONFLICT=warn
 echo "Cleaning old binaries..."
 rm -f bot server
 
-echo "Building bot..."
-go build -o bot ./cmd/bot
+# STEAM_SESSION_PASSPHRASE, when set, encrypts a saved Steam session so later
+# runs log in without the password.
+
+# GC_MODE=embedded runs the GC client inside the server instead of a
+# separate bot process.
+if [ "$GC_MODE" = "embedded" ]; then
+    echo "Building server with embedded GC..."
+    go build -tags embeddedgc -o server ./cmd/server
+else
+    echo "Building bot..."
+    go build -o bot ./cmd/bot
 
-echo "Building server..."
-go build -o server ./cmd/server
+    echo "Building server..."
+    go build -o server ./cmd/server
+fi
 
 if [ $? -eq 0 ]; then
     echo "Build successful!"
@@ -27,13 +37,15 @@ if [ $? -eq 0 ]; then
     }
     trap cleanup EXIT SIGINT SIGTERM
 
-    echo "Starting bot on port 8082..."
-    export BOT_PORT=8082
-    ./bot &
-    BOT_PID=$!
+    if [ "$GC_MODE" != "embedded" ]; then
+        echo "Starting bot on port 8082..."
+        export BOT_PORT=8082
+        ./bot &
+        BOT_PID=$!
 
-    # Wait a sec for bot to be ready
-    sleep 1
+        # Wait a sec for bot to be ready
+        sleep 1
+    fi
 
     echo "Starting server on http://localhost:8081"
     echo ""